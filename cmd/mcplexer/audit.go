@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/audit"
+	"github.com/revitteth/mcplexer/internal/secrets"
+	"github.com/revitteth/mcplexer/internal/store"
+	"github.com/revitteth/mcplexer/internal/store/sqlite"
+)
+
+// auditCloseTimeout bounds how long shutdown waits for audit sinks to flush
+// and for queued audit records to be stored with retry backoff. Records
+// still queued after it are written without backing off, never abandoned.
+const auditCloseTimeout = 5 * time.Second
+
+// auditLoggerOptions builds audit.Logger options for the configured
+// redaction patterns and sinks.
+func auditLoggerOptions(cfg *Config) ([]audit.Option, error) {
+	var opts []audit.Option
+	if cfg.AuditRedactPatterns != "" {
+		r, err := audit.LoadRedactor(cfg.AuditRedactPatterns)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, audit.WithRedactor(r))
+	}
+	if cfg.AuditQueueSize > 0 {
+		opts = append(opts, audit.WithBatchWriter(audit.WriterOptions{QueueSize: cfg.AuditQueueSize}))
+	}
+	if cfg.AuditFile != "" {
+		s, err := audit.NewFileSink(cfg.AuditFile, int64(cfg.AuditFileMaxMB)<<20, cfg.AuditFileBackups)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, audit.WithSink(s, audit.SinkOptions{}))
+	}
+	if cfg.AuditSyslog != "" {
+		network, addr, err := audit.ParseSyslogURL(cfg.AuditSyslog)
+		if err != nil {
+			return nil, err
+		}
+		s, err := audit.NewSyslogSink(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, audit.WithSink(s, audit.SinkOptions{}))
+	}
+	if cfg.AuditOTLPEndpoint != "" {
+		s := audit.NewOTLPSink(cfg.AuditOTLPEndpoint, parseHeaderList(cfg.AuditOTLPHeaders))
+		opts = append(opts, audit.WithSink(s, audit.SinkOptions{}))
+	}
+	return opts, nil
+}
+
+// closeAuditor stores queued audit records and flushes the audit sinks,
+// bounded by auditCloseTimeout.
+func closeAuditor(a *audit.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), auditCloseTimeout)
+	defer cancel()
+	if err := a.Close(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "mcplexer: close audit logger: %v\n", err)
+	}
+}
+
+// auditSigningKey loads or creates the audit signing key. It returns nil,
+// disabling checkpoints and signed retention gaps, when the key is
+// unavailable.
+func auditSigningKey(cfg *Config) ed25519.PrivateKey {
+	key, err := secrets.EnsureSigningKey(cfg.auditSigningKeyPath())
+	if err != nil {
+		slog.Warn("audit checkpoints disabled", "err", err)
+		return nil
+	}
+	return key
+}
+
+// startCheckpointer signs the audit chain head with key in the background.
+// It returns the public key for verification (nil when key is nil) and a
+// stop function that waits for the final checkpoint.
+func startCheckpointer(ctx context.Context, cfg *Config, db *sqlite.DB, key ed25519.PrivateKey) (ed25519.PublicKey, func()) {
+	if key == nil {
+		return nil, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		audit.NewCheckpointer(db, key, cfg.AuditCheckpointInterval).Run(ctx)
+	}()
+	return key.Public().(ed25519.PublicKey), func() {
+		cancel()
+		<-done
+	}
+}
+
+// parseHeaderList parses "k=v,k2=v2" into a map.
+func parseHeaderList(s string) map[string]string {
+	out := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(k) != "" {
+			out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return out
+}
+
+func cmdAudit(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: mcplexer audit <export|search|verify> [flags]")
+	}
+	switch args[0] {
+	case "export":
+		return auditExport(args[1:])
+	case "search":
+		return auditSearch(args[1:])
+	case "verify":
+		return auditVerify(args[1:])
+	default:
+		return fmt.Errorf("unknown audit command: %s\nUsage: mcplexer audit <export|search|verify> [flags]", args[0])
+	}
+}
+
+// auditExport writes stored audit records, oldest first, in a sink format
+// so they can be backfilled into an external system.
+func auditExport(args []string) error {
+	fs := flag.NewFlagSet("audit export", flag.ContinueOnError)
+	since := fs.String("since", "24h", "export records newer than this duration ago or RFC 3339 time")
+	until := fs.String("until", "", "export records up to this duration ago or RFC 3339 time (default now)")
+	format := fs.String("format", "jsonl", "output format: jsonl, syslog or otlp")
+	output := fs.String("output", "", "output file (default stdout)")
+	workspace := fs.String("workspace", "", "only export records for this workspace ID")
+	query := fs.String("q", "", "only export records matching this full-text search")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	after, err := parseSince(*since, now)
+	if err != nil {
+		return fmt.Errorf("--since: %w", err)
+	}
+	before := now
+	if *until != "" {
+		if before, err = parseSince(*until, now); err != nil {
+			return fmt.Errorf("--until: %w", err)
+		}
+	}
+
+	var encode func(w io.Writer, recs []*store.AuditRecord) error
+	switch *format {
+	case "jsonl":
+		encode = encodeJSONL
+	case "syslog":
+		hostname, _ := os.Hostname()
+		encode = func(w io.Writer, recs []*store.AuditRecord) error {
+			for _, rec := range recs {
+				msg, err := audit.FormatSyslog(rec, hostname, "mcplexer")
+				if err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintln(w, msg); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case "otlp":
+		// One ExportLogsServiceRequest per line, ready to POST.
+		encode = func(w io.Writer, recs []*store.AuditRecord) error {
+			body, err := audit.EncodeOTLPLogs(recs)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "%s\n", body)
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format %q (want jsonl, syslog or otlp)", *format)
+	}
+
+	ctx := context.Background()
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	db, err := sqlite.New(ctx, cfg.DBDSN)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	bw := bufio.NewWriter(out)
+
+	filter := store.AuditFilter{After: &after, Before: &before}
+	if *workspace != "" {
+		filter.WorkspaceID = workspace
+	}
+	if *query != "" {
+		filter.Search = query
+	}
+	n, err := exportAuditRecords(ctx, db, filter, func(recs []*store.AuditRecord) error {
+		return encode(bw, recs)
+	})
+	if err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "exported %d audit records\n", n)
+	return nil
+}
+
+// auditSearch prints the newest audit records matching a full-text query
+// over tool names, redacted params and error messages.
+func auditSearch(args []string) error {
+	fs := flag.NewFlagSet("audit search", flag.ContinueOnError)
+	since := fs.String("since", "", "only records newer than this duration ago or RFC 3339 time")
+	workspace := fs.String("workspace", "", "only records for this workspace ID")
+	status := fs.String("status", "", "only records with this status (success, error)")
+	limit := fs.Int("limit", 20, "maximum records to print")
+	jsonOut := fs.Bool("json", false, "print records as JSON lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("usage: mcplexer audit search [flags] <query>")
+	}
+
+	filter := store.AuditFilter{Search: &query, Limit: *limit}
+	if *since != "" {
+		after, err := parseSince(*since, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+		filter.After = &after
+	}
+	if *workspace != "" {
+		filter.WorkspaceID = workspace
+	}
+	if *status != "" {
+		filter.Status = status
+	}
+
+	ctx := context.Background()
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	db, err := sqlite.New(ctx, cfg.DBDSN)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	recs, total, err := db.QueryAuditRecords(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("search audit records: %w", err)
+	}
+	if *jsonOut {
+		page := make([]*store.AuditRecord, len(recs))
+		for i := range recs {
+			page[i] = &recs[i]
+		}
+		return encodeJSONL(os.Stdout, page)
+	}
+	for _, r := range recs {
+		line := fmt.Sprintf("%s  %-7s  %-40s  %s",
+			r.Timestamp.Local().Format("2006-01-02 15:04:05"), r.Status, r.ToolName, r.ID)
+		if r.ErrorMessage != "" {
+			line += "  " + r.ErrorMessage
+		}
+		fmt.Println(line)
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d matching records\n", len(recs), total)
+	return nil
+}
+
+// auditVerify checks the audit hash chain and checkpoint signatures and
+// fails if any record was edited, removed or reordered.
+func auditVerify(args []string) error {
+	fs := flag.NewFlagSet("audit verify", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, "print the full report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	db, err := sqlite.New(ctx, cfg.DBDSN)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	var pub ed25519.PublicKey
+	if key, err := secrets.LoadSigningKey(cfg.auditSigningKeyPath()); err == nil {
+		pub = key.Public().(ed25519.PublicKey)
+	} else {
+		fmt.Fprintf(os.Stderr, "warning: checkpoint signatures not checked: %v\n", err)
+	}
+
+	report, err := audit.VerifyChain(ctx, db, pub)
+	if err != nil {
+		return fmt.Errorf("verify audit chain: %w", err)
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printChainReport(os.Stdout, report)
+	}
+	if !report.OK {
+		return fmt.Errorf("audit chain verification failed with %d problem(s)", len(report.Problems))
+	}
+	return nil
+}
+
+func printChainReport(w io.Writer, r *audit.ChainReport) {
+	fmt.Fprintf(w, "head seq:     %d\n", r.HeadSeq)
+	fmt.Fprintf(w, "records:      %d verified, %d pruned\n", r.Records, r.Pruned)
+	fmt.Fprintf(w, "checkpoints:  %d (%d signatures verified)\n", r.Checkpoints, r.VerifiedCheckpoints)
+	for _, p := range r.Problems {
+		fmt.Fprintf(w, "  %-20s seq %-8d %s\n", p.Kind, p.Seq, p.Detail)
+	}
+	if r.ProblemsTruncated {
+		fmt.Fprintln(w, "  ... further problems omitted")
+	}
+	if r.OK {
+		fmt.Fprintln(w, "audit chain OK")
+	}
+}
+
+// exportAuditRecords pages through the records matching f oldest first,
+// continuing each page from a keyset cursor so records inserted during the
+// export do not shift the pages.
+func exportAuditRecords(
+	ctx context.Context, s store.AuditStore, f store.AuditFilter, emit func([]*store.AuditRecord) error,
+) (int, error) {
+	const pageSize = 500
+
+	f.Sort = store.AuditSortTimestampAsc
+	f.Limit = pageSize
+	f.Offset = 0
+	var n int
+	for {
+		recs, _, err := s.QueryAuditRecords(ctx, f)
+		if err != nil {
+			return n, fmt.Errorf("query audit records: %w", err)
+		}
+		if len(recs) == 0 {
+			return n, nil
+		}
+		page := make([]*store.AuditRecord, len(recs))
+		for i := range recs {
+			page[i] = &recs[i]
+		}
+		if err := emit(page); err != nil {
+			return n, fmt.Errorf("write audit records: %w", err)
+		}
+		n += len(page)
+		if len(recs) < pageSize {
+			return n, nil
+		}
+		f.Cursor = store.AuditCursor(f.Sort, &recs[len(recs)-1])
+	}
+}
+
+func encodeJSONL(w io.Writer, recs []*store.AuditRecord) error {
+	enc := json.NewEncoder(w)
+	for _, rec := range recs {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSince accepts a Go duration (relative to now) or an RFC 3339 time.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a duration nor an RFC 3339 time", s)
+	}
+	return t, nil
+}
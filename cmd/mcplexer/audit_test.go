@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+	"github.com/revitteth/mcplexer/internal/store/sqlite"
+)
+
+func TestExportAuditRecordsOldestFirst(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlite.New(ctx, t.TempDir()+"/test.db")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	const n = 1203 // spans several export pages
+	for i := 0; i < n; i++ {
+		rec := &store.AuditRecord{
+			ID:        fmt.Sprintf("r%04d", i),
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			ToolName:  "test__tool",
+			Status:    "success",
+		}
+		if err := db.InsertAuditRecord(ctx, rec); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	after, before := base, base.Add(time.Hour)
+	var got []string
+	count, err := exportAuditRecords(ctx, db, store.AuditFilter{After: &after, Before: &before},
+		func(recs []*store.AuditRecord) error {
+			for _, r := range recs {
+				got = append(got, r.ID)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if count != n || len(got) != n {
+		t.Fatalf("exported %d (%d ids), want %d", count, len(got), n)
+	}
+	for i, id := range got {
+		if want := fmt.Sprintf("r%04d", i); id != want {
+			t.Fatalf("record %d = %s, want %s", i, id, want)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+		err  bool
+	}{
+		{"24h", now.Add(-24 * time.Hour), false},
+		{"2025-01-01T00:00:00Z", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.in, now)
+		if (err != nil) != tt.err || !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
@@ -4,6 +4,12 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/downstream"
+	"github.com/revitteth/mcplexer/internal/retention"
+	"github.com/revitteth/mcplexer/internal/secrets"
 )
 
 // Config holds application configuration loaded from environment variables.
@@ -17,6 +23,84 @@ type Config struct {
 	LogLevel    slog.Level // slog level
 	SocketPath  string     // unix socket path for multi-client mode
 	ExternalURL string     // external URL for OAuth callbacks
+
+	// Retention; zero disables a limit.
+	AuditRetentionDays    int           // prune audit records older than this
+	AuditMaxRows          int           // keep at most this many audit records
+	AuditArchiveDir       string        // archive pruned audit records as gzip JSONL
+	SessionRetentionDays  int           // prune disconnected sessions older than this
+	ApprovalRetentionDays int           // prune resolved approvals older than this
+	CompactInterval       time.Duration // how often the compactor runs
+
+	// Audit export sinks; empty disables a sink.
+	AuditFile         string // rotating JSONL file
+	AuditFileMaxMB    int    // rotate the JSONL file past this size
+	AuditFileBackups  int    // rotated JSONL files to keep
+	AuditSyslog       string // syslog URL, e.g. udp://host:514
+	AuditOTLPEndpoint string // OTLP/HTTP logs URL, e.g. http://host:4318/v1/logs
+	AuditOTLPHeaders  string // extra OTLP headers as k=v,k2=v2
+
+	AuditRedactPatterns string // file of extra secret regexes, one per line
+	AuditQueueSize      int    // records queued for the batch writer; 0 writes synchronously
+
+	// Tracing; an empty endpoint keeps spans in-process only.
+	TraceOTLPEndpoint string // OTLP/HTTP traces URL, e.g. http://host:4318/v1/traces
+	TraceOTLPHeaders  string // extra OTLP headers as k=v,k2=v2
+
+	// Downstream stderr files; an empty dir keeps stderr in memory only.
+	DownstreamLogDir     string // one rotating <server id>.log per server
+	DownstreamLogMaxMB   int    // rotate a stderr file past this size
+	DownstreamLogBackups int    // rotated stderr files to keep per server
+
+	// Downstream health probes, for servers without their own settings.
+	HealthCheckInterval time.Duration // 0 disables probes
+	HealthCheckTimeout  time.Duration
+
+	// Downstream timeouts, for servers without their own settings.
+	InitTimeout time.Duration // initialize handshake
+	CallTimeout time.Duration // 0 lets calls run as long as the client waits
+
+	// Audit hash chain checkpoints.
+	AuditSigningKey         string        // Ed25519 key; empty means beside the age key
+	AuditCheckpointInterval time.Duration // how often the chain head is signed
+}
+
+// auditSigningKeyPath returns the checkpoint signing key path, defaulting to
+// a file beside the age identity.
+func (c *Config) auditSigningKeyPath() string {
+	if c.AuditSigningKey != "" {
+		return c.AuditSigningKey
+	}
+	agePath := c.AgeKeyPath
+	if agePath == "" {
+		agePath = c.DBDSN + ".age"
+	}
+	return secrets.SigningKeyPath(agePath)
+}
+
+// retentionPolicy converts the retention settings to a retention.Policy.
+func (c *Config) retentionPolicy() retention.Policy {
+	day := 24 * time.Hour
+	return retention.Policy{
+		AuditMaxAge:    time.Duration(c.AuditRetentionDays) * day,
+		AuditMaxRows:   c.AuditMaxRows,
+		SessionMaxAge:  time.Duration(c.SessionRetentionDays) * day,
+		ApprovalMaxAge: time.Duration(c.ApprovalRetentionDays) * day,
+		ArchiveDir:     c.AuditArchiveDir,
+		Interval:       c.CompactInterval,
+	}
+}
+
+// managerOptions configures the downstream manager from the settings.
+func managerOptions(c *Config) []downstream.ManagerOption {
+	opts := []downstream.ManagerOption{
+		downstream.WithHealthChecks(c.HealthCheckInterval, c.HealthCheckTimeout),
+		downstream.WithTimeouts(c.InitTimeout, c.CallTimeout),
+	}
+	if c.DownstreamLogDir != "" {
+		opts = append(opts, downstream.WithStderrFiles(c.DownstreamLogDir, int64(c.DownstreamLogMaxMB)<<20, c.DownstreamLogBackups))
+	}
+	return opts
 }
 
 // defaultDataPath returns ~/.mcplexer/<filename>, falling back to
@@ -40,6 +124,39 @@ func loadConfig() (*Config, error) {
 		LogLevel:    parseLogLevel(envOr("MCPLEXER_LOG_LEVEL", "info")),
 		SocketPath:  envOr("MCPLEXER_SOCKET_PATH", ""),
 		ExternalURL: envOr("MCPLEXER_EXTERNAL_URL", ""),
+
+		AuditRetentionDays:    envInt("MCPLEXER_AUDIT_RETENTION_DAYS", 0),
+		AuditMaxRows:          envInt("MCPLEXER_AUDIT_MAX_ROWS", 0),
+		AuditArchiveDir:       envOr("MCPLEXER_AUDIT_ARCHIVE_DIR", ""),
+		SessionRetentionDays:  envInt("MCPLEXER_SESSION_RETENTION_DAYS", 0),
+		ApprovalRetentionDays: envInt("MCPLEXER_APPROVAL_RETENTION_DAYS", 0),
+		CompactInterval:       envDuration("MCPLEXER_COMPACT_INTERVAL", time.Hour),
+
+		AuditFile:         envOr("MCPLEXER_AUDIT_FILE", ""),
+		AuditFileMaxMB:    envInt("MCPLEXER_AUDIT_FILE_MAX_MB", 100),
+		AuditFileBackups:  envInt("MCPLEXER_AUDIT_FILE_BACKUPS", 5),
+		AuditSyslog:       envOr("MCPLEXER_AUDIT_SYSLOG", ""),
+		AuditOTLPEndpoint: envOr("MCPLEXER_AUDIT_OTLP_ENDPOINT", ""),
+		AuditOTLPHeaders:  envOr("MCPLEXER_AUDIT_OTLP_HEADERS", ""),
+
+		AuditRedactPatterns: envOr("MCPLEXER_AUDIT_REDACT_PATTERNS", ""),
+		AuditQueueSize:      envInt("MCPLEXER_AUDIT_QUEUE_SIZE", 4096),
+
+		TraceOTLPEndpoint: envOr("MCPLEXER_TRACE_OTLP_ENDPOINT", ""),
+		TraceOTLPHeaders:  envOr("MCPLEXER_TRACE_OTLP_HEADERS", ""),
+
+		DownstreamLogDir:     envOr("MCPLEXER_DOWNSTREAM_LOG_DIR", ""),
+		DownstreamLogMaxMB:   envInt("MCPLEXER_DOWNSTREAM_LOG_MAX_MB", 10),
+		DownstreamLogBackups: envInt("MCPLEXER_DOWNSTREAM_LOG_BACKUPS", 3),
+
+		HealthCheckInterval: envInterval("MCPLEXER_HEALTH_CHECK_INTERVAL", 30*time.Second),
+		HealthCheckTimeout:  envDuration("MCPLEXER_HEALTH_CHECK_TIMEOUT", 10*time.Second),
+
+		InitTimeout: envDuration("MCPLEXER_INIT_TIMEOUT", 30*time.Second),
+		CallTimeout: envInterval("MCPLEXER_CALL_TIMEOUT", 60*time.Second),
+
+		AuditSigningKey:         envOr("MCPLEXER_AUDIT_SIGNING_KEY", ""),
+		AuditCheckpointInterval: envDuration("MCPLEXER_AUDIT_CHECKPOINT_INTERVAL", 5*time.Minute),
 	}
 	return cfg, nil
 }
@@ -51,6 +168,35 @@ func envOr(key, fallback string) string {
 	return fallback
 }
 
+// envInt reads a non-negative integer, falling back on absent or invalid
+// values.
+func envInt(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || v < 0 {
+		return fallback
+	}
+	return v
+}
+
+// envDuration reads a Go duration string, falling back on absent or invalid
+// values.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || v <= 0 {
+		return fallback
+	}
+	return v
+}
+
+// envInterval reads a duration like envDuration, except that "0" turns the
+// periodic task off.
+func envInterval(key string, fallback time.Duration) time.Duration {
+	if os.Getenv(key) == "0" {
+		return 0
+	}
+	return envDuration(key, fallback)
+}
+
 func parseLogLevel(s string) slog.Level {
 	switch s {
 	case "debug":
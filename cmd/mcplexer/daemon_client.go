@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/revitteth/mcplexer/internal/downstream"
+)
+
+// daemonClient reads live state from the running daemon's HTTP API: the
+// CLI and the control server only share its database, not its memory.
+type daemonClient struct {
+	base string
+}
+
+func newDaemonClient(cfg *Config) *daemonClient {
+	if strings.HasPrefix(cfg.HTTPAddr, ":") {
+		return &daemonClient{base: "http://localhost" + cfg.HTTPAddr}
+	}
+	return &daemonClient{base: "http://" + cfg.HTTPAddr}
+}
+
+// Logs reads a server's recent stderr lines.
+func (d *daemonClient) Logs(ctx context.Context, serverID string, lines int) ([]downstream.LogLine, error) {
+	var logs []downstream.LogLine
+	if err := d.getJSON(ctx, d.logsURL(serverID, lines, false), &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// Health reads the health probe summary of each probed server.
+func (d *daemonClient) Health(ctx context.Context) (map[string]downstream.ServerHealth, error) {
+	var health map[string]downstream.ServerHealth
+	if err := d.getJSON(ctx, d.base+"/api/v1/downstreams/health", &health); err != nil {
+		return nil, err
+	}
+	return health, nil
+}
+
+func (d *daemonClient) logsURL(serverID string, lines int, follow bool) string {
+	q := url.Values{"lines": {strconv.Itoa(lines)}}
+	if follow {
+		q.Set("follow", "true")
+	}
+	return d.base + "/api/v1/downstreams/" + url.PathEscape(serverID) + "/logs?" + q.Encode()
+}
+
+func (d *daemonClient) getJSON(ctx context.Context, u string, v any) error {
+	resp, err := d.get(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode daemon response: %w", err)
+	}
+	return nil
+}
+
+// get performs a GET and turns non-200 responses into errors carrying the
+// API's error message.
+func (d *daemonClient) get(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("reach mcplexer daemon (is it running in http mode?): %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body) //nolint:errcheck
+		if body.Error == "" {
+			body.Error = resp.Status
+		}
+		return nil, fmt.Errorf("daemon: %s", body.Error)
+	}
+	return resp, nil
+}
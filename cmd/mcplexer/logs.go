@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/revitteth/mcplexer/internal/downstream"
+	"github.com/revitteth/mcplexer/internal/store"
+	"github.com/revitteth/mcplexer/internal/store/sqlite"
+)
+
+// cmdLogs prints a downstream server's recent stderr, read from the
+// running daemon since only it holds the log buffers.
+func cmdLogs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	follow := fs.Bool("f", false, "keep streaming new lines")
+	lines := fs.Int("n", 100, "number of recent lines to show (at most 1000)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: mcplexer logs [-f] [-n lines] <server name or id>")
+	}
+
+	ctx := context.Background()
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	db, err := sqlite.New(ctx, cfg.DBDSN)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	srv, err := resolveDownstream(ctx, db, fs.Arg(0))
+	db.Close()
+	if err != nil {
+		return err
+	}
+
+	d := newDaemonClient(cfg)
+	if !*follow {
+		logs, err := d.Logs(ctx, srv.ID, *lines)
+		if err != nil {
+			return err
+		}
+		for _, l := range logs {
+			fmt.Println(l)
+		}
+		return nil
+	}
+	return followLogs(ctx, d, srv.ID, *lines)
+}
+
+// resolveDownstream finds a downstream server by name, then by ID.
+func resolveDownstream(ctx context.Context, s store.DownstreamServerStore, ref string) (*store.DownstreamServer, error) {
+	srv, err := s.GetDownstreamServerByName(ctx, ref)
+	if errors.Is(err, store.ErrNotFound) {
+		srv, err = s.GetDownstreamServer(ctx, ref)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("downstream server %q not found", ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get downstream server: %w", err)
+	}
+	return srv, nil
+}
+
+// followLogs prints the recent lines, then new ones as the daemon streams
+// them, until the stream ends.
+func followLogs(ctx context.Context, d *daemonClient, serverID string, lines int) error {
+	resp, err := d.get(ctx, d.logsURL(serverID, lines, true))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			event = ""
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && event == "dropped":
+			fmt.Fprintf(os.Stderr, "mcplexer: log lines dropped: %s\n", strings.TrimPrefix(line, "data: "))
+		case strings.HasPrefix(line, "data: "):
+			var l downstream.LogLine
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &l); err == nil {
+				fmt.Println(l)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read log stream: %w", err)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 	"os/exec"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
@@ -26,13 +28,17 @@ import (
 	"github.com/revitteth/mcplexer/internal/control"
 	"github.com/revitteth/mcplexer/internal/downstream"
 	"github.com/revitteth/mcplexer/internal/gateway"
+	"github.com/revitteth/mcplexer/internal/metrics"
 	"github.com/revitteth/mcplexer/internal/oauth"
+	"github.com/revitteth/mcplexer/internal/retention"
 	"github.com/revitteth/mcplexer/internal/routing"
 	"github.com/revitteth/mcplexer/internal/secrets"
 	"github.com/revitteth/mcplexer/internal/store/sqlite"
+	"github.com/revitteth/mcplexer/internal/tracing"
 )
 
 func main() {
+	downstream.SandboxMain()
 	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "mcplexer: %v\n", err)
 		os.Exit(1)
@@ -67,8 +73,12 @@ func run() error {
 		return cmdSetup()
 	case "control-server":
 		return cmdControlServer()
+	case "audit":
+		return cmdAudit(args)
+	case "logs":
+		return cmdLogs(args)
 	default:
-		return fmt.Errorf("unknown command: %s\nUsage: mcplexer [serve|connect|init|status|dry-run|secret|daemon|setup|control-server]", subcmd)
+		return fmt.Errorf("unknown command: %s\nUsage: mcplexer [serve|connect|init|status|dry-run|secret|daemon|setup|control-server|audit|logs]", subcmd)
 	}
 }
 
@@ -129,6 +139,17 @@ func cmdServe(args []string) error {
 		}
 	}
 
+	if cfg.TraceOTLPEndpoint != "" {
+		exporter := tracing.NewOTLPExporter(cfg.TraceOTLPEndpoint, parseHeaderList(cfg.TraceOTLPHeaders))
+		tracing.SetExporter(exporter)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			exporter.Shutdown(shutdownCtx) //nolint:errcheck
+		}()
+		logger.Info("exporting traces", "endpoint", cfg.TraceOTLPEndpoint)
+	}
+
 	cfgSvc := config.NewService(db)
 
 	switch cfg.Mode {
@@ -152,14 +173,20 @@ func runHTTP(ctx context.Context, cfg *Config, db *sqlite.DB, cfgSvc *config.Ser
 	}
 
 	engine := routing.NewEngine(db)
-	manager := downstream.NewManager(db, authInj)
+	manager := downstream.NewManager(db, authInj, managerOptions(cfg)...)
 	defer manager.Shutdown(ctx) //nolint:errcheck
+	manager.RegisterMetrics(metrics.Default)
 
 	approvalBus := approval.NewBus()
 	approvalMgr := approval.NewManager(db, approvalBus)
-	approvalMgr.ExpireStale(ctx)
+	approvalMgr.Restore(ctx)
 	defer approvalMgr.Shutdown()
 
+	signingKey := auditSigningKey(cfg)
+	startCompactor(ctx, cfg, db, signingKey)
+	auditKey, stopCheckpointer := startCheckpointer(ctx, cfg, db, signingKey)
+	defer stopCheckpointer()
+
 	auditBus := audit.NewBus()
 	router := api.NewRouter(api.RouterDeps{
 		Store:           db,
@@ -171,6 +198,7 @@ func runHTTP(ctx context.Context, cfg *Config, db *sqlite.DB, cfgSvc *config.Ser
 		AuditBus:        auditBus,
 		ApprovalManager: approvalMgr,
 		ApprovalBus:     approvalBus,
+		AuditSigningKey: auditKey,
 	})
 
 	srv := &http.Server{
@@ -203,20 +231,42 @@ func runStdio(ctx context.Context, cfg *Config, db *sqlite.DB) error {
 	}
 
 	engine := routing.NewEngine(db)
-	manager := downstream.NewManager(db, authInj)
+	manager := downstream.NewManager(db, authInj, managerOptions(cfg)...)
 	defer manager.Shutdown(ctx) //nolint:errcheck
+	manager.RegisterMetrics(metrics.Default)
 
 	approvalBus := approval.NewBus()
 	approvalMgr := approval.NewManager(db, approvalBus)
-	approvalMgr.ExpireStale(ctx)
+	approvalMgr.Restore(ctx)
 	defer approvalMgr.Shutdown()
 
-	auditor := audit.NewLogger(db, db, nil)
+	signingKey := auditSigningKey(cfg)
+	startCompactor(ctx, cfg, db, signingKey)
+	_, stopCheckpointer := startCheckpointer(ctx, cfg, db, signingKey)
+	defer stopCheckpointer()
+
+	auditOpts, err := auditLoggerOptions(cfg)
+	if err != nil {
+		return fmt.Errorf("configure audit logger: %w", err)
+	}
+	auditor := audit.NewLogger(db, db, nil, auditOpts...)
+	defer closeAuditor(auditor)
 	gw := gateway.NewServer(db, engine, manager, auditor, gateway.TransportStdio,
 		gateway.WithApprovals(approvalMgr))
 	return gw.RunStdio(ctx)
 }
 
+// startCompactor runs the retention compactor in the background until ctx is
+// cancelled. Workspaces can set their own audit limits, so it always runs.
+// Pruned audit ranges are signed with key when it is non-nil.
+func startCompactor(ctx context.Context, cfg *Config, db *sqlite.DB, key ed25519.PrivateKey) {
+	var opts []retention.Option
+	if key != nil {
+		opts = append(opts, retention.WithGapSigner(audit.GapSigner(key)))
+	}
+	go retention.NewCompactor(db, db, cfg.retentionPolicy(), opts...).Run(ctx)
+}
+
 // buildAuthInjector creates an auth.Injector and optionally an oauth.FlowManager.
 // Returns nil injector (safe to pass) if no key path is set.
 func buildAuthInjector(cfg *Config, db *sqlite.DB) (*auth.Injector, *oauth.FlowManager, *secrets.AgeEncryptor, error) {
@@ -488,6 +538,7 @@ func cmdControlServer() error {
 
 	readOnly := os.Getenv("MCPLEXER_CONTROL_READONLY") != "false"
 	srv := control.New(db, readOnly)
+	srv.SetDaemon(newDaemonClient(cfg))
 	return srv.RunStdio(ctx)
 }
 
@@ -516,16 +567,28 @@ func runHTTPAndSocket(ctx context.Context, cfg *Config, db *sqlite.DB, cfgSvc *c
 	}
 
 	engine := routing.NewEngine(db)
-	manager := downstream.NewManager(db, authInj)
+	manager := downstream.NewManager(db, authInj, managerOptions(cfg)...)
 	defer manager.Shutdown(ctx) //nolint:errcheck
+	manager.RegisterMetrics(metrics.Default)
 
 	approvalBus := approval.NewBus()
 	approvalMgr := approval.NewManager(db, approvalBus)
-	approvalMgr.ExpireStale(ctx)
+	approvalMgr.Restore(ctx)
 	defer approvalMgr.Shutdown()
 
+	signingKey := auditSigningKey(cfg)
+	startCompactor(ctx, cfg, db, signingKey)
+	auditKey, stopCheckpointer := startCheckpointer(ctx, cfg, db, signingKey)
+	defer stopCheckpointer()
+
 	auditBus := audit.NewBus()
-	auditor := audit.NewLogger(db, db, auditBus)
+	auditOpts, err := auditLoggerOptions(cfg)
+	if err != nil {
+		return fmt.Errorf("configure audit logger: %w", err)
+	}
+	auditor := audit.NewLogger(db, db, auditBus, auditOpts...)
+	defer closeAuditor(auditor)
+	auditor.RegisterMetrics(metrics.Default)
 	g, ctx := errgroup.WithContext(ctx)
 
 	// HTTP server
@@ -540,6 +603,7 @@ func runHTTPAndSocket(ctx context.Context, cfg *Config, db *sqlite.DB, cfgSvc *c
 			AuditBus:        auditBus,
 			ApprovalManager: approvalMgr,
 			ApprovalBus:     approvalBus,
+			AuditSigningKey: auditKey,
 		})
 		srv := &http.Server{Addr: cfg.HTTPAddr, Handler: router}
 		errCh := make(chan error, 1)
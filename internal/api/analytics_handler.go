@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// defaultAnalyticsRange is the window analytics covers when after is unset.
+const defaultAnalyticsRange = 7 * 24 * time.Hour
+
+type analyticsHandler struct {
+	store store.AuditStore
+}
+
+// query answers usage questions from the hourly and daily rollups, e.g.
+// top tools this week (group_by=tool) or which workspace uses a server most
+// (group_by=workspace&downstream_server_id=...).
+func (h *analyticsHandler) query(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	now := time.Now().UTC()
+	filter := store.UsageFilter{
+		Before:  now,
+		GroupBy: []string{store.UsageByTool},
+		Sort:    q.Get("sort"),
+		Limit:   50,
+	}
+
+	filter.After = now.Add(-defaultAnalyticsRange)
+	if v := q.Get("after"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid after")
+			return
+		}
+		filter.After = t
+	}
+	if v := q.Get("before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid before")
+			return
+		}
+		filter.Before = t
+	}
+
+	// Hourly buckets for up to two days, daily beyond that.
+	filter.Granularity = store.UsageDay
+	if filter.Before.Sub(filter.After) <= 48*time.Hour {
+		filter.Granularity = store.UsageHour
+	}
+	if v := q.Get("granularity"); v != "" {
+		filter.Granularity = v
+	}
+
+	if v, ok := q["group_by"]; ok {
+		filter.GroupBy = nil
+		for _, d := range strings.Split(strings.Join(v, ","), ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				filter.GroupBy = append(filter.GroupBy, d)
+			}
+		}
+	}
+	if v := q.Get("workspace_id"); v != "" {
+		filter.WorkspaceID = &v
+	}
+	if v := q.Get("tool_name"); v != "" {
+		filter.ToolName = &v
+	}
+	if v := q.Get("downstream_server_id"); v != "" {
+		filter.DownstreamServerID = &v
+	}
+	if v := q.Get("client_type"); v != "" {
+		filter.ClientType = &v
+	}
+	if v := q.Get("model"); v != "" {
+		filter.Model = &v
+	}
+	if v := q.Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+			filter.Limit = n
+		}
+	}
+
+	if err := filter.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := h.store.QueryUsage(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to query usage")
+		return
+	}
+	if rows == nil {
+		rows = []store.UsageRow{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data":        rows,
+		"granularity": filter.Granularity,
+		"group_by":    filter.GroupBy,
+		"after":       store.UsageBucket(filter.Granularity, filter.After),
+		"before":      filter.Before,
+	})
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/revitteth/mcplexer/internal/approval"
 	"github.com/revitteth/mcplexer/internal/store"
@@ -27,7 +29,10 @@ func (h *approvalHandler) list(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// For resolved statuses, query the DB.
-	approvals, err := h.store.ListPendingApprovals(r.Context())
+	approvals, _, err := h.store.QueryToolApprovals(r.Context(), store.ApprovalFilter{
+		Status: &status,
+		Limit:  500,
+	})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to list approvals")
 		return
@@ -38,6 +43,84 @@ func (h *approvalHandler) list(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, approvals)
 }
 
+// history returns a filtered, paginated page of approvals of any status.
+func (h *approvalHandler) history(w http.ResponseWriter, r *http.Request) {
+	filter := parseApprovalFilter(r)
+	approvals, total, err := h.store.QueryToolApprovals(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to query approvals")
+		return
+	}
+	if approvals == nil {
+		approvals = []store.ToolApproval{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data":   approvals,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// stats returns approval outcome counts, approval rate and median
+// time-to-decision, overall and per tool, for the same filters as history.
+func (h *approvalHandler) stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.store.GetApprovalStats(r.Context(), parseApprovalFilter(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get approval stats")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func parseApprovalFilter(r *http.Request) store.ApprovalFilter {
+	q := r.URL.Query()
+	filter := store.ApprovalFilter{
+		Limit:  50,
+		Offset: 0,
+	}
+
+	if v := q.Get("status"); v != "" {
+		filter.Status = &v
+	}
+	if v := q.Get("tool_name"); v != "" {
+		filter.ToolName = &v
+	}
+	if v := q.Get("workspace_id"); v != "" {
+		filter.WorkspaceID = &v
+	}
+	if v := q.Get("approver_type"); v != "" {
+		filter.ApproverType = &v
+	}
+	if v := q.Get("request_session_id"); v != "" {
+		filter.RequestSessionID = &v
+	}
+	if v := q.Get("q"); v != "" {
+		filter.Query = &v
+	}
+	if v := q.Get("after"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			filter.After = &t
+		}
+	}
+	if v := q.Get("before"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			filter.Before = &t
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 500 {
+			filter.Limit = n
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			filter.Offset = n
+		}
+	}
+	return filter
+}
+
 func (h *approvalHandler) get(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	a, err := h.store.GetToolApproval(r.Context(), id)
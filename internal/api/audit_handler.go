@@ -1,15 +1,20 @@
 package api
 
 import (
+	"crypto/ed25519"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/revitteth/mcplexer/internal/audit"
 	"github.com/revitteth/mcplexer/internal/store"
 )
 
 type auditHandler struct {
-	store store.AuditStore
+	store      store.AuditStore
+	chainStore store.AuditChainStore
+	signingKey ed25519.PublicKey // nil skips checkpoint signature checks
 }
 
 func (h *auditHandler) query(w http.ResponseWriter, r *http.Request) {
@@ -31,6 +36,32 @@ func (h *auditHandler) query(w http.ResponseWriter, r *http.Request) {
 	if v := q.Get("session_id"); v != "" {
 		filter.SessionID = &v
 	}
+	if v := q.Get("downstream_server_id"); v != "" {
+		filter.DownstreamServerID = &v
+	}
+	if v := q.Get("route_rule_id"); v != "" {
+		filter.RouteRuleID = &v
+	}
+	if v := q.Get("auth_scope_id"); v != "" {
+		filter.AuthScopeID = &v
+	}
+	if v := q.Get("error_code"); v != "" {
+		filter.ErrorCode = &v
+	}
+	if v := q.Get("client_type"); v != "" {
+		filter.ClientType = &v
+	}
+	if v := q.Get("trace_id"); v != "" {
+		filter.TraceID = &v
+	}
+	if v := q.Get("min_latency_ms"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			filter.MinLatencyMs = &n
+		}
+	}
+	if v := q.Get("q"); v != "" {
+		filter.Search = &v
+	}
 	if v := q.Get("after"); v != "" {
 		if t, err := time.Parse(time.RFC3339, v); err == nil {
 			filter.After = &t
@@ -51,8 +82,22 @@ func (h *auditHandler) query(w http.ResponseWriter, r *http.Request) {
 			filter.Offset = n
 		}
 	}
+	filter.Sort = q.Get("sort")
+	if !store.ValidAuditSort(filter.Sort) {
+		writeError(w, http.StatusBadRequest, "invalid sort")
+		return
+	}
+	filter.Cursor = q.Get("cursor")
+	if filter.Cursor != "" && q.Get("offset") != "" {
+		writeError(w, http.StatusBadRequest, "cursor and offset cannot be combined")
+		return
+	}
 
 	records, total, err := h.store.QueryAuditRecords(r.Context(), filter)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		writeError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to query audit records")
 		return
@@ -61,10 +106,25 @@ func (h *auditHandler) query(w http.ResponseWriter, r *http.Request) {
 	if records == nil {
 		records = []store.AuditRecord{}
 	}
-	writeJSON(w, http.StatusOK, map[string]any{
+	resp := map[string]any{
 		"data":   records,
 		"total":  total,
 		"limit":  filter.Limit,
 		"offset": filter.Offset,
-	})
+	}
+	// A full page may have more after it.
+	if len(records) == filter.Limit {
+		resp["next_cursor"] = store.AuditCursor(filter.Sort, &records[len(records)-1])
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// verify checks the audit hash chain and its signed checkpoints.
+func (h *auditHandler) verify(w http.ResponseWriter, r *http.Request) {
+	report, err := audit.VerifyChain(r.Context(), h.chainStore, h.signingKey)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to verify audit chain")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
 }
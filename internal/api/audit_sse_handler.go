@@ -2,11 +2,10 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/revitteth/mcplexer/internal/audit"
+	"github.com/revitteth/mcplexer/internal/store"
 )
 
 type auditSSEHandler struct {
@@ -14,53 +13,20 @@ type auditSSEHandler struct {
 }
 
 func (h *auditSSEHandler) stream(w http.ResponseWriter, r *http.Request) {
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		writeError(w, http.StatusInternalServerError, "streaming not supported")
-		return
-	}
-
 	// Read optional filters from query params.
 	qWorkspace := r.URL.Query().Get("workspace_id")
 	qTool := r.URL.Query().Get("tool_name")
 	qStatus := r.URL.Query().Get("status")
 
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	flusher.Flush()
-
-	ch := h.bus.Subscribe()
-	defer h.bus.Unsubscribe(ch)
-
-	heartbeat := time.NewTicker(15 * time.Second)
-	defer heartbeat.Stop()
-
-	ctx := r.Context()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case rec, ok := <-ch:
-			if !ok {
-				return
-			}
-			if !matchFilter(rec.WorkspaceID, qWorkspace) ||
-				!matchFilter(rec.ToolName, qTool) ||
-				!matchFilter(rec.Status, qStatus) {
-				continue
-			}
-			data, err := json.Marshal(rec)
-			if err != nil {
-				continue
-			}
-			fmt.Fprintf(w, "data: %s\n\n", data)
-			flusher.Flush()
-		case <-heartbeat.C:
-			fmt.Fprint(w, ":\n\n")
-			flusher.Flush()
+	streamEvents(w, r, h.bus, "audit", func(rec *store.AuditRecord) ([]byte, bool) {
+		if !matchFilter(rec.WorkspaceID, qWorkspace) ||
+			!matchFilter(rec.ToolName, qTool) ||
+			!matchFilter(rec.Status, qStatus) {
+			return nil, false
 		}
-	}
+		data, err := json.Marshal(rec)
+		return data, err == nil
+	})
 }
 
 // matchFilter returns true if the filter is empty or matches the value.
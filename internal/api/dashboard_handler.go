@@ -13,23 +13,26 @@ type dashboardHandler struct {
 	sessionStore    store.SessionStore
 	auditStore      store.AuditStore
 	downstreamStore store.DownstreamServerStore
+	retentionStore  store.RetentionStore
 	manager         *downstream.Manager // optional
 }
 
 type downstreamStatus struct {
-	ServerID      string `json:"server_id"`
-	ServerName    string `json:"server_name"`
-	InstanceCount int    `json:"instance_count"`
-	State         string `json:"state"`
+	ServerID      string                   `json:"server_id"`
+	ServerName    string                   `json:"server_name"`
+	InstanceCount int                      `json:"instance_count"`
+	State         string                   `json:"state"`
+	Health        *downstream.ServerHealth `json:"health,omitempty"` // absent until the server is probed
 }
 
 type dashboardResponse struct {
-	ActiveSessions    int                    `json:"active_sessions"`
-	ActiveDownstreams []downstreamStatus     `json:"active_downstreams"`
-	RecentErrors      []store.AuditRecord    `json:"recent_errors"`
-	RecentCalls       []store.AuditRecord    `json:"recent_calls"`
-	Stats             *store.AuditStats      `json:"stats,omitempty"`
+	ActiveSessions    int                     `json:"active_sessions"`
+	ActiveDownstreams []downstreamStatus      `json:"active_downstreams"`
+	RecentErrors      []store.AuditRecord     `json:"recent_errors"`
+	RecentCalls       []store.AuditRecord     `json:"recent_calls"`
+	Stats             *store.AuditStats       `json:"stats,omitempty"`
 	TimeSeries        []store.TimeSeriesPoint `json:"timeseries"`
+	DBSizeBytes       int64                   `json:"db_size_bytes"`
 }
 
 func (h *dashboardHandler) get(w http.ResponseWriter, r *http.Request) {
@@ -55,12 +58,12 @@ func (h *dashboardHandler) get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Recent errors only
-	errStatus := "error"
+	// Recent failures of any kind, timeouts included
+	success := "success"
 	errorRecords, _, err := h.auditStore.QueryAuditRecords(ctx, store.AuditFilter{
-		Status: &errStatus,
-		After:  &oneHourAgo,
-		Limit:  10,
+		ExcludeStatus: &success,
+		After:         &oneHourAgo,
+		Limit:         10,
 	})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to query recent errors")
@@ -90,6 +93,9 @@ func (h *dashboardHandler) get(w http.ResponseWriter, r *http.Request) {
 
 	activeDownstreams := h.buildDownstreamStatus(ctx)
 
+	// DB size is informational; report 0 rather than failing the dashboard.
+	dbSize, _ := h.retentionStore.DatabaseSize(ctx)
+
 	writeJSON(w, http.StatusOK, dashboardResponse{
 		ActiveSessions:    len(sessions),
 		ActiveDownstreams: activeDownstreams,
@@ -97,6 +103,7 @@ func (h *dashboardHandler) get(w http.ResponseWriter, r *http.Request) {
 		RecentCalls:       recentCalls,
 		Stats:             stats,
 		TimeSeries:        timeseries,
+		DBSizeBytes:       dbSize,
 	})
 }
 
@@ -138,7 +145,9 @@ func (h *dashboardHandler) buildDownstreamStatus(ctx context.Context) []downstre
 		state string // "best" state across instances
 	}
 	running := make(map[string]instanceAgg)
+	var health map[string]downstream.ServerHealth
 	if h.manager != nil {
+		health = h.manager.Health()
 		for _, info := range h.manager.ListInstances() {
 			agg := running[info.Key.ServerID]
 			agg.count++
@@ -159,6 +168,9 @@ func (h *dashboardHandler) buildDownstreamStatus(ctx context.Context) []downstre
 		} else {
 			ds.State = "stopped"
 		}
+		if sh, ok := health[srv.ID]; ok {
+			ds.Health = &sh
+		}
 		result = append(result, ds)
 	}
 	return result
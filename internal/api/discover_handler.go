@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/revitteth/mcplexer/internal/downstream"
@@ -27,6 +28,13 @@ func (h *discoverHandler) discover(w http.ResponseWriter, r *http.Request) {
 	authScopeID := h.findAuthScope(ctx, id)
 
 	raw, err := h.manager.ListTools(ctx, id, authScopeID)
+	if errors.Is(err, downstream.ErrMissingSessionVar) {
+		// There is no session here to expand the server's settings from;
+		// its tools are discovered when a session first calls it.
+		writeError(w, http.StatusUnprocessableEntity,
+			"server uses session template variables and cannot be discovered outside a session: "+err.Error())
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusBadGateway, "failed to discover tools: "+err.Error())
 		return
@@ -58,7 +58,7 @@ func (h *downstreamConnectHandler) connect(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if server.Transport != "http" || server.URL == nil {
+	if !server.Remote() || server.URL == nil {
 		writeError(w, http.StatusBadRequest,
 			"connect only works for HTTP transport servers")
 		return
@@ -185,7 +185,7 @@ func (h *downstreamConnectHandler) findOrConfigureProvider(
 	}
 
 	// No credentials — try auto-discovery + DCR for HTTP servers.
-	if server.Transport == "http" && server.URL != nil {
+	if server.Remote() && server.URL != nil {
 		discovered, discErr := h.autoDiscoverAndRegister(ctx, tx, server)
 		if discErr == nil {
 			return discovered, nil
@@ -396,7 +396,7 @@ func (h *downstreamConnectHandler) capabilities(w http.ResponseWriter, r *http.R
 		caps.Template = tmpl
 		caps.SupportsAutoDiscovery = tmpl.SupportsAutoDiscovery
 		caps.NeedsCredentials = tmpl.NeedsSecret && !tmpl.SupportsAutoDiscovery
-	} else if server.Transport == "http" && server.URL != nil {
+	} else if server.Remote() && server.URL != nil {
 		// No template — probe the server for OAuth discovery support.
 		metadata, discErr := oauth.DiscoverOAuthServer(ctx, *server.URL)
 		if discErr == nil && metadata.RegistrationEndpoint != "" {
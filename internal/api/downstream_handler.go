@@ -71,12 +71,20 @@ func (h *downstreamHandler) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Decode body on top of existing values.
+	// Decode body on top of existing values. A given env or tool_timeouts
+	// replaces the map rather than merging into it.
 	ds := *existing
+	ds.Env, ds.ToolTimeouts = nil, nil
 	if err := decodeJSON(r, &ds); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
+	if ds.Env == nil {
+		ds.Env = existing.Env
+	}
+	if ds.ToolTimeouts == nil {
+		ds.ToolTimeouts = existing.ToolTimeouts
+	}
 	ds.ID = id
 
 	if err := h.svc.UpdateDownstreamServer(ctx, &ds); err != nil {
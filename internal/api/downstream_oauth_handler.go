@@ -55,7 +55,7 @@ func (h *downstreamOAuthHandler) setup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if server.Transport != "http" || server.URL == nil {
+	if !server.Remote() || server.URL == nil {
 		writeError(w, http.StatusBadRequest, "oauth setup only works for HTTP transport servers")
 		return
 	}
@@ -316,7 +316,7 @@ func GetAllDownstreamOAuthStatuses(
 
 	for _, srv := range servers {
 		ds := DownstreamOAuthStatus{ServerID: srv.ID, Status: "not_applicable"}
-		if srv.Transport != "http" {
+		if !srv.Remote() {
 			statuses = append(statuses, ds)
 			continue
 		}
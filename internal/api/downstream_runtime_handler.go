@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/revitteth/mcplexer/internal/downstream"
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+const defaultLogLines = 100
+
+// downstreamRuntimeHandler serves the live state the manager keeps for
+// downstream servers: captured stderr and health probes.
+type downstreamRuntimeHandler struct {
+	manager *downstream.Manager
+	store   store.DownstreamServerStore
+}
+
+// logs returns the server's recent stderr lines, oldest first. With
+// follow=true it streams them as server-sent events instead, starting with
+// the same recent lines unless the client resumes from an event ID.
+func (h *downstreamRuntimeHandler) logs(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.store.GetDownstreamServer(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "downstream server not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to get downstream server")
+		return
+	}
+
+	q := r.URL.Query()
+	lines := defaultLogLines
+	if v := q.Get("lines"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= 1000 {
+			lines = n
+		}
+	}
+
+	bus := h.manager.Logs(id)
+	tail := bus.Tail(lines)
+
+	if follow, _ := strconv.ParseBool(q.Get("follow")); follow {
+		resume := lastEventID(r)
+		if resume == "" {
+			start := bus.LastID()
+			if len(tail) > 0 {
+				start = tail[0].ID - 1
+			}
+			resume = strconv.FormatUint(start, 10)
+		}
+		streamEventsFrom(w, r, bus, "downstream_logs", resume, func(l downstream.LogLine) ([]byte, bool) {
+			data, err := json.Marshal(l)
+			return data, err == nil
+		})
+		return
+	}
+
+	out := make([]downstream.LogLine, len(tail))
+	for i, ev := range tail {
+		out[i] = ev.Data
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// health returns the probe summary of each probed server by server ID.
+func (h *downstreamRuntimeHandler) health(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, h.manager.Health())
+}
@@ -89,7 +89,7 @@ func (h *oauthFlowHandler) autoDiscoverProvider(
 	if err != nil {
 		return fmt.Errorf("get downstream server: %w", err)
 	}
-	if server.Transport != "http" || server.URL == nil {
+	if !server.Remote() || server.URL == nil {
 		return fmt.Errorf("downstream %q is not an HTTP server", serverID)
 	}
 
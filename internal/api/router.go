@@ -1,6 +1,7 @@
 package api
 
 import (
+	"crypto/ed25519"
 	"io/fs"
 	"net/http"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"github.com/revitteth/mcplexer/internal/audit"
 	"github.com/revitteth/mcplexer/internal/config"
 	"github.com/revitteth/mcplexer/internal/downstream"
+	"github.com/revitteth/mcplexer/internal/metrics"
 	"github.com/revitteth/mcplexer/internal/oauth"
 	"github.com/revitteth/mcplexer/internal/routing"
 	"github.com/revitteth/mcplexer/internal/secrets"
@@ -21,12 +23,13 @@ type RouterDeps struct {
 	Store           store.Store
 	ConfigSvc       *config.Service
 	Engine          *routing.Engine
-	Manager         *downstream.Manager    // optional; enables tool discovery
-	FlowManager     *oauth.FlowManager     // optional; enables OAuth flows
-	Encryptor       *secrets.AgeEncryptor  // optional; enables secret encryption
-	AuditBus        *audit.Bus             // optional; enables SSE audit stream
-	ApprovalManager *approval.Manager      // optional; enables approval system
-	ApprovalBus     *approval.Bus          // optional; enables approval SSE stream
+	Manager         *downstream.Manager   // optional; enables tool discovery
+	FlowManager     *oauth.FlowManager    // optional; enables OAuth flows
+	Encryptor       *secrets.AgeEncryptor // optional; enables secret encryption
+	AuditBus        *audit.Bus            // optional; enables SSE audit stream
+	ApprovalManager *approval.Manager     // optional; enables approval system
+	ApprovalBus     *approval.Bus         // optional; enables approval SSE stream
+	AuditSigningKey ed25519.PublicKey     // optional; verifies audit checkpoint signatures
 }
 
 // NewRouter creates an http.Handler with all API routes and SPA fallback.
@@ -61,8 +64,12 @@ func NewRouter(deps RouterDeps) http.Handler {
 	mux.HandleFunc("PUT /api/v1/auth-scopes/{id}", auth.update)
 	mux.HandleFunc("DELETE /api/v1/auth-scopes/{id}", auth.delete)
 
-	auditH := &auditHandler{store: deps.Store}
+	auditH := &auditHandler{store: deps.Store, chainStore: deps.Store, signingKey: deps.AuditSigningKey}
 	mux.HandleFunc("GET /api/v1/audit", auditH.query)
+	mux.HandleFunc("GET /api/v1/audit/verify", auditH.verify)
+
+	analytics := &analyticsHandler{store: deps.Store}
+	mux.HandleFunc("GET /api/v1/analytics", analytics.query)
 
 	if deps.AuditBus != nil {
 		sse := &auditSSEHandler{bus: deps.AuditBus}
@@ -72,6 +79,8 @@ func NewRouter(deps RouterDeps) http.Handler {
 	if deps.ApprovalManager != nil {
 		ah := &approvalHandler{manager: deps.ApprovalManager, store: deps.Store}
 		mux.HandleFunc("GET /api/v1/approvals", ah.list)
+		mux.HandleFunc("GET /api/v1/approvals/history", ah.history)
+		mux.HandleFunc("GET /api/v1/approvals/stats", ah.stats)
 		mux.HandleFunc("GET /api/v1/approvals/{id}", ah.get)
 		mux.HandleFunc("POST /api/v1/approvals/{id}/resolve", ah.resolve)
 	}
@@ -82,11 +91,13 @@ func NewRouter(deps RouterDeps) http.Handler {
 	}
 
 	mux.HandleFunc("GET /api/v1/health", healthCheck)
+	mux.Handle("GET /metrics", metrics.Default.Handler())
 
 	dash := &dashboardHandler{
 		sessionStore:    deps.Store,
 		auditStore:      deps.Store,
 		downstreamStore: deps.Store,
+		retentionStore:  deps.Store,
 		manager:         deps.Manager,
 	}
 	mux.HandleFunc("GET /api/v1/dashboard", dash.get)
@@ -104,6 +115,12 @@ func NewRouter(deps RouterDeps) http.Handler {
 	disc := &discoverHandler{manager: deps.Manager, store: deps.Store}
 	mux.HandleFunc("POST /api/v1/downstreams/{id}/discover", disc.discover)
 
+	if deps.Manager != nil {
+		dsr := &downstreamRuntimeHandler{manager: deps.Manager, store: deps.Store}
+		mux.HandleFunc("GET /api/v1/downstreams/{id}/logs", dsr.logs)
+		mux.HandleFunc("GET /api/v1/downstreams/health", dsr.health)
+	}
+
 	if deps.FlowManager != nil {
 		dOAuth := &downstreamOAuthHandler{
 			store:       deps.Store,
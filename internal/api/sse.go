@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/eventbus"
+	"github.com/revitteth/mcplexer/internal/metrics"
+)
+
+// droppedEvent is sent as an SSE "dropped" event when a subscriber missed
+// events. Reset means the resume ID was unknown (e.g. from before a
+// restart), so the number missed is not known.
+type droppedEvent struct {
+	Dropped uint64 `json:"dropped"`
+	Reset   bool   `json:"reset,omitempty"`
+}
+
+// streamEvents serves bus events as server-sent events with their IDs.
+// Clients resume with the Last-Event-ID header (or a last_event_id query
+// parameter, which EventSource cannot set as a header); without one the
+// stream starts with new events. encode returns false to skip an event.
+func streamEvents[T any](w http.ResponseWriter, r *http.Request, bus *eventbus.Bus[T], stream string, encode func(T) ([]byte, bool)) {
+	streamEventsFrom(w, r, bus, stream, lastEventID(r), encode)
+}
+
+// lastEventID returns the event ID the client resumes from, or "".
+func lastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get("last_event_id")
+}
+
+// streamEventsFrom is streamEvents starting after event ID resume instead
+// of the one the client sent; an empty resume starts with new events.
+func streamEventsFrom[T any](
+	w http.ResponseWriter, r *http.Request, bus *eventbus.Bus[T], stream, resume string, encode func(T) ([]byte, bool),
+) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	// Subscribe before reading the cursor so nothing published in between
+	// is missed.
+	sub := bus.Subscribe()
+	defer sub.Close()
+
+	cursor := bus.LastID()
+	if resume != "" {
+		if id, err := strconv.ParseUint(resume, 10, 64); err == nil {
+			cursor = id
+		} else {
+			writeDropped(w, cursor, droppedEvent{Reset: true})
+		}
+	}
+
+	send := func() {
+		events, missed, ok := bus.Since(cursor)
+		if !ok {
+			cursor = bus.LastID()
+			writeDropped(w, cursor, droppedEvent{Reset: true})
+			flusher.Flush()
+			return
+		}
+		if missed > 0 {
+			metrics.StreamEventsDropped.Add(float64(missed), stream)
+			writeDropped(w, cursor+missed, droppedEvent{Dropped: missed})
+		}
+		for _, ev := range events {
+			cursor = ev.ID
+			data, ok := encode(ev.Data)
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", ev.ID, data)
+		}
+		flusher.Flush()
+	}
+	send()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.Ready():
+			send()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ":\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeDropped(w http.ResponseWriter, id uint64, evt droppedEvent) {
+	data, _ := json.Marshal(evt)
+	fmt.Fprintf(w, "event: dropped\nid: %d\ndata: %s\n\n", id, data)
+}
@@ -1,57 +1,25 @@
 package approval
 
 import (
-	"sync"
-
+	"github.com/revitteth/mcplexer/internal/eventbus"
 	"github.com/revitteth/mcplexer/internal/store"
 )
 
+// busReplaySize is how many events a reconnecting SSE subscriber can
+// catch up on.
+const busReplaySize = 256
+
 // ApprovalEvent is published when an approval is created or resolved.
 type ApprovalEvent struct {
 	Type     string              `json:"type"` // "pending" or "resolved"
 	Approval *store.ToolApproval `json:"approval"`
 }
 
-// Bus fans out approval events to SSE subscribers.
-type Bus struct {
-	mu   sync.RWMutex
-	subs map[<-chan ApprovalEvent]chan ApprovalEvent
-}
+// Bus fans out approval events to SSE subscribers, keeping recent events
+// for subscribers resuming with Last-Event-ID.
+type Bus = eventbus.Bus[ApprovalEvent]
 
 // NewBus creates a new approval event bus.
 func NewBus() *Bus {
-	return &Bus{
-		subs: make(map[<-chan ApprovalEvent]chan ApprovalEvent),
-	}
-}
-
-// Subscribe registers a new listener.
-func (b *Bus) Subscribe() <-chan ApprovalEvent {
-	ch := make(chan ApprovalEvent, 64)
-	b.mu.Lock()
-	b.subs[ch] = ch
-	b.mu.Unlock()
-	return ch
-}
-
-// Unsubscribe removes a listener and closes its channel.
-func (b *Bus) Unsubscribe(ch <-chan ApprovalEvent) {
-	b.mu.Lock()
-	if send, ok := b.subs[ch]; ok {
-		delete(b.subs, ch)
-		close(send)
-	}
-	b.mu.Unlock()
-}
-
-// Publish sends an event to all subscribers without blocking.
-func (b *Bus) Publish(evt ApprovalEvent) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-	for _, ch := range b.subs {
-		select {
-		case ch <- evt:
-		default:
-		}
-	}
+	return eventbus.New[ApprovalEvent](busReplaySize)
 }
@@ -5,7 +5,14 @@ import "errors"
 var (
 	// ErrSelfApproval is returned when an agent tries to approve its own request.
 	ErrSelfApproval = errors.New("cannot approve own request")
-
 	// ErrAlreadyResolved is returned when an approval has already been resolved.
 	ErrAlreadyResolved = errors.New("approval already resolved")
+	// ErrAlreadyExecuted is returned when an approved call has already been dispatched.
+	ErrAlreadyExecuted = errors.New("approved call already executed")
+	// ErrShuttingDown is returned to waiters released by Shutdown. The
+	// approval itself stays pending and is re-armed on the next start.
+	ErrShuttingDown = errors.New("approval manager shutting down")
+	// ErrNotArmed is returned by Await for a pending approval the manager
+	// is not tracking, e.g. one whose deadline passed before it was re-armed.
+	ErrNotArmed = errors.New("approval is pending but not armed")
 )
@@ -1,26 +1,68 @@
 package approval
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/revitteth/mcplexer/internal/metrics"
 	"github.com/revitteth/mcplexer/internal/store"
 )
 
+// defaultTimeout applies when an approval has no positive TimeoutSec.
+const defaultTimeout = 300 * time.Second
+
 // resolution carries the outcome of an approval decision.
 type resolution struct {
 	Approved bool
+	Status   string
 	Reason   string
+	Err      error // set when the waiter was released without a decision
+}
+
+// pendingApproval tracks an armed approval until it is resolved. done is
+// closed once res is set, so any number of waiters can observe the outcome.
+// waiters and finished are guarded by Manager.mu.
+type pendingApproval struct {
+	timer     *time.Timer
+	done      chan struct{}
+	res       resolution
+	createdAt time.Time // for metrics.ApprovalWait
+	waiters   int       // callers blocked on done that will dispatch the call
+	finished  bool
+}
+
+// Client identifies a connected MCP session that approved calls can be
+// resumed for.
+type Client struct {
+	SessionID   string
+	WorkspaceID string
+	ClientType  string
+}
+
+// attachedClient is a connected session and the function that runs approved
+// calls for it.
+type attachedClient struct {
+	Client
+	execute func(*store.ToolApproval)
 }
 
 // Manager coordinates tool call approval requests and their resolution.
+// Approvals are durable: the record (including the originating call) lives in
+// the store, and in-memory state only tracks timers and waiters. Approvals
+// left pending by a previous run are re-armed by Restore. Approved calls
+// nobody is waiting for are handed to an attached client to execute.
 type Manager struct {
 	store   store.ToolApprovalStore
 	bus     *Bus
 	mu      sync.Mutex
-	pending map[string]chan resolution // keyed by approval ID
+	pending map[string]*pendingApproval // keyed by approval ID
+	clients map[*attachedClient]struct{}
+	closed  bool
 }
 
 // NewManager creates a new approval manager.
@@ -28,74 +70,211 @@ func NewManager(s store.ToolApprovalStore, bus *Bus) *Manager {
 	return &Manager{
 		store:   s,
 		bus:     bus,
-		pending: make(map[string]chan resolution),
+		pending: make(map[string]*pendingApproval),
+		clients: make(map[*attachedClient]struct{}),
 	}
 }
 
 // RequestApproval persists an approval record and blocks until it is
 // resolved, times out, or the context is cancelled. Returns true if approved.
+// A cancelled context releases the caller but leaves the approval pending:
+// once approved, the call is handed to an attached client (see Attach), or
+// the same call can resume it via FindResumable and Await.
 func (m *Manager) RequestApproval(ctx context.Context, a *store.ToolApproval) (bool, error) {
+	m.mu.Lock()
+	closed := m.closed
+	m.mu.Unlock()
+	if closed {
+		return false, ErrShuttingDown
+	}
+
 	if err := m.store.CreateToolApproval(ctx, a); err != nil {
 		return false, err
 	}
 
-	ch := make(chan resolution, 1)
+	p := m.arm(a, 1)
+	m.publish("pending", a)
+	return m.wait(ctx, p, a)
+}
+
+// Await blocks on an existing approval, typically one found by FindResumable
+// after the requesting client reconnected. Approvals that are already
+// resolved return immediately. A pending approval that is not armed fails
+// with ErrNotArmed, or ErrShuttingDown once Shutdown has run. The outcome is
+// copied into a.
+func (m *Manager) Await(ctx context.Context, a *store.ToolApproval) (bool, error) {
 	m.mu.Lock()
-	m.pending[a.ID] = ch
+	p, ok := m.pending[a.ID]
+	if ok {
+		p.waiters++
+	}
+	closed := m.closed
 	m.mu.Unlock()
+	if ok {
+		return m.wait(ctx, p, a)
+	}
 
-	if m.bus != nil {
-		m.bus.Publish(ApprovalEvent{Type: "pending", Approval: a})
+	// Not armed: either resolved in the meantime or never re-armed.
+	cur, err := m.store.GetToolApproval(ctx, a.ID)
+	if err != nil {
+		return false, err
 	}
+	*a = *cur
+	switch a.Status {
+	case "approved":
+		return true, nil
+	case "pending":
+		if closed {
+			return false, ErrShuttingDown
+		}
+		return false, ErrNotArmed
+	default:
+		return false, nil
+	}
+}
 
-	timeout := time.Duration(a.TimeoutSec) * time.Second
-	if timeout <= 0 {
-		timeout = 300 * time.Second
+// FindResumable returns an earlier approval for the same call as probe that
+// the probe's session may pick up, or nil if there is none. Arguments are
+// compared as canonical JSON, so key order and whitespace do not matter.
+// Pending approvals qualify while armed; approved ones qualify until their
+// timeout has elapsed since the decision. See resumableBy for which
+// sessions qualify.
+func (m *Manager) FindResumable(ctx context.Context, probe *store.ToolApproval) (*store.ToolApproval, error) {
+	candidates, err := m.store.ListResumableApprovals(ctx, probe.WorkspaceID)
+	if err != nil {
+		return nil, err
 	}
-	timer := time.AfterFunc(timeout, func() {
-		m.mu.Lock()
-		if _, ok := m.pending[a.ID]; ok {
-			delete(m.pending, a.ID)
-			m.mu.Unlock()
 
-			if err := m.store.ResolveToolApproval(
-				context.Background(), a.ID, "timeout", "", "system", "timed out",
-			); err != nil {
-				slog.Warn("failed to expire approval", "id", a.ID, "err", err)
+	c := Client{
+		SessionID:   probe.RequestSessionID,
+		WorkspaceID: probe.WorkspaceID,
+		ClientType:  probe.RequestClientType,
+	}
+	args := CanonicalArguments(json.RawMessage(probe.Arguments))
+	now := time.Now()
+	for i := range candidates {
+		a := &candidates[i]
+		if a.ToolName != probe.ToolName || CanonicalArguments(json.RawMessage(a.Arguments)) != args ||
+			!m.resumableBy(a, c) {
+			continue
+		}
+		switch a.Status {
+		case "pending":
+			m.mu.Lock()
+			_, armed := m.pending[a.ID]
+			m.mu.Unlock()
+			if armed {
+				return a, nil
 			}
-			a.Status = "timeout"
-			if m.bus != nil {
-				m.bus.Publish(ApprovalEvent{Type: "resolved", Approval: a})
+		case "approved":
+			if withinWindow(a, now) {
+				return a, nil
 			}
-			ch <- resolution{Approved: false, Reason: "timed out"}
-		} else {
-			m.mu.Unlock()
 		}
-	})
-	defer timer.Stop()
+	}
+	return nil, nil
+}
 
-	select {
-	case res := <-ch:
-		return res.Approved, nil
-	case <-ctx.Done():
+// Attach registers a connected client. Approved calls it may resume that
+// nobody is waiting for are passed to execute, both those already approved
+// and those approved while it stays attached; execute must not block.
+// Call detach when the client disconnects.
+func (m *Manager) Attach(c Client, execute func(*store.ToolApproval)) (detach func()) {
+	ac := &attachedClient{Client: c, execute: execute}
+	m.mu.Lock()
+	m.clients[ac] = struct{}{}
+	m.mu.Unlock()
+
+	go m.offerApproved(ac)
+	return func() {
 		m.mu.Lock()
-		if _, ok := m.pending[a.ID]; ok {
-			delete(m.pending, a.ID)
-			m.mu.Unlock()
-			_ = m.store.ResolveToolApproval(
-				context.Background(), a.ID, "cancelled", "", "system", "client disconnected",
-			)
-			a.Status = "cancelled"
-			if m.bus != nil {
-				m.bus.Publish(ApprovalEvent{Type: "resolved", Approval: a})
-			}
-		} else {
-			m.mu.Unlock()
+		delete(m.clients, ac)
+		m.mu.Unlock()
+	}
+}
+
+// offerApproved passes ac the approved, not yet executed calls it may
+// resume, typically after it reconnected.
+func (m *Manager) offerApproved(ac *attachedClient) {
+	candidates, err := m.store.ListResumableApprovals(context.Background(), ac.WorkspaceID)
+	if err != nil {
+		slog.Warn("failed to list resumable approvals", "err", err)
+		return
+	}
+	now := time.Now()
+	for i := range candidates {
+		a := &candidates[i]
+		if a.Status == "approved" && withinWindow(a, now) && m.resumableBy(a, ac.Client) {
+			ac.execute(a)
 		}
-		return false, ctx.Err()
 	}
 }
 
+// handOff passes an approved call nobody is waiting for to a connected
+// client that may resume it, preferring the session that requested it.
+// Without one the approval stays approved until a client that may resume
+// it attaches or retries the call, or its window ends.
+func (m *Manager) handOff(id string) {
+	a, err := m.store.GetToolApproval(context.Background(), id)
+	if err != nil {
+		slog.Warn("failed to load approved call", "id", id, "err", err)
+		return
+	}
+
+	m.mu.Lock()
+	clients := make([]*attachedClient, 0, len(m.clients))
+	for ac := range m.clients {
+		clients = append(clients, ac)
+	}
+	m.mu.Unlock()
+
+	var chosen *attachedClient
+	for _, ac := range clients {
+		if ac.SessionID == a.RequestSessionID {
+			chosen = ac
+			break
+		}
+		if chosen == nil && m.resumableBy(a, ac.Client) {
+			chosen = ac
+		}
+	}
+	if chosen == nil {
+		slog.Info("approved call waiting for its client to reconnect", "id", id, "tool", a.ToolName)
+		return
+	}
+	chosen.execute(a)
+}
+
+// resumableBy reports whether client c may pick up a: it is the session
+// that requested a, or a later session of the same client (same workspace
+// and client type) once the requesting session is no longer connected.
+func (m *Manager) resumableBy(a *store.ToolApproval, c Client) bool {
+	if a.RequestSessionID == c.SessionID {
+		return true
+	}
+	if a.WorkspaceID == "" || a.WorkspaceID != c.WorkspaceID || a.RequestClientType != c.ClientType {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ac := range m.clients {
+		if ac.SessionID == a.RequestSessionID {
+			return false
+		}
+	}
+	return true
+}
+
+// ClaimExecution marks an approved call as dispatched. It fails with
+// ErrAlreadyExecuted if another session already used the approval.
+func (m *Manager) ClaimExecution(ctx context.Context, id string) error {
+	err := m.store.MarkToolApprovalExecuted(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrAlreadyExecuted
+	}
+	return err
+}
+
 // Resolve approves or denies a pending approval. It validates that the
 // approver is not the same session as the requester (self-approval prevention).
 func (m *Manager) Resolve(
@@ -126,27 +305,16 @@ func (m *Manager) Resolve(
 		return err
 	}
 
+	now := time.Now().UTC()
 	a.Status = status
 	a.ApproverSessionID = approverSessionID
 	a.ApproverType = approverType
 	a.Resolution = reason
+	a.ResolvedAt = &now
 
-	// Signal the blocked goroutine.
-	m.mu.Lock()
-	ch, ok := m.pending[id]
-	if ok {
-		delete(m.pending, id)
-	}
-	m.mu.Unlock()
-
-	if ok {
-		ch <- resolution{Approved: approved, Reason: reason}
-	}
-
-	if m.bus != nil {
-		m.bus.Publish(ApprovalEvent{Type: "resolved", Approval: a})
-	}
-
+	// Signal any blocked waiters.
+	m.finish(id, resolution{Approved: approved, Status: status, Reason: reason})
+	m.publish("resolved", a)
 	return nil
 }
 
@@ -177,30 +345,190 @@ func (m *Manager) ListPending(excludeSessionID string) []*store.ToolApproval {
 	return out
 }
 
-// Shutdown resolves all in-memory pending approvals as cancelled.
+// Shutdown releases all waiters with ErrShuttingDown. Pending approvals are
+// left pending in the store so Restore can re-arm them on the next start.
 func (m *Manager) Shutdown() {
 	m.mu.Lock()
+	m.closed = true
 	pending := m.pending
-	m.pending = make(map[string]chan resolution)
+	m.pending = make(map[string]*pendingApproval)
+	for _, p := range pending {
+		p.res = resolution{Status: "pending", Err: ErrShuttingDown}
+		p.finished = true
+	}
 	m.mu.Unlock()
 
-	for id, ch := range pending {
-		_ = m.store.ResolveToolApproval(
-			context.Background(), id, "cancelled", "", "system", "server shutdown",
-		)
-		ch <- resolution{Approved: false, Reason: "server shutdown"}
+	for _, p := range pending {
+		p.timer.Stop()
+		close(p.done)
 	}
 }
 
-// ExpireStale marks orphaned pending approvals in the DB (from previous runs)
-// as expired, so they don't accumulate.
-func (m *Manager) ExpireStale(ctx context.Context) {
-	n, err := m.store.ExpirePendingApprovals(ctx, time.Now().UTC())
+// Restore re-arms approvals left pending by a previous run. Timeouts are
+// recomputed from CreatedAt, so time spent while the daemon was down counts
+// against them; approvals already past their deadline are expired.
+func (m *Manager) Restore(ctx context.Context) {
+	pending, err := m.store.ListPendingApprovals(ctx)
 	if err != nil {
-		slog.Warn("failed to expire stale approvals", "err", err)
+		slog.Warn("failed to load pending approvals", "err", err)
 		return
 	}
-	if n > 0 {
-		slog.Info("expired stale approvals from previous run", "count", n)
+
+	now := time.Now()
+	var rearmed, expired int
+	for i := range pending {
+		a := &pending[i]
+		if !deadlineOf(a).After(now) {
+			if err := m.store.ResolveToolApproval(
+				ctx, a.ID, "timeout", "", "system", "timed out",
+			); err != nil {
+				slog.Warn("failed to expire approval", "id", a.ID, "err", err)
+				continue
+			}
+			expired++
+			continue
+		}
+		m.arm(a, 0)
+		rearmed++
+	}
+	if rearmed > 0 || expired > 0 {
+		slog.Info("restored approvals from previous run",
+			"rearmed", rearmed, "expired", expired)
+	}
+}
+
+// arm registers a pending approval with the given number of waiters and
+// starts its timeout timer.
+func (m *Manager) arm(a *store.ToolApproval, waiters int) *pendingApproval {
+	id := a.ID
+	p := &pendingApproval{done: make(chan struct{}), createdAt: a.CreatedAt, waiters: waiters}
+
+	m.mu.Lock()
+	m.pending[id] = p
+	p.timer = time.AfterFunc(time.Until(deadlineOf(a)), func() { m.expire(id) })
+	m.mu.Unlock()
+	return p
+}
+
+// expire resolves an approval as timed out once its deadline passes.
+func (m *Manager) expire(id string) {
+	m.mu.Lock()
+	_, ok := m.pending[id]
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if err := m.store.ResolveToolApproval(
+		context.Background(), id, "timeout", "", "system", "timed out",
+	); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return // resolved concurrently; Resolve wakes the waiters
+		}
+		slog.Warn("failed to expire approval", "id", id, "err", err)
+	}
+	if !m.finish(id, resolution{Status: "timeout", Reason: "timed out"}) {
+		return
 	}
+	if a, err := m.store.GetToolApproval(context.Background(), id); err == nil {
+		m.publish("resolved", a)
+	}
+}
+
+// finish removes an armed approval and wakes its waiters. An approved call
+// without waiters is handed off for execution. Returns false if the
+// approval was not armed (already finished or never re-armed).
+func (m *Manager) finish(id string, res resolution) bool {
+	m.mu.Lock()
+	p, ok := m.pending[id]
+	if ok {
+		delete(m.pending, id)
+		p.res = res
+		p.finished = true
+	}
+	unattended := ok && res.Approved && p.waiters == 0
+	m.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	p.timer.Stop()
+	close(p.done)
+	if unattended {
+		go m.handOff(id)
+	}
+	if res.Err == nil && !p.createdAt.IsZero() {
+		metrics.ApprovalWait.ObserveDuration(time.Since(p.createdAt), res.Status)
+	}
+	return true
+}
+
+// wait blocks until p is resolved or ctx is done, copying the outcome into a.
+// The caller must be counted in p.waiters. The last waiter to give up on a
+// call that was approved meanwhile hands it off for execution.
+func (m *Manager) wait(ctx context.Context, p *pendingApproval, a *store.ToolApproval) (bool, error) {
+	select {
+	case <-p.done:
+		if p.res.Err != nil {
+			return false, p.res.Err
+		}
+		a.Status = p.res.Status
+		a.Resolution = p.res.Reason
+		return p.res.Approved, nil
+	case <-ctx.Done():
+		m.mu.Lock()
+		p.waiters--
+		unattended := p.finished && p.res.Approved && p.waiters == 0
+		m.mu.Unlock()
+		if unattended {
+			go m.handOff(a.ID)
+		}
+		return false, ctx.Err()
+	}
+}
+
+func (m *Manager) publish(typ string, a *store.ToolApproval) {
+	if m.bus != nil {
+		m.bus.Publish(ApprovalEvent{Type: typ, Approval: a})
+	}
+}
+
+// withinWindow reports whether an approved call may still be executed: its
+// timeout has not elapsed since the decision.
+func withinWindow(a *store.ToolApproval, now time.Time) bool {
+	return a.ResolvedAt != nil && a.ResolvedAt.Add(timeoutOf(a)).After(now)
+}
+
+// CanonicalArguments returns tool call arguments as compact JSON with
+// object keys sorted, so equal arguments compare equal however the client
+// encoded them. Invalid JSON is returned unchanged.
+func CanonicalArguments(args json.RawMessage) string {
+	if len(args) == 0 {
+		return "{}"
+	}
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return string(args)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return string(args)
+	}
+	return string(out)
+}
+
+// timeoutOf returns the approval's decision window.
+func timeoutOf(a *store.ToolApproval) time.Duration {
+	if a.TimeoutSec <= 0 {
+		return defaultTimeout
+	}
+	return time.Duration(a.TimeoutSec) * time.Second
+}
+
+// deadlineOf returns when a pending approval times out, measured from
+// CreatedAt so the deadline is stable across restarts.
+func deadlineOf(a *store.ToolApproval) time.Time {
+	return a.CreatedAt.Add(timeoutOf(a))
 }
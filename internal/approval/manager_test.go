@@ -2,6 +2,7 @@ package approval
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -79,21 +80,43 @@ func (m *memStore) ResolveToolApproval(_ context.Context, id, status, approverSI
 	return nil
 }
 
-func (m *memStore) ExpirePendingApprovals(_ context.Context, before time.Time) (int, error) {
+func (m *memStore) ListResumableApprovals(_ context.Context, workspaceID string) ([]store.ToolApproval, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	n := 0
+	var out []store.ToolApproval
 	for _, a := range m.approvals {
-		if a.Status == "pending" && a.CreatedAt.Before(before) {
-			a.Status = "timeout"
-			now := time.Now().UTC()
-			a.ResolvedAt = &now
-			n++
+		if a.WorkspaceID != workspaceID {
+			continue
+		}
+		if (a.Status == "pending" || a.Status == "approved") && a.ExecutedAt == nil {
+			out = append(out, *a)
 		}
 	}
-	return n, nil
+	return out, nil
+}
+
+func (m *memStore) MarkToolApprovalExecuted(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.approvals[id]
+	if !ok || a.Status != "approved" || a.ExecutedAt != nil {
+		return store.ErrNotFound
+	}
+	now := time.Now().UTC()
+	a.ExecutedAt = &now
+	return nil
 }
 
+func (m *memStore) QueryToolApprovals(context.Context, store.ApprovalFilter) ([]store.ToolApproval, int, error) {
+	return nil, 0, nil
+}
+
+func (m *memStore) GetApprovalStats(context.Context, store.ApprovalFilter) (*store.ApprovalStats, error) {
+	return &store.ApprovalStats{}, nil
+}
+
+func (m *memStore) LinkApprovalAuditRecord(context.Context, string, string) error { return nil }
+
 func TestRequestApproval_Approved(t *testing.T) {
 	s := newMemStore()
 	bus := NewBus()
@@ -230,11 +253,14 @@ func TestRequestApproval_ContextCancelled(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	a := &store.ToolApproval{
-		ID:               uuid.NewString(),
-		RequestSessionID: "session-1",
-		ToolName:         "github__create_issue",
-		Justification:    "test",
-		TimeoutSec:       60,
+		ID:                uuid.NewString(),
+		RequestSessionID:  "session-1",
+		RequestClientType: "claude-code",
+		WorkspaceID:       "ws-1",
+		ToolName:          "github__create_issue",
+		Arguments:         `{"title":"bug"}`,
+		Justification:     "test",
+		TimeoutSec:        60,
 	}
 
 	done := make(chan struct{})
@@ -247,9 +273,156 @@ func TestRequestApproval_ContextCancelled(t *testing.T) {
 	cancel()
 	<-done
 
+	// The approval outlives its waiter so a reconnecting client can resume it.
 	rec, _ := s.GetToolApproval(context.Background(), a.ID)
-	if rec.Status != "cancelled" {
-		t.Errorf("status = %q, want cancelled", rec.Status)
+	if rec.Status != "pending" {
+		t.Errorf("status = %q, want pending", rec.Status)
+	}
+
+	probe := &store.ToolApproval{
+		RequestSessionID:  "session-9",
+		RequestClientType: "claude-code",
+		WorkspaceID:       "ws-1",
+		ToolName:          "github__create_issue",
+		Arguments:         `{ "title": "bug" }`,
+	}
+	// Another session of the same client cannot pick it up while the
+	// requesting session is connected...
+	detach := mgr.Attach(Client{SessionID: "session-1", WorkspaceID: "ws-1", ClientType: "claude-code"},
+		func(*store.ToolApproval) {})
+	if foreign, err := mgr.FindResumable(context.Background(), probe); err != nil || foreign != nil {
+		t.Fatalf("FindResumable from a foreign session = %+v, %v; want nil", foreign, err)
+	}
+	detach()
+	// ...nor can a different client.
+	other := *probe
+	other.RequestClientType = "cursor"
+	if foreign, err := mgr.FindResumable(context.Background(), &other); err != nil || foreign != nil {
+		t.Fatalf("FindResumable from another client = %+v, %v; want nil", foreign, err)
+	}
+	// Once it is gone, the reconnected client resumes it.
+	resumed, err := mgr.FindResumable(context.Background(), probe)
+	if err != nil {
+		t.Fatalf("FindResumable: %v", err)
+	}
+	if resumed == nil || resumed.ID != a.ID {
+		t.Fatalf("FindResumable = %+v, want %s", resumed, a.ID)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		mgr.Resolve(a.ID, "", "dashboard", "ok", true) //nolint:errcheck
+	}()
+	approved, err := mgr.Await(context.Background(), resumed)
+	if err != nil || !approved {
+		t.Fatalf("Await = %v, %v; want approved", approved, err)
+	}
+
+	if err := mgr.ClaimExecution(context.Background(), a.ID); err != nil {
+		t.Fatalf("ClaimExecution: %v", err)
+	}
+	if err := mgr.ClaimExecution(context.Background(), a.ID); err != ErrAlreadyExecuted {
+		t.Errorf("second ClaimExecution = %v, want ErrAlreadyExecuted", err)
+	}
+}
+
+func TestApprovedCallHandedToAttachedClient(t *testing.T) {
+	s := newMemStore()
+	mgr := NewManager(s, NewBus())
+	defer mgr.Shutdown()
+
+	executed := make(chan *store.ToolApproval, 2)
+	c := Client{SessionID: "session-1", WorkspaceID: "ws-1", ClientType: "claude-code"}
+	defer mgr.Attach(c, func(a *store.ToolApproval) { executed <- a })()
+
+	// The tools/call gives up waiting but the session stays connected.
+	a := &store.ToolApproval{
+		ID:                uuid.NewString(),
+		RequestSessionID:  "session-1",
+		RequestClientType: "claude-code",
+		WorkspaceID:       "ws-1",
+		ToolName:          "github__create_issue",
+		TimeoutSec:        60,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := mgr.RequestApproval(ctx, a); err == nil {
+		t.Fatal("RequestApproval returned before a decision")
+	}
+
+	if err := mgr.Resolve(a.ID, "", "dashboard", "ok", true); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	select {
+	case got := <-executed:
+		if got.ID != a.ID {
+			t.Fatalf("executed %s, want %s", got.ID, a.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("approved call not handed to the connected session")
+	}
+}
+
+func TestAttachOffersApprovedCalls(t *testing.T) {
+	s := newMemStore()
+	mgr := NewManager(s, NewBus())
+	defer mgr.Shutdown()
+
+	// Approved after the requesting session (and the daemon) went away.
+	a := &store.ToolApproval{
+		RequestSessionID:  "session-1",
+		RequestClientType: "claude-code",
+		WorkspaceID:       "ws-1",
+		ToolName:          "github__create_issue",
+		TimeoutSec:        60,
+	}
+	if err := s.CreateToolApproval(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	mgr.Restore(context.Background())
+	if err := mgr.Resolve(a.ID, "", "dashboard", "ok", true); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+
+	executed := make(chan string, 2)
+	other := Client{SessionID: "session-2", WorkspaceID: "ws-1", ClientType: "cursor"}
+	defer mgr.Attach(other, func(a *store.ToolApproval) { executed <- "other:" + a.ID })()
+	same := Client{SessionID: "session-3", WorkspaceID: "ws-1", ClientType: "claude-code"}
+	defer mgr.Attach(same, func(a *store.ToolApproval) { executed <- "same:" + a.ID })()
+
+	select {
+	case got := <-executed:
+		if got != "same:"+a.ID {
+			t.Fatalf("executed %s, want it for the reconnected client", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("approved call not offered to the reconnected client")
+	}
+	// The hand-off on approval may offer it again; the executor's claim
+	// keeps that to one run. Another client never gets it.
+	select {
+	case got := <-executed:
+		if got != "same:"+a.ID {
+			t.Fatalf("executed %s", got)
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAwaitNotArmed(t *testing.T) {
+	s := newMemStore()
+	mgr := NewManager(s, NewBus())
+
+	a := &store.ToolApproval{WorkspaceID: "ws-1", ToolName: "github__create_issue", TimeoutSec: 60}
+	if err := s.CreateToolApproval(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.Await(context.Background(), a); !errors.Is(err, ErrNotArmed) {
+		t.Fatalf("Await on an unarmed approval = %v, want ErrNotArmed", err)
+	}
+	mgr.Shutdown()
+	if _, err := mgr.Await(context.Background(), a); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("Await after Shutdown = %v, want ErrShuttingDown", err)
 	}
 }
 
@@ -335,7 +508,7 @@ func TestListPending_ExcludesSelf(t *testing.T) {
 	mgr.Shutdown()
 }
 
-func TestShutdown_CancelsPending(t *testing.T) {
+func TestShutdown_KeepsPending(t *testing.T) {
 	s := newMemStore()
 	mgr := NewManager(s, NewBus())
 
@@ -349,8 +522,9 @@ func TestShutdown_CancelsPending(t *testing.T) {
 
 	done := make(chan struct{})
 	var approved bool
+	var err error
 	go func() {
-		approved, _ = mgr.RequestApproval(context.Background(), a)
+		approved, err = mgr.RequestApproval(context.Background(), a)
 		close(done)
 	}()
 
@@ -361,9 +535,57 @@ func TestShutdown_CancelsPending(t *testing.T) {
 	if approved {
 		t.Error("expected approved=false after shutdown")
 	}
+	if err != ErrShuttingDown {
+		t.Errorf("err = %v, want ErrShuttingDown", err)
+	}
 
 	rec, _ := s.GetToolApproval(context.Background(), a.ID)
-	if rec.Status != "cancelled" {
-		t.Errorf("status = %q, want cancelled", rec.Status)
+	if rec.Status != "pending" {
+		t.Errorf("status = %q, want pending", rec.Status)
+	}
+}
+
+func TestRestore_RearmsAndExpires(t *testing.T) {
+	s := newMemStore()
+	ctx := context.Background()
+
+	// One approval still inside its window, one that expired while down.
+	live := &store.ToolApproval{
+		RequestSessionID: "session-1",
+		ToolName:         "github__create_issue",
+		TimeoutSec:       60,
+		CreatedAt:        time.Now().UTC().Add(-30 * time.Second),
+	}
+	stale := &store.ToolApproval{
+		RequestSessionID: "session-1",
+		ToolName:         "github__delete_repo",
+		TimeoutSec:       60,
+		CreatedAt:        time.Now().UTC().Add(-2 * time.Minute),
+	}
+	for _, a := range []*store.ToolApproval{live, stale} {
+		if err := s.CreateToolApproval(ctx, a); err != nil {
+			t.Fatal(err)
+		}
 	}
+
+	mgr := NewManager(s, NewBus())
+	mgr.Restore(ctx)
+
+	pending := mgr.ListPending("")
+	if len(pending) != 1 || pending[0].ID != live.ID {
+		t.Fatalf("pending = %+v, want only %s", pending, live.ID)
+	}
+	rec, _ := s.GetToolApproval(ctx, stale.ID)
+	if rec.Status != "timeout" {
+		t.Errorf("stale status = %q, want timeout", rec.Status)
+	}
+
+	// A restored approval can be resolved like any other.
+	if err := mgr.Resolve(live.ID, "", "dashboard", "ok", true); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(mgr.ListPending("")) != 0 {
+		t.Error("expected no pending approvals after resolve")
+	}
+	mgr.Shutdown()
 }
@@ -0,0 +1,325 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// Chain problem kinds reported by VerifyChain.
+const (
+	ProblemEdited             = "edited"              // record content does not match its hash
+	ProblemBrokenLink         = "broken_link"         // prev_hash does not match the predecessor (reordered or replaced)
+	ProblemGap                = "gap"                 // sequence numbers missing without a retention record
+	ProblemTruncated          = "truncated"           // records missing at the end of the chain
+	ProblemHeadMismatch       = "head_mismatch"       // chain head disagrees with the records
+	ProblemCheckpointMismatch = "checkpoint_mismatch" // signed hash differs from the record or no signed gap covers it
+	ProblemBadSignature       = "bad_signature"       // checkpoint or gap signature missing, invalid or from another key
+)
+
+// maxChainProblems caps the problems listed in a report.
+const maxChainProblems = 100
+
+// chainPageSize is the number of records read per verification step.
+const chainPageSize = 1000
+
+// ChainProblem is one integrity violation found by VerifyChain.
+type ChainProblem struct {
+	Kind     string `json:"kind"`
+	Seq      int64  `json:"seq"`
+	RecordID string `json:"record_id,omitempty"`
+	Detail   string `json:"detail"`
+}
+
+// ChainReport is the outcome of verifying the audit hash chain.
+type ChainReport struct {
+	OK                  bool                   `json:"ok"`
+	VerifiedAt          time.Time              `json:"verified_at"`
+	HeadSeq             int64                  `json:"head_seq"`
+	Records             int                    `json:"records"`
+	Pruned              int64                  `json:"pruned"` // removed by retention
+	Checkpoints         int                    `json:"checkpoints"`
+	VerifiedCheckpoints int                    `json:"verified_checkpoints"` // signature checked
+	LatestCheckpoint    *store.AuditCheckpoint `json:"latest_checkpoint,omitempty"`
+	SignatureKeyMissing bool                   `json:"signature_key_missing,omitempty"`
+	Problems            []ChainProblem         `json:"problems"`
+	ProblemsTruncated   bool                   `json:"problems_truncated,omitempty"`
+}
+
+func (r *ChainReport) add(p ChainProblem) {
+	if len(r.Problems) >= maxChainProblems {
+		r.ProblemsTruncated = true
+		return
+	}
+	r.Problems = append(r.Problems, p)
+}
+
+// CheckpointMessage is the byte string signed for a checkpoint.
+func CheckpointMessage(seq int64, hash string, createdAt time.Time) []byte {
+	return fmt.Appendf(nil, "mcplexer-audit-checkpoint/v1\n%d\n%s\n%s",
+		seq, hash, createdAt.UTC().Format(time.RFC3339))
+}
+
+// GapMessage is the byte string signed for a range pruned by retention.
+func GapMessage(g *store.AuditChainGap) []byte {
+	return fmt.Appendf(nil, "mcplexer-audit-gap/v1\n%d\n%d\n%s\n%s\n%s",
+		g.FirstSeq, g.LastSeq, g.PrevHash, g.LastHash, g.PrunedAt.UTC().Format(time.RFC3339))
+}
+
+// GapSigner returns a store.AuditGapSigner that signs pruned ranges with key.
+func GapSigner(key ed25519.PrivateKey) store.AuditGapSigner {
+	pub := base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	return func(g *store.AuditChainGap) {
+		g.PublicKey = pub
+		g.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, GapMessage(g)))
+	}
+}
+
+// VerifyChain walks the audit chain in sequence order, recomputing every
+// record hash and checking each link, the chain head and the signed
+// checkpoints. Runs removed by retention are bridged using their recorded
+// boundary hashes and must be signed; a checkpoint inside one is accepted
+// only if a correctly signed gap covers it. A nil pub skips signature checks.
+func VerifyChain(ctx context.Context, s store.AuditChainStore, pub ed25519.PublicKey) (*ChainReport, error) {
+	rep := &ChainReport{VerifiedAt: time.Now().UTC(), Problems: []ChainProblem{}}
+
+	head, err := s.GetAuditChainHead(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get chain head: %w", err)
+	}
+	rep.HeadSeq = head.Seq
+
+	gaps, err := s.ListAuditChainGaps(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list chain gaps: %w", err)
+	}
+	checkpoints, err := s.ListAuditCheckpoints(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list checkpoints: %w", err)
+	}
+	wantHash := make(map[int64]string, len(checkpoints)) // checkpoint seq -> hash seen
+	for _, c := range checkpoints {
+		wantHash[c.Seq] = ""
+	}
+
+	var pubB64 string
+	if pub != nil {
+		pubB64 = base64.StdEncoding.EncodeToString(pub)
+	} else {
+		rep.SignatureKeyMissing = true
+	}
+	// signed reports whether g was signed by pub, recording a problem if not.
+	signed := func(g *store.AuditChainGap) bool {
+		if pub == nil {
+			return true
+		}
+		sig, err := base64.StdEncoding.DecodeString(g.Signature)
+		switch {
+		case g.Signature == "":
+			rep.add(ChainProblem{Kind: ProblemBadSignature, Seq: g.FirstSeq,
+				Detail: fmt.Sprintf("pruned range %d-%d is not signed", g.FirstSeq, g.LastSeq)})
+		case g.PublicKey != pubB64:
+			rep.add(ChainProblem{Kind: ProblemBadSignature, Seq: g.FirstSeq,
+				Detail: fmt.Sprintf("pruned range %d-%d was signed by a different key", g.FirstSeq, g.LastSeq)})
+		case err != nil || !ed25519.Verify(pub, GapMessage(g), sig):
+			rep.add(ChainProblem{Kind: ProblemBadSignature, Seq: g.FirstSeq,
+				Detail: fmt.Sprintf("pruned range %d-%d has an invalid signature", g.FirstSeq, g.LastSeq)})
+		default:
+			return true
+		}
+		return false
+	}
+
+	var lastSeq int64
+	var lastHash string
+	var covered []store.AuditChainGap // bridged gaps with a valid signature
+	gi := 0
+	// bridge consumes retention gaps that continue the chain before seq.
+	bridge := func(seq int64) {
+		for gi < len(gaps) && gaps[gi].FirstSeq == lastSeq+1 && gaps[gi].LastSeq < seq {
+			g := gaps[gi]
+			if g.PrevHash != lastHash {
+				rep.add(ChainProblem{Kind: ProblemBrokenLink, Seq: g.FirstSeq,
+					Detail: fmt.Sprintf("pruned range %d-%d does not link to its predecessor", g.FirstSeq, g.LastSeq)})
+			}
+			if signed(&g) {
+				covered = append(covered, g)
+			}
+			rep.Pruned += g.LastSeq - g.FirstSeq + 1
+			lastSeq, lastHash = g.LastSeq, g.LastHash
+			if _, ok := wantHash[lastSeq]; ok {
+				wantHash[lastSeq] = lastHash
+			}
+			gi++
+		}
+	}
+
+	for {
+		recs, err := s.ListAuditChain(ctx, lastSeq, chainPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("list chain: %w", err)
+		}
+		if len(recs) == 0 {
+			break
+		}
+		for i := range recs {
+			r := &recs[i]
+			bridge(r.Seq)
+			switch {
+			case r.Seq != lastSeq+1:
+				rep.add(ChainProblem{Kind: ProblemGap, Seq: lastSeq + 1, RecordID: r.ID,
+					Detail: fmt.Sprintf("records %d-%d are missing", lastSeq+1, r.Seq-1)})
+			case r.PrevHash != lastHash:
+				rep.add(ChainProblem{Kind: ProblemBrokenLink, Seq: r.Seq, RecordID: r.ID,
+					Detail: "prev_hash does not match the preceding record"})
+			}
+			if store.AuditRecordHash(r) != r.Hash {
+				rep.add(ChainProblem{Kind: ProblemEdited, Seq: r.Seq, RecordID: r.ID,
+					Detail: "record content does not match its hash"})
+			}
+			// Continue from the stored hash so one edit is reported once.
+			lastSeq, lastHash = r.Seq, r.Hash
+			rep.Records++
+			if _, ok := wantHash[r.Seq]; ok {
+				wantHash[r.Seq] = r.Hash
+			}
+		}
+	}
+	bridge(head.Seq + 1)
+	for ; gi < len(gaps); gi++ {
+		g := gaps[gi]
+		rep.add(ChainProblem{Kind: ProblemBrokenLink, Seq: g.FirstSeq,
+			Detail: fmt.Sprintf("pruned range %d-%d does not fit the chain", g.FirstSeq, g.LastSeq)})
+	}
+
+	switch {
+	case lastSeq < head.Seq:
+		rep.add(ChainProblem{Kind: ProblemTruncated, Seq: lastSeq + 1,
+			Detail: fmt.Sprintf("records %d-%d are missing at the end of the chain", lastSeq+1, head.Seq)})
+	case lastSeq > head.Seq || lastHash != head.Hash:
+		rep.add(ChainProblem{Kind: ProblemHeadMismatch, Seq: head.Seq,
+			Detail: fmt.Sprintf("chain head (seq %d) does not match the last record (seq %d)", head.Seq, lastSeq)})
+	}
+
+	for i := range checkpoints {
+		c := &checkpoints[i]
+		rep.Checkpoints++
+		rep.LatestCheckpoint = c
+		if pub != nil {
+			sig, err := base64.StdEncoding.DecodeString(c.Signature)
+			switch {
+			case c.PublicKey != pubB64:
+				rep.add(ChainProblem{Kind: ProblemBadSignature, Seq: c.Seq,
+					Detail: "checkpoint was signed by a different key"})
+			case err != nil || !ed25519.Verify(pub, CheckpointMessage(c.Seq, c.Hash, c.CreatedAt), sig):
+				rep.add(ChainProblem{Kind: ProblemBadSignature, Seq: c.Seq,
+					Detail: "checkpoint signature is invalid"})
+			default:
+				rep.VerifiedCheckpoints++
+			}
+		}
+		if c.Seq > lastSeq {
+			rep.add(ChainProblem{Kind: ProblemTruncated, Seq: c.Seq,
+				Detail: fmt.Sprintf("checkpoint at seq %d is beyond the end of the chain (seq %d)", c.Seq, lastSeq)})
+			continue
+		}
+		switch h := wantHash[c.Seq]; {
+		case h == "":
+			// No hash seen: the checkpoint falls inside a pruned range,
+			// which must be signed and reach at least to its seq.
+			if !coveredBy(covered, c.Seq) {
+				rep.add(ChainProblem{Kind: ProblemCheckpointMismatch, Seq: c.Seq,
+					Detail: fmt.Sprintf("checkpoint at seq %d is not covered by a record or a signed pruned range", c.Seq)})
+			}
+		case h != c.Hash:
+			rep.add(ChainProblem{Kind: ProblemCheckpointMismatch, Seq: c.Seq,
+				Detail: "record hash differs from the signed checkpoint"})
+		}
+	}
+
+	rep.OK = len(rep.Problems) == 0
+	return rep, nil
+}
+
+// coveredBy reports whether one of gaps contains seq.
+func coveredBy(gaps []store.AuditChainGap, seq int64) bool {
+	for _, g := range gaps {
+		if g.FirstSeq <= seq && seq <= g.LastSeq {
+			return true
+		}
+	}
+	return false
+}
+
+// Checkpointer periodically signs the audit chain head.
+type Checkpointer struct {
+	store    store.AuditChainStore
+	key      ed25519.PrivateKey
+	interval time.Duration
+}
+
+// NewCheckpointer creates a Checkpointer that signs with key.
+func NewCheckpointer(s store.AuditChainStore, key ed25519.PrivateKey, interval time.Duration) *Checkpointer {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	return &Checkpointer{store: s, key: key, interval: interval}
+}
+
+// Run checkpoints on every interval until ctx is done, then once more so the
+// final head is covered.
+func (c *Checkpointer) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if _, err := c.Checkpoint(context.Background()); err != nil {
+				slog.Warn("final audit checkpoint failed", "err", err)
+			}
+			return
+		case <-ticker.C:
+			if _, err := c.Checkpoint(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("audit checkpoint failed", "err", err)
+			}
+		}
+	}
+}
+
+// Checkpoint signs the current chain head. Returns nil when the chain is
+// empty or the head is already checkpointed.
+func (c *Checkpointer) Checkpoint(ctx context.Context) (*store.AuditCheckpoint, error) {
+	head, err := c.store.GetAuditChainHead(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get chain head: %w", err)
+	}
+	if head.Seq == 0 {
+		return nil, nil
+	}
+	latest, err := c.store.GetLatestAuditCheckpoint(ctx)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("get latest checkpoint: %w", err)
+	}
+	if latest != nil && latest.Seq == head.Seq {
+		return nil, nil
+	}
+
+	cp := &store.AuditCheckpoint{
+		Seq:       head.Seq,
+		Hash:      head.Hash,
+		PublicKey: base64.StdEncoding.EncodeToString(c.key.Public().(ed25519.PublicKey)),
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	cp.Signature = base64.StdEncoding.EncodeToString(
+		ed25519.Sign(c.key, CheckpointMessage(cp.Seq, cp.Hash, cp.CreatedAt)))
+	if err := c.store.CreateAuditCheckpoint(ctx, cp); err != nil {
+		return nil, fmt.Errorf("create checkpoint: %w", err)
+	}
+	return cp, nil
+}
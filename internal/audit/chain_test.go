@@ -0,0 +1,250 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// memChain is an in-memory store.AuditChainStore.
+type memChain struct {
+	recs        []store.AuditRecord
+	head        store.AuditChainHead
+	gaps        []store.AuditChainGap
+	checkpoints []store.AuditCheckpoint
+	signGap     store.AuditGapSigner // signs gaps recorded by prune
+}
+
+func newMemChain(n int) *memChain {
+	c := &memChain{}
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		r := store.AuditRecord{
+			ID:        fmt.Sprintf("r%d", i+1),
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			ToolName:  "github__create_issue",
+			Status:    "success",
+			Seq:       c.head.Seq + 1,
+			PrevHash:  c.head.Hash,
+			CreatedAt: base,
+		}
+		r.Hash = store.AuditRecordHash(&r)
+		c.head = store.AuditChainHead{Seq: r.Seq, Hash: r.Hash}
+		c.recs = append(c.recs, r)
+	}
+	return c
+}
+
+func (c *memChain) GetAuditChainHead(context.Context) (*store.AuditChainHead, error) {
+	h := c.head
+	return &h, nil
+}
+
+func (c *memChain) ListAuditChain(_ context.Context, afterSeq int64, limit int) ([]store.AuditRecord, error) {
+	var out []store.AuditRecord
+	for _, r := range c.recs {
+		if r.Seq > afterSeq && len(out) < limit {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (c *memChain) ListAuditChainGaps(context.Context) ([]store.AuditChainGap, error) {
+	return c.gaps, nil
+}
+
+func (c *memChain) CreateAuditCheckpoint(_ context.Context, cp *store.AuditCheckpoint) error {
+	c.checkpoints = append(c.checkpoints, *cp)
+	return nil
+}
+
+func (c *memChain) GetLatestAuditCheckpoint(context.Context) (*store.AuditCheckpoint, error) {
+	if len(c.checkpoints) == 0 {
+		return nil, store.ErrNotFound
+	}
+	cp := c.checkpoints[len(c.checkpoints)-1]
+	return &cp, nil
+}
+
+func (c *memChain) ListAuditCheckpoints(context.Context) ([]store.AuditCheckpoint, error) {
+	return c.checkpoints, nil
+}
+
+// prune removes records [i, j) and records the gap as the store does.
+func (c *memChain) prune(i, j int) {
+	g := store.AuditChainGap{
+		FirstSeq: c.recs[i].Seq,
+		LastSeq:  c.recs[j-1].Seq,
+		PrevHash: c.recs[i].PrevHash,
+		LastHash: c.recs[j-1].Hash,
+		PrunedAt: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if c.signGap != nil {
+		c.signGap(&g)
+	}
+	c.gaps = append(c.gaps, g)
+	c.recs = append(c.recs[:i:i], c.recs[j:]...)
+}
+
+func problemKinds(r *ChainReport) []string {
+	var kinds []string
+	for _, p := range r.Problems {
+		kinds = append(kinds, p.Kind)
+	}
+	return kinds
+}
+
+func TestVerifyChain(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		tamper func(c *memChain)
+		want   []string
+	}{
+		{"intact", func(*memChain) {}, nil},
+		{"pruned by retention", func(c *memChain) { c.prune(1, 3) }, nil},
+		{"edited", func(c *memChain) { c.recs[2].Status = "error" }, []string{ProblemEdited}},
+		{"rehashed edit", func(c *memChain) {
+			c.recs[2].Status = "error"
+			c.recs[2].Hash = store.AuditRecordHash(&c.recs[2])
+		}, []string{ProblemBrokenLink}},
+		{"deleted", func(c *memChain) { c.recs = append(c.recs[:2], c.recs[3:]...) }, []string{ProblemGap}},
+		{"reordered", func(c *memChain) {
+			c.recs[1].Seq, c.recs[2].Seq = c.recs[2].Seq, c.recs[1].Seq
+			c.recs[1], c.recs[2] = c.recs[2], c.recs[1]
+		}, []string{ProblemBrokenLink, ProblemEdited, ProblemBrokenLink, ProblemEdited, ProblemBrokenLink}},
+		{"truncated", func(c *memChain) { c.recs = c.recs[:3] }, []string{ProblemTruncated}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMemChain(5)
+			tt.tamper(c)
+			rep, err := VerifyChain(ctx, c, nil)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			got := problemKinds(rep)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("problems = %v, want %v (%+v)", got, tt.want, rep.Problems)
+			}
+			if rep.OK != (len(tt.want) == 0) {
+				t.Fatalf("ok = %v", rep.OK)
+			}
+		})
+	}
+}
+
+func TestCheckpointer(t *testing.T) {
+	ctx := context.Background()
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	c := newMemChain(3)
+	cp := NewCheckpointer(c, key, time.Minute)
+	if got, err := cp.Checkpoint(ctx); err != nil || got == nil || got.Seq != 3 {
+		t.Fatalf("checkpoint = %+v, %v", got, err)
+	}
+	if got, err := cp.Checkpoint(ctx); err != nil || got != nil {
+		t.Fatalf("unchanged head should not checkpoint, got %+v, %v", got, err)
+	}
+
+	rep, err := VerifyChain(ctx, c, pub)
+	if err != nil || !rep.OK || rep.VerifiedCheckpoints != 1 {
+		t.Fatalf("report = %+v, %v", rep, err)
+	}
+
+	// A rewritten chain no longer matches the signed checkpoint.
+	forged := newMemChain(3)
+	forged.recs[2].Status = "error"
+	forged.recs[2].Hash = store.AuditRecordHash(&forged.recs[2])
+	forged.head.Hash = forged.recs[2].Hash
+	forged.checkpoints = c.checkpoints
+	rep, _ = VerifyChain(ctx, forged, pub)
+	if fmt.Sprint(problemKinds(rep)) != fmt.Sprint([]string{ProblemCheckpointMismatch}) {
+		t.Fatalf("forged chain problems = %+v", rep.Problems)
+	}
+
+	// A checkpoint signed with another key is rejected.
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	rep, _ = VerifyChain(ctx, c, otherPub)
+	if fmt.Sprint(problemKinds(rep)) != fmt.Sprint([]string{ProblemBadSignature}) {
+		t.Fatalf("wrong key problems = %+v", rep.Problems)
+	}
+}
+
+func TestVerifyChainPrunedRanges(t *testing.T) {
+	ctx := context.Background()
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name   string
+		tamper func(c *memChain)
+		want   []string
+	}{
+		{"signed by retention", func(c *memChain) {
+			c.signGap = GapSigner(key)
+			c.prune(1, 4)
+		}, nil},
+		{"adjacent signed gaps", func(c *memChain) {
+			c.signGap = GapSigner(key)
+			c.prune(1, 3)
+			c.prune(1, 2)
+		}, nil},
+		// Records 2-4 deleted and a gap row forged from the neighbours' hashes.
+		{"forged gap", func(c *memChain) { c.prune(1, 4) },
+			[]string{ProblemBadSignature, ProblemCheckpointMismatch}},
+		{"gap signed by another key", func(c *memChain) {
+			c.signGap = GapSigner(otherKey)
+			c.prune(1, 4)
+		}, []string{ProblemBadSignature, ProblemCheckpointMismatch}},
+		// A signed gap for 4 stretched back over deleted records 2-3, which
+		// hold the checkpoint.
+		{"widened gap", func(c *memChain) {
+			c.signGap = GapSigner(key)
+			c.prune(3, 4)
+			c.gaps[0].FirstSeq, c.gaps[0].PrevHash = 2, c.recs[1].PrevHash
+			c.recs = append(c.recs[:1], c.recs[3:]...)
+		}, []string{ProblemBadSignature, ProblemCheckpointMismatch}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMemChain(5)
+			// A checkpoint at seq 3, inside every pruned range above.
+			cp := store.AuditCheckpoint{
+				Seq:       3,
+				Hash:      c.recs[2].Hash,
+				PublicKey: base64.StdEncoding.EncodeToString(pub),
+				CreatedAt: time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC),
+			}
+			cp.Signature = base64.StdEncoding.EncodeToString(
+				ed25519.Sign(key, CheckpointMessage(cp.Seq, cp.Hash, cp.CreatedAt)))
+			c.checkpoints = []store.AuditCheckpoint{cp}
+			tt.tamper(c)
+
+			rep, err := VerifyChain(ctx, c, pub)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if got := problemKinds(rep); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("problems = %v, want %v (%+v)", got, tt.want, rep.Problems)
+			}
+			if rep.OK && rep.Pruned != 3 {
+				t.Fatalf("pruned = %d, want 3", rep.Pruned)
+			}
+		})
+	}
+}
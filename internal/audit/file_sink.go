@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// FileSink appends audit records as JSON lines to a file, rotating it once
+// it exceeds MaxBytes. Rotated files are renamed path.1, path.2, ... with at
+// most MaxBackups kept.
+type FileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewFileSink opens (or creates) path for appending. A maxBytes of 0
+// disables rotation.
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create audit log dir: %w", err)
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) Name() string { return "file:" + s.path }
+
+func (s *FileSink) Write(_ context.Context, recs []*store.AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	for _, rec := range recs {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return Permanent(fmt.Errorf("marshal audit record: %w", err))
+		}
+		line = append(line, '\n')
+		if s.maxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+			if err := s.rotate(); err != nil {
+				return err
+			}
+		}
+		n, err := s.f.Write(line)
+		s.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat audit log: %w", err)
+	}
+	s.f = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate shifts path.N to path.N+1, moves the current file to path.1 and
+// reopens path.
+func (s *FileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return fmt.Errorf("close audit log: %w", err)
+	}
+	s.f = nil
+
+	if s.maxBackups <= 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove audit log: %w", err)
+		}
+		return s.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxBackups)) //nolint:errcheck
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1)) //nolint:errcheck
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return fmt.Errorf("rotate audit log: %w", err)
+	}
+	return s.open()
+}
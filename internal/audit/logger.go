@@ -3,61 +3,363 @@ package audit
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
+	"github.com/google/uuid"
+	"github.com/revitteth/mcplexer/internal/metrics"
 	"github.com/revitteth/mcplexer/internal/store"
 )
 
-// Logger writes audit records with parameter redaction.
+// Logger writes audit records with parameter redaction, and forwards them to
+// any configured export sinks.
 type Logger struct {
-	store store.AuditStore
-	scope store.AuthScopeStore
-	bus   *Bus
+	store    store.AuditStore
+	scope    store.AuthScopeStore
+	bus      *Bus
+	redactor *Redactor
+	writer   *batchWriter // nil stores records synchronously
+
+	mu     sync.RWMutex
+	sinks  []*sinkWorker
+	closed bool
+
+	hintsMu sync.Mutex
+	hints   map[string]cachedHints // by auth scope ID
+
+	suppressed       atomic.Int64 // calls not recorded at log level none
+	suppressedErrors atomic.Int64
+}
+
+// hintsTTL is how long an auth scope's redaction hints are cached, so edits
+// to a scope apply to new records shortly after.
+const hintsTTL = 30 * time.Second
+
+type cachedHints struct {
+	hints   []string
+	expires time.Time
+}
+
+// maxResponseBytes caps the tool response kept at the full and debug log
+// levels, and each message of a debug exchange.
+const maxResponseBytes = 64 << 10
+
+// SuppressedStats counts tool calls that were not recorded because their
+// route's log level is none.
+type SuppressedStats struct {
+	Total  int64 `json:"total"`
+	Errors int64 `json:"errors"`
+}
+
+// Option configures a Logger.
+type Option func(*Logger)
+
+// WithSink forwards recorded audit entries to s, buffered per opts.
+func WithSink(s Sink, opts SinkOptions) Option {
+	return func(l *Logger) {
+		l.sinks = append(l.sinks, newSinkWorker(s, opts))
+	}
+}
+
+// WithRedactor replaces the default redactor, e.g. to add custom patterns.
+func WithRedactor(r *Redactor) Option {
+	return func(l *Logger) {
+		l.redactor = r
+	}
 }
 
 // NewLogger creates an audit Logger. The bus parameter is optional (nil-safe).
-func NewLogger(auditStore store.AuditStore, scopeStore store.AuthScopeStore, bus *Bus) *Logger {
-	return &Logger{store: auditStore, scope: scopeStore, bus: bus}
+func NewLogger(auditStore store.AuditStore, scopeStore store.AuthScopeStore, bus *Bus, opts ...Option) *Logger {
+	l := &Logger{
+		store:    auditStore,
+		scope:    scopeStore,
+		bus:      bus,
+		redactor: defaultRedactor,
+		hints:    make(map[string]cachedHints),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
 }
 
-// Record redacts sensitive parameters and inserts the audit record.
+// Record applies the record's log level, redacts sensitive values and
+// inserts the audit record, or queues it when the logger has a batch
+// writer. At level none the call is only counted.
 func (l *Logger) Record(ctx context.Context, rec *store.AuditRecord) error {
+	rec.LogLevel = normalizeLogLevel(rec.LogLevel)
+	switch rec.LogLevel {
+	case store.LogLevelNone:
+		l.suppressed.Add(1)
+		if rec.Status != "success" {
+			l.suppressedErrors.Add(1)
+		}
+		return nil
+	case store.LogLevelMetadata:
+		rec.ParamsRedacted = nil
+	}
+	if rec.LogLevel != store.LogLevelFull && rec.LogLevel != store.LogLevelDebug {
+		rec.Response = nil
+	}
+	if rec.LogLevel != store.LogLevelDebug {
+		rec.Exchange = nil
+	}
+
 	hints, err := l.loadRedactionHints(ctx, rec.AuthScopeID)
 	if err != nil {
 		return fmt.Errorf("load redaction hints: %w", err)
 	}
 
+	var found, rs []store.Redaction
 	if len(rec.ParamsRedacted) > 0 {
-		rec.ParamsRedacted = Redact(rec.ParamsRedacted, hints)
+		rec.ParamsRedacted, rs = l.redactor.Redact(rec.ParamsRedacted, hints, "params")
+		found = append(found, rs...)
+	}
+	if len(rec.Response) > 0 {
+		rec.Response, rs = l.redactor.Redact(rec.Response, hints, "response")
+		rec.Response = capJSON(rec.Response, maxResponseBytes)
+		found = append(found, rs...)
+	}
+	if len(rec.Exchange) > 0 {
+		rec.Exchange, rs = l.redactExchange(rec.Exchange, hints)
+		found = append(found, rs...)
+	}
+	rec.Redactions = found
+
+	// Assign the ID and timestamps now so they are known to the caller and
+	// reflect the call rather than when a queued record is written.
+	now := time.Now().UTC()
+	if rec.ID == "" {
+		rec.ID = uuid.NewString()
+	}
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = now
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = now
 	}
 
+	if l.writer != nil {
+		if l.writer.enqueue(ctx, rec) {
+			return nil
+		}
+		// The queue stayed full until ctx ended: store the record here
+		// rather than lose it.
+		ctx = context.WithoutCancel(ctx)
+	}
 	if err := l.store.InsertAuditRecord(ctx, rec); err != nil {
 		return fmt.Errorf("insert audit record: %w", err)
 	}
+	l.deliver(rec)
+	return nil
+}
+
+// deliver publishes a stored record to the bus and the export sinks.
+func (l *Logger) deliver(rec *store.AuditRecord) {
 	if l.bus != nil {
 		l.bus.Publish(rec)
 	}
-	return nil
+
+	l.mu.RLock()
+	if !l.closed {
+		for _, w := range l.sinks {
+			w.enqueue(rec)
+		}
+	}
+	l.mu.RUnlock()
+}
+
+// Suppressed returns counters for calls skipped at log level none.
+func (l *Logger) Suppressed() SuppressedStats {
+	return SuppressedStats{Total: l.suppressed.Load(), Errors: l.suppressedErrors.Load()}
 }
 
-// loadRedactionHints fetches per-scope redaction hints from the auth scope.
+// SinkStats returns delivery counters for each configured sink.
+func (l *Logger) SinkStats() []SinkStats {
+	out := make([]SinkStats, 0, len(l.sinks))
+	for _, w := range l.sinks {
+		out = append(out, w.stats())
+	}
+	return out
+}
+
+// WriterStats returns the batch writer's counters, or zero stats when
+// records are stored synchronously.
+func (l *Logger) WriterStats() WriterStats {
+	if l.writer == nil {
+		return WriterStats{}
+	}
+	return l.writer.stats()
+}
+
+// RegisterMetrics reports writer, sink delivery and suppressed-call
+// counters on every scrape of r.
+func (l *Logger) RegisterMetrics(r *metrics.Registry) {
+	sinks := func(value func(SinkStats) float64) func() []metrics.Sample {
+		return func() []metrics.Sample {
+			var out []metrics.Sample
+			for _, s := range l.SinkStats() {
+				out = append(out, metrics.Sample{Labels: []string{s.Name}, Value: value(s)})
+			}
+			return out
+		}
+	}
+	r.Collect("mcplexer_audit_writer_queued", "Audit records waiting to be stored.",
+		"gauge", nil, func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(l.WriterStats().Queued)}}
+		})
+	r.Collect("mcplexer_audit_writer_records_total", "Audit records handled by the batch writer, by result.",
+		"counter", []string{"result"}, func() []metrics.Sample {
+			s := l.WriterStats()
+			return []metrics.Sample{
+				{Labels: []string{"written"}, Value: float64(s.Written)},
+				{Labels: []string{"failed"}, Value: float64(s.Failed)},
+			}
+		})
+	r.Collect("mcplexer_audit_sink_queued", "Audit records queued for an export sink.",
+		"gauge", []string{"sink"}, sinks(func(s SinkStats) float64 { return float64(s.Queued) }))
+	r.Collect("mcplexer_audit_sink_lag_seconds", "Age of the oldest audit record not yet delivered to a sink.",
+		"gauge", []string{"sink"}, sinks(func(s SinkStats) float64 { return s.LagSeconds }))
+	r.Collect("mcplexer_audit_sink_records_total", "Audit records handled by an export sink, by result.",
+		"counter", []string{"sink", "result"}, func() []metrics.Sample {
+			var out []metrics.Sample
+			for _, s := range l.SinkStats() {
+				out = append(out,
+					metrics.Sample{Labels: []string{s.Name, "sent"}, Value: float64(s.Sent)},
+					metrics.Sample{Labels: []string{s.Name, "dropped"}, Value: float64(s.Dropped)},
+					metrics.Sample{Labels: []string{s.Name, "failed"}, Value: float64(s.Failed)})
+			}
+			return out
+		})
+	r.Collect("mcplexer_audit_suppressed_total", "Tool calls not audited because their route's log level is none.",
+		"counter", []string{"status"}, func() []metrics.Sample {
+			sup := l.Suppressed()
+			return []metrics.Sample{
+				{Labels: []string{"success"}, Value: float64(sup.Total - sup.Errors)},
+				{Labels: []string{"error"}, Value: float64(sup.Errors)},
+			}
+		})
+}
+
+// Close stores every record still queued for the batch writer, past ctx's
+// deadline if need be, then flushes queued records to the sinks and closes
+// them. Records recorded afterwards are only stored. Pending sink retries
+// are abandoned when ctx expires.
+func (l *Logger) Close(ctx context.Context) error {
+	var errs []error
+	if l.writer != nil {
+		if err := l.writer.close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("flush audit writer: %w", err))
+		}
+	}
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	for _, w := range l.sinks {
+		if err := w.close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("close sink %s: %w", w.sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// loadRedactionHints returns per-scope redaction hints from the auth scope,
+// cached for hintsTTL so most records need no lookup.
 func (l *Logger) loadRedactionHints(ctx context.Context, authScopeID string) ([]string, error) {
 	if authScopeID == "" {
 		return nil, nil
 	}
 
+	l.hintsMu.Lock()
+	c, ok := l.hints[authScopeID]
+	l.hintsMu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.hints, nil
+	}
+
+	hints := l.fetchRedactionHints(ctx, authScopeID)
+	l.hintsMu.Lock()
+	l.hints[authScopeID] = cachedHints{hints: hints, expires: time.Now().Add(hintsTTL)}
+	l.hintsMu.Unlock()
+	return hints, nil
+}
+
+// fetchRedactionHints reads an auth scope's redaction hints. A missing scope
+// or malformed hints mean no hints.
+func (l *Logger) fetchRedactionHints(ctx context.Context, authScopeID string) []string {
 	scope, err := l.scope.GetAuthScope(ctx, authScopeID)
 	if err != nil {
-		return nil, nil // scope not found is non-fatal for audit
+		return nil // scope not found is non-fatal for audit
 	}
 
 	if len(scope.RedactionHints) == 0 {
-		return nil, nil
+		return nil
 	}
 
 	var hints []string
 	if err := json.Unmarshal(scope.RedactionHints, &hints); err != nil {
-		return nil, nil // malformed hints is non-fatal
+		return nil // malformed hints is non-fatal
 	}
-	return hints, nil
+	return hints
+}
+
+// normalizeLogLevel maps an empty or unknown level to the default.
+func normalizeLogLevel(level string) string {
+	switch level {
+	case store.LogLevelNone, store.LogLevelMetadata, store.LogLevelFull, store.LogLevelDebug:
+		return level
+	default:
+		return store.LogLevelInfo
+	}
+}
+
+// capJSON returns data unchanged if it fits in limit bytes, otherwise a JSON
+// object holding the original size and a truncated text preview.
+func capJSON(data json.RawMessage, limit int) json.RawMessage {
+	if len(data) <= limit {
+		return data
+	}
+	preview := []rune(string(data[:limit]))
+	if len(preview) > 0 && preview[len(preview)-1] == utf8.RuneError {
+		preview = preview[:len(preview)-1] // drop a rune split by the cut
+	}
+	out, _ := json.Marshal(map[string]any{
+		"truncated": true,
+		"size":      len(data),
+		"preview":   string(preview),
+	})
+	return out
+}
+
+// redactExchange redacts and caps each message of a debug exchange.
+func (l *Logger) redactExchange(data json.RawMessage, hints []string) (json.RawMessage, []store.Redaction) {
+	var ex map[string]json.RawMessage
+	if err := json.Unmarshal(data, &ex); err != nil {
+		return capJSON(data, maxResponseBytes), nil
+	}
+	var found []store.Redaction
+	for _, k := range []string{"request", "response"} {
+		msg, ok := ex[k]
+		if !ok {
+			continue
+		}
+		redacted, rs := l.redactor.Redact(msg, hints, "exchange."+k)
+		ex[k] = capJSON(redacted, maxResponseBytes)
+		found = append(found, rs...)
+	}
+	out, err := json.Marshal(ex)
+	if err != nil {
+		return nil, found
+	}
+	return out, found
 }
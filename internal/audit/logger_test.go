@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// memAuditStore keeps inserted records; other AuditStore methods are unused.
+type memAuditStore struct {
+	store.AuditStore
+	mu      sync.Mutex
+	recs    []*store.AuditRecord
+	batches []int // size of each InsertAuditRecords call
+}
+
+func (s *memAuditStore) InsertAuditRecord(ctx context.Context, r *store.AuditRecord) error {
+	return s.InsertAuditRecords(ctx, []*store.AuditRecord{r})
+}
+
+func (s *memAuditStore) InsertAuditRecords(_ context.Context, recs []*store.AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recs = append(s.recs, recs...)
+	s.batches = append(s.batches, len(recs))
+	return nil
+}
+
+func TestLoggerLogLevels(t *testing.T) {
+	ctx := context.Background()
+	call := func(level string) *store.AuditRecord {
+		return &store.AuditRecord{
+			ToolName:       "db__query",
+			Status:         "success",
+			LogLevel:       level,
+			ParamsRedacted: json.RawMessage(`{"sql":"select 1","api_key":"k"}`),
+			Response:       json.RawMessage(`{"content":[{"type":"text","text":"1"}],"token":"t"}`),
+			Exchange:       json.RawMessage(`{"request":{"params":{"arguments":{"password":"p"}}},"response":{"result":{}}}`),
+		}
+	}
+
+	tests := []struct {
+		level                      string
+		params, response, exchange bool
+		wantLevel                  string
+	}{
+		{"metadata", false, false, false, "metadata"},
+		{"", true, false, false, "info"},
+		{"bogus", true, false, false, "info"},
+		{"full", true, true, false, "full"},
+		{"debug", true, true, true, "debug"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantLevel+"/"+tt.level, func(t *testing.T) {
+			s := &memAuditStore{}
+			l := NewLogger(s, nil, nil)
+			if err := l.Record(ctx, call(tt.level)); err != nil {
+				t.Fatalf("record: %v", err)
+			}
+			rec := s.recs[0]
+			if rec.LogLevel != tt.wantLevel {
+				t.Errorf("log level = %q, want %q", rec.LogLevel, tt.wantLevel)
+			}
+			if got := len(rec.ParamsRedacted) > 0; got != tt.params {
+				t.Errorf("params kept = %v, want %v", got, tt.params)
+			}
+			if got := len(rec.Response) > 0; got != tt.response {
+				t.Errorf("response kept = %v, want %v", got, tt.response)
+			}
+			if got := len(rec.Exchange) > 0; got != tt.exchange {
+				t.Errorf("exchange kept = %v, want %v", got, tt.exchange)
+			}
+			if got := len(rec.Redactions) > 0; got != tt.params {
+				t.Errorf("redactions = %v, want some: %v", rec.Redactions, tt.params)
+			}
+			for _, field := range []json.RawMessage{rec.ParamsRedacted, rec.Response, rec.Exchange} {
+				for _, secret := range []string{`"k"`, `"t"`, `"p"`} {
+					if strings.Contains(string(field), secret) {
+						t.Errorf("secret %s not redacted in %s", secret, field)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("none", func(t *testing.T) {
+		s := &memAuditStore{}
+		l := NewLogger(s, nil, nil)
+		rec := call("none")
+		l.Record(ctx, rec)
+		rec = call("none")
+		rec.Status = "error"
+		l.Record(ctx, rec)
+		if len(s.recs) != 0 {
+			t.Fatalf("stored %d records at level none", len(s.recs))
+		}
+		if got := l.Suppressed(); got != (SuppressedStats{Total: 2, Errors: 1}) {
+			t.Fatalf("suppressed = %+v", got)
+		}
+	})
+
+	t.Run("response cap", func(t *testing.T) {
+		s := &memAuditStore{}
+		l := NewLogger(s, nil, nil)
+		rec := call("full")
+		big, _ := json.Marshal(map[string]string{"text": strings.Repeat("é", maxResponseBytes)})
+		rec.Response = big
+		l.Record(ctx, rec)
+		var capped struct {
+			Truncated bool   `json:"truncated"`
+			Size      int    `json:"size"`
+			Preview   string `json:"preview"`
+		}
+		if err := json.Unmarshal(s.recs[0].Response, &capped); err != nil {
+			t.Fatalf("capped response is not JSON: %v", err)
+		}
+		if !capped.Truncated || capped.Size != len(big) || len(capped.Preview) > maxResponseBytes {
+			t.Fatalf("capped = truncated %v, size %d, preview %d bytes", capped.Truncated, capped.Size, len(capped.Preview))
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// OTLP severity numbers (opentelemetry logs data model).
+const (
+	otlpSeverityInfo = 9
+	otlpSeverityWarn = 13
+)
+
+// OTLPSink exports audit records as OTLP/HTTP logs using the JSON encoding.
+// Endpoint is the full logs URL, typically http://collector:4318/v1/logs.
+type OTLPSink struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// NewOTLPSink creates a sink that posts to endpoint with the given extra
+// headers (e.g. authorization).
+func NewOTLPSink(endpoint string, headers map[string]string) *OTLPSink {
+	return &OTLPSink{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *OTLPSink) Name() string { return "otlp:" + s.endpoint }
+
+func (s *OTLPSink) Write(ctx context.Context, recs []*store.AuditRecord) error {
+	body, err := EncodeOTLPLogs(recs)
+	if err != nil {
+		return Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(fmt.Errorf("build otlp request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post otlp logs: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) //nolint:errcheck
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("otlp collector returned %s", resp.Status)
+	default:
+		return Permanent(fmt.Errorf("otlp collector returned %s", resp.Status))
+	}
+}
+
+func (s *OTLPSink) Close() error { return nil }
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"` // int64 is a string in OTLP JSON
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	TraceID              string         `json:"traceId,omitempty"` // links the log to the tool call trace
+	Body                 otlpValue      `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes"`
+}
+
+func otlpString(k, v string) otlpKeyValue {
+	return otlpKeyValue{Key: k, Value: otlpValue{StringValue: &v}}
+}
+
+func otlpInt(k string, v int) otlpKeyValue {
+	s := strconv.Itoa(v)
+	return otlpKeyValue{Key: k, Value: otlpValue{IntValue: &s}}
+}
+
+// EncodeOTLPLogs renders records as an OTLP ExportLogsServiceRequest in JSON.
+// Each log body is the full record as JSON; key fields are attributes.
+func EncodeOTLPLogs(recs []*store.AuditRecord) ([]byte, error) {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	logs := make([]otlpLogRecord, 0, len(recs))
+	for _, rec := range recs {
+		body, err := json.Marshal(rec)
+		if err != nil {
+			return nil, fmt.Errorf("marshal audit record: %w", err)
+		}
+		bodyStr := string(body)
+
+		sevNum, sevText := otlpSeverityInfo, "INFO"
+		if rec.Status != "success" {
+			sevNum, sevText = otlpSeverityWarn, "WARN"
+		}
+		attrs := []otlpKeyValue{
+			otlpString("mcplexer.audit.id", rec.ID),
+			otlpString("mcplexer.tool", rec.ToolName),
+			otlpString("mcplexer.status", rec.Status),
+			otlpInt("mcplexer.latency_ms", rec.LatencyMs),
+		}
+		for _, kv := range [][2]string{
+			{"mcplexer.session_id", rec.SessionID},
+			{"mcplexer.workspace_id", rec.WorkspaceID},
+			{"mcplexer.downstream_server_id", rec.DownstreamServerID},
+			{"mcplexer.client_type", rec.ClientType},
+			{"mcplexer.error_message", rec.ErrorMessage},
+		} {
+			if kv[1] != "" {
+				attrs = append(attrs, otlpString(kv[0], kv[1]))
+			}
+		}
+
+		logs = append(logs, otlpLogRecord{
+			TimeUnixNano:         strconv.FormatInt(rec.Timestamp.UnixNano(), 10),
+			ObservedTimeUnixNano: now,
+			SeverityNumber:       sevNum,
+			SeverityText:         sevText,
+			TraceID:              rec.TraceID,
+			Body:                 otlpValue{StringValue: &bodyStr},
+			Attributes:           attrs,
+		})
+	}
+
+	req := map[string]any{
+		"resourceLogs": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []otlpKeyValue{otlpString("service.name", "mcplexer")},
+			},
+			"scopeLogs": []any{map[string]any{
+				"scope":      map[string]any{"name": "mcplexer.audit"},
+				"logRecords": logs,
+			}},
+		}},
+	}
+	return json.Marshal(req)
+}
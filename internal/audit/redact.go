@@ -1,8 +1,19 @@
 package audit
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
+
+	"github.com/revitteth/mcplexer/internal/store"
 )
 
 // globalRedactPatterns are key substrings that always trigger redaction.
@@ -18,58 +29,290 @@ var globalRedactPatterns = []string{
 
 const redactedValue = "[REDACTED]"
 
-// Redact replaces sensitive values in a JSON params object with [REDACTED].
-// It matches keys against global patterns and the provided per-scope hints.
-func Redact(params json.RawMessage, hints []string) json.RawMessage {
-	if len(params) == 0 {
-		return params
+// Detector names for key-based redaction, reported in store.Redaction.
+const (
+	DetectorKeyName = "key_name" // key matched a global pattern
+	DetectorHint    = "hint"     // key matched a per-scope redaction hint
+)
+
+// detector finds secrets inside string values. When re has a capture group
+// only the first group is redacted, otherwise the whole match.
+type detector struct {
+	name       string
+	re         *regexp.Regexp
+	minEntropy float64 // if set, matches must also look random
+}
+
+// builtinDetectors run in order, so specific formats are reported before
+// the generic high-entropy check sees what is left.
+var builtinDetectors = []detector{
+	{name: "private_key", re: regexp.MustCompile(
+		`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z0-9 ]*PRIVATE KEY-----|$)`)},
+	{name: "github_token", re: regexp.MustCompile(
+		`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)},
+	{name: "stripe_key", re: regexp.MustCompile(
+		`\b(?:[sr]k_(?:live|test)_[A-Za-z0-9]{16,}|whsec_[A-Za-z0-9]{24,})\b`)},
+	{name: "aws_access_key", re: regexp.MustCompile(
+		`\b(?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`)},
+	{name: "slack_token", re: regexp.MustCompile(
+		`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{name: "slack_webhook", re: regexp.MustCompile(
+		`https://hooks\.slack\.com/services/[A-Za-z0-9/_-]+`)},
+	{name: "jwt", re: regexp.MustCompile(
+		`\beyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`)},
+	{name: "connection_string", re: regexp.MustCompile(
+		`\b[A-Za-z][A-Za-z0-9+.-]*://[^\s:/@]*:([^\s@/]+)@`)},
+	{name: "password_field", re: regexp.MustCompile(
+		`(?i)\b(?:password|passwd|pwd)\s*[=:]\s*([^\s;&'",]+)`)},
+	{name: "high_entropy", re: regexp.MustCompile(
+		`[A-Za-z0-9+_=-]{32,}`), minEntropy: 4.2},
+}
+
+// Redactor replaces sensitive values in JSON documents: values under
+// sensitive keys, and secrets found inside string values by the built-in
+// detectors and any custom patterns.
+type Redactor struct {
+	detectors []detector
+}
+
+// defaultRedactor uses only the built-in detectors.
+var defaultRedactor = &Redactor{detectors: builtinDetectors}
+
+// NewRedactor creates a Redactor with the built-in detectors followed by
+// the given regular expressions, reported as custom_1, custom_2 and so on.
+func NewRedactor(patterns []string) (*Redactor, error) {
+	r := &Redactor{detectors: append([]detector(nil), builtinDetectors...)}
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redact pattern %d: %w", i+1, err)
+		}
+		if re.MatchString("") {
+			return nil, fmt.Errorf("redact pattern %d %q matches the empty string", i+1, p)
+		}
+		r.detectors = append(r.detectors, detector{name: "custom_" + strconv.Itoa(i+1), re: re})
 	}
+	return r, nil
+}
 
-	var obj map[string]json.RawMessage
-	if err := json.Unmarshal(params, &obj); err != nil {
-		return params
+// LoadRedactor reads custom patterns from path, one regular expression per
+// line; blank lines and lines starting with # are skipped.
+func LoadRedactor(path string) (*Redactor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open redact patterns: %w", err)
 	}
+	defer f.Close()
 
-	changed := false
-	for key, val := range obj {
-		if shouldRedact(key, hints) {
-			redacted, _ := json.Marshal(redactedValue)
-			obj[key] = redacted
-			changed = true
+	var patterns []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		// Recurse into nested objects
-		if redacted := Redact(val, hints); !jsonEqual(val, redacted) {
-			obj[key] = redacted
-			changed = true
+		patterns = append(patterns, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read redact patterns: %w", err)
+	}
+	return NewRedactor(patterns)
+}
+
+// Redact replaces sensitive values in a JSON params object with [REDACTED]
+// using the built-in detectors and the provided per-scope hints.
+func Redact(params json.RawMessage, hints []string) json.RawMessage {
+	out, _ := defaultRedactor.Redact(params, hints, "params")
+	return out
+}
+
+// Redact replaces sensitive values in data, recursing into objects and
+// arrays. Values under keys matching a global pattern or per-scope hint are
+// replaced whole; secrets found inside other strings are replaced in place.
+// It returns where values were redacted, with paths rooted at root. Data
+// that is not valid JSON is returned unchanged.
+func (r *Redactor) Redact(data json.RawMessage, hints []string, root string) (json.RawMessage, []store.Redaction) {
+	if len(data) == 0 || !json.Valid(data) {
+		return data, nil
+	}
+	var found []store.Redaction
+	out := r.redact(data, hints, root, &found)
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Path != found[j].Path {
+			return found[i].Path < found[j].Path
+		}
+		return found[i].Detector < found[j].Detector
+	})
+	return out, found
+}
+
+func (r *Redactor) redact(data json.RawMessage, hints []string, path string, found *[]store.Redaction) json.RawMessage {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return data
+	}
+
+	switch trimmed[0] {
+	case '{':
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &obj); err != nil {
+			return data
+		}
+		changed := false
+		for key, val := range obj {
+			p := joinPath(path, key)
+			if name := matchKey(key, hints); name != "" {
+				obj[key], _ = json.Marshal(redactedValue)
+				*found = append(*found, store.Redaction{Path: p, Detector: name})
+				changed = true
+				continue
+			}
+			if redacted := r.redact(val, hints, p, found); !jsonEqual(val, redacted) {
+				obj[key] = redacted
+				changed = true
+			}
+		}
+		if !changed {
+			return data
+		}
+		return marshalOr(obj, data)
+
+	case '[':
+		var arr []json.RawMessage
+		if err := json.Unmarshal(trimmed, &arr); err != nil {
+			return data
+		}
+		changed := false
+		for i, val := range arr {
+			p := path + "[" + strconv.Itoa(i) + "]"
+			if redacted := r.redact(val, hints, p, found); !jsonEqual(val, redacted) {
+				arr[i] = redacted
+				changed = true
+			}
+		}
+		if !changed {
+			return data
+		}
+		return marshalOr(arr, data)
+
+	case '"':
+		var s string
+		if err := json.Unmarshal(trimmed, &s); err != nil {
+			return data
+		}
+		redacted, names := r.scanString(s)
+		if len(names) == 0 {
+			return data
 		}
+		for _, name := range names {
+			*found = append(*found, store.Redaction{Path: path, Detector: name})
+		}
+		return marshalOr(redacted, data)
 	}
+	return data
+}
 
-	if !changed {
-		return params
+// scanString runs every detector over s in order, replacing what each one
+// finds. It returns the redacted string and the detectors that matched.
+func (r *Redactor) scanString(s string) (string, []string) {
+	var names []string
+	for _, d := range r.detectors {
+		var b strings.Builder
+		last, matched := 0, false
+		for _, m := range d.re.FindAllStringSubmatchIndex(s, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			if d.minEntropy > 0 && !looksRandom(s[start:end], d.minEntropy) {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(redactedValue)
+			last, matched = end, true
+		}
+		if !matched {
+			continue
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+		names = append(names, d.name)
 	}
+	return s, names
+}
 
-	result, err := json.Marshal(obj)
-	if err != nil {
-		return params
+// looksRandom reports whether s mixes letter cases and digits with at least
+// minEntropy bits of Shannon entropy per character, which excludes words,
+// identifiers and hex digests.
+func looksRandom(s string, minEntropy float64) bool {
+	var upper, lower, digit bool
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		}
+	}
+	if !upper || !lower || !digit {
+		return false
 	}
-	return result
+	var entropy float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= minEntropy
 }
 
-// shouldRedact checks if a key matches any global pattern or per-scope hint.
-func shouldRedact(key string, hints []string) bool {
+// matchKey returns the detector name if key matches a global pattern or
+// per-scope hint, or "" if it does not.
+func matchKey(key string, hints []string) string {
 	lower := strings.ToLower(key)
 	for _, pattern := range globalRedactPatterns {
 		if strings.Contains(lower, pattern) {
-			return true
+			return DetectorKeyName
 		}
 	}
 	for _, hint := range hints {
 		if strings.Contains(lower, strings.ToLower(hint)) {
-			return true
+			return DetectorHint
+		}
+	}
+	return ""
+}
+
+// joinPath appends an object key to path, bracket-quoting keys that are
+// not plain identifiers.
+func joinPath(path, key string) string {
+	plain := key != ""
+	for _, c := range key {
+		if !(c == '_' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			plain = false
+			break
 		}
 	}
-	return false
+	switch {
+	case !plain:
+		return path + "[" + strconv.Quote(key) + "]"
+	case path == "":
+		return key
+	default:
+		return path + "." + key
+	}
+}
+
+func marshalOr(v any, fallback json.RawMessage) json.RawMessage {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fallback
+	}
+	return out
 }
 
 func jsonEqual(a, b json.RawMessage) bool {
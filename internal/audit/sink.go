@@ -0,0 +1,202 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// Sink delivers batches of audit records to an external system. Write may
+// be retried with the same batch, so sinks should tolerate duplicates.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, recs []*store.AuditRecord) error
+	Close() error
+}
+
+// permanentError marks a sink error that retrying will not fix.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the batch is dropped instead of retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// SinkOptions tunes the buffering and retry behaviour of a sink. Zero values
+// use the defaults.
+type SinkOptions struct {
+	BufferSize    int           // queued records before new ones are dropped (default 1024)
+	BatchSize     int           // max records per Write (default 100)
+	FlushInterval time.Duration // max wait before a partial batch is written (default 1s)
+	MaxRetries    int           // retries per batch after the first attempt (default 5)
+	RetryBackoff  time.Duration // initial retry delay, doubled per attempt (default 500ms)
+}
+
+func (o SinkOptions) withDefaults() SinkOptions {
+	if o.BufferSize <= 0 {
+		o.BufferSize = 1024
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = time.Second
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = 5
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = 500 * time.Millisecond
+	}
+	return o
+}
+
+// SinkStats reports delivery counters for a sink.
+type SinkStats struct {
+	Name    string `json:"name"`
+	Queued  int    `json:"queued"`
+	Sent    int64  `json:"sent"`
+	Dropped int64  `json:"dropped"` // buffer was full
+	Failed  int64  `json:"failed"`  // retries exhausted or permanent error
+	// LagSeconds is the age of the oldest record not yet delivered, or 0
+	// when the sink is caught up.
+	LagSeconds float64 `json:"lag_seconds"`
+}
+
+// sinkWorker owns a sink's queue and delivers batches on its own goroutine,
+// so a slow or failing sink never blocks Record. When the queue is full new
+// records are dropped and counted.
+type sinkWorker struct {
+	sink    Sink
+	opts    SinkOptions
+	queue   chan *store.AuditRecord
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	sent    atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+	oldest  atomic.Int64 // unix nanos of the oldest undelivered record; 0 if none
+}
+
+func newSinkWorker(s Sink, opts SinkOptions) *sinkWorker {
+	opts = opts.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &sinkWorker{
+		sink:   s,
+		opts:   opts,
+		queue:  make(chan *store.AuditRecord, opts.BufferSize),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// enqueue adds rec to the queue without blocking.
+func (w *sinkWorker) enqueue(rec *store.AuditRecord) {
+	select {
+	case w.queue <- rec:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+func (w *sinkWorker) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*store.AuditRecord, 0, w.opts.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			w.deliver(batch)
+			batch = make([]*store.AuditRecord, 0, w.opts.BatchSize)
+			w.oldest.Store(0)
+		}
+	}
+	for {
+		select {
+		case rec, ok := <-w.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if len(batch) == 0 {
+				// The queue is FIFO, so the batch head is the oldest record.
+				w.oldest.Store(rec.CreatedAt.UnixNano())
+			}
+			batch = append(batch, rec)
+			if len(batch) >= w.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// deliver writes a batch, retrying with exponential backoff. The batch is
+// counted as failed once retries run out, the error is permanent, or the
+// worker is being torn down.
+func (w *sinkWorker) deliver(batch []*store.AuditRecord) {
+	backoff := w.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := w.sink.Write(w.ctx, batch)
+		if err == nil {
+			w.sent.Add(int64(len(batch)))
+			return
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) || attempt >= w.opts.MaxRetries || w.ctx.Err() != nil {
+			w.failed.Add(int64(len(batch)))
+			slog.Warn("audit sink dropped batch",
+				"sink", w.sink.Name(), "records", len(batch), "attempts", attempt+1, "err", err)
+			return
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-w.ctx.Done():
+		}
+	}
+}
+
+// close drains the queue and closes the sink. If ctx expires first, pending
+// retries are abandoned.
+func (w *sinkWorker) close(ctx context.Context) error {
+	close(w.queue)
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		w.cancel()
+		<-w.done
+	}
+	w.cancel()
+	return w.sink.Close()
+}
+
+func (w *sinkWorker) stats() SinkStats {
+	s := SinkStats{
+		Name:    w.sink.Name(),
+		Queued:  len(w.queue),
+		Sent:    w.sent.Load(),
+		Dropped: w.dropped.Load(),
+		Failed:  w.failed.Load(),
+	}
+	if oldest := w.oldest.Load(); oldest != 0 {
+		s.LagSeconds = max(time.Since(time.Unix(0, oldest)).Seconds(), 0)
+	}
+	return s
+}
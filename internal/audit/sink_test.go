@@ -0,0 +1,273 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// memSink records batches and can be made to fail or block.
+type memSink struct {
+	mu      sync.Mutex
+	recs    []*store.AuditRecord
+	fail    atomic.Int32 // fail this many more writes
+	block   chan struct{}
+	permErr bool
+}
+
+func (s *memSink) Name() string { return "mem" }
+
+func (s *memSink) Write(ctx context.Context, recs []*store.AuditRecord) error {
+	if s.block != nil {
+		select {
+		case <-s.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if s.fail.Add(-1) >= 0 {
+		err := errors.New("unavailable")
+		if s.permErr {
+			return Permanent(err)
+		}
+		return err
+	}
+	s.mu.Lock()
+	s.recs = append(s.recs, recs...)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memSink) Close() error { return nil }
+
+func (s *memSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.recs)
+}
+
+func testRecord(id string) *store.AuditRecord {
+	return &store.AuditRecord{
+		ID:        id,
+		Timestamp: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC),
+		ToolName:  "github__create_issue",
+		Status:    "success",
+		LatencyMs: 12,
+	}
+}
+
+func TestSinkWorkerRetries(t *testing.T) {
+	s := &memSink{}
+	s.fail.Store(2)
+	w := newSinkWorker(s, SinkOptions{FlushInterval: 10 * time.Millisecond, RetryBackoff: time.Millisecond})
+	w.enqueue(testRecord("a"))
+	w.enqueue(testRecord("b"))
+	if err := w.close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got := s.count(); got != 2 {
+		t.Fatalf("delivered %d records, want 2", got)
+	}
+	if st := w.stats(); st.Sent != 2 || st.Failed != 0 {
+		t.Fatalf("stats = %+v", st)
+	}
+}
+
+func TestSinkWorkerPermanentError(t *testing.T) {
+	s := &memSink{permErr: true}
+	s.fail.Store(1)
+	w := newSinkWorker(s, SinkOptions{BatchSize: 1, RetryBackoff: time.Millisecond})
+	w.enqueue(testRecord("a"))
+	w.enqueue(testRecord("b"))
+	w.close(context.Background()) //nolint:errcheck
+	if st := w.stats(); st.Failed != 1 || st.Sent != 1 {
+		t.Fatalf("stats = %+v, want 1 failed and 1 sent", st)
+	}
+}
+
+func TestSinkWorkerDoesNotBlock(t *testing.T) {
+	s := &memSink{block: make(chan struct{})}
+	w := newSinkWorker(s, SinkOptions{BufferSize: 2, BatchSize: 1})
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			w.enqueue(testRecord("r"))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("enqueue blocked on a stalled sink")
+	}
+	if st := w.stats(); st.Dropped == 0 {
+		t.Fatalf("expected dropped records, got %+v", st)
+	}
+
+	// An expired close context abandons the stalled write.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	w.close(ctx) //nolint:errcheck
+}
+
+func TestFileSinkRotates(t *testing.T) {
+	path := t.TempDir() + "/audit.jsonl"
+	s, err := NewFileSink(path, 800, 2)
+	if err != nil {
+		t.Fatalf("new file sink: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := s.Write(context.Background(), []*store.AuditRecord{testRecord("r")}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	s.Close()
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatalf("read %s: %v", p, err)
+		}
+		if len(data) > 800 {
+			t.Errorf("%s is %d bytes, want <= 800", p, len(data))
+		}
+		sc := bufio.NewScanner(strings.NewReader(string(data)))
+		for sc.Scan() {
+			var rec store.AuditRecord
+			if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+				t.Fatalf("%s: invalid JSON line: %v", p, err)
+			}
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected at most 2 backups, stat .3 = %v", err)
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	t.Run("udp", func(t *testing.T) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		defer pc.Close()
+
+		s, err := NewSyslogSink("udp", pc.LocalAddr().String())
+		if err != nil {
+			t.Fatalf("new syslog sink: %v", err)
+		}
+		defer s.Close()
+		rec := testRecord("abc")
+		rec.ToolName = `weird"tool]`
+		if err := s.Write(context.Background(), []*store.AuditRecord{rec}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+
+		buf := make([]byte, 4096)
+		pc.SetReadDeadline(time.Now().Add(time.Second)) //nolint:errcheck
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		msg := string(buf[:n])
+		if !strings.HasPrefix(msg, "<110>1 2025-01-15T10:00:00.000000Z ") {
+			t.Fatalf("unexpected header: %s", msg)
+		}
+		if !strings.Contains(msg, ` mcplexer `) || !strings.Contains(msg, ` audit [mcplexer@32473 id="abc" tool="weird\"tool\]"`) {
+			t.Fatalf("unexpected message: %s", msg)
+		}
+	})
+
+	t.Run("tcp octet counting", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		defer ln.Close()
+		got := make(chan string, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			prefix, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(prefix))
+			msg := make([]byte, size)
+			io.ReadFull(r, msg) //nolint:errcheck
+			got <- string(msg)
+		}()
+
+		s, _ := NewSyslogSink("tcp", ln.Addr().String())
+		defer s.Close()
+		if err := s.Write(context.Background(), []*store.AuditRecord{testRecord("abc")}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		select {
+		case msg := <-got:
+			if !strings.HasPrefix(msg, "<110>1 ") || !strings.HasSuffix(msg, "}") {
+				t.Fatalf("unexpected frame: %q", msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no message received")
+		}
+	})
+}
+
+func TestOTLPSink(t *testing.T) {
+	var calls atomic.Int32
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	s := NewOTLPSink(srv.URL+"/v1/logs", map[string]string{"Authorization": "Bearer t"})
+	recs := []*store.AuditRecord{testRecord("a")}
+	if err := s.Write(context.Background(), recs); err == nil {
+		t.Fatal("expected a retryable error on 503")
+	} else if errors.As(err, new(*permanentError)) {
+		t.Fatalf("503 should be retryable, got %v", err)
+	}
+	if err := s.Write(context.Background(), recs); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	rl := body["resourceLogs"].([]any)[0].(map[string]any)
+	lr := rl["scopeLogs"].([]any)[0].(map[string]any)["logRecords"].([]any)[0].(map[string]any)
+	if lr["timeUnixNano"] != "1736935200000000000" || lr["severityText"] != "INFO" {
+		t.Fatalf("unexpected log record: %v", lr)
+	}
+
+	bad := NewOTLPSink(srv.URL, nil)
+	if err := bad.Write(context.Background(), recs); !errors.As(err, new(*permanentError)) {
+		t.Fatalf("401 should be permanent, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+const (
+	// syslogFacilityAudit is the RFC 5424 "log audit" facility.
+	syslogFacilityAudit = 13
+
+	syslogSeverityWarning = 4
+	syslogSeverityInfo    = 6
+
+	// syslogSDID is the structured data ID, qualified with the documentation
+	// enterprise number from RFC 5612.
+	syslogSDID = "mcplexer@32473"
+
+	syslogDialTimeout = 5 * time.Second
+)
+
+// SyslogSink sends audit records as RFC 5424 messages over udp, tcp, unix
+// (stream) or unixgram. Stream transports use octet-counting framing
+// (RFC 6587). The connection is dialled lazily and re-dialled after errors.
+type SyslogSink struct {
+	network  string
+	addr     string
+	hostname string
+	appName  string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink creates a sink for the given network and address.
+func NewSyslogSink(network, addr string) (*SyslogSink, error) {
+	switch network {
+	case "udp", "tcp", "unix", "unixgram":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q (want udp, tcp, unix or unixgram)", network)
+	}
+	hostname, _ := os.Hostname()
+	return &SyslogSink{network: network, addr: addr, hostname: hostname, appName: "mcplexer"}, nil
+}
+
+// ParseSyslogURL splits a URL such as udp://host:514 or unix:///dev/log into
+// network and address.
+func ParseSyslogURL(raw string) (network, addr string, err error) {
+	network, addr, ok := strings.Cut(raw, "://")
+	if !ok || addr == "" {
+		return "", "", fmt.Errorf("invalid syslog url %q (want scheme://address)", raw)
+	}
+	return network, addr, nil
+}
+
+func (s *SyslogSink) Name() string { return "syslog:" + s.network + "://" + s.addr }
+
+func (s *SyslogSink) Write(ctx context.Context, recs []*store.AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		d := net.Dialer{Timeout: syslogDialTimeout}
+		conn, err := d.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return fmt.Errorf("dial syslog: %w", err)
+		}
+		s.conn = conn
+	}
+
+	stream := s.network == "tcp" || s.network == "unix"
+	for _, rec := range recs {
+		msg, err := FormatSyslog(rec, s.hostname, s.appName)
+		if err != nil {
+			return Permanent(err)
+		}
+		if stream {
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			s.conn.SetWriteDeadline(deadline) //nolint:errcheck
+		}
+		if _, err := s.conn.Write([]byte(msg)); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return fmt.Errorf("write syslog: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// FormatSyslog renders rec as an RFC 5424 message: key fields as structured
+// data and the full record as a JSON message body.
+func FormatSyslog(rec *store.AuditRecord, hostname, appName string) (string, error) {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return "", fmt.Errorf("marshal audit record: %w", err)
+	}
+
+	severity := syslogSeverityInfo
+	if rec.Status != "success" {
+		severity = syslogSeverityWarning
+	}
+
+	var sd strings.Builder
+	sd.WriteString("[" + syslogSDID)
+	for _, p := range [][2]string{
+		{"id", rec.ID},
+		{"tool", rec.ToolName},
+		{"status", rec.Status},
+		{"session", rec.SessionID},
+		{"workspace", rec.WorkspaceID},
+		{"server", rec.DownstreamServerID},
+		{"latency_ms", strconv.Itoa(rec.LatencyMs)},
+	} {
+		if p[1] != "" {
+			fmt.Fprintf(&sd, ` %s="%s"`, p[0], escapeSDValue(p[1]))
+		}
+	}
+	sd.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d audit %s %s",
+		syslogFacilityAudit*8+severity,
+		rec.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(hostname, 255),
+		syslogHeaderField(appName, 48),
+		os.Getpid(),
+		sd.String(),
+		body,
+	), nil
+}
+
+// syslogHeaderField returns "-" for empty values and strips characters that
+// are not allowed in RFC 5424 header fields.
+func syslogHeaderField(s string, maxLen int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > maxLen {
+		s = s[:maxLen]
+	}
+	return s
+}
+
+// escapeSDValue escapes '"', '\' and ']' in structured data values.
+func escapeSDValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
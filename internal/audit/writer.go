@@ -0,0 +1,207 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// WriterOptions tunes the batched audit writer. Zero values use the
+// defaults.
+type WriterOptions struct {
+	QueueSize  int           // records waiting to be stored before Record blocks (default 4096)
+	BatchSize  int           // max records per transaction (default 256)
+	MaxRetries int           // retries per insert after the first attempt (default 3)
+	RetryDelay time.Duration // initial retry delay, doubled per attempt (default 50ms)
+}
+
+func (o WriterOptions) withDefaults() WriterOptions {
+	if o.QueueSize <= 0 {
+		o.QueueSize = 4096
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 256
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = 3
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = 50 * time.Millisecond
+	}
+	return o
+}
+
+// WriterStats reports counters for the batched writer.
+type WriterStats struct {
+	Queued  int   `json:"queued"`
+	Written int64 `json:"written"`
+	Failed  int64 `json:"failed"` // insert kept failing
+}
+
+// WithBatchWriter stores records from a background goroutine instead of on
+// the caller's path. Records queued together are inserted in one
+// transaction. A full queue makes Record wait; if the caller's context
+// ends first, Record stores the record itself. Close writes everything
+// still queued.
+func WithBatchWriter(opts WriterOptions) Option {
+	return func(l *Logger) {
+		l.writer = newBatchWriter(l, opts)
+	}
+}
+
+// batchWriter owns the queue of redacted records waiting to be stored.
+type batchWriter struct {
+	l     *Logger
+	opts  WriterOptions
+	queue chan *store.AuditRecord
+	done  chan struct{}
+	hurry chan struct{} // closed when Close's deadline passes: stop backing off
+
+	hurryOnce sync.Once
+
+	// mu guards closed; enqueue holds it shared so close never races a
+	// send on the closed queue.
+	mu     sync.RWMutex
+	closed bool
+
+	written atomic.Int64
+	failed  atomic.Int64
+}
+
+func newBatchWriter(l *Logger, opts WriterOptions) *batchWriter {
+	opts = opts.withDefaults()
+	w := &batchWriter{
+		l:     l,
+		opts:  opts,
+		queue: make(chan *store.AuditRecord, opts.QueueSize),
+		done:  make(chan struct{}),
+		hurry: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// enqueue queues rec, waiting while the queue is full. It reports false
+// once the writer is closed or ctx ends first, in which case the caller
+// stores rec itself.
+func (w *batchWriter) enqueue(ctx context.Context, rec *store.AuditRecord) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return false
+	}
+	select {
+	case w.queue <- rec:
+		return true
+	default:
+	}
+	select {
+	case w.queue <- rec:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (w *batchWriter) run() {
+	defer close(w.done)
+	batch := make([]*store.AuditRecord, 0, w.opts.BatchSize)
+	for rec := range w.queue {
+		batch = append(batch[:0], rec)
+		// Group commit: take whatever queued up while the last batch was
+		// being written.
+	fill:
+		for len(batch) < w.opts.BatchSize {
+			select {
+			case r, ok := <-w.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, r)
+			default:
+				break fill
+			}
+		}
+		w.write(batch)
+	}
+}
+
+// write inserts a batch. If the batch keeps failing, its records are
+// inserted one by one, each with its own retries, so a single bad record
+// does not take the others with it.
+func (w *batchWriter) write(batch []*store.AuditRecord) {
+	if len(batch) > 1 {
+		if w.insert(batch) == nil {
+			return
+		}
+	}
+	for _, rec := range batch {
+		if err := w.insert([]*store.AuditRecord{rec}); err != nil {
+			w.failed.Add(1)
+			slog.Error("audit record dropped", "id", rec.ID, "error", err)
+		}
+	}
+}
+
+// insert stores recs in one transaction, retrying with backoff. Once
+// Close's deadline has passed, retries no longer wait.
+func (w *batchWriter) insert(recs []*store.AuditRecord) error {
+	ctx := context.Background()
+	delay := w.opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := w.l.store.InsertAuditRecords(ctx, recs)
+		if err == nil {
+			w.written.Add(int64(len(recs)))
+			for _, rec := range recs {
+				w.l.deliver(rec)
+			}
+			return nil
+		}
+		if attempt >= w.opts.MaxRetries {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-w.hurry:
+		}
+		delay *= 2
+	}
+}
+
+// close stops accepting records and waits until every queued record has
+// been written or has failed. Once ctx expires the rest of the queue is
+// still written, but failed inserts are retried without backing off. It
+// reports records that could not be stored while closing.
+func (w *batchWriter) close(ctx context.Context) error {
+	failed := w.failed.Load()
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		w.hurryOnce.Do(func() { close(w.hurry) })
+		<-w.done
+	}
+	if n := w.failed.Load() - failed; n > 0 {
+		return fmt.Errorf("%d audit records could not be stored", n)
+	}
+	return nil
+}
+
+func (w *batchWriter) stats() WriterStats {
+	return WriterStats{
+		Queued:  len(w.queue),
+		Written: w.written.Load(),
+		Failed:  w.failed.Load(),
+	}
+}
@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// gatedAuditStore blocks inserts until gate is closed.
+type gatedAuditStore struct {
+	memAuditStore
+	gate chan struct{}
+}
+
+func (s *gatedAuditStore) InsertAuditRecords(ctx context.Context, recs []*store.AuditRecord) error {
+	<-s.gate
+	return s.memAuditStore.InsertAuditRecords(ctx, recs)
+}
+
+func TestBatchWriterGroupCommitAndFlush(t *testing.T) {
+	ctx := context.Background()
+	s := &gatedAuditStore{gate: make(chan struct{})}
+	bus := NewBus()
+	since := bus.LastID()
+	l := NewLogger(s, nil, bus, WithBatchWriter(WriterOptions{QueueSize: 16}))
+
+	var ids []string
+	for i := range 5 {
+		rec := &store.AuditRecord{ToolName: "t" + strconv.Itoa(i), Status: "success"}
+		if err := l.Record(ctx, rec); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+		if rec.ID == "" || rec.CreatedAt.IsZero() {
+			t.Fatal("ID and timestamps not assigned before queueing")
+		}
+		ids = append(ids, rec.ID)
+	}
+	close(s.gate)
+
+	if err := l.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if len(s.recs) != 5 {
+		t.Fatalf("stored %d records after close, want 5", len(s.recs))
+	}
+	for i, rec := range s.recs {
+		if rec.ID != ids[i] {
+			t.Fatalf("record %d stored out of order", i)
+		}
+	}
+	// Records queued behind a blocked insert commit together.
+	if len(s.batches) > 2 {
+		t.Fatalf("batches = %v, want at most 2 transactions", s.batches)
+	}
+	if evs, _, _ := bus.Since(since); len(evs) != 5 {
+		t.Fatalf("published %d records, want 5", len(evs))
+	}
+	if got := l.WriterStats(); got.Written != 5 || got.Queued != 0 {
+		t.Fatalf("stats = %+v", got)
+	}
+
+	// After Close records are stored synchronously.
+	if err := l.Record(ctx, &store.AuditRecord{ToolName: "late"}); err != nil {
+		t.Fatalf("record after close: %v", err)
+	}
+	if len(s.recs) != 6 {
+		t.Fatal("record after close not stored")
+	}
+}
+
+func TestBatchWriterBackpressure(t *testing.T) {
+	s := &gatedAuditStore{gate: make(chan struct{})}
+	l := NewLogger(s, nil, nil, WithBatchWriter(WriterOptions{QueueSize: 1}))
+
+	record := func() {
+		t.Helper()
+		if err := l.Record(context.Background(), &store.AuditRecord{ToolName: "t"}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	// One record is being written, the next fills the queue.
+	record()
+	deadline := time.Now().Add(time.Second)
+	for l.WriterStats().Queued != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("writer did not take the first record")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	record()
+
+	// A caller that gives up waiting stores its record directly.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Record(ctx, &store.AuditRecord{ToolName: "direct"}); err != nil {
+		t.Fatalf("record on a full queue: %v", err)
+	}
+	if len(s.recs) != 1 || s.recs[0].ToolName != "direct" {
+		t.Fatal("record not stored after the queue stayed full")
+	}
+
+	close(s.gate)
+	if err := l.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if len(s.recs) != 3 {
+		t.Fatalf("stored %d records, want 3", len(s.recs))
+	}
+}
+
+func TestBatchWriterCloseDrainsFullQueue(t *testing.T) {
+	s := &gatedAuditStore{gate: make(chan struct{})}
+	l := NewLogger(s, nil, nil, WithBatchWriter(WriterOptions{QueueSize: 8}))
+
+	for i := range 9 {
+		if err := l.Record(context.Background(), &store.AuditRecord{ToolName: "t"}); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if i == 0 {
+			// Wait for the writer to block on the first record.
+			deadline := time.Now().Add(time.Second)
+			for l.WriterStats().Queued != 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("writer did not take the first record")
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+	if q := l.WriterStats().Queued; q != 8 {
+		t.Fatalf("queued = %d, want a full queue", q)
+	}
+
+	// The store stays blocked past Close's deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, func() { close(s.gate) })
+	if err := l.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if len(s.recs) != 9 {
+		t.Fatalf("stored %d records after close, want 9", len(s.recs))
+	}
+}
+
+// poisonAuditStore rejects every transaction containing a "bad" record.
+type poisonAuditStore struct {
+	gatedAuditStore
+}
+
+func (s *poisonAuditStore) InsertAuditRecords(ctx context.Context, recs []*store.AuditRecord) error {
+	for _, r := range recs {
+		if r.ToolName == "bad" {
+			return errors.New("constraint failed")
+		}
+	}
+	return s.gatedAuditStore.InsertAuditRecords(ctx, recs)
+}
+
+func TestBatchWriterFailedBatchFallsBackToSingleRows(t *testing.T) {
+	s := &poisonAuditStore{gatedAuditStore{gate: make(chan struct{})}}
+	l := NewLogger(s, nil, nil, WithBatchWriter(WriterOptions{RetryDelay: time.Millisecond}))
+
+	// The first record blocks the writer so the rest form one batch.
+	for _, name := range []string{"first", "a", "bad", "b"} {
+		if err := l.Record(context.Background(), &store.AuditRecord{ToolName: name}); err != nil {
+			t.Fatalf("record %s: %v", name, err)
+		}
+	}
+	close(s.gate)
+
+	if err := l.Close(context.Background()); err == nil {
+		t.Fatal("close did not report the failed record")
+	}
+	var stored []string
+	for _, r := range s.recs {
+		stored = append(stored, r.ToolName)
+	}
+	if len(stored) != 3 || stored[1] != "a" || stored[2] != "b" {
+		t.Fatalf("stored %v, want first, a and b", stored)
+	}
+	if got := l.WriterStats(); got.Written != 3 || got.Failed != 1 {
+		t.Fatalf("stats = %+v", got)
+	}
+}
+
+// countingScopeStore serves one auth scope and counts lookups.
+type countingScopeStore struct {
+	store.AuthScopeStore
+	gets int
+}
+
+func (s *countingScopeStore) GetAuthScope(_ context.Context, id string) (*store.AuthScope, error) {
+	s.gets++
+	return &store.AuthScope{ID: id, RedactionHints: json.RawMessage(`["account"]`)}, nil
+}
+
+func TestRedactionHintsCached(t *testing.T) {
+	ctx := context.Background()
+	s := &memAuditStore{}
+	scopes := &countingScopeStore{}
+	l := NewLogger(s, scopes, nil)
+
+	for range 3 {
+		rec := &store.AuditRecord{
+			AuthScopeID:    "scope-1",
+			ParamsRedacted: json.RawMessage(`{"account":"acct-42"}`),
+		}
+		if err := l.Record(ctx, rec); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+		if string(rec.ParamsRedacted) == `{"account":"acct-42"}` {
+			t.Fatal("hinted key not redacted")
+		}
+	}
+	if scopes.gets != 1 {
+		t.Fatalf("GetAuthScope called %d times, want 1", scopes.gets)
+	}
+}
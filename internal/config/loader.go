@@ -22,11 +22,40 @@ type FileConfig struct {
 }
 
 type workspaceConfig struct {
-	ID            string   `yaml:"id"`
-	Name          string   `yaml:"name"`
-	RootPath      string   `yaml:"root_path"`
-	Tags          []string `yaml:"tags,omitempty"`
-	DefaultPolicy string   `yaml:"default_policy"`
+	ID                 string                  `yaml:"id"`
+	Name               string                  `yaml:"name"`
+	RootPath           string                  `yaml:"root_path"`
+	Tags               []string                `yaml:"tags,omitempty"`
+	DefaultPolicy      string                  `yaml:"default_policy"`
+	AnnotationPolicy   *annotationPolicyConfig `yaml:"annotation_policy,omitempty"`
+	AuditRetentionDays int                     `yaml:"audit_retention_days,omitempty"`
+	AuditMaxRows       int                     `yaml:"audit_max_rows,omitempty"`
+}
+
+// annotationPolicyConfig mirrors store.AnnotationPolicy for YAML.
+type annotationPolicyConfig struct {
+	RequireApprovalDestructive *bool `yaml:"require_approval_destructive,omitempty"`
+	AutoAllowReadOnly          *bool `yaml:"auto_allow_read_only,omitempty"`
+}
+
+func (c *annotationPolicyConfig) toStore() store.AnnotationPolicy {
+	if c == nil {
+		return store.AnnotationPolicy{}
+	}
+	return store.AnnotationPolicy{
+		RequireApprovalDestructive: c.RequireApprovalDestructive,
+		AutoAllowReadOnly:          c.AutoAllowReadOnly,
+	}
+}
+
+func annotationPolicyFromStore(p store.AnnotationPolicy) *annotationPolicyConfig {
+	if p.RequireApprovalDestructive == nil && p.AutoAllowReadOnly == nil {
+		return nil
+	}
+	return &annotationPolicyConfig{
+		RequireApprovalDestructive: p.RequireApprovalDestructive,
+		AutoAllowReadOnly:          p.AutoAllowReadOnly,
+	}
 }
 
 type oauthProviderConfig struct {
@@ -48,29 +77,74 @@ type authScopeConfig struct {
 }
 
 type downstreamServerConfig struct {
-	ID             string   `yaml:"id"`
-	Name           string   `yaml:"name"`
-	Transport      string   `yaml:"transport"`
-	Command        string   `yaml:"command"`
-	Args           []string `yaml:"args,omitempty"`
-	URL            string   `yaml:"url,omitempty"`
-	ToolNamespace  string   `yaml:"tool_namespace"`
-	Discovery      string   `yaml:"discovery,omitempty"` // "static" (default) or "dynamic"
-	IdleTimeoutSec int      `yaml:"idle_timeout_sec"`
-	MaxInstances   int      `yaml:"max_instances"`
-	RestartPolicy  string   `yaml:"restart_policy"`
+	ID              string   `yaml:"id"`
+	Name            string   `yaml:"name"`
+	Transport       string   `yaml:"transport"`
+	Command         string   `yaml:"command"`
+	Args            []string `yaml:"args,omitempty"`
+	URL             string   `yaml:"url,omitempty"`
+	ToolNamespace   string   `yaml:"tool_namespace"`
+	Discovery       string   `yaml:"discovery,omitempty"` // "static" (default) or "dynamic"
+	IdleTimeoutSec  int      `yaml:"idle_timeout_sec"`
+	MaxInstances    int      `yaml:"max_instances"`
+	RestartPolicy   string   `yaml:"restart_policy"`
+	AnnotationTrust string   `yaml:"annotation_trust,omitempty"` // "trusted" (default) or "untrusted"
+	InstanceScope   string   `yaml:"instance_scope,omitempty"`   // "global" (default), "workspace" or "session"
+
+	Env            map[string]string `yaml:"env,omitempty"`
+	EnvPassthrough *[]string         `yaml:"env_passthrough,omitempty"` // unset inherits the whole OS env; [] none
+	WorkingDir     string            `yaml:"working_dir,omitempty"`
+	Sandbox        *sandboxConfig    `yaml:"sandbox,omitempty"`
+
+	HealthIntervalSec int `yaml:"health_interval_sec,omitempty"` // 0 uses the gateway default; negative disables probes
+	HealthTimeoutSec  int `yaml:"health_timeout_sec,omitempty"`  // 0 uses the gateway default
+
+	InitTimeoutSec int            `yaml:"init_timeout_sec,omitempty"` // 0 uses the gateway default
+	CallTimeoutSec int            `yaml:"call_timeout_sec,omitempty"` // 0 uses the gateway default; negative means none
+	ToolTimeouts   map[string]int `yaml:"tool_timeouts,omitempty"`    // call timeout in seconds by tool name (without namespace)
+}
+
+// sandboxConfig mirrors store.SandboxProfile for YAML.
+type sandboxConfig struct {
+	CPUSeconds   uint64   `yaml:"cpu_seconds,omitempty"`
+	MemoryMB     uint64   `yaml:"memory_mb,omitempty"`
+	MaxOpenFiles uint64   `yaml:"max_open_files,omitempty"`
+	Namespaces   bool     `yaml:"namespaces,omitempty"`
+	DenyNetwork  bool     `yaml:"deny_network,omitempty"`
+	Landlock     bool     `yaml:"landlock,omitempty"`
+	ReadPaths    []string `yaml:"read_paths,omitempty"`
+	WritePaths   []string `yaml:"write_paths,omitempty"`
+	Seccomp      bool     `yaml:"seccomp,omitempty"`
+	SeccompDeny  []string `yaml:"seccomp_deny,omitempty"`
+}
+
+func (c *sandboxConfig) toStore() *store.SandboxProfile {
+	if c == nil {
+		return nil
+	}
+	p := store.SandboxProfile(*c)
+	return &p
+}
+
+func sandboxFromStore(p *store.SandboxProfile) *sandboxConfig {
+	if p == nil {
+		return nil
+	}
+	c := sandboxConfig(*p)
+	return &c
 }
 
 type routeRuleConfig struct {
-	ID                 string `yaml:"id"`
-	Priority           int    `yaml:"priority"`
-	WorkspaceID        string `yaml:"workspace_id"`
-	PathGlob           string `yaml:"path_glob"`
-	ToolMatch          string `yaml:"tool_match"`
-	DownstreamServerID string `yaml:"downstream_server_id"`
-	AuthScopeID        string `yaml:"auth_scope_id"`
-	Policy             string `yaml:"policy"`
-	LogLevel           string `yaml:"log_level"`
+	ID                 string                  `yaml:"id"`
+	Priority           int                     `yaml:"priority"`
+	WorkspaceID        string                  `yaml:"workspace_id"`
+	PathGlob           string                  `yaml:"path_glob"`
+	ToolMatch          string                  `yaml:"tool_match"`
+	DownstreamServerID string                  `yaml:"downstream_server_id"`
+	AuthScopeID        string                  `yaml:"auth_scope_id"`
+	Policy             string                  `yaml:"policy"`
+	LogLevel           string                  `yaml:"log_level"`
+	AnnotationPolicy   *annotationPolicyConfig `yaml:"annotation_policy,omitempty"`
 }
 
 // LoadFile reads, parses, and validates a YAML config file.
@@ -122,8 +196,10 @@ func applyWorkspaces(ctx context.Context, tx store.Store, items []workspaceConfi
 		tags, _ := json.Marshal(w.Tags)
 		ws := &store.Workspace{
 			ID: w.ID, Name: w.Name, RootPath: w.RootPath,
-			Tags: tags, DefaultPolicy: w.DefaultPolicy, Source: "yaml",
-			UpdatedAt: time.Now().UTC(),
+			Tags: tags, DefaultPolicy: w.DefaultPolicy,
+			AnnotationPolicy:   w.AnnotationPolicy.toStore(),
+			AuditRetentionDays: w.AuditRetentionDays, AuditMaxRows: w.AuditMaxRows,
+			Source: "yaml", UpdatedAt: time.Now().UTC(),
 		}
 		existing, err := tx.GetWorkspace(ctx, w.ID)
 		if err != nil {
@@ -209,7 +285,14 @@ func applyDownstreamServers(ctx context.Context, tx store.Store, items []downstr
 			Command: d.Command, Args: args, ToolNamespace: d.ToolNamespace,
 			Discovery: d.Discovery, IdleTimeoutSec: d.IdleTimeoutSec,
 			MaxInstances: d.MaxInstances, RestartPolicy: d.RestartPolicy,
-			Source: "yaml", UpdatedAt: time.Now().UTC(),
+			AnnotationTrust: d.AnnotationTrust,
+			Env:             d.Env, WorkingDir: d.WorkingDir, InstanceScope: d.InstanceScope,
+			Sandbox: d.Sandbox.toStore(), Source: "yaml", UpdatedAt: time.Now().UTC(),
+			HealthIntervalSec: d.HealthIntervalSec, HealthTimeoutSec: d.HealthTimeoutSec,
+			InitTimeoutSec: d.InitTimeoutSec, CallTimeoutSec: d.CallTimeoutSec, ToolTimeouts: d.ToolTimeouts,
+		}
+		if d.EnvPassthrough != nil {
+			ds.EnvPassthrough = append([]string{}, *d.EnvPassthrough...)
 		}
 		if d.URL != "" {
 			ds.URL = &d.URL
@@ -240,9 +323,9 @@ func applyRouteRules(ctx context.Context, tx store.Store, items []routeRuleConfi
 			ID: r.ID, Priority: r.Priority, WorkspaceID: r.WorkspaceID,
 			PathGlob: r.PathGlob, ToolMatch: toolMatch,
 			DownstreamServerID: r.DownstreamServerID,
-			AuthScopeID: r.AuthScopeID, Policy: r.Policy,
-			LogLevel: r.LogLevel, Source: "yaml",
-			UpdatedAt: time.Now().UTC(),
+			AuthScopeID:        r.AuthScopeID, Policy: r.Policy,
+			LogLevel: r.LogLevel, AnnotationPolicy: r.AnnotationPolicy.toStore(),
+			Source: "yaml", UpdatedAt: time.Now().UTC(),
 		}
 		existing, err := tx.GetRouteRule(ctx, r.ID)
 		if err != nil {
@@ -27,6 +27,9 @@ func (s *Service) CreateWorkspace(ctx context.Context, w *store.Workspace) error
 	if err := validatePolicy(w.DefaultPolicy); err != nil {
 		return err
 	}
+	if err := validateAuditRetention(w.AuditRetentionDays, w.AuditMaxRows); err != nil {
+		return err
+	}
 	now := time.Now().UTC()
 	w.CreatedAt = now
 	w.UpdatedAt = now
@@ -38,6 +41,9 @@ func (s *Service) UpdateWorkspace(ctx context.Context, w *store.Workspace) error
 	if err := validatePolicy(w.DefaultPolicy); err != nil {
 		return err
 	}
+	if err := validateAuditRetention(w.AuditRetentionDays, w.AuditMaxRows); err != nil {
+		return err
+	}
 	w.UpdatedAt = time.Now().UTC()
 	return s.store.UpdateWorkspace(ctx, w)
 }
@@ -47,6 +53,24 @@ func (s *Service) CreateDownstreamServer(ctx context.Context, d *store.Downstrea
 	if err := validateTransport(d.Transport); err != nil {
 		return err
 	}
+	if err := validateAnnotationTrust(d.AnnotationTrust); err != nil {
+		return err
+	}
+	if err := validateInstanceScope(d.InstanceScope); err != nil {
+		return err
+	}
+	if err := validateSandbox(d.Transport, d.Sandbox); err != nil {
+		return err
+	}
+	if err := validateHealthCheck(d.HealthTimeoutSec); err != nil {
+		return err
+	}
+	if err := validateTimeouts(d.InitTimeoutSec, d.ToolTimeouts); err != nil {
+		return err
+	}
+	if err := validateServerEnv(d.Env, d.EnvPassthrough); err != nil {
+		return err
+	}
 	if err := s.checkNamespaceUnique(ctx, d.ToolNamespace, d.ID); err != nil {
 		return err
 	}
@@ -61,6 +85,24 @@ func (s *Service) UpdateDownstreamServer(ctx context.Context, d *store.Downstrea
 	if err := validateTransport(d.Transport); err != nil {
 		return err
 	}
+	if err := validateAnnotationTrust(d.AnnotationTrust); err != nil {
+		return err
+	}
+	if err := validateInstanceScope(d.InstanceScope); err != nil {
+		return err
+	}
+	if err := validateSandbox(d.Transport, d.Sandbox); err != nil {
+		return err
+	}
+	if err := validateHealthCheck(d.HealthTimeoutSec); err != nil {
+		return err
+	}
+	if err := validateTimeouts(d.InitTimeoutSec, d.ToolTimeouts); err != nil {
+		return err
+	}
+	if err := validateServerEnv(d.Env, d.EnvPassthrough); err != nil {
+		return err
+	}
 	if err := s.checkNamespaceUnique(ctx, d.ToolNamespace, d.ID); err != nil {
 		return err
 	}
@@ -155,6 +197,8 @@ func (s *Service) Export(ctx context.Context) (*FileConfig, error) {
 		cfg.Workspaces = append(cfg.Workspaces, workspaceConfig{
 			ID: w.ID, Name: w.Name, RootPath: w.RootPath,
 			Tags: tags, DefaultPolicy: w.DefaultPolicy,
+			AnnotationPolicy:   annotationPolicyFromStore(w.AnnotationPolicy),
+			AuditRetentionDays: w.AuditRetentionDays, AuditMaxRows: w.AuditMaxRows,
 		})
 	}
 	for _, a := range scopes {
@@ -179,6 +223,23 @@ func (s *Service) Export(ctx context.Context) (*FileConfig, error) {
 			IdleTimeoutSec: d.IdleTimeoutSec, MaxInstances: d.MaxInstances,
 			RestartPolicy: d.RestartPolicy,
 		}
+		if d.AnnotationTrust != "trusted" {
+			dc.AnnotationTrust = d.AnnotationTrust
+		}
+		if d.InstanceScope != "global" {
+			dc.InstanceScope = d.InstanceScope
+		}
+		dc.Env = d.Env
+		if d.EnvPassthrough != nil {
+			dc.EnvPassthrough = &d.EnvPassthrough
+		}
+		dc.WorkingDir = d.WorkingDir
+		dc.Sandbox = sandboxFromStore(d.Sandbox)
+		dc.HealthIntervalSec = d.HealthIntervalSec
+		dc.HealthTimeoutSec = d.HealthTimeoutSec
+		dc.InitTimeoutSec = d.InitTimeoutSec
+		dc.CallTimeoutSec = d.CallTimeoutSec
+		dc.ToolTimeouts = d.ToolTimeouts
 		if d.URL != nil {
 			dc.URL = *d.URL
 		}
@@ -223,6 +284,9 @@ func (s *Service) validateRouteRefs(ctx context.Context, r *store.RouteRule) err
 	if err := validateToolMatch(r.ToolMatch); err != nil {
 		return err
 	}
+	if err := validateLogLevel(r.LogLevel); err != nil {
+		return err
+	}
 	return validatePolicy(r.Policy)
 }
 
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+
+	"github.com/revitteth/mcplexer/internal/store"
 )
 
 // ValidationError holds all validation failures for a config file.
@@ -54,6 +56,9 @@ func validate(cfg *FileConfig) error {
 		if err := validatePolicy(ws.DefaultPolicy); err != nil {
 			errs = append(errs, fmt.Sprintf("workspaces[%d]: %v", i, err))
 		}
+		if err := validateAuditRetention(ws.AuditRetentionDays, ws.AuditMaxRows); err != nil {
+			errs = append(errs, fmt.Sprintf("workspaces[%d]: %v", i, err))
+		}
 	}
 
 	scopeIDs := make(map[string]bool, len(cfg.AuthScopes))
@@ -90,6 +95,28 @@ func validate(cfg *FileConfig) error {
 		if err := validateTransport(ds.Transport); err != nil {
 			errs = append(errs, fmt.Sprintf("downstream_servers[%d]: %v", i, err))
 		}
+		if err := validateAnnotationTrust(ds.AnnotationTrust); err != nil {
+			errs = append(errs, fmt.Sprintf("downstream_servers[%d]: %v", i, err))
+		}
+		if err := validateInstanceScope(ds.InstanceScope); err != nil {
+			errs = append(errs, fmt.Sprintf("downstream_servers[%d]: %v", i, err))
+		}
+		if err := validateSandbox(ds.Transport, ds.Sandbox.toStore()); err != nil {
+			errs = append(errs, fmt.Sprintf("downstream_servers[%d]: %v", i, err))
+		}
+		if err := validateHealthCheck(ds.HealthTimeoutSec); err != nil {
+			errs = append(errs, fmt.Sprintf("downstream_servers[%d]: %v", i, err))
+		}
+		if err := validateTimeouts(ds.InitTimeoutSec, ds.ToolTimeouts); err != nil {
+			errs = append(errs, fmt.Sprintf("downstream_servers[%d]: %v", i, err))
+		}
+		var passthrough []string
+		if ds.EnvPassthrough != nil {
+			passthrough = *ds.EnvPassthrough
+		}
+		if err := validateServerEnv(ds.Env, passthrough); err != nil {
+			errs = append(errs, fmt.Sprintf("downstream_servers[%d]: %v", i, err))
+		}
 	}
 
 	errs = append(errs, validateRouteRules(cfg.RouteRules, wsIDs, dsIDs, scopeIDs)...)
@@ -128,10 +155,23 @@ func validateRouteRules(rules []routeRuleConfig, wsIDs, dsIDs, scopeIDs map[stri
 		if err := validatePolicy(r.Policy); err != nil {
 			errs = append(errs, fmt.Sprintf("route_rules[%d]: %v", i, err))
 		}
+		if err := validateLogLevel(r.LogLevel); err != nil {
+			errs = append(errs, fmt.Sprintf("route_rules[%d]: %v", i, err))
+		}
 	}
 	return errs
 }
 
+func validateLogLevel(l string) error {
+	switch l {
+	case store.LogLevelNone, store.LogLevelMetadata, store.LogLevelInfo,
+		store.LogLevelFull, store.LogLevelDebug, "":
+		return nil
+	default:
+		return fmt.Errorf("invalid log_level %q (must be none, metadata, info, full or debug)", l)
+	}
+}
+
 func validatePolicy(p string) error {
 	switch p {
 	case "allow", "deny", "":
@@ -141,15 +181,102 @@ func validatePolicy(p string) error {
 	}
 }
 
+func validateAuditRetention(days, maxRows int) error {
+	if days < 0 || maxRows < 0 {
+		return fmt.Errorf("audit_retention_days and audit_max_rows must not be negative")
+	}
+	return nil
+}
+
 func validateTransport(t string) error {
 	switch t {
-	case "stdio", "http", "":
+	case "stdio", "http", "sse", "websocket", "":
+		return nil
+	default:
+		return fmt.Errorf("invalid transport %q (must be stdio, http, sse or websocket)", t)
+	}
+}
+
+func validateAnnotationTrust(t string) error {
+	switch t {
+	case "trusted", "untrusted", "":
 		return nil
 	default:
-		return fmt.Errorf("invalid transport %q (must be stdio or http)", t)
+		return fmt.Errorf("invalid annotation_trust %q (must be trusted or untrusted)", t)
 	}
 }
 
+func validateInstanceScope(s string) error {
+	switch s {
+	case "global", "workspace", "session", "":
+		return nil
+	default:
+		return fmt.Errorf("invalid instance_scope %q (must be global, workspace or session)", s)
+	}
+}
+
+// validateHealthCheck rejects negative probe timeouts. Any interval is
+// valid: 0 uses the default and a negative one disables probes.
+func validateHealthCheck(timeoutSec int) error {
+	if timeoutSec < 0 {
+		return fmt.Errorf("invalid health_timeout_sec %d (must not be negative)", timeoutSec)
+	}
+	return nil
+}
+
+// validateTimeouts rejects a negative init timeout and unnamed tool
+// timeouts. Call timeouts may be negative, which means none.
+func validateTimeouts(initSec int, tools map[string]int) error {
+	if initSec < 0 {
+		return fmt.Errorf("invalid init_timeout_sec %d (must not be negative)", initSec)
+	}
+	for name := range tools {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("tool_timeouts: empty tool name")
+		}
+	}
+	return nil
+}
+
+// validateServerEnv checks env names and passthrough patterns. A pattern
+// may end in * to match a prefix.
+func validateServerEnv(env map[string]string, passthrough []string) error {
+	for k := range env {
+		if k == "" || strings.ContainsAny(k, "=\x00") {
+			return fmt.Errorf("invalid env name %q", k)
+		}
+	}
+	for _, p := range passthrough {
+		name := strings.TrimSuffix(p, "*")
+		if p == "" || strings.ContainsAny(name, "=*\x00") {
+			return fmt.Errorf("invalid env_passthrough entry %q", p)
+		}
+	}
+	return nil
+}
+
+// validateSandbox checks a sandbox profile. Paths must be absolute or start
+// with a session template variable such as ${WORKSPACE_ROOT}.
+func validateSandbox(transport string, p *store.SandboxProfile) error {
+	if p == nil {
+		return nil
+	}
+	if transport != "" && transport != "stdio" {
+		return fmt.Errorf("sandbox applies to stdio servers only")
+	}
+	for _, path := range append(append([]string{}, p.ReadPaths...), p.WritePaths...) {
+		if !filepath.IsAbs(path) && !strings.HasPrefix(path, "${") {
+			return fmt.Errorf("sandbox path %q must be absolute", path)
+		}
+	}
+	for _, name := range p.SeccompDeny {
+		if name == "" || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+			return fmt.Errorf("invalid seccomp_deny syscall %q", name)
+		}
+	}
+	return nil
+}
+
 func validateGlob(pattern string) error {
 	if pattern == "" {
 		return nil
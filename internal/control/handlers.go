@@ -34,7 +34,6 @@ var handlers = map[string]handlerFunc{
 	"create_auth_scope": handleCreateAuthScope,
 	"delete_auth_scope": handleDeleteAuthScope,
 	// Info
-	"status":      handleStatus,
 	"query_audit": handleQueryAudit,
 }
 
@@ -5,27 +5,58 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/revitteth/mcplexer/internal/downstream"
 	"github.com/revitteth/mcplexer/internal/store"
 )
 
-func handleStatus(
-	ctx context.Context, s store.Store, _ json.RawMessage,
+// handleStatus counts the configured items and adds the health of each
+// probed downstream server, by name, when the gateway can be asked for it.
+func (s *Server) handleStatus(
+	ctx context.Context, st store.Store, _ json.RawMessage,
 ) (json.RawMessage, error) {
+	counts, servers, err := statusCounts(ctx, st)
+	if err != nil {
+		return nil, err
+	}
+	if s.daemon == nil {
+		return jsonResult(counts)
+	}
+	status := make(map[string]any, len(counts)+1)
+	for k, v := range counts {
+		status[k] = v
+	}
+	health, err := s.daemon.Health(ctx)
+	if err != nil {
+		status["downstream_health_error"] = err.Error()
+		return jsonResult(status)
+	}
+	byName := make(map[string]downstream.ServerHealth, len(health))
+	for _, srv := range servers {
+		if h, ok := health[srv.ID]; ok {
+			byName[srv.Name] = h
+		}
+	}
+	status["downstream_health"] = byName
+	return jsonResult(status)
+}
+
+// statusCounts counts the configured items and also returns the servers.
+func statusCounts(ctx context.Context, s store.Store) (map[string]int, []store.DownstreamServer, error) {
 	servers, err := s.ListDownstreamServers(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("list servers: %w", err)
+		return nil, nil, fmt.Errorf("list servers: %w", err)
 	}
 	workspaces, err := s.ListWorkspaces(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("list workspaces: %w", err)
+		return nil, nil, fmt.Errorf("list workspaces: %w", err)
 	}
 	sessions, err := s.ListActiveSessions(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("list sessions: %w", err)
+		return nil, nil, fmt.Errorf("list sessions: %w", err)
 	}
 	scopes, err := s.ListAuthScopes(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("list auth scopes: %w", err)
+		return nil, nil, fmt.Errorf("list auth scopes: %w", err)
 	}
 
 	status := map[string]int{
@@ -34,17 +65,29 @@ func handleStatus(
 		"active_sessions":    len(sessions),
 		"auth_scopes":        len(scopes),
 	}
-	return jsonResult(status)
+	return status, servers, nil
 }
 
 func handleQueryAudit(
 	ctx context.Context, s store.Store, args json.RawMessage,
 ) (json.RawMessage, error) {
 	var p struct {
-		ToolName *string `json:"tool_name"`
-		Status   *string `json:"status"`
-		Limit    int     `json:"limit"`
-		Offset   int     `json:"offset"`
+		Query              *string `json:"q"`
+		ToolName           *string `json:"tool_name"`
+		Status             *string `json:"status"`
+		WorkspaceID        *string `json:"workspace_id"`
+		SessionID          *string `json:"session_id"`
+		DownstreamServerID *string `json:"downstream_server_id"`
+		RouteRuleID        *string `json:"route_rule_id"`
+		AuthScopeID        *string `json:"auth_scope_id"`
+		ErrorCode          *string `json:"error_code"`
+		ClientType         *string `json:"client_type"`
+		TraceID            *string `json:"trace_id"`
+		MinLatencyMs       *int    `json:"min_latency_ms"`
+		Sort               string  `json:"sort"`
+		Cursor             string  `json:"cursor"`
+		Limit              int     `json:"limit"`
+		Offset             int     `json:"offset"`
 	}
 	if len(args) > 0 {
 		if err := json.Unmarshal(args, &p); err != nil {
@@ -54,12 +97,30 @@ func handleQueryAudit(
 	if p.Limit == 0 {
 		p.Limit = 50
 	}
+	if !store.ValidAuditSort(p.Sort) {
+		return nil, fmt.Errorf("invalid sort %q", p.Sort)
+	}
+	if p.Cursor != "" && p.Offset != 0 {
+		return nil, fmt.Errorf("cursor and offset cannot be combined")
+	}
 
 	filter := store.AuditFilter{
-		ToolName: p.ToolName,
-		Status:   p.Status,
-		Limit:    p.Limit,
-		Offset:   p.Offset,
+		Search:             p.Query,
+		ToolName:           p.ToolName,
+		Status:             p.Status,
+		WorkspaceID:        p.WorkspaceID,
+		SessionID:          p.SessionID,
+		DownstreamServerID: p.DownstreamServerID,
+		RouteRuleID:        p.RouteRuleID,
+		AuthScopeID:        p.AuthScopeID,
+		ErrorCode:          p.ErrorCode,
+		ClientType:         p.ClientType,
+		TraceID:            p.TraceID,
+		MinLatencyMs:       p.MinLatencyMs,
+		Sort:               p.Sort,
+		Cursor:             p.Cursor,
+		Limit:              p.Limit,
+		Offset:             p.Offset,
 	}
 	records, total, err := s.QueryAuditRecords(ctx, filter)
 	if err != nil {
@@ -70,5 +131,8 @@ func handleQueryAudit(
 		"records": records,
 		"total":   total,
 	}
+	if len(records) == p.Limit {
+		result["next_cursor"] = store.AuditCursor(p.Sort, &records[len(records)-1])
+	}
 	return jsonResult(result)
 }
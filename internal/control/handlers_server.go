@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/revitteth/mcplexer/internal/store"
 )
@@ -62,10 +63,19 @@ func handleUpdateServer(
 	if err != nil {
 		return nil, fmt.Errorf("get server: %w", err)
 	}
-	// Unmarshal args on top of existing record for partial update.
+	// Unmarshal args on top of existing record for partial update. A given
+	// env or tool_timeouts replaces the map rather than merging into it.
+	env, toolTimeouts := srv.Env, srv.ToolTimeouts
+	srv.Env, srv.ToolTimeouts = nil, nil
 	if err := json.Unmarshal(args, srv); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
+	if srv.Env == nil {
+		srv.Env = env
+	}
+	if srv.ToolTimeouts == nil {
+		srv.ToolTimeouts = toolTimeouts
+	}
 	srv.ID = id // ensure ID is not overwritten
 	if err := s.UpdateDownstreamServer(ctx, srv); err != nil {
 		return nil, fmt.Errorf("update server: %w", err)
@@ -85,3 +95,39 @@ func handleDeleteServer(
 	}
 	return textResult("deleted"), nil
 }
+
+func (s *Server) handleGetServerLogs(
+	ctx context.Context, _ store.Store, args json.RawMessage,
+) (json.RawMessage, error) {
+	var p struct {
+		ID    string `json:"id"`
+		Lines int    `json:"lines"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	if p.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	if p.Lines <= 0 {
+		p.Lines = 100
+	}
+	if _, err := s.store.GetDownstreamServer(ctx, p.ID); err != nil {
+		return nil, fmt.Errorf("get server: %w", err)
+	}
+	if s.daemon == nil {
+		return nil, fmt.Errorf("server logs are unavailable")
+	}
+	logs, err := s.daemon.Logs(ctx, p.ID, min(p.Lines, 1000))
+	if err != nil {
+		return nil, fmt.Errorf("get server logs: %w", err)
+	}
+	if len(logs) == 0 {
+		return textResult("no stderr output captured"), nil
+	}
+	lines := make([]string, len(logs))
+	for i, l := range logs {
+		lines[i] = l.String()
+	}
+	return textResult(strings.Join(lines, "\n")), nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/revitteth/mcplexer/internal/downstream"
 	"github.com/revitteth/mcplexer/internal/store"
 )
 
@@ -57,7 +59,10 @@ func TestHandleCreateServer(t *testing.T) {
 		"name": "new-server",
 		"command": "node",
 		"args": ["server.js"],
-		"tool_namespace": "myns"
+		"tool_namespace": "myns",
+		"env": {"READ_ONLY": "1"},
+		"env_passthrough": ["PATH"],
+		"working_dir": "/srv/app"
 	}`)
 
 	result, err := handleCreateServer(ctx, db, args)
@@ -91,6 +96,9 @@ func TestHandleCreateServer(t *testing.T) {
 	if got.ToolNamespace != "myns" {
 		t.Fatalf("namespace = %q", got.ToolNamespace)
 	}
+	if got.Env["READ_ONLY"] != "1" || len(got.EnvPassthrough) != 1 || got.WorkingDir != "/srv/app" {
+		t.Fatalf("env = %v, passthrough = %v, working_dir = %q", got.Env, got.EnvPassthrough, got.WorkingDir)
+	}
 }
 
 func TestHandleUpdateServer(t *testing.T) {
@@ -161,9 +169,10 @@ func TestHandleGetServerNotFound(t *testing.T) {
 func TestHandleStatus(t *testing.T) {
 	db := newTestDB(t)
 	ctx := context.Background()
+	ctl := New(db)
 
 	// Empty DB.
-	result, err := handleStatus(ctx, db, nil)
+	result, err := ctl.handleStatus(ctx, db, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -184,7 +193,7 @@ func TestHandleStatus(t *testing.T) {
 	seedServer(t, db)
 	seedWorkspace(t, db)
 
-	result, err = handleStatus(ctx, db, nil)
+	result, err = ctl.handleStatus(ctx, db, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -322,3 +331,81 @@ func TestHandleRequiredFieldValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleGetServerLogs(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	srv := seedServer(t, db)
+	ctl := New(db)
+	args := json.RawMessage(`{"id": "` + srv.ID + `", "lines": 5}`)
+
+	if _, err := ctl.handleGetServerLogs(ctx, db, args); err == nil {
+		t.Fatal("expected an error without a log source")
+	}
+
+	d := &fakeDaemon{logs: []downstream.LogLine{
+		{Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Text: "listening"},
+		{Time: time.Date(2026, 1, 2, 3, 4, 6, 0, time.UTC), Instance: "scope-1", Text: "boom"},
+	}}
+	ctl.SetDaemon(d)
+	result, err := ctl.handleGetServerLogs(ctx, db, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, isErr := parseToolResult(t, result)
+	if isErr {
+		t.Fatalf("unexpected error result: %s", text)
+	}
+	want := "2026-01-02T03:04:05Z listening\n2026-01-02T03:04:06Z [scope-1] boom"
+	if text != want || d.gotServer != srv.ID || d.gotLines != 5 {
+		t.Fatalf("text = %q (server %q, lines %d), want %q", text, d.gotServer, d.gotLines, want)
+	}
+
+	if _, err := ctl.handleGetServerLogs(ctx, db, json.RawMessage(`{"id": "missing"}`)); err == nil {
+		t.Fatal("expected an error for an unknown server")
+	}
+}
+
+func TestServerStatusIncludesHealth(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	srv := seedServer(t, db)
+	ctl := New(db)
+	ctl.SetDaemon(&fakeDaemon{health: map[string]downstream.ServerHealth{
+		srv.ID: {Status: "unhealthy", LastError: "ping: context deadline exceeded"},
+	}})
+
+	result, err := ctl.handleStatus(ctx, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _ := parseToolResult(t, result)
+	var status struct {
+		Servers int                                `json:"downstream_servers"`
+		Health  map[string]downstream.ServerHealth `json:"downstream_health"`
+	}
+	if err := json.Unmarshal([]byte(text), &status); err != nil {
+		t.Fatal(err)
+	}
+	h := status.Health[srv.Name]
+	if status.Servers != 1 || h.Status != "unhealthy" || h.LastError == "" {
+		t.Fatalf("status = %s", text)
+	}
+}
+
+// fakeDaemon serves canned live state and records log requests.
+type fakeDaemon struct {
+	logs      []downstream.LogLine
+	health    map[string]downstream.ServerHealth
+	gotServer string
+	gotLines  int
+}
+
+func (d *fakeDaemon) Logs(_ context.Context, serverID string, lines int) ([]downstream.LogLine, error) {
+	d.gotServer, d.gotLines = serverID, lines
+	return d.logs, nil
+}
+
+func (d *fakeDaemon) Health(context.Context) (map[string]downstream.ServerHealth, error) {
+	return d.health, nil
+}
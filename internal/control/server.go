@@ -10,6 +10,7 @@ import (
 	"os"
 	"sync"
 
+	"github.com/revitteth/mcplexer/internal/downstream"
 	"github.com/revitteth/mcplexer/internal/gateway"
 	"github.com/revitteth/mcplexer/internal/store"
 )
@@ -18,9 +19,17 @@ import (
 type Server struct {
 	store    store.Store
 	readOnly bool
+	daemon   Daemon // nil when the gateway's live state is unavailable
 	mu       sync.Mutex
 }
 
+// Daemon reads state that only the gateway process holds, such as the
+// stderr and health of running downstream instances.
+type Daemon interface {
+	Logs(ctx context.Context, serverID string, lines int) ([]downstream.LogLine, error)
+	Health(ctx context.Context) (map[string]downstream.ServerHealth, error)
+}
+
 // New creates a new control server.
 // When readOnly is true, admin tools (create/update/delete) are blocked.
 func New(s store.Store, readOnly ...bool) *Server {
@@ -31,6 +40,12 @@ func New(s store.Store, readOnly ...bool) *Server {
 	return &Server{store: s, readOnly: ro}
 }
 
+// SetDaemon enables the get_server_logs tool and downstream health in the
+// status tool.
+func (s *Server) SetDaemon(d Daemon) {
+	s.daemon = d
+}
+
 // RunStdio runs the control server over stdio.
 func (s *Server) RunStdio(ctx context.Context) error {
 	return s.run(ctx, os.Stdin, os.Stdout)
@@ -172,7 +187,7 @@ func (s *Server) handleToolsCall(
 		}
 	}
 
-	handler, ok := handlers[req.Name]
+	handler, ok := s.handler(req.Name)
 	if !ok {
 		return nil, &gateway.RPCError{
 			Code:    gateway.CodeMethodNotFound,
@@ -194,6 +209,19 @@ func (s *Server) handleToolsCall(
 	return result, nil
 }
 
+// handler returns the handler of a tool. Tools that need more than the
+// store are bound to the server here.
+func (s *Server) handler(name string) (handlerFunc, bool) {
+	switch name {
+	case "get_server_logs":
+		return s.handleGetServerLogs, true
+	case "status":
+		return s.handleStatus, true
+	}
+	h, ok := handlers[name]
+	return h, ok
+}
+
 func (s *Server) writeResponse(w io.Writer, resp *gateway.Response) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -81,8 +81,8 @@ func TestServerToolsList(t *testing.T) {
 		if err := json.Unmarshal(readResponses(t, out.Bytes())[0].Result, &result); err != nil {
 			t.Fatal(err)
 		}
-		if len(result.Tools) != 19 {
-			t.Fatalf("got %d tools, want 19", len(result.Tools))
+		if len(result.Tools) != 20 {
+			t.Fatalf("got %d tools, want 20", len(result.Tools))
 		}
 
 		names := make(map[string]bool)
@@ -111,9 +111,9 @@ func TestServerToolsList(t *testing.T) {
 		if err := json.Unmarshal(readResponses(t, out.Bytes())[0].Result, &result); err != nil {
 			t.Fatal(err)
 		}
-		// 19 total - 11 admin tools = 8 read-only tools.
-		if len(result.Tools) != 8 {
-			t.Fatalf("got %d tools, want 8 (read-only)", len(result.Tools))
+		// 20 total - 11 admin tools = 9 read-only tools.
+		if len(result.Tools) != 9 {
+			t.Fatalf("got %d tools, want 9 (read-only)", len(result.Tools))
 		}
 
 		// Admin tools should be absent.
@@ -19,35 +19,65 @@ func allTools() []gateway.Tool {
 			Description: "Get a downstream server by ID",
 			InputSchema: schema(props{"id": propStr("Server ID")}, []string{"id"}),
 		},
+		{
+			Name:        "get_server_logs",
+			Description: "Get the recent stderr output of a downstream server, oldest first",
+			InputSchema: schema(props{
+				"id":    propStr("Server ID"),
+				"lines": propInt("Number of recent lines (default 100, max 1000)"),
+			}, []string{"id"}),
+		},
 		{
 			Name:        "create_server",
 			Description: "Create a new downstream MCP server",
 			InputSchema: schema(props{
-				"name":             propStr("Unique server name"),
-				"transport":        propStr("Transport type: stdio"),
-				"command":          propStr("Command to run"),
-				"args":             propArr("Command arguments"),
-				"tool_namespace":   propStr("Tool namespace prefix"),
-				"discovery":        propStr("Discovery mode: static or dynamic"),
-				"idle_timeout_sec": propInt("Idle timeout in seconds"),
-				"max_instances":    propInt("Maximum concurrent instances"),
-				"restart_policy":   propStr("Restart policy: never, on-failure, always"),
+				"name":                propStr("Unique server name"),
+				"transport":           propStr("Transport type: stdio"),
+				"command":             propStr("Command to run"),
+				"args":                propArr("Command arguments"),
+				"tool_namespace":      propStr("Tool namespace prefix"),
+				"discovery":           propStr("Discovery mode: static or dynamic"),
+				"idle_timeout_sec":    propInt("Idle timeout in seconds"),
+				"max_instances":       propInt("Maximum concurrent instances"),
+				"restart_policy":      propStr("Restart policy: never, on-failure, always"),
+				"annotation_trust":    propStr("Whether to act on tool annotations: trusted or untrusted"),
+				"env":                 propObj("Extra environment variables (stdio); values may reference ${VAR}"),
+				"env_passthrough":     propArr("OS environment variables to inherit, NAME or PREFIX*; omit to inherit all"),
+				"working_dir":         propStr("Working directory for the server process (stdio)"),
+				"instance_scope":      propStr("Instance sharing: global, workspace or session"),
+				"sandbox":             propObj("Linux sandbox profile (stdio): cpu_seconds, memory_mb, max_open_files, namespaces, deny_network, landlock, read_paths, write_paths, seccomp, seccomp_deny"),
+				"health_interval_sec": propInt("Seconds between health probes (0 = gateway default, negative disables)"),
+				"health_timeout_sec":  propInt("Health probe timeout in seconds (0 = gateway default)"),
+				"init_timeout_sec":    propInt("Initialize handshake timeout in seconds (0 = gateway default)"),
+				"call_timeout_sec":    propInt("Tool call timeout in seconds (0 = gateway default, negative means none)"),
+				"tool_timeouts":       propObj("Call timeout in seconds by tool name (without namespace), overriding call_timeout_sec"),
 			}, []string{"name", "command", "tool_namespace"}),
 		},
 		{
 			Name:        "update_server",
 			Description: "Update a downstream MCP server (partial update, only provided fields change)",
 			InputSchema: schema(props{
-				"id":               propStr("Server ID"),
-				"name":             propStr("Unique server name"),
-				"transport":        propStr("Transport type"),
-				"command":          propStr("Command to run"),
-				"args":             propArr("Command arguments"),
-				"tool_namespace":   propStr("Tool namespace prefix"),
-				"discovery":        propStr("Discovery mode"),
-				"idle_timeout_sec": propInt("Idle timeout in seconds"),
-				"max_instances":    propInt("Maximum concurrent instances"),
-				"restart_policy":   propStr("Restart policy"),
+				"id":                  propStr("Server ID"),
+				"name":                propStr("Unique server name"),
+				"transport":           propStr("Transport type"),
+				"command":             propStr("Command to run"),
+				"args":                propArr("Command arguments"),
+				"tool_namespace":      propStr("Tool namespace prefix"),
+				"discovery":           propStr("Discovery mode"),
+				"idle_timeout_sec":    propInt("Idle timeout in seconds"),
+				"max_instances":       propInt("Maximum concurrent instances"),
+				"restart_policy":      propStr("Restart policy"),
+				"annotation_trust":    propStr("Whether to act on tool annotations"),
+				"env":                 propObj("Extra environment variables; replaces the current map"),
+				"env_passthrough":     propArr("OS environment variables to inherit; null inherits all"),
+				"working_dir":         propStr("Working directory for the server process"),
+				"instance_scope":      propStr("Instance sharing: global, workspace or session"),
+				"sandbox":             propObj("Linux sandbox profile; fields given are changed, null removes it"),
+				"health_interval_sec": propInt("Seconds between health probes (0 = gateway default, negative disables)"),
+				"health_timeout_sec":  propInt("Health probe timeout in seconds (0 = gateway default)"),
+				"init_timeout_sec":    propInt("Initialize handshake timeout in seconds (0 = gateway default)"),
+				"call_timeout_sec":    propInt("Tool call timeout in seconds (0 = gateway default, negative means none)"),
+				"tool_timeouts":       propObj("Call timeout in seconds by tool name; replaces the current map"),
 			}, []string{"id"}),
 		},
 		{
@@ -71,21 +101,27 @@ func allTools() []gateway.Tool {
 			Name:        "create_workspace",
 			Description: "Create a new workspace",
 			InputSchema: schema(props{
-				"name":           propStr("Unique workspace name"),
-				"root_path":      propStr("Root file path for the workspace"),
-				"default_policy": propStr("Default routing policy: allow or deny"),
-				"tags":           propArr("Workspace tags"),
+				"name":                 propStr("Unique workspace name"),
+				"root_path":            propStr("Root file path for the workspace"),
+				"default_policy":       propStr("Default routing policy: allow or deny"),
+				"tags":                 propArr("Workspace tags"),
+				"annotation_policy":    propObj("Annotation approval policy: {require_approval_destructive, auto_allow_read_only}"),
+				"audit_retention_days": propInt("Prune this workspace's audit records older than N days (0 = global policy)"),
+				"audit_max_rows":       propInt("Keep at most N audit records for this workspace (0 = global policy)"),
 			}, []string{"name"}),
 		},
 		{
 			Name:        "update_workspace",
 			Description: "Update a workspace (partial update, only provided fields change)",
 			InputSchema: schema(props{
-				"id":             propStr("Workspace ID"),
-				"name":           propStr("Unique workspace name"),
-				"root_path":      propStr("Root file path"),
-				"default_policy": propStr("Default routing policy"),
-				"tags":           propArr("Workspace tags"),
+				"id":                   propStr("Workspace ID"),
+				"name":                 propStr("Unique workspace name"),
+				"root_path":            propStr("Root file path"),
+				"default_policy":       propStr("Default routing policy"),
+				"tags":                 propArr("Workspace tags"),
+				"annotation_policy":    propObj("Annotation approval policy: {require_approval_destructive, auto_allow_read_only}"),
+				"audit_retention_days": propInt("Prune this workspace's audit records older than N days (0 = global policy)"),
+				"audit_max_rows":       propInt("Keep at most N audit records for this workspace (0 = global policy)"),
 			}, []string{"id"}),
 		},
 		{
@@ -106,28 +142,30 @@ func allTools() []gateway.Tool {
 			Name:        "create_route",
 			Description: "Create a new route rule",
 			InputSchema: schema(props{
-				"priority":              propInt("Route priority (lower number = higher priority)"),
-				"workspace_id":          propStr("Workspace ID"),
-				"path_glob":             propStr("Path glob pattern"),
-				"tool_match":            propObj("Tool match criteria"),
-				"downstream_server_id":  propStr("Downstream server ID"),
-				"auth_scope_id":         propStr("Auth scope ID"),
-				"policy":                propStr("Policy: allow or deny"),
-				"log_level":             propStr("Log level override"),
+				"priority":             propInt("Route priority (lower number = higher priority)"),
+				"workspace_id":         propStr("Workspace ID"),
+				"path_glob":            propStr("Path glob pattern"),
+				"tool_match":           propObj("Tool match criteria"),
+				"downstream_server_id": propStr("Downstream server ID"),
+				"auth_scope_id":        propStr("Auth scope ID"),
+				"policy":               propStr("Policy: allow or deny"),
+				"log_level":            propStr("Audit capture depth: none, metadata, info (default), full or debug"),
+				"annotation_policy":    propObj("Annotation approval policy: {require_approval_destructive, auto_allow_read_only} (overrides the workspace)"),
 			}, []string{"workspace_id", "downstream_server_id", "policy"}),
 		},
 		{
 			Name:        "update_route",
 			Description: "Update a route rule (partial update, only provided fields change)",
 			InputSchema: schema(props{
-				"id":                    propStr("Route rule ID"),
-				"priority":              propInt("Route priority"),
-				"path_glob":             propStr("Path glob pattern"),
-				"tool_match":            propObj("Tool match criteria"),
-				"downstream_server_id":  propStr("Downstream server ID"),
-				"auth_scope_id":         propStr("Auth scope ID"),
-				"policy":                propStr("Policy"),
-				"log_level":             propStr("Log level override"),
+				"id":                   propStr("Route rule ID"),
+				"priority":             propInt("Route priority"),
+				"path_glob":            propStr("Path glob pattern"),
+				"tool_match":           propObj("Tool match criteria"),
+				"downstream_server_id": propStr("Downstream server ID"),
+				"auth_scope_id":        propStr("Auth scope ID"),
+				"policy":               propStr("Policy"),
+				"log_level":            propStr("Audit capture depth: none, metadata, info (default), full or debug"),
+				"annotation_policy":    propObj("Annotation approval policy: {require_approval_destructive, auto_allow_read_only} (overrides the workspace)"),
 			}, []string{"id"}),
 		},
 		{
@@ -164,12 +202,24 @@ func allTools() []gateway.Tool {
 		},
 		{
 			Name:        "query_audit",
-			Description: "Query audit log records with optional filters",
+			Description: "Query audit log records with optional filters and full-text search",
 			InputSchema: schema(props{
-				"tool_name": propStr("Filter by tool name"),
-				"status":    propStr("Filter by status (success, error)"),
-				"limit":     propInt("Max records to return (default 50)"),
-				"offset":    propInt("Offset for pagination"),
+				"q":                    propStr("Full-text search over tool names, redacted params and error messages, e.g. customers.csv or \"rate limit\""),
+				"tool_name":            propStr("Filter by tool name"),
+				"status":               propStr("Filter by status (success, error, timeout)"),
+				"workspace_id":         propStr("Filter by workspace ID"),
+				"session_id":           propStr("Filter by session ID"),
+				"downstream_server_id": propStr("Filter by downstream server ID"),
+				"route_rule_id":        propStr("Filter by route rule ID"),
+				"auth_scope_id":        propStr("Filter by auth scope ID"),
+				"error_code":           propStr("Filter by error code"),
+				"client_type":          propStr("Filter by client type"),
+				"trace_id":             propStr("Filter by trace ID (32 hex characters)"),
+				"min_latency_ms":       propInt("Only calls at least this slow, in milliseconds"),
+				"sort":                 propStr("Sort order: timestamp_desc (default), timestamp_asc, latency_desc or latency_asc"),
+				"cursor":               propStr("next_cursor from a previous page; continues after it without duplicates"),
+				"limit":                propInt("Max records to return (default 50)"),
+				"offset":               propInt("Offset for pagination; not with cursor"),
 			}, nil),
 		},
 	}
@@ -36,6 +36,26 @@ func MergeEnv(osEnv []string, serverEnv, authEnv map[string]string) []string {
 	return out
 }
 
+// FilterEnv keeps the entries of osEnv named in allow. An allow entry
+// ending in * matches names with that prefix. A nil allow keeps
+// everything; an empty one keeps nothing.
+func FilterEnv(osEnv []string, allow []string) []string {
+	if allow == nil {
+		return osEnv
+	}
+	var out []string
+	for _, e := range osEnv {
+		k, _, _ := strings.Cut(e, "=")
+		for _, a := range allow {
+			if prefix, ok := strings.CutSuffix(a, "*"); (ok && strings.HasPrefix(k, prefix)) || k == a {
+				out = append(out, e)
+				break
+			}
+		}
+	}
+	return out
+}
+
 // expandVars replaces ${VAR} references in val with values from env.
 func expandVars(val string, env map[string]string) string {
 	return os.Expand(val, func(key string) string {
@@ -0,0 +1,75 @@
+package downstream
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFilterEnv(t *testing.T) {
+	osEnv := []string{"HOME=/home/u", "PATH=/bin", "AWS_REGION=eu", "AWS_SECRET=s", "AWSOME=1", "GOPATH=/go"}
+	tests := []struct {
+		name  string
+		allow []string
+		want  []string
+	}{
+		{"nil inherits all", nil, osEnv},
+		{"empty inherits none", []string{}, nil},
+		{"exact names", []string{"PATH", "HOME", "MISSING"}, []string{"HOME=/home/u", "PATH=/bin"}},
+		{"no partial match without *", []string{"AWS"}, nil},
+		{"trailing * matches a prefix", []string{"AWS_*"}, []string{"AWS_REGION=eu", "AWS_SECRET=s"}},
+		{"bare prefix", []string{"AWS*", "PATH"}, []string{"PATH=/bin", "AWS_REGION=eu", "AWS_SECRET=s", "AWSOME=1"}},
+		{"* alone matches everything", []string{"*"}, osEnv},
+	}
+	for _, tt := range tests {
+		got := FilterEnv(osEnv, tt.allow)
+		if tt.want == nil {
+			if got != nil {
+				t.Errorf("%s: FilterEnv = %q, want nil", tt.name, got)
+			}
+			continue
+		}
+		sort.Strings(got)
+		want := append([]string(nil), tt.want...)
+		sort.Strings(want)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: FilterEnv = %q, want %q", tt.name, got, want)
+		}
+	}
+}
+
+func TestMergeEnvExpansionOrder(t *testing.T) {
+	osEnv := FilterEnv([]string{"HOME=/home/u", "SECRET=s", "TOKEN=os"}, []string{"HOME", "TOKEN"})
+	serverEnv := map[string]string{
+		"CACHE":  "${HOME}/.cache", // inherited OS variable
+		"LEAK":   "${SECRET}",      // filtered out, so empty
+		"BEARER": "${TOKEN}",       // auth env is applied later: the OS value
+	}
+	authEnv := map[string]string{
+		"TOKEN":      "auth",
+		"TOKEN_FILE": "${CACHE}/token", // sees the server env
+	}
+
+	env := map[string]string{}
+	for _, e := range MergeEnv(osEnv, serverEnv, authEnv) {
+		k, v, _ := strings.Cut(e, "=")
+		env[k] = v
+	}
+	want := map[string]string{
+		"HOME":       "/home/u",
+		"BEARER":     "os",
+		"CACHE":      "/home/u/.cache",
+		"LEAK":       "",
+		"TOKEN":      "auth",
+		"TOKEN_FILE": "/home/u/.cache/token",
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("%s = %q, want %q", k, env[k], v)
+		}
+	}
+	if _, ok := env["SECRET"]; ok {
+		t.Error("filtered SECRET was inherited")
+	}
+}
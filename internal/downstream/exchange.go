@@ -0,0 +1,28 @@
+package downstream
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Exchange is the raw JSON-RPC request and response of one downstream call,
+// captured for debug-level audit records.
+type Exchange struct {
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+type exchangeKey struct{}
+
+// WithExchange returns a context that makes Manager.Call record the raw
+// JSON-RPC messages it exchanges with the downstream server into ex. The
+// fields are set before Call returns.
+func WithExchange(ctx context.Context, ex *Exchange) context.Context {
+	return context.WithValue(ctx, exchangeKey{}, ex)
+}
+
+// ExchangeFrom returns the Exchange attached by WithExchange, or nil.
+func ExchangeFrom(ctx context.Context) *Exchange {
+	ex, _ := ctx.Value(exchangeKey{}).(*Exchange)
+	return ex
+}
@@ -0,0 +1,251 @@
+package downstream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/metrics"
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+const (
+	defaultHealthInterval = 30 * time.Second
+	defaultHealthTimeout  = 10 * time.Second
+	unhealthyAfter        = 3  // consecutive failed probes before an instance is recycled
+	healthHistorySize     = 20 // probes kept per server
+)
+
+// errProbeSkipped is returned by ping when probing would say nothing about
+// the instance, e.g. while a stdio process is serving a call.
+var errProbeSkipped = errors.New("probe skipped")
+
+// HealthCheck is the result of one probe of an instance.
+type HealthCheck struct {
+	Time      time.Time `json:"time"`
+	Instance  string    `json:"instance,omitempty"`
+	OK        bool      `json:"ok"`
+	LatencyMs int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ServerHealth summarises the probes of a server's running instances.
+type ServerHealth struct {
+	Status      string        `json:"status"` // "healthy", "unhealthy" or "unknown" (no probes yet)
+	LastCheck   *time.Time    `json:"last_check,omitempty"`
+	LastError   string        `json:"last_error,omitempty"`
+	LastErrorAt *time.Time    `json:"last_error_at,omitempty"`
+	Recycled    int           `json:"recycled"` // instances replaced after failing probes
+	History     []HealthCheck `json:"history"`  // oldest first
+}
+
+// serverHealth is the probe state of one server.
+type serverHealth struct {
+	history     []HealthCheck
+	instances   map[InstanceKey]bool // last probe of each probed instance passed
+	lastError   string
+	lastErrorAt time.Time
+	recycled    int
+}
+
+// WithHealthChecks sets how often instances are probed and how long a probe
+// may take, for servers that do not set their own. A zero interval
+// disables probes for those servers.
+func WithHealthChecks(interval, timeout time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.healthInterval = interval
+		m.healthTimeout = timeout
+	}
+}
+
+// Health returns the probe summary of each server with probe results.
+func (m *Manager) Health() map[string]ServerHealth {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+
+	out := make(map[string]ServerHealth, len(m.health))
+	for id, sh := range m.health {
+		h := ServerHealth{
+			Status:   "unknown",
+			Recycled: sh.recycled,
+			History:  append([]HealthCheck(nil), sh.history...),
+		}
+		if len(sh.instances) > 0 {
+			h.Status = "healthy"
+			for _, ok := range sh.instances {
+				if !ok {
+					h.Status = "unhealthy"
+				}
+			}
+		}
+		if n := len(h.History); n > 0 {
+			h.LastCheck = &h.History[n-1].Time
+		}
+		if sh.lastError != "" {
+			t := sh.lastErrorAt
+			h.LastError = sh.lastError
+			h.LastErrorAt = &t
+		}
+		out[id] = h
+	}
+	return out
+}
+
+// healthSettings returns the probe interval and timeout of a server; a
+// zero interval means no probes.
+func (m *Manager) healthSettings(server *store.DownstreamServer) (interval, timeout time.Duration) {
+	interval = m.healthInterval
+	if server.HealthIntervalSec != 0 {
+		interval = time.Duration(server.HealthIntervalSec) * time.Second
+	}
+	timeout = m.healthTimeout
+	if server.HealthTimeoutSec > 0 {
+		timeout = time.Duration(server.HealthTimeoutSec) * time.Second
+	}
+	if interval < 0 {
+		interval = 0
+	}
+	return interval, timeout
+}
+
+// watchHealth probes inst every interval until it stops or is replaced.
+// After unhealthyAfter failed probes in a row the instance is recycled
+// according to the server's restart policy: "never" only reports it,
+// "always" starts a replacement right away and anything else ("on-failure")
+// stops it so the next call starts a fresh one, which restart does.
+func (m *Manager) watchHealth(
+	key InstanceKey, inst downstream, interval, timeout time.Duration, policy string, restart func(),
+) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		replace := false
+		defer func() {
+			m.forgetHealth(key)
+			if replace {
+				restart()
+			}
+		}()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		failures := 0
+		for {
+			select {
+			case <-m.closed:
+				return
+			case <-ticker.C:
+			}
+			if !m.tracks(key, inst) {
+				return
+			}
+			switch inst.getState() {
+			case StateStopped, StateStopping:
+				return
+			case StateStarting:
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			start := time.Now()
+			err := inst.ping(ctx)
+			cancel()
+			if errors.Is(err, errProbeSkipped) {
+				continue
+			}
+			var remote *remoteError
+			if errors.As(err, &remote) {
+				err = nil // it answered, just not to ping
+			}
+			m.recordHealth(key, start, time.Since(start), err)
+			if err == nil {
+				failures = 0
+				continue
+			}
+
+			failures++
+			slog.Warn("downstream health check failed",
+				"server", key.ServerID, "instance", key.label(), "failures", failures, "error", err)
+			if failures < unhealthyAfter || policy == "never" || !m.tracks(key, inst) {
+				continue
+			}
+			replace = m.recycle(key, inst, policy)
+			return
+		}
+	}()
+}
+
+// recycle stops an unhealthy instance and reports whether the restart
+// policy wants a replacement started right away.
+func (m *Manager) recycle(key InstanceKey, inst downstream, policy string) bool {
+	slog.Warn("recycling unhealthy downstream instance",
+		"server", key.ServerID, "instance", key.label(), "restart_policy", policy)
+
+	m.mu.Lock()
+	if m.instances[key] == inst {
+		delete(m.instances, key)
+	}
+	m.mu.Unlock()
+	inst.stop()
+
+	m.healthMu.Lock()
+	if sh := m.health[key.ServerID]; sh != nil {
+		sh.recycled++
+	}
+	m.healthMu.Unlock()
+
+	return policy == "always"
+}
+
+// tracks reports whether inst is still the manager's instance for key.
+func (m *Manager) tracks(key InstanceKey, inst downstream) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.instances[key] == inst
+}
+
+func (m *Manager) recordHealth(key InstanceKey, at time.Time, latency time.Duration, err error) {
+	check := HealthCheck{
+		Time:      at.UTC(),
+		Instance:  key.label(),
+		OK:        err == nil,
+		LatencyMs: latency.Milliseconds(),
+	}
+	result := "ok"
+	if err != nil {
+		check.Error = err.Error()
+		result = "failed"
+	}
+	metrics.DownstreamHealthChecks.Inc(key.ServerID, result)
+
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+
+	sh := m.health[key.ServerID]
+	if sh == nil {
+		sh = &serverHealth{instances: make(map[InstanceKey]bool)}
+		m.health[key.ServerID] = sh
+	}
+	if len(sh.history) == healthHistorySize {
+		sh.history = append(sh.history[:0], sh.history[1:]...)
+	}
+	sh.history = append(sh.history, check)
+	sh.instances[key] = check.OK
+	if err != nil {
+		sh.lastError = check.Error
+		sh.lastErrorAt = check.Time
+	}
+}
+
+// forgetHealth drops a stopped instance from its server's status; the
+// server's history and last error are kept.
+func (m *Manager) forgetHealth(key InstanceKey) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	if sh := m.health[key.ServerID]; sh != nil {
+		delete(sh.instances, key)
+	}
+}
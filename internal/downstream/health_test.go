@@ -0,0 +1,106 @@
+package downstream
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// Scripts for sh acting as MCP servers: echoServer answers every request
+// with an empty result, hungServer answers initialize and then stops
+// reading.
+const (
+	echoServer = `while read -r line; do
+  id=$(printf '%s' "$line" | sed -n 's/.*"id":\([0-9]*\).*/\1/p')
+  [ -n "$id" ] && printf '{"jsonrpc":"2.0","id":%s,"result":{}}\n' "$id"
+done`
+	hungServer = `read -r line; printf '{"jsonrpc":"2.0","id":1,"result":{}}\n'; exec sleep 600`
+)
+
+func startScripted(t *testing.T, m *Manager, key InstanceKey, script string) *Instance {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh")
+	}
+	inst := newInstance(key, sh, []string{"-c", script}, os.Environ(), "", nil, time.Minute)
+	if err := inst.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	m.instances[key] = inst
+	m.mu.Unlock()
+	return inst
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHealthCheckHealthy(t *testing.T) {
+	m := NewManager(nil, nil)
+	defer m.Shutdown(context.Background()) //nolint:errcheck
+	key := InstanceKey{ServerID: "srv"}
+	inst := startScripted(t, m, key, echoServer)
+
+	m.watchHealth(key, inst, 20*time.Millisecond, time.Second, "on-failure", func() {})
+	waitFor(t, "probes", func() bool { return len(m.Health()["srv"].History) >= 2 })
+
+	h := m.Health()["srv"]
+	if h.Status != "healthy" || h.LastError != "" || !h.History[0].OK {
+		t.Fatalf("health = %+v", h)
+	}
+	// Probes do not count as use: the instance never became busy or idle.
+	if s := inst.getState(); s != StateReady {
+		t.Fatalf("state = %s, want ready", s)
+	}
+}
+
+func TestHealthCheckRecyclesHungInstance(t *testing.T) {
+	m := NewManager(nil, nil)
+	defer m.Shutdown(context.Background()) //nolint:errcheck
+	key := InstanceKey{ServerID: "srv"}
+	inst := startScripted(t, m, key, hungServer)
+
+	restarted := make(chan struct{})
+	m.watchHealth(key, inst, 20*time.Millisecond, 20*time.Millisecond, "always", func() { close(restarted) })
+
+	select {
+	case <-restarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hung instance not replaced")
+	}
+	if m.tracks(key, inst) || inst.getState() != StateStopped {
+		t.Fatalf("hung instance still running (state %s)", inst.getState())
+	}
+	h := m.Health()["srv"]
+	if h.Recycled != 1 || h.LastError == "" || len(h.History) != unhealthyAfter {
+		t.Fatalf("health = %+v", h)
+	}
+}
+
+func TestHealthCheckNeverPolicyOnlyReports(t *testing.T) {
+	m := NewManager(nil, nil)
+	defer m.Shutdown(context.Background()) //nolint:errcheck
+	key := InstanceKey{ServerID: "srv"}
+	inst := startScripted(t, m, key, hungServer)
+	defer inst.stop()
+
+	m.watchHealth(key, inst, 20*time.Millisecond, 20*time.Millisecond, "never", func() {
+		t.Error("restarted with restart_policy never")
+	})
+	waitFor(t, "failed probes", func() bool { return len(m.Health()["srv"].History) > unhealthyAfter })
+
+	if h := m.Health()["srv"]; h.Status != "unhealthy" || h.Recycled != 0 || !m.tracks(key, inst) {
+		t.Fatalf("health = %+v, tracked %v", h, m.tracks(key, inst))
+	}
+}
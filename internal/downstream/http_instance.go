@@ -1,35 +1,63 @@
 package downstream
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/revitteth/mcplexer/internal/tracing"
 )
 
 // ErrAuthRequired indicates the downstream server returned 401 and needs OAuth.
 var ErrAuthRequired = errors.New("downstream server requires authentication")
 
-// HTTPInstance communicates with a remote MCP server over Streamable HTTP
-// (JSON-RPC over HTTP POST). Each request is a separate HTTP POST.
+// statusError is an unexpected HTTP status from a downstream server.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("http %d: %s", e.code, e.body)
+}
+
+// errSessionExpired means the server no longer knows the session a request
+// carried; the request may be retried on a new one.
+var errSessionExpired = errors.New("session expired")
+
+// errNotEventStream means a GET was answered with something other than an
+// event stream.
+var errNotEventStream = errors.New("response is not an event stream")
+
+// HTTPInstance communicates with a remote MCP server over Streamable HTTP.
+// Each message to the server is a POST, answered with JSON or an event
+// stream; a standing GET stream carries the server's own requests and
+// notifications. Streams that drop are resumed with Last-Event-ID, an
+// expired session is re-initialized transparently and the session is
+// ended with DELETE when the instance stops.
 type HTTPInstance struct {
 	key    InstanceKey
 	url    string
 	client *http.Client
 
-	mu          sync.Mutex
-	state       InstanceState
-	authHeaders http.Header
-	sessionID   string // Mcp-Session-Id from server
+	mu              sync.Mutex
+	state           InstanceState
+	authHeaders     http.Header
+	sessionID       string             // Mcp-Session-Id from server
+	protocolVersion string             // negotiated by initialize
+	listenCancel    context.CancelFunc // stops the GET stream of the session
+	renewMu         sync.Mutex         // serializes re-initialization
 
+	initTimeout time.Duration
 	idleTimeout time.Duration
 	idleTimer   *time.Timer
 	reqID       atomic.Int64
@@ -43,9 +71,9 @@ func newHTTPInstance(key InstanceKey, url string, idleTimeout time.Duration, hea
 		url:         url,
 		state:       StateStopped,
 		authHeaders: headers,
-		client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		// Requests are bounded by their context: the init and call timeouts.
+		client:      &http.Client{},
+		initTimeout: defaultInitTimeout,
 		idleTimeout: idleTimeout,
 	}
 }
@@ -73,35 +101,15 @@ func (h *HTTPInstance) start(ctx context.Context) error {
 	h.state = StateStarting
 	h.mu.Unlock()
 
-	// Perform MCP initialize handshake over HTTP (mutex released so doRPC can read authHeaders).
-	initReq := jsonRPCRequest{
-		JSONRPC: "2.0",
-		ID:      json.RawMessage(`1`),
-		Method:  "initialize",
-		Params: json.RawMessage(`{
-			"protocolVersion": "2024-11-05",
-			"capabilities": {},
-			"clientInfo": {"name": "mcplexer", "version": "0.1.0"}
-		}`),
-	}
+	ctx, cancel := context.WithTimeout(ctx, h.initTimeout)
+	defer cancel()
 
-	resp, err := h.doRPC(ctx, initReq)
-	if err != nil {
+	if err := h.initialize(ctx); err != nil {
 		h.mu.Lock()
 		h.state = StateStopped
 		h.mu.Unlock()
 		return fmt.Errorf("initialize: %w", err)
 	}
-	_ = resp // initialize response not needed
-
-	// Send initialized notification (no ID = notification).
-	notif := jsonRPCRequest{
-		JSONRPC: "2.0",
-		Method:  "notifications/initialized",
-	}
-	if _, err := h.doRPC(ctx, notif); err != nil {
-		// Non-fatal: some servers don't handle this
-	}
 
 	h.mu.Lock()
 	h.state = StateReady
@@ -109,14 +117,127 @@ func (h *HTTPInstance) start(ctx context.Context) error {
 	return nil
 }
 
+// stop ends the session, telling the server so it can free it.
 func (h *HTTPInstance) stop() {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	if h.idleTimer != nil {
 		h.idleTimer.Stop()
 	}
 	h.state = StateStopped
+	if h.listenCancel != nil {
+		h.listenCancel()
+		h.listenCancel = nil
+	}
+	sid := h.sessionID
+	h.sessionID = ""
+	h.mu.Unlock()
+
+	if sid != "" {
+		h.endSession(sid)
+	}
+}
+
+// initialize performs the MCP handshake, which starts a new session, and
+// opens the session's GET stream. The handshake is sent without a session;
+// concurrent requests keep using the old one until the new ID arrives.
+func (h *HTTPInstance) initialize(ctx context.Context) error {
+	id := h.nextID()
+	body, err := json.Marshal(jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  "initialize",
+		Params:  initializeParams,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	result, err := h.send(ctx, "", body, id)
+	if err != nil {
+		return err
+	}
+	var init struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	json.Unmarshal(result, &init) //nolint:errcheck // the header is optional
+
+	h.mu.Lock()
+	h.protocolVersion = init.ProtocolVersion
+	sid := h.sessionID
+	h.mu.Unlock()
+
+	// Non-fatal: some servers don't handle this.
+	if body, err := json.Marshal(jsonRPCRequest{JSONRPC: "2.0", Method: "notifications/initialized"}); err == nil {
+		h.send(ctx, sid, body, nil) //nolint:errcheck
+	}
+
+	listenCtx, cancel := context.WithCancel(context.Background())
+	h.mu.Lock()
+	if h.state == StateStopped {
+		// stop ran during the handshake and may have missed the new
+		// session; end it here unless it already did.
+		orphan := h.sessionID == sid && sid != ""
+		if orphan {
+			h.sessionID = ""
+		}
+		h.mu.Unlock()
+		cancel()
+		if orphan {
+			h.endSession(sid)
+		}
+		return fmt.Errorf("http instance stopped")
+	}
+	if h.listenCancel != nil {
+		h.listenCancel()
+	}
+	h.listenCancel = cancel
+	h.mu.Unlock()
+	go h.listen(listenCtx, sid)
+	return nil
+}
+
+// renewSession re-initializes after the server expired session stale,
+// unless a concurrent request already did.
+func (h *HTTPInstance) renewSession(ctx context.Context, stale string) error {
+	h.renewMu.Lock()
+	defer h.renewMu.Unlock()
+	if h.getState() == StateStopped {
+		return fmt.Errorf("http instance stopped")
+	}
+	if h.session() != stale {
+		return nil
+	}
+	slog.Info("downstream session expired, reinitializing", "server", h.key.ServerID)
+	return h.initialize(ctx)
+}
+
+// endSession sends DELETE for the session. Servers that do not let
+// clients end sessions answer 405, which is fine.
+func (h *HTTPInstance) endSession(sid string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelGrace)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, h.target(), nil)
+	if err != nil {
+		return
+	}
+	h.setHeaders(req, sid)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		slog.Debug("end downstream session", "server", h.key.ServerID, "error", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// ping sends an MCP ping with the current auth headers, checking that the
+// server is reachable and still accepts them. It does not count as use
+// for the idle timeout.
+func (h *HTTPInstance) ping(ctx context.Context) error {
+	_, err := h.doRPC(ctx, jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      h.nextID(),
+		Method:  "ping",
+	})
+	return err
 }
 
 // ListTools sends a tools/list request to the HTTP MCP server.
@@ -132,10 +253,9 @@ func (h *HTTPInstance) ListTools(ctx context.Context) (json.RawMessage, error) {
 		h.mu.Unlock()
 	}()
 
-	id := h.reqID.Add(1)
 	req := jsonRPCRequest{
 		JSONRPC: "2.0",
-		ID:      json.RawMessage(fmt.Sprintf(`%d`, id)),
+		ID:      h.nextID(),
 		Method:  "tools/list",
 		Params:  json.RawMessage(`{}`),
 	}
@@ -143,7 +263,8 @@ func (h *HTTPInstance) ListTools(ctx context.Context) (json.RawMessage, error) {
 	return h.doRPC(ctx, req)
 }
 
-// Call sends a tools/call request to the HTTP MCP server.
+// Call sends a request to the HTTP MCP server. If the caller gives up
+// first, the server is told with notifications/cancelled.
 func (h *HTTPInstance) Call(
 	ctx context.Context, method string, params json.RawMessage,
 ) (json.RawMessage, error) {
@@ -158,50 +279,75 @@ func (h *HTTPInstance) Call(
 		h.mu.Unlock()
 	}()
 
-	id := h.reqID.Add(1)
 	req := jsonRPCRequest{
 		JSONRPC: "2.0",
-		ID:      json.RawMessage(fmt.Sprintf(`%d`, id)),
+		ID:      h.nextID(),
 		Method:  method,
 		Params:  params,
 	}
 
-	return h.doRPC(ctx, req)
+	result, err := h.doRPC(ctx, req)
+	if err != nil && ctx.Err() != nil {
+		h.cancelRequest(req.ID, ctx.Err())
+	}
+	return result, err
 }
 
-// doRPC sends a JSON-RPC request via HTTP POST and returns the result.
+// cancelRequest tells the server to stop working on a request whose
+// caller gave up.
+func (h *HTTPInstance) cancelRequest(id json.RawMessage, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelGrace)
+	defer cancel()
+	params, _ := json.Marshal(map[string]any{"requestId": id, "reason": cancelReason(cause)})
+	_, err := h.doRPC(ctx, jsonRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "notifications/cancelled",
+		Params:  params,
+	})
+	if err != nil {
+		slog.Debug("send cancellation to downstream", "server", h.key.ServerID, "error", err)
+	}
+}
+
+// doRPC sends a JSON-RPC message and returns the result. A message sent
+// on a session the server has expired is retried once on a new session.
 func (h *HTTPInstance) doRPC(ctx context.Context, rpcReq jsonRPCRequest) (json.RawMessage, error) {
 	body, err := json.Marshal(rpcReq)
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
+	sid := h.session()
+	result, err := h.send(ctx, sid, body, rpcReq.ID)
+	if !errors.Is(err, errSessionExpired) {
+		return result, err
+	}
+	if err := h.renewSession(ctx, sid); err != nil {
+		return nil, fmt.Errorf("reinitialize expired session: %w", err)
+	}
+	return h.send(ctx, h.session(), body, rpcReq.ID)
+}
 
-	url := h.url
-	if h.sessionURL != "" {
-		url = h.sessionURL
+// send POSTs one message on session sid and, if id is set, returns the
+// result of the response to it.
+func (h *HTTPInstance) send(
+	ctx context.Context, sid string, body []byte, id json.RawMessage,
+) (json.RawMessage, error) {
+	ex := ExchangeFrom(ctx)
+	if ex != nil {
+		ex.Request = body
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.target(), bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	h.setHeaders(httpReq, sid)
 
-	// Inject auth headers (e.g. Authorization: Bearer <token>).
-	h.mu.Lock()
-	headers := h.authHeaders
-	sid := h.sessionID
-	h.mu.Unlock()
-	for k, vals := range headers {
-		for _, v := range vals {
-			httpReq.Header.Set(k, v)
-		}
-	}
-
-	// Include session ID from previous initialize handshake.
-	if sid != "" {
-		httpReq.Header.Set("Mcp-Session-Id", sid)
+	// Continue the caller's trace in the downstream server.
+	if tp := tracing.Traceparent(ctx); tp != "" {
+		httpReq.Header.Set("traceparent", tp)
 	}
 
 	resp, err := h.client.Do(httpReq)
@@ -210,8 +356,9 @@ func (h *HTTPInstance) doRPC(ctx context.Context, rpcReq jsonRPCRequest) (json.R
 	}
 	defer resp.Body.Close()
 
-	// Capture session ID from server (returned on initialize, echoed thereafter).
-	if v := resp.Header.Get("Mcp-Session-Id"); v != "" {
+	// Capture the session ID the server returns on initialize; later
+	// responses only echo it.
+	if v := resp.Header.Get("Mcp-Session-Id"); v != "" && sid == "" {
 		h.mu.Lock()
 		h.sessionID = v
 		h.mu.Unlock()
@@ -220,9 +367,12 @@ func (h *HTTPInstance) doRPC(ctx context.Context, rpcReq jsonRPCRequest) (json.R
 	if resp.StatusCode == http.StatusUnauthorized {
 		return nil, ErrAuthRequired
 	}
+	if resp.StatusCode == http.StatusNotFound && sid != "" {
+		return nil, errSessionExpired
+	}
 
-	// Notifications return 202 with no body.
-	if rpcReq.ID == nil {
+	// Notifications and responses return 202 with no body.
+	if id == nil {
 		if resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusOK {
 			return nil, nil
 		}
@@ -232,14 +382,14 @@ func (h *HTTPInstance) doRPC(ctx context.Context, rpcReq jsonRPCRequest) (json.R
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("http %d: %s", resp.StatusCode, respBody)
+		return nil, &statusError{code: resp.StatusCode, body: string(respBody)}
 	}
 
 	ct := resp.Header.Get("Content-Type")
 
 	// Handle SSE responses (text/event-stream).
 	if strings.HasPrefix(ct, "text/event-stream") {
-		return h.readSSEResponse(resp.Body)
+		return h.readSSEResponse(ctx, sid, resp.Body, id, ex)
 	}
 
 	// Standard JSON response.
@@ -247,6 +397,9 @@ func (h *HTTPInstance) doRPC(ctx context.Context, rpcReq jsonRPCRequest) (json.R
 	if err != nil {
 		return nil, fmt.Errorf("read response: %w", err)
 	}
+	if ex != nil {
+		ex.Response = respBody
+	}
 
 	var rpcResp jsonRPCResponse
 	if err := json.Unmarshal(respBody, &rpcResp); err != nil {
@@ -254,39 +407,215 @@ func (h *HTTPInstance) doRPC(ctx context.Context, rpcReq jsonRPCRequest) (json.R
 	}
 
 	if rpcResp.Error != nil {
-		return nil, fmt.Errorf("rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+		return nil, &remoteError{prefix: "rpc error", jsonRPCError: *rpcResp.Error}
 	}
 
 	return rpcResp.Result, nil
 }
 
-// readSSEResponse reads a text/event-stream response and extracts the JSON-RPC result.
-// Per MCP Streamable HTTP spec, the server sends SSE events with "data:" lines.
-func (h *HTTPInstance) readSSEResponse(body io.Reader) (json.RawMessage, error) {
-	scanner := bufio.NewScanner(body)
-	// GitHub's MCP API returns large tool lists that exceed the default 64KB buffer.
-	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024) // up to 4MB
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
+// readSSEResponse reads a text/event-stream response until the response to
+// request id, answering requests the server sends on the way. If the stream
+// ends first and its events had IDs, it is resumed over GET with
+// Last-Event-ID. When ex is non-nil it receives the raw JSON-RPC response.
+func (h *HTTPInstance) readSSEResponse(
+	ctx context.Context, sid string, body io.Reader, id json.RawMessage, ex *Exchange,
+) (json.RawMessage, error) {
+	var lastID string
+	var resp *jsonRPCResponse
+	var raw string
+	read := func(r io.Reader) error {
+		return readEvents(r, func(ev sseEvent) bool {
+			lastID = ev.id
+			if ev.event == "message" {
+				resp = h.handleMessage([]byte(ev.data), id)
+				raw = ev.data
+			}
+			return resp == nil
+		})
+	}
+
+	err := read(body)
+	for attempt := 1; resp == nil && lastID != "" && attempt <= maxReconnects; attempt++ {
+		if ctx.Err() != nil {
+			break
 		}
-		data := strings.TrimPrefix(line, "data: ")
-		var rpcResp jsonRPCResponse
-		if err := json.Unmarshal([]byte(data), &rpcResp); err != nil {
-			continue // skip non-JSON data lines
+		slog.Debug("resuming downstream response stream",
+			"server", h.key.ServerID, "last_event_id", lastID, "error", err)
+		var stream *http.Response
+		if stream, err = h.openStream(ctx, sid, lastID); err != nil {
+			break
 		}
-		if rpcResp.Error != nil {
-			return nil, fmt.Errorf("rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+		err = read(stream.Body)
+		stream.Body.Close()
+	}
+
+	if resp == nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read sse stream: %w", err)
 		}
-		if rpcResp.Result != nil {
-			return rpcResp.Result, nil
+		return nil, fmt.Errorf("no result in sse stream")
+	}
+	if ex != nil {
+		ex.Response = json.RawMessage(raw)
+	}
+	if resp.Error != nil {
+		return nil, &remoteError{prefix: "rpc error", jsonRPCError: *resp.Error}
+	}
+	return resp.Result, nil
+}
+
+// handleMessage handles a message from an event stream. It returns the
+// message if it is the response to request want; requests from the server
+// are answered and anything else is dropped.
+func (h *HTTPInstance) handleMessage(data []byte, want json.RawMessage) *jsonRPCResponse {
+	var msg struct {
+		jsonRPCResponse
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil // skip non-JSON data
+	}
+	if msg.Method != "" {
+		if msg.ID != nil {
+			go h.reply(msg.ID, msg.Method)
 		}
+		return nil
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("read sse stream: %w", err)
+	if want == nil || string(msg.ID) != string(want) || (msg.Result == nil && msg.Error == nil) {
+		return nil
 	}
-	return nil, fmt.Errorf("no result in sse stream")
+	return &msg.jsonRPCResponse
+}
+
+// reply answers a request the server sent on an event stream.
+func (h *HTTPInstance) reply(id json.RawMessage, method string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelGrace)
+	defer cancel()
+	if _, err := h.send(ctx, h.session(), replyTo(id, method), nil); err != nil {
+		slog.Debug("answer downstream request", "server", h.key.ServerID, "method", method, "error", err)
+	}
+}
+
+// listen holds the session's GET stream, on which the server sends
+// requests and notifications of its own, until ctx ends. A dropped stream
+// is reopened from its last event ID. Servers that offer no stream reject
+// the GET, usually with 405, and are not asked again.
+func (h *HTTPInstance) listen(ctx context.Context, sid string) {
+	var lastID string
+	delay := reconnectDelay
+	failures := 0
+	for {
+		stream, err := h.openStream(ctx, sid, lastID)
+		if err == nil {
+			failures, delay = 0, reconnectDelay
+			err = readEvents(stream.Body, func(ev sseEvent) bool {
+				lastID = ev.id
+				if ev.event == "message" {
+					h.handleMessage([]byte(ev.data), nil)
+				}
+				return true
+			})
+			stream.Body.Close()
+		} else {
+			var status *statusError
+			if errors.As(err, &status) || errors.Is(err, ErrAuthRequired) ||
+				errors.Is(err, errSessionExpired) || errors.Is(err, errNotEventStream) {
+				slog.Debug("downstream offers no event stream", "server", h.key.ServerID, "error", err)
+				return
+			}
+			if failures++; failures > maxReconnects {
+				slog.Warn("downstream event stream lost", "server", h.key.ServerID, "error", err)
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if err != nil {
+			delay *= 2
+		}
+	}
+}
+
+// openStream opens a GET event stream on session sid, resuming after
+// lastEventID if set.
+func (h *HTTPInstance) openStream(ctx context.Context, sid, lastEventID string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.target(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	h.setHeaders(req, sid)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http get: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"):
+		return resp, nil
+	case resp.StatusCode == http.StatusOK:
+		err = errNotEventStream
+	case resp.StatusCode == http.StatusUnauthorized:
+		err = ErrAuthRequired
+	case resp.StatusCode == http.StatusNotFound && sid != "":
+		err = errSessionExpired
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err = &statusError{code: resp.StatusCode, body: string(body)}
+	}
+	resp.Body.Close()
+	return nil, err
+}
+
+// setHeaders adds the auth headers (e.g. Authorization: Bearer <token>),
+// the session ID and the negotiated protocol version to req.
+func (h *HTTPInstance) setHeaders(req *http.Request, sid string) {
+	h.mu.Lock()
+	headers := h.authHeaders
+	version := h.protocolVersion
+	h.mu.Unlock()
+	for k, vals := range headers {
+		for _, v := range vals {
+			req.Header.Set(k, v)
+		}
+	}
+	if sid != "" {
+		req.Header.Set("Mcp-Session-Id", sid)
+	}
+	if version != "" {
+		req.Header.Set("Mcp-Protocol-Version", version)
+	}
+}
+
+// session returns the current session ID.
+func (h *HTTPInstance) session() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sessionID
+}
+
+// target is the URL requests are sent to.
+func (h *HTTPInstance) target() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sessionURL != "" {
+		return h.sessionURL
+	}
+	return h.url
+}
+
+func (h *HTTPInstance) nextID() json.RawMessage {
+	return json.RawMessage(fmt.Sprintf(`%d`, h.reqID.Add(1)))
 }
 
 func (h *HTTPInstance) resetIdleTimer() {
@@ -0,0 +1,335 @@
+package downstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// streamableServer is a Streamable HTTP MCP server. Sessions start on
+// initialize; tools/call is answered on an event stream, which drops
+// before the response when dropResponses is set so the client has to
+// resume it. The GET stream sends the client one ping request.
+type streamableServer struct {
+	*httptest.Server
+	dropResponses bool
+	noStream      bool // GET is answered with 405
+
+	mu       sync.Mutex
+	initHold chan struct{} // initialize waits on it while set
+	held     int           // initialize requests waiting on initHold
+	sessions map[string]bool
+	inits    int
+	gets     []string // Last-Event-ID of each GET
+	deleted  []string
+	pinged   bool // the client answered the GET stream's ping
+	versions []string
+	replay   map[string]string // event ID -> message sent after it
+}
+
+func newStreamableServer(t *testing.T) *streamableServer {
+	t.Helper()
+	srv := &streamableServer{sessions: make(map[string]bool), replay: make(map[string]string)}
+	srv.Server = httptest.NewServer(http.HandlerFunc(srv.serve))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (s *streamableServer) serve(w http.ResponseWriter, r *http.Request) {
+	sid := r.Header.Get("Mcp-Session-Id")
+	s.mu.Lock()
+	known := s.sessions[sid]
+	s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodDelete:
+		s.mu.Lock()
+		s.deleted = append(s.deleted, sid)
+		delete(s.sessions, sid)
+		s.mu.Unlock()
+	case http.MethodGet:
+		s.stream(w, r, known)
+	case http.MethodPost:
+		s.post(w, r, sid, known)
+	}
+}
+
+func (s *streamableServer) stream(w http.ResponseWriter, r *http.Request, known bool) {
+	if s.noStream {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !known {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	last := r.Header.Get("Last-Event-ID")
+	s.mu.Lock()
+	s.gets = append(s.gets, last)
+	msg, resuming := s.replay[last]
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	if resuming {
+		fmt.Fprintf(w, "id: %s-2\ndata: %s\n\n", last, msg)
+		return
+	}
+	fmt.Fprint(w, "id: g1\ndata: {\"jsonrpc\":\"2.0\",\"id\":\"srv-1\",\"method\":\"ping\"}\n\n")
+	w.(http.Flusher).Flush()
+	<-r.Context().Done()
+}
+
+func (s *streamableServer) post(w http.ResponseWriter, r *http.Request, sid string, known bool) {
+	var msg struct {
+		jsonRPCRequest
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	if hold := s.initHold; msg.Method == "initialize" && hold != nil {
+		s.held++
+		s.mu.Unlock()
+		<-hold
+		s.mu.Lock()
+	}
+	defer s.mu.Unlock()
+	if msg.Method == "initialize" {
+		s.inits++
+		sid = fmt.Sprintf("session-%d", s.inits)
+		s.sessions[sid] = true
+		w.Header().Set("Mcp-Session-Id", sid)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2025-06-18"}}`, msg.ID)
+		return
+	}
+	if !known {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.versions = append(s.versions, r.Header.Get("Mcp-Protocol-Version"))
+
+	switch {
+	case msg.ID == nil:
+		w.WriteHeader(http.StatusAccepted)
+	case msg.Method == "" && string(msg.ID) == `"srv-1"`:
+		s.pinged = msg.Result != nil
+		w.WriteHeader(http.StatusAccepted)
+	case msg.Method == "tools/call":
+		result := fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"ok":true}}`, msg.ID)
+		w.Header().Set("Content-Type", "text/event-stream")
+		eventID := fmt.Sprintf("call-%s", msg.ID)
+		fmt.Fprintf(w, "id: %s\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n", eventID)
+		if s.dropResponses {
+			s.replay[eventID] = result
+			return
+		}
+		fmt.Fprintf(w, "data: %s\n\n", result)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{}}`, msg.ID)
+	}
+}
+
+// expire forgets every session, as a server that restarted would.
+func (s *streamableServer) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]bool)
+}
+
+func (s *streamableServer) locked(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn()
+}
+
+func startHTTP(t *testing.T, srv *streamableServer) *HTTPInstance {
+	t.Helper()
+	h := newHTTPInstance(InstanceKey{ServerID: "srv"}, srv.URL, time.Minute, nil)
+	if err := h.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestHTTPInstanceSessionLifecycle(t *testing.T) {
+	srv := newStreamableServer(t)
+	h := startHTTP(t, srv)
+
+	// The standing GET stream delivers the server's ping, which is answered.
+	waitFor(t, "reply to server ping", func() bool {
+		var pinged bool
+		srv.locked(func() { pinged = srv.pinged })
+		return pinged
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := h.Call(ctx, "tools/call", json.RawMessage(`{"name":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != `{"ok":true}` {
+		t.Fatalf("result = %s", result)
+	}
+
+	h.stop()
+	srv.locked(func() {
+		if len(srv.deleted) != 1 || srv.deleted[0] != "session-1" {
+			t.Errorf("deleted sessions = %v", srv.deleted)
+		}
+		for _, v := range srv.versions {
+			if v != "2025-06-18" {
+				t.Errorf("Mcp-Protocol-Version = %q", v)
+			}
+		}
+	})
+}
+
+func TestHTTPInstanceReinitializesExpiredSession(t *testing.T) {
+	srv := newStreamableServer(t)
+	srv.noStream = true
+	h := startHTTP(t, srv)
+	defer h.stop()
+
+	srv.expire()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := h.ListTools(ctx); err != nil {
+		t.Fatal(err)
+	}
+	srv.locked(func() {
+		if srv.inits != 2 {
+			t.Errorf("initialized %d times, want 2", srv.inits)
+		}
+	})
+	if sid := h.session(); sid != "session-2" {
+		t.Fatalf("session = %q, want session-2", sid)
+	}
+}
+
+// holdInits makes later initialize requests wait until the returned
+// function is called.
+func (s *streamableServer) holdInits() (release func()) {
+	hold := make(chan struct{})
+	s.locked(func() { s.initHold = hold })
+	return func() {
+		s.locked(func() { s.initHold = nil })
+		close(hold)
+	}
+}
+
+func (s *streamableServer) heldInits() int {
+	var n int
+	s.locked(func() { n = s.held })
+	return n
+}
+
+func TestHTTPInstanceKeepsSessionDuringRenewal(t *testing.T) {
+	srv := newStreamableServer(t)
+	srv.noStream = true
+	h := startHTTP(t, srv)
+	defer h.stop()
+
+	srv.expire()
+	release := srv.holdInits()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errc := make(chan error, 2)
+	go func() {
+		_, err := h.ListTools(ctx)
+		errc <- err
+	}()
+	waitFor(t, "renewal", func() bool { return srv.heldInits() == 1 })
+
+	// Until the new session arrives, requests use the stale one and wait
+	// for the renewal instead of going out without a session.
+	if sid := h.session(); sid != "session-1" {
+		t.Fatalf("session during renewal = %q, want session-1", sid)
+	}
+	go func() {
+		_, err := h.ListTools(ctx)
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	release()
+	for range 2 {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv.locked(func() {
+		if srv.inits != 2 {
+			t.Errorf("initialized %d times, want 2", srv.inits)
+		}
+	})
+}
+
+func TestHTTPInstanceStopDuringRenewal(t *testing.T) {
+	srv := newStreamableServer(t)
+	h := startHTTP(t, srv)
+
+	srv.expire()
+	release := srv.holdInits()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := h.ListTools(ctx)
+		errc <- err
+	}()
+	waitFor(t, "renewal", func() bool { return srv.heldInits() == 1 })
+	h.stop()
+	release()
+
+	if err := <-errc; err == nil {
+		t.Fatal("call succeeded on a stopped instance")
+	}
+	// The session the renewal started is ended, not left open.
+	srv.locked(func() {
+		if len(srv.deleted) != 2 || srv.deleted[1] != "session-2" {
+			t.Errorf("deleted sessions = %v", srv.deleted)
+		}
+		if len(srv.sessions) != 0 {
+			t.Errorf("open sessions = %v", srv.sessions)
+		}
+	})
+	if sid := h.session(); sid != "" {
+		t.Fatalf("session = %q after stop", sid)
+	}
+}
+
+func TestHTTPInstanceResumesResponseStream(t *testing.T) {
+	srv := newStreamableServer(t)
+	srv.dropResponses = true
+	h := startHTTP(t, srv)
+	defer h.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := h.Call(ctx, "tools/call", json.RawMessage(`{"name":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != `{"ok":true}` {
+		t.Fatalf("result = %s", result)
+	}
+	srv.locked(func() {
+		resumed := false
+		for _, id := range srv.gets {
+			resumed = resumed || id != ""
+		}
+		if !resumed {
+			t.Errorf("no GET with Last-Event-ID: %q", srv.gets)
+		}
+	})
+}
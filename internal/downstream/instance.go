@@ -4,15 +4,22 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os/exec"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/revitteth/mcplexer/internal/tracing"
 )
 
+// errNoResponse means the process closed stdout before answering.
+var errNoResponse = errors.New("no response from downstream")
+
 // InstanceState represents the lifecycle state of a downstream process.
 type InstanceState int
 
@@ -48,6 +55,20 @@ func (s InstanceState) String() string {
 type InstanceKey struct {
 	ServerID    string
 	AuthScopeID string
+	Variant     string // hash of the expanded settings of a templated server
+	Scope       string // "workspace:<id>" or "session:<id>" unless the server is shared globally
+}
+
+// label tells apart the instances of one server in logs; it is empty for
+// a server's only instance.
+func (k InstanceKey) label() string {
+	var parts []string
+	for _, p := range []string{k.AuthScopeID, k.Scope, k.Variant} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "/")
 }
 
 // Instance manages a single downstream MCP server process.
@@ -56,7 +77,11 @@ type Instance struct {
 	command string
 	args    []string
 	env     []string
+	dir     string
+	sandbox *sandboxSpec // nil runs the process unconfined
+	stderr  *stderrLog   // nil discards stderr
 
+	initTimeout time.Duration
 	idleTimeout time.Duration
 	idleTimer   *time.Timer
 
@@ -72,12 +97,17 @@ type Instance struct {
 }
 
 // newInstance creates a new stopped instance.
-func newInstance(key InstanceKey, command string, args, env []string, idleTimeout time.Duration) *Instance {
+func newInstance(
+	key InstanceKey, command string, args, env []string, dir string, sandbox *sandboxSpec, idleTimeout time.Duration,
+) *Instance {
 	return &Instance{
 		key:         key,
 		command:     command,
 		args:        args,
 		env:         env,
+		dir:         dir,
+		sandbox:     sandbox,
+		initTimeout: defaultInitTimeout,
 		idleTimeout: idleTimeout,
 		state:       StateStopped,
 		done:        make(chan struct{}),
@@ -99,6 +129,22 @@ func (inst *Instance) start(ctx context.Context) error {
 
 	cmd := exec.CommandContext(childCtx, inst.command, inst.args...)
 	cmd.Env = inst.env
+	cmd.Dir = inst.dir
+	if inst.stderr != nil {
+		cmd.Stderr = inst.stderr
+		// Don't let a grandchild holding stderr open stall Wait.
+		cmd.WaitDelay = 5 * time.Second
+	}
+
+	var waitSandbox func() error
+	if inst.sandbox != nil {
+		var err error
+		if waitSandbox, err = inst.sandbox.wrap(cmd); err != nil {
+			cancel()
+			inst.state = StateStopped
+			return fmt.Errorf("sandbox: %w", err)
+		}
+	}
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -115,73 +161,66 @@ func (inst *Instance) start(ctx context.Context) error {
 	}
 
 	if err := cmd.Start(); err != nil {
+		if waitSandbox != nil {
+			waitSandbox() //nolint:errcheck
+		}
 		cancel()
 		inst.state = StateStopped
 		return fmt.Errorf("start process: %w", err)
 	}
+	if waitSandbox != nil {
+		if err := waitSandbox(); err != nil {
+			cmd.Wait() //nolint:errcheck
+			cancel()
+			inst.state = StateStopped
+			return withStderr(fmt.Errorf("sandbox: %w", err), inst.stderr.recent())
+		}
+	}
 
 	inst.cmd = cmd
 	inst.stdin = stdin
 	inst.done = make(chan struct{})
+	lines := make(chan []byte)
+	go readLines(stdout, lines, inst.done)
 
 	// Perform MCP initialize handshake with timeout.
-	initCtx, initCancel := context.WithTimeout(childCtx, 30*time.Second)
-	if err := inst.initialize(initCtx, stdin, stdout); err != nil {
+	initCtx, initCancel := context.WithTimeout(childCtx, inst.initTimeout)
+	if err := inst.initialize(initCtx, stdin, lines); err != nil {
 		initCancel()
 		cmd.Process.Kill()
+		cmd.Wait() //nolint:errcheck // collects the rest of stderr
+		close(inst.done)
 		cancel()
 		inst.state = StateStopped
-		return fmt.Errorf("initialize: %w", err)
+		return withStderr(fmt.Errorf("initialize: %w", err), inst.stderr.recent())
 	}
 	initCancel()
 
 	inst.state = StateReady
 
 	// Start the processing loop and monitor goroutines.
-	go inst.processLoop(stdout)
+	go inst.processLoop(lines)
 	go inst.monitorProcess(cmd)
 
 	return nil
 }
 
-func (inst *Instance) initialize(ctx context.Context, stdin io.Writer, stdout io.Reader) error {
+func (inst *Instance) initialize(ctx context.Context, stdin io.Writer, lines <-chan []byte) error {
 	initReq := jsonRPCRequest{
 		JSONRPC: "2.0",
 		ID:      json.RawMessage(`1`),
 		Method:  "initialize",
-		Params: json.RawMessage(`{
-			"protocolVersion": "2024-11-05",
-			"capabilities": {},
-			"clientInfo": {"name": "mcplexer", "version": "0.1.0"}
-		}`),
+		Params:  initializeParams,
 	}
 	if err := writeJSONLine(stdin, initReq); err != nil {
 		return fmt.Errorf("write initialize: %w", err)
 	}
 
-	// Read response with context timeout support.
-	type scanResult struct {
-		line []byte
-		err  error
-	}
-	ch := make(chan scanResult, 1)
-	go func() {
-		scanner := bufio.NewScanner(stdout)
-		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
-		if scanner.Scan() {
-			ch <- scanResult{line: append([]byte{}, scanner.Bytes()...)}
-		} else {
-			ch <- scanResult{err: fmt.Errorf("no initialize response")}
-		}
-	}()
-
-	select {
-	case <-ctx.Done():
-		return fmt.Errorf("initialize timed out: %w", ctx.Err())
-	case res := <-ch:
-		if res.err != nil {
-			return res.err
+	if _, _, err := inst.await(ctx, initReq.ID, lines); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("initialize timed out: %w", err)
 		}
+		return err
 	}
 
 	// Send initialized notification.
@@ -192,36 +231,84 @@ func (inst *Instance) initialize(ctx context.Context, stdin io.Writer, stdout io
 	return writeJSONLine(stdin, notif)
 }
 
-func (inst *Instance) processLoop(stdout io.Reader) {
-	defer close(inst.done)
+// readLines sends each line the process writes to stdout on lines until
+// EOF, when it closes lines, or until done is closed.
+func readLines(stdout io.Reader, lines chan<- []byte, done <-chan struct{}) {
+	defer close(lines)
 
 	scanner := bufio.NewScanner(stdout)
 	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		select {
+		case lines <- append([]byte(nil), scanner.Bytes()...):
+		case <-done:
+			return
+		}
+	}
+}
+
+func (inst *Instance) processLoop(lines <-chan []byte) {
+	defer close(inst.done)
 
 	for {
 		req, ok := inst.queue.dequeue()
 		if !ok {
 			return
 		}
+		req.Queued.End()
+		if err := req.Ctx.Err(); err != nil {
+			// The caller gave up while the request was queued.
+			req.Result <- response{Err: err}
+			continue
+		}
 
-		inst.mu.Lock()
-		inst.state = StateBusy
-		inst.mu.Unlock()
+		if !req.Probe {
+			inst.mu.Lock()
+			inst.state = StateBusy
+			inst.mu.Unlock()
+		}
 
-		result, err := inst.handleRequest(req, scanner)
+		var ex *Exchange
+		if req.Capture {
+			ex = &Exchange{}
+		}
+		result, err := inst.handleRequest(req, lines, ex)
 
-		req.Result <- response{Data: result, Err: err}
+		req.Result <- response{Data: result, Err: err, Exchange: ex}
 
-		inst.mu.Lock()
-		inst.state = StateIdle
-		inst.resetIdleTimer()
-		inst.mu.Unlock()
+		if !req.Probe && errors.Is(err, context.DeadlineExceeded) && !inst.responsive(lines) {
+			slog.Warn("downstream did not answer after a timed-out call, recycling",
+				"server", inst.key.ServerID, "instance", inst.key.label())
+			// stop waits for this loop to exit, so it runs on its own; the
+			// instance must not go back to idle meanwhile.
+			go inst.stop()
+			continue
+		}
+
+		if !req.Probe {
+			inst.mu.Lock()
+			inst.state = StateIdle
+			inst.resetIdleTimer()
+			inst.mu.Unlock()
+		}
 	}
 }
 
+// handleRequest writes req to the process and reads its response. When ex
+// is non-nil it receives copies of both raw messages. If the caller gives
+// up first, the process is told with notifications/cancelled.
 func (inst *Instance) handleRequest(
-	req request, scanner *bufio.Scanner,
-) (json.RawMessage, error) {
+	req request, lines <-chan []byte, ex *Exchange,
+) (_ json.RawMessage, err error) {
+	_, span := tracing.StartChild(
+		tracing.WithParent(context.Background(), req.Trace),
+		"downstream.request", tracing.KindClient,
+		tracing.String("rpc.method", req.Method))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	rpcReq := jsonRPCRequest{
 		JSONRPC: "2.0",
 		ID:      json.RawMessage(fmt.Sprintf(`%d`, req.ID)),
@@ -233,27 +320,102 @@ func (inst *Instance) handleRequest(
 	w := inst.stdin
 	inst.mu.Unlock()
 
+	if ex != nil {
+		ex.Request, _ = json.Marshal(rpcReq)
+	}
 	if err := writeJSONLine(w, rpcReq); err != nil {
 		return nil, fmt.Errorf("write request: %w", err)
 	}
 
-	if !scanner.Scan() {
-		return nil, fmt.Errorf("no response from downstream")
+	line, rpcResp, err := inst.await(req.Ctx, rpcReq.ID, lines)
+	if err != nil {
+		if ctxErr := req.Ctx.Err(); ctxErr != nil {
+			inst.cancelRequest(w, rpcReq.ID, ctxErr)
+			return nil, ctxErr
+		}
+		if errors.Is(err, errNoResponse) {
+			err = withStderr(err, inst.stderr.recent())
+		}
+		return nil, err
 	}
-
-	var rpcResp jsonRPCResponse
-	if err := json.Unmarshal(scanner.Bytes(), &rpcResp); err != nil {
-		return nil, fmt.Errorf("unmarshal response: %w", err)
+	if ex != nil {
+		ex.Response = line
 	}
 
 	if rpcResp.Error != nil {
-		return nil, fmt.Errorf("downstream error %d: %s",
-			rpcResp.Error.Code, rpcResp.Error.Message)
+		return nil, &remoteError{prefix: "downstream error", jsonRPCError: *rpcResp.Error}
 	}
 
 	return rpcResp.Result, nil
 }
 
+// await reads lines until the response to id. It skips anything else:
+// notifications, and late responses to requests whose caller gave up
+// (e.g. a timed-out health probe).
+func (inst *Instance) await(
+	ctx context.Context, id json.RawMessage, lines <-chan []byte,
+) ([]byte, jsonRPCResponse, error) {
+	for {
+		var line []byte
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return nil, jsonRPCResponse{}, ctx.Err()
+		case line, ok = <-lines:
+			if !ok {
+				return nil, jsonRPCResponse{}, errNoResponse
+			}
+		}
+		var resp jsonRPCResponse
+		if err := json.Unmarshal(line, &resp); err != nil {
+			return nil, resp, fmt.Errorf("unmarshal response: %w", err)
+		}
+		if string(resp.ID) == string(id) {
+			return line, resp, nil
+		}
+		slog.Debug("skipping unmatched downstream message",
+			"server", inst.key.ServerID, "id", string(resp.ID))
+	}
+}
+
+// cancelRequest tells the process to stop working on a request whose
+// caller gave up; any late response is skipped by await.
+func (inst *Instance) cancelRequest(w io.Writer, id json.RawMessage, cause error) {
+	params, _ := json.Marshal(map[string]any{"requestId": id, "reason": cancelReason(cause)})
+	err := writeJSONLine(w, jsonRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "notifications/cancelled",
+		Params:  params,
+	})
+	if err != nil {
+		slog.Debug("send cancellation to downstream",
+			"server", inst.key.ServerID, "error", err)
+	}
+}
+
+// responsive pings the process after a call timed out. A process that
+// cannot answer within cancelGrace is wedged, e.g. blocked in the
+// abandoned call, and has to be replaced.
+func (inst *Instance) responsive(lines <-chan []byte) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelGrace)
+	defer cancel()
+
+	inst.mu.Lock()
+	w := inst.stdin
+	inst.mu.Unlock()
+
+	ping := jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(fmt.Sprintf(`%d`, inst.reqID.Add(1))),
+		Method:  "ping",
+	}
+	if err := writeJSONLine(w, ping); err != nil {
+		return false
+	}
+	_, _, err := inst.await(ctx, ping.ID, lines)
+	return err == nil
+}
+
 func (inst *Instance) getState() InstanceState {
 	inst.mu.Lock()
 	defer inst.mu.Unlock()
@@ -266,18 +428,31 @@ func (inst *Instance) Call(
 ) (json.RawMessage, error) {
 	resultCh := make(chan response, 1)
 	id := int(inst.reqID.Add(1))
-
-	inst.queue.enqueue(request{
-		ID:     id,
-		Method: method,
-		Params: params,
-		Result: resultCh,
+	capture := ExchangeFrom(ctx)
+	_, queued := tracing.StartChild(ctx, "downstream.queue", tracing.KindInternal)
+
+	err := inst.queue.enqueue(request{
+		ID:      id,
+		Method:  method,
+		Params:  params,
+		Result:  resultCh,
+		Capture: capture != nil,
+		Ctx:     ctx,
+		Trace:   tracing.SpanContextFrom(ctx),
+		Queued:  queued,
 	})
+	if err != nil {
+		queued.End()
+		return nil, err
+	}
 
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	case resp := <-resultCh:
+		if capture != nil && resp.Exchange != nil {
+			*capture = *resp.Exchange
+		}
 		return resp.Data, resp.Err
 	}
 }
@@ -287,12 +462,16 @@ func (inst *Instance) ListTools(ctx context.Context) (json.RawMessage, error) {
 	resultCh := make(chan response, 1)
 	id := int(inst.reqID.Add(1))
 
-	inst.queue.enqueue(request{
+	err := inst.queue.enqueue(request{
 		ID:     id,
 		Method: "tools/list",
 		Params: json.RawMessage(`{}`),
 		Result: resultCh,
+		Ctx:    ctx,
 	})
+	if err != nil {
+		return nil, err
+	}
 
 	select {
 	case <-ctx.Done():
@@ -302,6 +481,31 @@ func (inst *Instance) ListTools(ctx context.Context) (json.RawMessage, error) {
 	}
 }
 
+// ping sends an MCP ping through the request queue. A busy process is
+// skipped: the ping would only wait behind the running call.
+func (inst *Instance) ping(ctx context.Context) error {
+	if inst.getState() == StateBusy {
+		return errProbeSkipped
+	}
+	resultCh := make(chan response, 1)
+	if !inst.queue.offer(request{
+		ID:     int(inst.reqID.Add(1)),
+		Method: "ping",
+		Result: resultCh,
+		Probe:  true,
+		Ctx:    ctx,
+	}) {
+		return fmt.Errorf("request queue full or closed")
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case resp := <-resultCh:
+		return resp.Err
+	}
+}
+
 func (inst *Instance) monitorProcess(cmd *exec.Cmd) {
 	err := cmd.Wait()
 	inst.mu.Lock()
@@ -313,7 +517,7 @@ func (inst *Instance) monitorProcess(cmd *exec.Cmd) {
 
 	if err != nil {
 		slog.Error("downstream process crashed",
-			"server", inst.key.ServerID, "error", err)
+			"server", inst.key.ServerID, "error", withStderr(err, inst.stderr.recent()))
 	}
 	inst.state = StateStopped
 }
@@ -3,25 +3,31 @@ package downstream
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/revitteth/mcplexer/internal/auth"
+	"github.com/revitteth/mcplexer/internal/eventbus"
+	"github.com/revitteth/mcplexer/internal/metrics"
 	"github.com/revitteth/mcplexer/internal/store"
+	"github.com/revitteth/mcplexer/internal/tracing"
 	"golang.org/x/sync/errgroup"
 )
 
-// downstream is the common interface for stdio and HTTP MCP instances.
+// downstream is the common interface for stdio and remote MCP instances.
 type downstream interface {
 	start(ctx context.Context) error
 	stop()
 	ListTools(ctx context.Context) (json.RawMessage, error)
 	Call(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error)
+	ping(ctx context.Context) error
 	getState() InstanceState
 }
 
@@ -31,82 +37,265 @@ type Manager struct {
 	auth      *auth.Injector
 	mu        sync.Mutex
 	instances map[InstanceKey]downstream
+	servers   map[string]*store.DownstreamServer // as last loaded, by ID
+
+	logMu       sync.Mutex
+	logs        map[string]*serverLog // by server ID
+	logDir      string                // stderr files; empty keeps logs in memory only
+	logMaxBytes int64
+	logBackups  int
+
+	healthInterval time.Duration // probe defaults for servers without their own
+	healthTimeout  time.Duration
+	healthMu       sync.Mutex
+	health         map[string]*serverHealth // by server ID
+	closed         chan struct{}            // closed by Shutdown to stop the probes
+	closeOnce      sync.Once
+
+	initTimeout time.Duration // defaults for servers without their own
+	callTimeout time.Duration // zero means none
+}
+
+// ManagerOption configures a Manager.
+type ManagerOption func(*Manager)
+
+// WithStderrFiles also appends each server's stderr to dir/<server id>.log,
+// rotating it past maxBytes and keeping maxBackups old files.
+func WithStderrFiles(dir string, maxBytes int64, maxBackups int) ManagerOption {
+	return func(m *Manager) {
+		m.logDir = dir
+		m.logMaxBytes = maxBytes
+		m.logBackups = maxBackups
+	}
 }
 
 // NewManager creates a new downstream process manager.
-func NewManager(s store.Store, authInj *auth.Injector) *Manager {
-	return &Manager{
+func NewManager(s store.Store, authInj *auth.Injector, opts ...ManagerOption) *Manager {
+	m := &Manager{
 		store:     s,
 		auth:      authInj,
 		instances: make(map[InstanceKey]downstream),
+		servers:   make(map[string]*store.DownstreamServer),
+		logs:      make(map[string]*serverLog),
+
+		healthInterval: defaultHealthInterval,
+		healthTimeout:  defaultHealthTimeout,
+		health:         make(map[string]*serverHealth),
+		closed:         make(chan struct{}),
+
+		initTimeout: defaultInitTimeout,
+		callTimeout: defaultCallTimeout,
+	}
+	for _, o := range opts {
+		o(m)
+	}
+	return m
+}
+
+// Logs returns the bus of stderr lines captured from a server's
+// instances. It keeps the most recent lines for replay.
+func (m *Manager) Logs(serverID string) *eventbus.Bus[LogLine] {
+	return m.serverLog(serverID).bus
+}
+
+func (m *Manager) serverLog(serverID string) *serverLog {
+	m.logMu.Lock()
+	defer m.logMu.Unlock()
+
+	if sl, ok := m.logs[serverID]; ok {
+		return sl
+	}
+	sl := &serverLog{bus: eventbus.New[LogLine](stderrBufferLines)}
+	if m.logDir != "" {
+		path := filepath.Join(m.logDir, serverID+".log")
+		f, err := newLogFile(path, m.logMaxBytes, m.logBackups)
+		if err != nil {
+			slog.Warn("downstream stderr file disabled", "server", serverID, "error", err)
+		} else {
+			sl.file = f
+		}
 	}
+	m.logs[serverID] = sl
+	return sl
 }
 
 // Call dispatches a tool call to the appropriate downstream instance.
-// It lazy-starts the process if not already running.
+// It lazy-starts the process if not already running. Template variables in
+// the server's settings expand from the context's SessionVars. The call's
+// trace context is passed on in params._meta.traceparent. A call that
+// outlives its timeout is cancelled downstream and fails with ErrTimeout.
 func (m *Manager) Call(
 	ctx context.Context,
 	serverID, authScopeID, toolName string,
 	args json.RawMessage,
-) (json.RawMessage, error) {
-	key := InstanceKey{ServerID: serverID, AuthScopeID: authScopeID}
-
-	inst, err := m.getOrStart(ctx, key)
+) (_ json.RawMessage, err error) {
+	ctx, span := tracing.Start(ctx, "downstream.call", tracing.KindClient,
+		tracing.String("mcplexer.downstream_server_id", serverID),
+		tracing.String("mcp.tool", toolName))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	inst, server, err := m.getOrStart(ctx, serverID, authScopeID)
 	if err != nil {
 		return nil, fmt.Errorf("get or start instance: %w", err)
 	}
 
-	params, err := json.Marshal(map[string]any{
+	callParams := map[string]any{
 		"name":      toolName,
 		"arguments": json.RawMessage(args),
-	})
+	}
+	if tp := tracing.Traceparent(ctx); tp != "" {
+		callParams["_meta"] = map[string]string{"traceparent": tp}
+	}
+	params, err := json.Marshal(callParams)
 	if err != nil {
 		return nil, fmt.Errorf("marshal call params: %w", err)
 	}
 
-	return inst.Call(ctx, "tools/call", json.RawMessage(params))
+	timeout := m.toolCallTimeout(server, toolName)
+	return callWithTimeout(ctx, timeout, func(ctx context.Context) (json.RawMessage, error) {
+		return inst.Call(ctx, "tools/call", json.RawMessage(params))
+	})
 }
 
-func (m *Manager) getOrStart(ctx context.Context, key InstanceKey) (downstream, error) {
+// callWithTimeout runs call under timeout, if any, and reports expiry as
+// ErrTimeout. The instance tells the server the request was cancelled.
+func callWithTimeout(
+	ctx context.Context, timeout time.Duration, call func(context.Context) (json.RawMessage, error),
+) (json.RawMessage, error) {
+	if timeout <= 0 {
+		return call(ctx)
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result, err := call(callCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w after %s", ErrTimeout, timeout)
+	}
+	return result, err
+}
+
+func (m *Manager) getOrStart(
+	ctx context.Context, serverID, authScopeID string,
+) (_ downstream, _ *store.DownstreamServer, err error) {
+	ctx, span := tracing.StartChild(ctx, "downstream.get_or_start", tracing.KindInternal,
+		tracing.String("mcplexer.downstream_server_id", serverID))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	vars := sessionFrom(ctx)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if inst, ok := m.instances[key]; ok {
-		if inst.getState() != StateStopped {
-			return inst, nil
+	// A running instance is found from the server as last loaded; the
+	// store is only read for a cold start, which picks up config changes.
+	server, loaded := m.servers[serverID], false
+	var spec *launchSpec
+	var key InstanceKey
+	for {
+		if server == nil {
+			if server, err = m.store.GetDownstreamServer(ctx, serverID); err != nil {
+				return nil, nil, fmt.Errorf("get server %s: %w", serverID, err)
+			}
+			m.servers[serverID] = server
+			loaded = true
+		}
+		spec, err = expandServer(server, vars)
+		if err == nil {
+			key = InstanceKey{
+				ServerID:    serverID,
+				AuthScopeID: authScopeID,
+				Variant:     spec.variant(),
+				Scope:       instanceScope(server, vars),
+			}
+			if inst, ok := m.instances[key]; ok {
+				if s := inst.getState(); s != StateStopped && s != StateStopping {
+					span.SetAttributes(tracing.Bool("mcplexer.cold_start", false))
+					return inst, server, nil
+				}
+			}
+		}
+		if loaded {
+			break
 		}
-		// Instance stopped (idle timeout or crash); remove and restart.
+		server = nil // nothing running for the cached settings; reload them
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	restart := false
+	if _, ok := m.instances[key]; ok {
+		// Instance stopped (idle timeout, crash or recycled); remove and restart.
 		delete(m.instances, key)
+		restart = true
 	}
+	span.SetAttributes(tracing.Bool("mcplexer.cold_start", true))
 
-	inst, err := m.createInstance(ctx, key)
+	inst, err := m.createInstance(ctx, key, server, spec)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if err := inst.start(ctx); err != nil {
-		return nil, fmt.Errorf("start instance: %w", err)
+	startCtx, startSpan := tracing.StartChild(ctx, "downstream.start", tracing.KindInternal)
+	inst, err = startInstance(startCtx, inst)
+	startSpan.RecordError(err)
+	startSpan.End()
+	if err != nil {
+		return nil, nil, fmt.Errorf("start instance: %w", err)
+	}
+	if restart {
+		metrics.DownstreamRestarts.Inc(key.ServerID)
 	}
 
 	m.instances[key] = inst
-	return inst, nil
+
+	interval, timeout := m.healthSettings(server)
+	m.watchHealth(key, inst, interval, timeout, server.RestartPolicy, func() {
+		restartCtx := WithSession(context.Background(), vars)
+		if _, _, err := m.getOrStart(restartCtx, serverID, authScopeID); err != nil {
+			slog.Error("restart unhealthy downstream instance",
+				"server", serverID, "error", err)
+		}
+	})
+	return inst, server, nil
 }
 
-func (m *Manager) createInstance(
-	ctx context.Context, key InstanceKey,
-) (downstream, error) {
-	server, err := m.store.GetDownstreamServer(ctx, key.ServerID)
-	if err != nil {
-		return nil, fmt.Errorf("get server %s: %w", key.ServerID, err)
+// startInstance starts inst. A Streamable HTTP server that rejects the
+// initialize POST with a 4xx may only speak the older HTTP+SSE transport,
+// so that is tried next on the same URL; the instance that started is
+// returned.
+func startInstance(ctx context.Context, inst downstream) (downstream, error) {
+	err := inst.start(ctx)
+	h, ok := inst.(*HTTPInstance)
+	var status *statusError
+	if err == nil || !ok || !errors.As(err, &status) || status.code < 400 || status.code >= 500 {
+		return inst, err
 	}
 
+	slog.Info("streamable http initialize rejected, trying http+sse",
+		"server", h.key.ServerID, "status", status.code)
+	legacy := h.legacy()
+	if sseErr := legacy.start(ctx); sseErr != nil {
+		return nil, fmt.Errorf("%w; http+sse: %w", err, sseErr)
+	}
+	return legacy, nil
+}
+
+func (m *Manager) createInstance(
+	ctx context.Context, key InstanceKey, server *store.DownstreamServer, spec *launchSpec,
+) (downstream, error) {
 	if server.Disabled {
 		return nil, fmt.Errorf("downstream server %q is disabled", server.Name)
 	}
 
 	timeout := time.Duration(server.IdleTimeoutSec) * time.Second
 
-	if server.Transport == "http" && server.URL != nil {
+	if server.Remote() && spec.URL != "" {
 		var headers http.Header
 		if m.auth != nil && key.AuthScopeID != "" {
 			var err error
@@ -115,17 +304,22 @@ func (m *Manager) createInstance(
 				return nil, fmt.Errorf("resolve auth for scope %s: %w", key.AuthScopeID, err)
 			}
 		}
-		return newHTTPInstance(key, *server.URL, timeout, headers), nil
-	}
-
-	// Default: stdio transport
-	var cmdArgs []string
-	if len(server.Args) > 0 {
-		if err := json.Unmarshal(server.Args, &cmdArgs); err != nil {
-			return nil, fmt.Errorf("unmarshal args: %w", err)
+		switch server.Transport {
+		case "sse":
+			s := newSSEInstance(key, spec.URL, timeout, headers)
+			s.initTimeout = m.serverInitTimeout(server)
+			return s, nil
+		case "websocket":
+			w := newWSInstance(key, spec.URL, timeout, headers)
+			w.initTimeout = m.serverInitTimeout(server)
+			return w, nil
 		}
+		h := newHTTPInstance(key, spec.URL, timeout, headers)
+		h.initTimeout = m.serverInitTimeout(server)
+		return h, nil
 	}
 
+	// Default: stdio transport
 	var authEnv map[string]string
 	if m.auth != nil {
 		var err error
@@ -135,23 +329,24 @@ func (m *Manager) createInstance(
 				"scope", key.AuthScopeID, "error", err)
 		}
 	}
-	env := MergeEnv(os.Environ(), nil, authEnv)
+	env := MergeEnv(FilterEnv(os.Environ(), server.EnvPassthrough), spec.Env, authEnv)
 
-	return newInstance(key, server.Command, cmdArgs, env, timeout), nil
+	inst := newInstance(key, spec.Command, spec.Args, env, spec.WorkingDir, spec.Sandbox, timeout)
+	inst.stderr = &stderrLog{serverID: key.ServerID, instance: key.label(), log: m.serverLog(key.ServerID)}
+	inst.initTimeout = m.serverInitTimeout(server)
+	return inst, nil
 }
 
 // ListTools sends a tools/list request to a specific downstream instance.
 func (m *Manager) ListTools(
 	ctx context.Context, serverID, authScopeID string,
 ) (json.RawMessage, error) {
-	key := InstanceKey{ServerID: serverID, AuthScopeID: authScopeID}
-
-	inst, err := m.getOrStart(ctx, key)
+	inst, server, err := m.getOrStart(ctx, serverID, authScopeID)
 	if err != nil {
 		return nil, fmt.Errorf("get or start instance: %w", err)
 	}
 
-	return inst.ListTools(ctx)
+	return callWithTimeout(ctx, m.toolCallTimeout(server, ""), inst.ListTools)
 }
 
 // ListAllTools queries all downstream servers for their tools in parallel.
@@ -243,8 +438,50 @@ func (m *Manager) ListInstances() []InstanceInfo {
 	return out
 }
 
+// RegisterMetrics reports the number of tracked instances per server and
+// state on every scrape of r.
+func (m *Manager) RegisterMetrics(r *metrics.Registry) {
+	r.Collect("mcplexer_downstream_instances", "Downstream instances by server and state.",
+		"gauge", []string{"server", "state"}, func() []metrics.Sample {
+			counts := make(map[[2]string]int)
+			for _, info := range m.ListInstances() {
+				counts[[2]string{info.Key.ServerID, info.State.String()}]++
+			}
+			out := make([]metrics.Sample, 0, len(counts))
+			for k, n := range counts {
+				out = append(out, metrics.Sample{Labels: k[:], Value: float64(n)})
+			}
+			return out
+		})
+}
+
+// StopSession stops the instances of per-session servers started for the
+// given session. It is called when the client disconnects.
+func (m *Manager) StopSession(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	scope := "session:" + sessionID
+
+	m.mu.Lock()
+	var instances []downstream
+	for key, inst := range m.instances {
+		if key.Scope == scope {
+			instances = append(instances, inst)
+			delete(m.instances, key)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, inst := range instances {
+		inst.stop()
+	}
+}
+
 // Shutdown gracefully stops all running instances.
 func (m *Manager) Shutdown(ctx context.Context) error {
+	m.closeOnce.Do(func() { close(m.closed) })
+
 	m.mu.Lock()
 	instances := make([]downstream, 0, len(m.instances))
 	for _, inst := range m.instances {
@@ -259,11 +496,26 @@ func (m *Manager) Shutdown(ctx context.Context) error {
 	m.mu.Lock()
 	m.instances = make(map[InstanceKey]downstream)
 	m.mu.Unlock()
+
+	m.logMu.Lock()
+	for _, sl := range m.logs {
+		if sl.file != nil {
+			sl.file.close()
+		}
+	}
+	m.logMu.Unlock()
 	return nil
 }
 
 // JSON-RPC types for downstream communication.
 
+// initializeParams are the gateway's initialize request params.
+var initializeParams = json.RawMessage(`{
+	"protocolVersion": "2024-11-05",
+	"capabilities": {},
+	"clientInfo": {"name": "mcplexer", "version": "0.1.0"}
+}`)
+
 type jsonRPCRequest struct {
 	JSONRPC string          `json:"jsonrpc"`
 	ID      json.RawMessage `json:"id,omitempty"`
@@ -283,6 +535,17 @@ type jsonRPCError struct {
 	Message string `json:"message"`
 }
 
+// remoteError is an error response from a downstream server, as opposed to
+// a failure to reach it.
+type remoteError struct {
+	prefix string
+	jsonRPCError
+}
+
+func (e *remoteError) Error() string {
+	return fmt.Sprintf("%s %d: %s", e.prefix, e.Code, e.Message)
+}
+
 func writeJSONLine(w io.Writer, v any) error {
 	data, err := json.Marshal(v)
 	if err != nil {
@@ -292,4 +555,3 @@ func writeJSONLine(w io.Writer, v any) error {
 	_, err = w.Write(data)
 	return err
 }
-
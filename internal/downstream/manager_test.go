@@ -0,0 +1,114 @@
+package downstream
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"sync"
+	"testing"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// serverStore serves one downstream server and counts how often it is
+// read. The other store methods are not used by these tests.
+type serverStore struct {
+	store.Store
+
+	mu     sync.Mutex
+	server store.DownstreamServer
+	gets   int
+}
+
+func (s *serverStore) GetDownstreamServer(_ context.Context, id string) (*store.DownstreamServer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id != s.server.ID {
+		return nil, store.ErrNotFound
+	}
+	s.gets++
+	srv := s.server
+	return &srv, nil
+}
+
+func (s *serverStore) reads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets
+}
+
+func newServerStore(t *testing.T) *serverStore {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	args, _ := json.Marshal([]string{"-c", echoServer})
+	return &serverStore{server: store.DownstreamServer{
+		ID: "srv", Name: "echo", Transport: "stdio", Command: "sh", Args: args,
+	}}
+}
+
+func TestGetOrStartReadsStoreOnlyOnColdStart(t *testing.T) {
+	st := newServerStore(t)
+	m := NewManager(st, nil)
+	defer m.Shutdown(context.Background()) //nolint:errcheck
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := m.ListTools(ctx, "srv", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := st.reads(); n != 1 {
+		t.Fatalf("store read %d times for one cold start, want 1", n)
+	}
+
+	// Once the instance stops, the next call reloads the server.
+	inst, _, err := m.getOrStart(ctx, "srv", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.stop()
+	if _, err := m.ListTools(ctx, "srv", ""); err != nil {
+		t.Fatal(err)
+	}
+	if n := st.reads(); n != 2 {
+		t.Fatalf("store read %d times after a restart, want 2", n)
+	}
+}
+
+func TestStopSessionStopsOnlyItsInstances(t *testing.T) {
+	m := NewManager(nil, nil)
+	defer m.Shutdown(context.Background()) //nolint:errcheck
+
+	own := InstanceKey{ServerID: "srv", Scope: "session:s-1"}
+	others := []InstanceKey{
+		{ServerID: "srv", Scope: "session:s-10"},
+		{ServerID: "srv", Scope: "workspace:s-1"},
+		{ServerID: "srv"},
+	}
+	inst := startScripted(t, m, own, echoServer)
+	var kept []*Instance
+	for _, key := range others {
+		kept = append(kept, startScripted(t, m, key, echoServer))
+	}
+
+	m.StopSession("")
+	m.StopSession("s-1")
+
+	if st := inst.getState(); st != StateStopped {
+		t.Errorf("session instance state = %s, want stopped", st)
+	}
+	m.mu.Lock()
+	_, tracked := m.instances[own]
+	n := len(m.instances)
+	m.mu.Unlock()
+	if tracked || n != len(others) {
+		t.Errorf("instances after StopSession: tracked=%v, %d left, want %d", tracked, n, len(others))
+	}
+	for i, k := range kept {
+		if st := k.getState(); st == StateStopped || st == StateStopping {
+			t.Errorf("instance %+v state = %s, want running", others[i], st)
+		}
+	}
+}
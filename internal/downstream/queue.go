@@ -1,6 +1,13 @@
 package downstream
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/revitteth/mcplexer/internal/tracing"
+)
 
 // request represents a JSON-RPC request to send to a downstream process.
 type request struct {
@@ -8,25 +15,76 @@ type request struct {
 	Method string
 	Params json.RawMessage // raw JSON-RPC params
 	Result chan response
+	// Capture asks for the raw messages in response.Exchange.
+	Capture bool
+	// Probe marks a health check, which leaves the instance's state and
+	// idle timer alone.
+	Probe bool
+	// Ctx is the caller's context; once it is done the request is
+	// abandoned.
+	Ctx context.Context
+	// Trace is the caller's span context; Queued times the wait for the
+	// process loop and is ended when the request is dequeued.
+	Trace  tracing.SpanContext
+	Queued *tracing.Span
 }
 
 // response is the result of a downstream tool call.
 type response struct {
-	Data json.RawMessage
-	Err  error
+	Data     json.RawMessage
+	Err      error
+	Exchange *Exchange // set when the request asked for capture
 }
 
+// errQueueClosed is returned for requests to an instance that is stopping.
+var errQueueClosed = errors.New("downstream instance is stopping")
+
 // requestQueue is a buffered channel of pending requests.
 type requestQueue struct {
 	ch chan request
+
+	mu        sync.RWMutex // orders enqueue and offer against close
+	closed    bool
+	closing   chan struct{} // closed first, releasing blocked enqueues
+	closeOnce sync.Once
 }
 
 func newRequestQueue(size int) *requestQueue {
-	return &requestQueue{ch: make(chan request, size)}
+	return &requestQueue{ch: make(chan request, size), closing: make(chan struct{})}
+}
+
+// enqueue adds r to the queue, waiting while it is full. It fails once the
+// queue is closed or r's context is done.
+func (q *requestQueue) enqueue(r request) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return errQueueClosed
+	}
+	select {
+	case q.ch <- r:
+		return nil
+	case <-q.closing:
+		return errQueueClosed
+	case <-r.Ctx.Done():
+		return r.Ctx.Err()
+	}
 }
 
-func (q *requestQueue) enqueue(r request) {
-	q.ch <- r
+// offer enqueues r unless the queue is full or already closed, so a health
+// probe never blocks on a stuck or stopping instance.
+func (q *requestQueue) offer(r request) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return false
+	}
+	select {
+	case q.ch <- r:
+		return true
+	default:
+		return false
+	}
 }
 
 func (q *requestQueue) dequeue() (request, bool) {
@@ -35,5 +93,12 @@ func (q *requestQueue) dequeue() (request, bool) {
 }
 
 func (q *requestQueue) close() {
+	q.closeOnce.Do(func() { close(q.closing) })
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
 	close(q.ch)
 }
@@ -0,0 +1,51 @@
+package downstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRequestQueueCloseReleasesBlockedEnqueue(t *testing.T) {
+	q := newRequestQueue(1)
+	ctx := context.Background()
+	if err := q.enqueue(request{Ctx: ctx}); err != nil {
+		t.Fatal(err)
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- q.enqueue(request{Ctx: ctx}) }() // blocks: queue full
+	time.Sleep(20 * time.Millisecond)
+	q.close()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errQueueClosed) {
+			t.Fatalf("err = %v, want errQueueClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("enqueue still blocked after close")
+	}
+	if err := q.enqueue(request{Ctx: ctx}); !errors.Is(err, errQueueClosed) {
+		t.Fatalf("enqueue after close = %v", err)
+	}
+	q.close() // closing twice is harmless
+}
+
+func TestCallOnStoppedInstance(t *testing.T) {
+	m := NewManager(nil, nil)
+	defer m.Shutdown(context.Background()) //nolint:errcheck
+	inst := startScripted(t, m, InstanceKey{ServerID: "srv"}, echoServer)
+	inst.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := inst.Call(ctx, "tools/call", json.RawMessage(`{}`)); !errors.Is(err, errQueueClosed) {
+		t.Fatalf("Call err = %v, want errQueueClosed", err)
+	}
+	if _, err := inst.ListTools(ctx); !errors.Is(err, errQueueClosed) {
+		t.Fatalf("ListTools err = %v, want errQueueClosed", err)
+	}
+}
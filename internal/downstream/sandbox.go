@@ -0,0 +1,34 @@
+package downstream
+
+import (
+	"os"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// sandboxEnv carries the sandbox spec from the daemon to the helper it
+// re-executes as. The helper removes it before starting the server.
+const sandboxEnv = "MCPLEXER_SANDBOX"
+
+// sandboxSpec is what the helper needs to confine one server process.
+type sandboxSpec struct {
+	store.SandboxProfile
+
+	Root    string `json:"root,omitempty"`    // workspace root; writable under Landlock
+	Dir     string `json:"dir,omitempty"`     // working directory; readable under Landlock
+	Command string `json:"command,omitempty"` // resolved server executable
+	ErrFD   int    `json:"err_fd,omitempty"`  // pipe for setup errors, closed on exec
+}
+
+// SandboxMain must be called first thing in main. When the process was
+// started as a sandbox helper for a downstream server, it confines itself
+// according to the spec in its environment and execs the server, never
+// returning. Otherwise it returns immediately.
+func SandboxMain() {
+	data, ok := os.LookupEnv(sandboxEnv)
+	if !ok {
+		return
+	}
+	os.Unsetenv(sandboxEnv) //nolint:errcheck
+	runSandboxHelper(data)
+}
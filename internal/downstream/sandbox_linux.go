@@ -0,0 +1,320 @@
+package downstream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// wrap rewrites cmd to start through the sandbox helper: the daemon's own
+// binary, which confines itself and then execs the server in place. The
+// returned function must be called after cmd.Start (whether or not it
+// succeeded); it waits until the helper has exec'd the server and returns
+// any error it hit while setting up the sandbox.
+func (s *sandboxSpec) wrap(cmd *exec.Cmd) (func() error, error) {
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locate sandbox helper: %w", err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("sandbox error pipe: %w", err)
+	}
+
+	spec := *s
+	spec.Command = cmd.Path
+	spec.Dir = cmd.Dir
+	spec.ErrFD = 3 + len(cmd.ExtraFiles)
+	data, err := json.Marshal(spec)
+	if err != nil {
+		r.Close()
+		w.Close()
+		return nil, fmt.Errorf("marshal sandbox spec: %w", err)
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env[:len(env):len(env)], sandboxEnv+"="+string(data))
+	cmd.Path = self
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+
+	attr := &syscall.SysProcAttr{}
+	if s.Namespaces || s.DenyNetwork {
+		// Map the daemon's own IDs so files keep their owners inside.
+		attr.Cloneflags = syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+		if s.Namespaces {
+			attr.Cloneflags |= syscall.CLONE_NEWNS
+		}
+		if s.DenyNetwork {
+			attr.Cloneflags |= syscall.CLONE_NEWNET
+		}
+	}
+	cmd.SysProcAttr = attr
+
+	return func() error {
+		w.Close()
+		defer r.Close()
+		msg, _ := io.ReadAll(r)
+		if len(msg) > 0 {
+			return errors.New(string(msg))
+		}
+		return nil
+	}, nil
+}
+
+// runSandboxHelper confines the current process as described by data and
+// execs the server. Setup errors are written to the spec's error pipe.
+func runSandboxHelper(data string) {
+	// Landlock and no_new_privs apply to the calling thread, so everything
+	// up to the exec has to happen on one.
+	runtime.LockOSThread()
+
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		fmt.Fprintf(os.Stderr, "mcplexer sandbox: invalid spec: %v\n", err)
+		os.Exit(126)
+	}
+	errPipe := os.NewFile(uintptr(spec.ErrFD), "sandbox-errors")
+	unix.CloseOnExec(spec.ErrFD)
+
+	if err := spec.apply(); err != nil {
+		fmt.Fprint(errPipe, err.Error())
+		os.Exit(126)
+	}
+	err := unix.Exec(spec.Command, os.Args, os.Environ())
+	fmt.Fprintf(errPipe, "exec %s: %v", spec.Command, err)
+	os.Exit(127)
+}
+
+// apply confines the calling thread. Order matters: mounts need the
+// namespace's capabilities, and seccomp comes last because it denies the
+// syscalls used before it.
+func (s *sandboxSpec) apply() error {
+	if s.Namespaces {
+		if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+			return fmt.Errorf("make mounts private: %w", err)
+		}
+		if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("mount private /tmp: %w", err)
+		}
+	}
+	if err := s.setRlimits(); err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %w", err)
+	}
+	if s.Landlock {
+		if err := s.restrictFilesystem(); err != nil {
+			return fmt.Errorf("landlock: %w", err)
+		}
+	}
+	if s.Seccomp {
+		if err := installSeccomp(append(append([]string{}, defaultSeccompDeny...), s.SeccompDeny...)); err != nil {
+			return fmt.Errorf("seccomp: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *sandboxSpec) setRlimits() error {
+	limits := []struct {
+		name     string
+		resource int
+		value    uint64
+	}{
+		{"cpu", unix.RLIMIT_CPU, s.CPUSeconds},
+		{"memory", unix.RLIMIT_AS, s.MemoryMB << 20},
+		{"open files", unix.RLIMIT_NOFILE, s.MaxOpenFiles},
+	}
+	for _, l := range limits {
+		if l.value == 0 {
+			continue
+		}
+		rl := unix.Rlimit{Cur: l.value, Max: l.value}
+		if err := unix.Setrlimit(l.resource, &rl); err != nil {
+			return fmt.Errorf("set %s limit: %w", l.name, err)
+		}
+	}
+	return nil
+}
+
+// Landlock access rights. Directory rights cannot be granted on files.
+const (
+	landlockRead = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+	landlockFileRights = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE |
+		unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	landlockV1 = landlockRead |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+)
+
+// systemReadPaths are readable under Landlock so interpreters, shared
+// libraries and system configuration keep working.
+var systemReadPaths = []string{
+	"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/etc", "/opt",
+	"/nix", "/run", "/proc", "/sys",
+}
+
+// restrictFilesystem allows writes only under the workspace root, /dev,
+// the private /tmp and WritePaths, and reads only there plus the system
+// directories, the server's own directory, its working directory and
+// ReadPaths.
+func (s *sandboxSpec) restrictFilesystem() error {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return fmt.Errorf("not supported by this kernel: %w", errno)
+	}
+	handled := uint64(landlockV1)
+	if abi >= 2 {
+		handled |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		handled |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if abi >= 5 {
+		handled |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("create ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer unix.Close(ruleset)
+
+	read := append(append([]string{}, systemReadPaths...), s.ReadPaths...)
+	read = append(read, filepath.Dir(s.Command))
+	if s.Dir != "" {
+		read = append(read, s.Dir)
+	}
+	write := append([]string{"/dev"}, s.WritePaths...)
+	if s.Root != "" {
+		write = append(write, s.Root)
+	}
+	if s.Namespaces {
+		write = append(write, "/tmp")
+	}
+
+	for _, p := range read {
+		if err := landlockAllow(ruleset, p, landlockRead&handled); err != nil {
+			return err
+		}
+	}
+	for _, p := range write {
+		if err := landlockAllow(ruleset, p, handled); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("restrict self: %w", errno)
+	}
+	return nil
+}
+
+// landlockAllow grants access beneath path. Paths that do not exist on
+// this system are skipped.
+func landlockAllow(ruleset int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if errors.Is(err, unix.ENOENT) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer unix.Close(fd)
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= landlockFileRights
+	}
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset),
+		unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("allow %s: %w", path, errno)
+	}
+	return nil
+}
+
+// x32Bit marks syscalls made through the x32 ABI on amd64, which the
+// filter would otherwise not recognise.
+const x32Bit = 0x40000000
+
+// installSeccomp loads a filter that fails the named syscalls with EPERM
+// and kills the process on a foreign architecture.
+func installSeccomp(deny []string) error {
+	if seccompArch == 0 {
+		return fmt.Errorf("not supported on %s", runtime.GOARCH)
+	}
+	nrs := make([]uint32, 0, len(deny))
+	for _, name := range deny {
+		nr, ok := seccompSyscalls[name]
+		if !ok {
+			return fmt.Errorf("unknown syscall %q", name)
+		}
+		nrs = append(nrs, uint32(nr))
+	}
+
+	const (
+		ld  = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+		jeq = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+		jge = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+		ret = unix.BPF_RET | unix.BPF_K
+	)
+	deny1 := unix.SockFilter{Code: ret, K: unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)}
+	prog := []unix.SockFilter{
+		{Code: ld, K: 4}, // seccomp_data.arch
+		{Code: jeq, Jt: 1, K: seccompArch},
+		{Code: ret, K: unix.SECCOMP_RET_KILL_PROCESS},
+		{Code: ld, K: 0}, // seccomp_data.nr
+	}
+	if runtime.GOARCH == "amd64" {
+		prog = append(prog, unix.SockFilter{Code: jge, Jf: 1, K: x32Bit}, deny1)
+	}
+	for _, nr := range nrs {
+		prog = append(prog, unix.SockFilter{Code: jeq, Jf: 1, K: nr}, deny1)
+	}
+	prog = append(prog, unix.SockFilter{Code: ret, K: unix.SECCOMP_RET_ALLOW})
+
+	fprog := unix.SockFprog{Len: uint16(len(prog)), Filter: &prog[0]}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER,
+		uintptr(unsafe.Pointer(&fprog)), 0, 0); err != nil {
+		return fmt.Errorf("load filter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package downstream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// probeEnv makes the test binary act as a sandboxed server: it runs the
+// named probe and prints the result instead of running tests.
+const probeEnv = "MCPLEXER_SANDBOX_PROBE"
+
+func TestMain(m *testing.M) {
+	SandboxMain()
+	if probe, ok := os.LookupEnv(probeEnv); ok {
+		fmt.Print(runProbe(probe))
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func runProbe(probe string) string {
+	name, arg, _ := strings.Cut(probe, ":")
+	switch name {
+	case "nofile":
+		var rl unix.Rlimit
+		if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rl); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprint(rl.Cur)
+	case "personality":
+		_, _, errno := unix.Syscall(unix.SYS_PERSONALITY, 0xffffffff, 0, 0)
+		return errnoString(errno)
+	case "read":
+		if _, err := os.ReadFile(arg); err != nil {
+			return errnoString(err)
+		}
+		return "ok"
+	case "write":
+		if err := os.WriteFile(arg, []byte("x"), 0o600); err != nil {
+			return errnoString(err)
+		}
+		return "ok"
+	case "net":
+		ifaces, err := net.Interfaces()
+		if err != nil {
+			return err.Error()
+		}
+		names := make([]string, len(ifaces))
+		for i, ifc := range ifaces {
+			names[i] = ifc.Name
+		}
+		return strings.Join(names, ",")
+	}
+	return "unknown probe " + name
+}
+
+func errnoString(err error) string {
+	var errno unix.Errno
+	if errors.As(err, &errno) {
+		if errno == 0 {
+			return "ok"
+		}
+		return unix.ErrnoName(errno)
+	}
+	return err.Error()
+}
+
+// runSandboxed runs a probe in the test binary under spec and returns its
+// output.
+func runSandboxed(t *testing.T, spec *sandboxSpec, probe string) (string, error) {
+	t.Helper()
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Namespaces {
+		// The test binary lives under the /tmp the sandbox replaces; the
+		// magic link still reaches it.
+		self = "/proc/self/exe"
+	}
+	cmd := exec.Command(self)
+	cmd.Env = append(os.Environ(), probeEnv+"="+probe)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	wait, err := spec.wrap(cmd)
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	startErr := cmd.Start()
+	if err := wait(); err != nil {
+		if startErr == nil {
+			cmd.Wait() //nolint:errcheck
+		}
+		return "", err
+	}
+	if startErr != nil {
+		return "", startErr
+	}
+	if err := cmd.Wait(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func TestSandboxRlimits(t *testing.T) {
+	spec := &sandboxSpec{SandboxProfile: store.SandboxProfile{MaxOpenFiles: 64}}
+	out, err := runSandboxed(t, spec, "nofile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "64" {
+		t.Fatalf("RLIMIT_NOFILE = %s, want 64", out)
+	}
+}
+
+func TestSandboxSeccomp(t *testing.T) {
+	if seccompArch == 0 {
+		t.Skip("seccomp filters not built for this architecture")
+	}
+	if out, err := runSandboxed(t, &sandboxSpec{}, "personality"); err != nil || out != "ok" {
+		t.Fatalf("unfiltered personality = %q, %v", out, err)
+	}
+
+	spec := &sandboxSpec{SandboxProfile: store.SandboxProfile{
+		Seccomp:     true,
+		SeccompDeny: []string{"personality"},
+	}}
+	out, err := runSandboxed(t, spec, "personality")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "EPERM" {
+		t.Fatalf("filtered personality = %q, want EPERM", out)
+	}
+
+	spec.SeccompDeny = []string{"no_such_syscall"}
+	if _, err := runSandboxed(t, spec, "personality"); err == nil || !strings.Contains(err.Error(), "no_such_syscall") {
+		t.Fatalf("unknown syscall: err = %v", err)
+	}
+}
+
+func TestSandboxLandlock(t *testing.T) {
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0,
+		unix.LANDLOCK_CREATE_RULESET_VERSION); errno != 0 {
+		t.Skipf("landlock unavailable: %v", errno)
+	}
+	dir := t.TempDir()
+	root := filepath.Join(dir, "workspace")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{root, outside} {
+		if err := os.Mkdir(d, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(d, "f"), []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	spec := &sandboxSpec{SandboxProfile: store.SandboxProfile{Landlock: true}, Root: root}
+	cases := []struct{ probe, want string }{
+		{"read:" + filepath.Join(root, "f"), "ok"},
+		{"write:" + filepath.Join(root, "g"), "ok"},
+		{"read:" + filepath.Join(outside, "f"), "EACCES"},
+		{"write:" + filepath.Join(outside, "g"), "EACCES"},
+	}
+	for _, c := range cases {
+		out, err := runSandboxed(t, spec, c.probe)
+		if err != nil {
+			t.Fatalf("%s: %v", c.probe, err)
+		}
+		if out != c.want {
+			t.Errorf("%s = %q, want %q", c.probe, out, c.want)
+		}
+	}
+}
+
+func TestSandboxNamespaces(t *testing.T) {
+	spec := &sandboxSpec{SandboxProfile: store.SandboxProfile{Namespaces: true, DenyNetwork: true}}
+	out, err := runSandboxed(t, spec, "net")
+	if errors.Is(err, unix.EPERM) || errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOSPC) {
+		t.Skipf("user namespaces unavailable: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "lo" {
+		t.Fatalf("interfaces = %q, want only lo", out)
+	}
+
+	// The private /tmp hides the host's.
+	marker := filepath.Join(os.TempDir(), "mcplexer-sandbox-marker")
+	if err := os.WriteFile(marker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(marker)
+	if out, err := runSandboxed(t, spec, "read:"+marker); err != nil || out != "ENOENT" {
+		t.Fatalf("host /tmp visible: %q, %v", out, err)
+	}
+}
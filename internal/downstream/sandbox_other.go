@@ -0,0 +1,14 @@
+//go:build !linux
+
+package downstream
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func (s *sandboxSpec) wrap(*exec.Cmd) (func() error, error) {
+	return nil, errors.New("sandbox profiles are only supported on Linux")
+}
+
+func runSandboxHelper(string) {}
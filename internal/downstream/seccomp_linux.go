@@ -0,0 +1,86 @@
+//go:build linux && (amd64 || arm64)
+
+package downstream
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// seccompArch is the audit architecture the filter accepts; syscalls made
+// through any other ABI kill the process.
+var seccompArch = map[string]uint32{
+	"amd64": unix.AUDIT_ARCH_X86_64,
+	"arm64": unix.AUDIT_ARCH_AARCH64,
+}[runtime.GOARCH]
+
+// defaultSeccompDeny are the syscalls a sandboxed server never needs: they
+// load kernel code, change mounts or namespaces, inspect other processes,
+// or alter host-wide state.
+var defaultSeccompDeny = []string{
+	"acct", "add_key", "bpf", "chroot", "clock_adjtime", "clock_settime",
+	"delete_module", "finit_module", "fsconfig", "fsmount", "fsopen",
+	"init_module", "kexec_file_load", "kexec_load", "keyctl", "mount",
+	"move_mount", "open_by_handle_at", "open_tree", "perf_event_open",
+	"pivot_root", "process_vm_readv", "process_vm_writev", "ptrace",
+	"quotactl", "reboot", "request_key", "setns", "settimeofday", "swapoff",
+	"swapon", "syslog", "umount2", "unshare", "userfaultfd",
+}
+
+// seccompSyscalls maps the syscall names a profile may deny to their
+// numbers: the defaults plus others commonly blocked by container runtimes.
+var seccompSyscalls = map[string]uintptr{
+	"acct":              unix.SYS_ACCT,
+	"add_key":           unix.SYS_ADD_KEY,
+	"bpf":               unix.SYS_BPF,
+	"chroot":            unix.SYS_CHROOT,
+	"clock_adjtime":     unix.SYS_CLOCK_ADJTIME,
+	"clock_settime":     unix.SYS_CLOCK_SETTIME,
+	"delete_module":     unix.SYS_DELETE_MODULE,
+	"fanotify_init":     unix.SYS_FANOTIFY_INIT,
+	"finit_module":      unix.SYS_FINIT_MODULE,
+	"fsconfig":          unix.SYS_FSCONFIG,
+	"fsmount":           unix.SYS_FSMOUNT,
+	"fsopen":            unix.SYS_FSOPEN,
+	"get_mempolicy":     unix.SYS_GET_MEMPOLICY,
+	"init_module":       unix.SYS_INIT_MODULE,
+	"io_uring_enter":    unix.SYS_IO_URING_ENTER,
+	"io_uring_register": unix.SYS_IO_URING_REGISTER,
+	"io_uring_setup":    unix.SYS_IO_URING_SETUP,
+	"kcmp":              unix.SYS_KCMP,
+	"kexec_file_load":   unix.SYS_KEXEC_FILE_LOAD,
+	"kexec_load":        unix.SYS_KEXEC_LOAD,
+	"keyctl":            unix.SYS_KEYCTL,
+	"mbind":             unix.SYS_MBIND,
+	"memfd_create":      unix.SYS_MEMFD_CREATE,
+	"migrate_pages":     unix.SYS_MIGRATE_PAGES,
+	"mknodat":           unix.SYS_MKNODAT,
+	"mount":             unix.SYS_MOUNT,
+	"move_mount":        unix.SYS_MOVE_MOUNT,
+	"move_pages":        unix.SYS_MOVE_PAGES,
+	"name_to_handle_at": unix.SYS_NAME_TO_HANDLE_AT,
+	"open_by_handle_at": unix.SYS_OPEN_BY_HANDLE_AT,
+	"open_tree":         unix.SYS_OPEN_TREE,
+	"perf_event_open":   unix.SYS_PERF_EVENT_OPEN,
+	"personality":       unix.SYS_PERSONALITY,
+	"pivot_root":        unix.SYS_PIVOT_ROOT,
+	"process_vm_readv":  unix.SYS_PROCESS_VM_READV,
+	"process_vm_writev": unix.SYS_PROCESS_VM_WRITEV,
+	"ptrace":            unix.SYS_PTRACE,
+	"quotactl":          unix.SYS_QUOTACTL,
+	"reboot":            unix.SYS_REBOOT,
+	"request_key":       unix.SYS_REQUEST_KEY,
+	"set_mempolicy":     unix.SYS_SET_MEMPOLICY,
+	"setdomainname":     unix.SYS_SETDOMAINNAME,
+	"sethostname":       unix.SYS_SETHOSTNAME,
+	"setns":             unix.SYS_SETNS,
+	"settimeofday":      unix.SYS_SETTIMEOFDAY,
+	"swapoff":           unix.SYS_SWAPOFF,
+	"swapon":            unix.SYS_SWAPON,
+	"syslog":            unix.SYS_SYSLOG,
+	"umount2":           unix.SYS_UMOUNT2,
+	"unshare":           unix.SYS_UNSHARE,
+	"userfaultfd":       unix.SYS_USERFAULTFD,
+	"vhangup":           unix.SYS_VHANGUP,
+}
@@ -0,0 +1,11 @@
+//go:build linux && !amd64 && !arm64
+
+package downstream
+
+// Seccomp filters are only built for amd64 and arm64; profiles that ask
+// for one fail to start elsewhere.
+var (
+	seccompArch        uint32
+	defaultSeccompDeny []string
+	seccompSyscalls    map[string]uintptr
+)
@@ -0,0 +1,625 @@
+package downstream
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/tracing"
+)
+
+// Reconnection of the long-lived connections of the sse and websocket
+// transports.
+const (
+	maxReconnects  = 5
+	reconnectDelay = 500 * time.Millisecond // doubled after each failed attempt
+)
+
+// errStreamClosed means the event stream or websocket carrying responses
+// dropped before a response arrived.
+var errStreamClosed = errors.New("connection to server closed")
+
+// SSEInstance communicates with a remote MCP server over the HTTP+SSE
+// transport of protocol version 2024-11-05. Responses arrive on a
+// long-lived GET event stream; requests are POSTed to the endpoint the
+// stream announces and matched to their responses by ID. A dropped stream
+// is reconnected, which starts a new session.
+type SSEInstance struct {
+	key    InstanceKey
+	url    string
+	client *http.Client
+
+	initTimeout time.Duration
+	idleTimeout time.Duration
+	reqID       atomic.Int64
+
+	mu          sync.Mutex
+	state       InstanceState
+	authHeaders http.Header
+	idleTimer   *time.Timer
+	endpoint    string                 // POST URL of the current session; empty while connecting
+	connected   chan struct{}          // closed once endpoint is set
+	pending     map[string]chan []byte // response waiters by request ID
+
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// sseStream is one connection of the event stream.
+type sseStream struct {
+	cancel context.CancelFunc
+	closed chan struct{} // closed when the stream ends
+}
+
+func newSSEInstance(key InstanceKey, streamURL string, idleTimeout time.Duration, headers http.Header) *SSEInstance {
+	return &SSEInstance{
+		key:         key,
+		url:         streamURL,
+		client:      &http.Client{}, // the stream has no deadline; requests are bounded by their context
+		initTimeout: defaultInitTimeout,
+		idleTimeout: idleTimeout,
+		state:       StateStopped,
+		authHeaders: headers,
+		connected:   make(chan struct{}),
+		pending:     make(map[string]chan []byte),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// legacy returns an HTTP+SSE instance for the same server and settings,
+// used when the server does not speak Streamable HTTP.
+func (h *HTTPInstance) legacy() *SSEInstance {
+	h.mu.Lock()
+	headers := h.authHeaders
+	h.mu.Unlock()
+
+	s := newSSEInstance(h.key, h.url, h.idleTimeout, headers)
+	s.initTimeout = h.initTimeout
+	return s
+}
+
+func (s *SSEInstance) getState() InstanceState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+func (s *SSEInstance) start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.state != StateStopped {
+		st := s.state
+		s.mu.Unlock()
+		return fmt.Errorf("cannot start sse instance in state %s", st)
+	}
+	s.state = StateStarting
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, s.initTimeout)
+	defer cancel()
+	stream, err := s.connect(ctx)
+	if err != nil {
+		s.mu.Lock()
+		s.state = StateStopped
+		s.mu.Unlock()
+		return err
+	}
+
+	s.mu.Lock()
+	s.state = StateReady
+	s.mu.Unlock()
+	go s.run(stream)
+	return nil
+}
+
+func (s *SSEInstance) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+	}
+	s.state = StateStopped
+	s.stopOnce.Do(func() { close(s.stopped) })
+}
+
+// connect opens the event stream, waits for its endpoint event and
+// initializes a session on that endpoint. ctx bounds the whole handshake
+// but not the stream.
+func (s *SSEInstance) connect(ctx context.Context) (*sseStream, error) {
+	streamCtx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, s.url, nil)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	s.setAuthHeaders(req)
+
+	detach := context.AfterFunc(ctx, cancel)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("open event stream: %w", err)
+	}
+	if err := checkStreamResponse(resp); err != nil {
+		resp.Body.Close()
+		cancel()
+		return nil, err
+	}
+
+	stream := &sseStream{cancel: cancel, closed: make(chan struct{})}
+	announced := make(chan string, 1)
+	go s.readStream(resp.Body, stream, announced)
+
+	var endpoint string
+	select {
+	case raw := <-announced:
+		endpoint, err = resolveEndpoint(s.url, raw)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	case <-stream.closed:
+		cancel()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("wait for endpoint event: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("event stream ended before the endpoint event")
+	}
+
+	if err := s.handshake(ctx, endpoint); err != nil {
+		cancel()
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	if !detach() {
+		return nil, fmt.Errorf("initialize: %w", ctx.Err())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.stopped:
+		cancel()
+		return nil, fmt.Errorf("sse instance stopped")
+	default:
+	}
+	s.endpoint = endpoint
+	close(s.connected)
+	return stream, nil
+}
+
+func checkStreamResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrAuthRequired
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &statusError{code: resp.StatusCode, body: string(body)}
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		return fmt.Errorf("event stream has content type %q", ct)
+	}
+	return nil
+}
+
+// resolveEndpoint resolves the announced POST endpoint against the stream
+// URL. It must stay on the stream's origin so a server cannot send the
+// gateway's requests, and credentials, elsewhere.
+func resolveEndpoint(streamURL, endpoint string) (string, error) {
+	base, err := url.Parse(streamURL)
+	if err != nil {
+		return "", fmt.Errorf("parse stream url: %w", err)
+	}
+	ref, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return "", fmt.Errorf("parse endpoint %q: %w", endpoint, err)
+	}
+	u := base.ResolveReference(ref)
+	if u.Scheme != base.Scheme || u.Host != base.Host {
+		return "", fmt.Errorf("endpoint %q is not on the server's origin", endpoint)
+	}
+	return u.String(), nil
+}
+
+func (s *SSEInstance) handshake(ctx context.Context, endpoint string) error {
+	_, err := s.roundTrip(ctx, endpoint, jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      s.nextID(),
+		Method:  "initialize",
+		Params:  initializeParams,
+	})
+	if err != nil {
+		return err
+	}
+	// Non-fatal: some servers don't handle this.
+	s.roundTrip(ctx, endpoint, jsonRPCRequest{JSONRPC: "2.0", Method: "notifications/initialized"}) //nolint:errcheck
+	return nil
+}
+
+// run reconnects the event stream whenever it drops, until the instance
+// stops. Requests waiting on a dropped stream fail: their responses went
+// with it. If the server stays unreachable the instance stops, so the
+// next call starts a fresh one.
+func (s *SSEInstance) run(stream *sseStream) {
+	for {
+		select {
+		case <-stream.closed:
+		case <-s.stopped:
+			stream.cancel()
+			return
+		}
+
+		s.mu.Lock()
+		s.endpoint = ""
+		s.connected = make(chan struct{})
+		for id, ch := range s.pending {
+			close(ch)
+			delete(s.pending, id)
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.stopped:
+			return
+		default:
+		}
+		slog.Warn("downstream event stream closed, reconnecting", "server", s.key.ServerID)
+		if stream = s.reconnect(); stream == nil {
+			select {
+			case <-s.stopped:
+			default:
+				slog.Error("downstream event stream lost", "server", s.key.ServerID)
+				s.stop()
+			}
+			return
+		}
+	}
+}
+
+func (s *SSEInstance) reconnect() *sseStream {
+	delay := reconnectDelay
+	for attempt := 1; attempt <= maxReconnects; attempt++ {
+		select {
+		case <-time.After(delay):
+		case <-s.stopped:
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), s.initTimeout)
+		stream, err := s.connect(ctx)
+		cancel()
+		if err == nil {
+			return stream
+		}
+		slog.Warn("reconnect downstream event stream",
+			"server", s.key.ServerID, "attempt", attempt, "error", err)
+		delay *= 2
+	}
+	return nil
+}
+
+// readStream handles the events of one stream until it ends: the first
+// endpoint event goes to announced, messages go to the requests waiting
+// for them.
+func (s *SSEInstance) readStream(body io.ReadCloser, stream *sseStream, announced chan<- string) {
+	defer close(stream.closed)
+	defer body.Close()
+
+	err := readEvents(body, func(ev sseEvent) bool {
+		switch ev.event {
+		case "endpoint":
+			select {
+			case announced <- ev.data:
+			default:
+			}
+		case "message":
+			s.dispatch([]byte(ev.data))
+		}
+		return true
+	})
+	if err != nil {
+		slog.Debug("downstream event stream ended", "server", s.key.ServerID, "error", err)
+	}
+}
+
+// dispatch hands a response to its waiting request and answers requests
+// from the server. Notifications are dropped.
+func (s *SSEInstance) dispatch(data []byte) {
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil || msg.ID == nil {
+		return
+	}
+	if msg.Method != "" {
+		go s.answer(msg.ID, msg.Method)
+		return
+	}
+
+	s.mu.Lock()
+	ch := s.pending[string(msg.ID)]
+	delete(s.pending, string(msg.ID))
+	s.mu.Unlock()
+	if ch != nil {
+		ch <- data
+	}
+}
+
+// answer replies to a request from the server.
+func (s *SSEInstance) answer(id json.RawMessage, method string) {
+	s.mu.Lock()
+	endpoint := s.endpoint
+	s.mu.Unlock()
+	if endpoint == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cancelGrace)
+	defer cancel()
+	if err := s.post(ctx, endpoint, replyTo(id, method)); err != nil {
+		slog.Debug("answer downstream request", "server", s.key.ServerID, "method", method, "error", err)
+	}
+}
+
+// replyTo answers a request from a server: pings succeed, anything else is
+// not supported by the gateway.
+func replyTo(id json.RawMessage, method string) []byte {
+	resp := jsonRPCResponse{JSONRPC: "2.0", ID: id, Result: json.RawMessage(`{}`)}
+	if method != "ping" {
+		resp.Result = nil
+		resp.Error = &jsonRPCError{Code: -32601, Message: "method not found"}
+	}
+	body, _ := json.Marshal(resp)
+	return body
+}
+
+// rpc sends req on the current session, waiting out a reconnect in
+// progress.
+func (s *SSEInstance) rpc(ctx context.Context, req jsonRPCRequest) (json.RawMessage, error) {
+	for {
+		s.mu.Lock()
+		endpoint, connected := s.endpoint, s.connected
+		s.mu.Unlock()
+		if endpoint != "" {
+			return s.roundTrip(ctx, endpoint, req)
+		}
+		select {
+		case <-connected:
+		case <-s.stopped:
+			return nil, fmt.Errorf("sse instance stopped")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// roundTrip POSTs req to endpoint and, unless it is a notification, waits
+// for its response on the event stream.
+func (s *SSEInstance) roundTrip(
+	ctx context.Context, endpoint string, req jsonRPCRequest,
+) (json.RawMessage, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	ex := ExchangeFrom(ctx)
+	if ex != nil {
+		ex.Request = body
+	}
+
+	var ch chan []byte
+	if req.ID != nil {
+		id := string(req.ID)
+		ch = make(chan []byte, 1)
+		s.mu.Lock()
+		s.pending[id] = ch
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.pending, id)
+			s.mu.Unlock()
+		}()
+	}
+
+	if err := s.post(ctx, endpoint, body); err != nil {
+		return nil, err
+	}
+	if ch == nil {
+		return nil, nil
+	}
+
+	var data []byte
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.stopped:
+		return nil, fmt.Errorf("sse instance stopped")
+	case d, ok := <-ch:
+		if !ok {
+			return nil, errStreamClosed
+		}
+		data = d
+	}
+	if ex != nil {
+		ex.Response = data
+	}
+	var resp jsonRPCResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, &remoteError{prefix: "rpc error", jsonRPCError: *resp.Error}
+	}
+	return resp.Result, nil
+}
+
+// post sends one JSON-RPC message to the session endpoint. The server
+// acknowledges it; any response comes on the event stream.
+func (s *SSEInstance) post(ctx context.Context, endpoint string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	s.setAuthHeaders(req)
+	if tp := tracing.Traceparent(ctx); tp != "" {
+		req.Header.Set("traceparent", tp)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrAuthRequired
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &statusError{code: resp.StatusCode, body: string(respBody)}
+	}
+	io.Copy(io.Discard, resp.Body) //nolint:errcheck // lets the connection be reused
+	return nil
+}
+
+// setAuthHeaders injects the auth headers (e.g. Authorization: Bearer
+// <token>) into req.
+func (s *SSEInstance) setAuthHeaders(req *http.Request) {
+	s.mu.Lock()
+	headers := s.authHeaders
+	s.mu.Unlock()
+	for k, vals := range headers {
+		for _, v := range vals {
+			req.Header.Set(k, v)
+		}
+	}
+}
+
+func (s *SSEInstance) nextID() json.RawMessage {
+	return json.RawMessage(fmt.Sprintf(`%d`, s.reqID.Add(1)))
+}
+
+// ping sends an MCP ping on the current session. It does not count as use
+// for the idle timeout.
+func (s *SSEInstance) ping(ctx context.Context) error {
+	_, err := s.rpc(ctx, jsonRPCRequest{JSONRPC: "2.0", ID: s.nextID(), Method: "ping"})
+	return err
+}
+
+// ListTools sends a tools/list request to the server.
+func (s *SSEInstance) ListTools(ctx context.Context) (json.RawMessage, error) {
+	return s.Call(ctx, "tools/list", json.RawMessage(`{}`))
+}
+
+// Call sends a request to the server and waits for its response. If the
+// caller gives up first, the server is told with notifications/cancelled.
+func (s *SSEInstance) Call(
+	ctx context.Context, method string, params json.RawMessage,
+) (json.RawMessage, error) {
+	s.setBusy(true)
+	defer s.setBusy(false)
+
+	req := jsonRPCRequest{JSONRPC: "2.0", ID: s.nextID(), Method: method, Params: params}
+	result, err := s.rpc(ctx, req)
+	if err != nil && ctx.Err() != nil {
+		s.cancelRequest(req.ID, ctx.Err())
+	}
+	return result, err
+}
+
+// cancelRequest tells the server to stop working on a request whose
+// caller gave up.
+func (s *SSEInstance) cancelRequest(id json.RawMessage, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelGrace)
+	defer cancel()
+	params, _ := json.Marshal(map[string]any{"requestId": id, "reason": cancelReason(cause)})
+	_, err := s.rpc(ctx, jsonRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "notifications/cancelled",
+		Params:  params,
+	})
+	if err != nil {
+		slog.Debug("send cancellation to downstream", "server", s.key.ServerID, "error", err)
+	}
+}
+
+// setBusy marks the instance busy for a call and idle after it, unless it
+// has stopped meanwhile.
+func (s *SSEInstance) setBusy(busy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == StateStopped || s.state == StateStopping {
+		return
+	}
+	if busy {
+		s.state = StateBusy
+		return
+	}
+	s.state = StateIdle
+	if s.idleTimeout <= 0 {
+		return
+	}
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+	}
+	s.idleTimer = time.AfterFunc(s.idleTimeout, s.stop)
+}
+
+// sseEvent is one event of a text/event-stream.
+type sseEvent struct {
+	id    string // the stream's last event ID as of this event
+	event string // "message" unless the event names its type
+	data  string
+}
+
+// readEvents parses a text/event-stream, calling fn with each event until
+// fn returns false or the stream ends.
+func readEvents(r io.Reader, fn func(ev sseEvent) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	var id, event string
+	var data []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			if len(data) > 0 {
+				if event == "" {
+					event = "message"
+				}
+				if !fn(sseEvent{id: id, event: event, data: strings.Join(data, "\n")}) {
+					return nil
+				}
+			}
+			event, data = "", nil
+			continue
+		}
+		// Lines starting with ":" are comments; retry is unused.
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				id = value
+			}
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return scanner.Err()
+}
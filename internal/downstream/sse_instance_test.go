@@ -0,0 +1,272 @@
+package downstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sseServer is a minimal HTTP+SSE (2024-11-05) MCP server: GET /sse opens
+// a session stream and announces its POST endpoint, which answers every
+// request with an empty result on that stream. POSTs to /sse are rejected
+// as a server without Streamable HTTP would.
+type sseServer struct {
+	*httptest.Server
+	unanswered string // method whose requests get no response
+
+	mu       sync.Mutex
+	sessions map[string]chan string
+	opened   int
+	methods  []string
+}
+
+func newSSEServer(t *testing.T) *sseServer {
+	t.Helper()
+	srv := &sseServer{sessions: make(map[string]chan string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sse", srv.stream)
+	mux.HandleFunc("POST /sse", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+	mux.HandleFunc("POST /messages", srv.message)
+	srv.Server = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (s *sseServer) stream(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.opened++
+	id := fmt.Sprint(s.opened)
+	out := make(chan string, 16)
+	s.sessions[id] = out
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprintf(w, "event: endpoint\ndata: /messages?session=%s\n\n", id)
+	w.(http.Flusher).Flush()
+	for {
+		select {
+		case msg, ok := <-out:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *sseServer) message(w http.ResponseWriter, r *http.Request) {
+	var req jsonRPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.sessions[r.URL.Query().Get("session")]
+	if out == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.methods = append(s.methods, req.Method)
+	w.WriteHeader(http.StatusAccepted)
+	if req.ID != nil && req.Method != s.unanswered {
+		out <- fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{}}`, req.ID)
+	}
+}
+
+// drop ends every open stream.
+func (s *sseServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, out := range s.sessions {
+		close(out)
+		delete(s.sessions, id)
+	}
+}
+
+func (s *sseServer) calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, m := range s.methods {
+		if m == method {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSSEInstanceCall(t *testing.T) {
+	srv := newSSEServer(t)
+	inst := newSSEInstance(InstanceKey{ServerID: "srv"}, srv.URL+"/sse", time.Minute, nil)
+	if err := inst.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer inst.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := inst.Call(ctx, "tools/call", json.RawMessage(`{"name":"echo"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != "{}" {
+		t.Fatalf("result = %s", result)
+	}
+	if err := inst.ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if srv.calls("initialize") != 1 || srv.calls("notifications/initialized") != 1 {
+		t.Fatalf("methods = %v", srv.methods)
+	}
+}
+
+func TestSSEInstanceReconnects(t *testing.T) {
+	srv := newSSEServer(t)
+	inst := newSSEInstance(InstanceKey{ServerID: "srv"}, srv.URL+"/sse", time.Minute, nil)
+	if err := inst.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer inst.stop()
+
+	srv.drop()
+	waitFor(t, "second session", func() bool {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		return srv.opened == 2
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := inst.Call(ctx, "tools/list", json.RawMessage(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.calls("initialize"); n != 2 {
+		t.Fatalf("initialize sent %d times, want 2 (one per session)", n)
+	}
+}
+
+func TestSSEInstanceFailsPendingOnDrop(t *testing.T) {
+	srv := newSSEServer(t)
+	inst := newSSEInstance(InstanceKey{ServerID: "srv"}, srv.URL+"/sse", time.Minute, nil)
+	if err := inst.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer inst.stop()
+
+	// A request whose response never comes fails when the stream drops.
+	ch := make(chan []byte, 1)
+	inst.mu.Lock()
+	inst.pending["99"] = ch
+	inst.mu.Unlock()
+	srv.drop()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("got a response")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending request not failed")
+	}
+}
+
+func TestSSEInstanceStopFailsWaitingCall(t *testing.T) {
+	srv := newSSEServer(t)
+	srv.unanswered = "tools/call"
+	inst := newSSEInstance(InstanceKey{ServerID: "srv"}, srv.URL+"/sse", time.Minute, nil)
+	if err := inst.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := inst.Call(context.Background(), "tools/call", json.RawMessage(`{"name":"slow"}`))
+		errc <- err
+	}()
+	waitFor(t, "call sent", func() bool { return srv.calls("tools/call") == 1 })
+	inst.stop()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("call succeeded on a stopped instance")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("call still waiting after stop")
+	}
+}
+
+func TestResolveEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{endpoint: "/messages?session=1", want: "https://mcp.example.com/messages?session=1"},
+		{endpoint: "messages", want: "https://mcp.example.com/v1/messages"},
+		{endpoint: "https://mcp.example.com/other", want: "https://mcp.example.com/other"},
+		{endpoint: "https://evil.example.com/messages", wantErr: true},
+		{endpoint: "http://mcp.example.com/messages", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := resolveEndpoint("https://mcp.example.com/v1/sse", tt.endpoint)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("resolveEndpoint(%q) = %q, %v", tt.endpoint, got, err)
+		}
+	}
+}
+
+func TestReadEvents(t *testing.T) {
+	stream := ": comment\nevent: endpoint\ndata: /messages\n\nid: 7\ndata: {\"a\":\ndata: 1}\r\n\r\nid: 8\n\ndata: x\n\ndata: y\n\n"
+	var got []string
+	if err := readEvents(strings.NewReader(stream), func(ev sseEvent) bool {
+		got = append(got, ev.id+" "+ev.event+" "+ev.data)
+		return ev.data != "x"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{" endpoint /messages", "7 message {\"a\":\n1}", "8 message x"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+}
+
+func TestStartInstanceFallsBackToSSE(t *testing.T) {
+	srv := newSSEServer(t)
+	h := newHTTPInstance(InstanceKey{ServerID: "srv"}, srv.URL+"/sse", time.Minute, nil)
+
+	inst, err := startInstance(context.Background(), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inst.stop()
+	if _, ok := inst.(*SSEInstance); !ok {
+		t.Fatalf("started %T, want *SSEInstance", inst)
+	}
+}
+
+func TestStartInstanceNoFallbackOnServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body) //nolint:errcheck
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	h := newHTTPInstance(InstanceKey{ServerID: "srv"}, srv.URL, time.Minute, nil)
+
+	_, err := startInstance(context.Background(), h)
+	var status *statusError
+	if !errors.As(err, &status) || status.code != http.StatusBadGateway || strings.Contains(err.Error(), "http+sse") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package downstream
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/eventbus"
+)
+
+const (
+	stderrBufferLines = 1000 // recent stderr lines kept per server
+	stderrTailLines   = 20   // lines attached to start and crash errors
+	maxLogLine        = 4096 // longer lines are split
+)
+
+// LogLine is a line a downstream server wrote to stderr.
+type LogLine struct {
+	Time     time.Time `json:"time"`
+	ServerID string    `json:"server_id"`
+	Instance string    `json:"instance,omitempty"` // which instance of the server, when it has several
+	Text     string    `json:"text"`
+}
+
+// String formats the line as it appears in stderr log files.
+func (l LogLine) String() string {
+	prefix := l.Time.Format(time.RFC3339Nano)
+	if l.Instance != "" {
+		prefix += " [" + l.Instance + "]"
+	}
+	return prefix + " " + l.Text
+}
+
+// serverLog holds the captured stderr of all instances of one server.
+type serverLog struct {
+	bus  *eventbus.Bus[LogLine]
+	file *logFile // nil unless stderr files are enabled
+}
+
+// stderrLog is the stderr writer of one instance. Complete lines go to the
+// server's log; the last few are also kept to explain failures.
+type stderrLog struct {
+	serverID string
+	instance string
+	log      *serverLog
+
+	mu      sync.Mutex
+	partial []byte
+	tail    []string
+}
+
+func (l *stderrLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			l.partial = append(l.partial, p...)
+			if len(l.partial) >= maxLogLine {
+				l.emit()
+			}
+			break
+		}
+		l.partial = append(l.partial, p[:i]...)
+		l.emit()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// flush emits a trailing line without a newline.
+func (l *stderrLog) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.partial) > 0 {
+		l.emit()
+	}
+}
+
+// emit publishes the pending line. Callers hold mu.
+func (l *stderrLog) emit() {
+	for len(l.partial) > 0 {
+		chunk := l.partial[:min(len(l.partial), maxLogLine)]
+		l.partial = l.partial[len(chunk):]
+		line := LogLine{
+			Time:     time.Now().UTC(),
+			ServerID: l.serverID,
+			Instance: l.instance,
+			Text:     strings.TrimRight(string(chunk), "\r"),
+		}
+		l.log.bus.Publish(line)
+		if l.log.file != nil {
+			l.log.file.write(line)
+		}
+		if len(l.tail) == stderrTailLines {
+			l.tail = append(l.tail[:0], l.tail[1:]...)
+		}
+		l.tail = append(l.tail, line.Text)
+	}
+	l.partial = l.partial[:0]
+}
+
+// recent returns the last lines this instance wrote.
+func (l *stderrLog) recent() []string {
+	if l == nil {
+		return nil
+	}
+	l.flush()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.tail...)
+}
+
+// withStderr appends a server's recent stderr to err; it is usually the
+// only explanation of why the server failed.
+func withStderr(err error, tail []string) error {
+	if len(tail) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w\nstderr:\n  %s", err, strings.Join(tail, "\n  "))
+}
+
+// logFile appends stderr lines to a file, rotating it once it exceeds
+// maxBytes. Rotated files are renamed path.1, path.2, ... with at most
+// maxBackups kept. Write errors are dropped: stderr capture must never
+// block or fail the server.
+type logFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func newLogFile(path string, maxBytes int64, maxBackups int) (*logFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create downstream log dir: %w", err)
+	}
+	lf := &logFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := lf.open(); err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+func (lf *logFile) write(line LogLine) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+	if lf.f == nil && lf.open() != nil {
+		return
+	}
+	data := line.String() + "\n"
+	if lf.maxBytes > 0 && lf.size > 0 && lf.size+int64(len(data)) > lf.maxBytes {
+		if lf.rotate() != nil {
+			return
+		}
+	}
+	n, _ := lf.f.WriteString(data)
+	lf.size += int64(n)
+}
+
+func (lf *logFile) close() {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.f != nil {
+		lf.f.Close()
+		lf.f = nil
+	}
+}
+
+func (lf *logFile) open() error {
+	f, err := os.OpenFile(lf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open downstream log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat downstream log: %w", err)
+	}
+	lf.f = f
+	lf.size = info.Size()
+	return nil
+}
+
+// rotate shifts path.N to path.N+1, moves the current file to path.1 and
+// reopens path.
+func (lf *logFile) rotate() error {
+	lf.f.Close()
+	lf.f = nil
+	if lf.maxBackups <= 0 {
+		os.Remove(lf.path) //nolint:errcheck
+		return lf.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", lf.path, lf.maxBackups)) //nolint:errcheck
+	for i := lf.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", lf.path, i), fmt.Sprintf("%s.%d", lf.path, i+1)) //nolint:errcheck
+	}
+	os.Rename(lf.path, lf.path+".1") //nolint:errcheck
+	return lf.open()
+}
@@ -0,0 +1,72 @@
+package downstream
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/eventbus"
+)
+
+func TestStderrLogSplitsLines(t *testing.T) {
+	sl := &serverLog{bus: eventbus.New[LogLine](10)}
+	l := &stderrLog{serverID: "srv", instance: "scope", log: sl}
+
+	l.Write([]byte("first\r\nsec"))    //nolint:errcheck
+	l.Write([]byte("ond\nunfinished")) //nolint:errcheck
+
+	evs := sl.bus.Tail(10)
+	if len(evs) != 2 || evs[0].Data.Text != "first" || evs[1].Data.Text != "second" {
+		t.Fatalf("lines = %+v", evs)
+	}
+	if evs[0].Data.ServerID != "srv" || evs[0].Data.Instance != "scope" {
+		t.Fatalf("line = %+v", evs[0].Data)
+	}
+	if got := l.recent(); strings.Join(got, "|") != "first|second|unfinished" {
+		t.Fatalf("recent = %q", got)
+	}
+}
+
+func TestInitFailureIncludesStderr(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh")
+	}
+	sl := &serverLog{bus: eventbus.New[LogLine](10)}
+	inst := newInstance(InstanceKey{ServerID: "srv"}, sh,
+		[]string{"-c", "echo 'missing API_TOKEN' >&2; exit 1"}, os.Environ(), "", nil, time.Minute)
+	inst.stderr = &stderrLog{serverID: "srv", log: sl}
+
+	err = inst.start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "missing API_TOKEN") {
+		t.Fatalf("start error = %v, want the server's stderr", err)
+	}
+	if evs := sl.bus.Tail(1); len(evs) != 1 || evs[0].Data.Text != "missing API_TOKEN" {
+		t.Fatalf("captured = %+v", evs)
+	}
+}
+
+func TestLogFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "srv.log")
+	lf, err := newLogFile(path, 64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.close()
+
+	for _, text := range []string{"one", "two", "three"} {
+		lf.write(LogLine{Time: time.Now(), Text: text})
+	}
+	cur, _ := os.ReadFile(path)
+	old, _ := os.ReadFile(path + ".1")
+	if !strings.HasSuffix(string(cur), " three\n") || !strings.HasSuffix(string(old), " two\n") {
+		t.Fatalf("current = %q, backup = %q", cur, old)
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Fatalf("kept more than one backup: %v", err)
+	}
+}
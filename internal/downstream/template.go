@@ -0,0 +1,169 @@
+package downstream
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// SessionVars are the session values a server's command, args, env, url and
+// working_dir can reference as ${WORKSPACE_ROOT}, ${CLIENT_ROOT},
+// ${WORKSPACE_NAME} and ${SESSION_ID}. WorkspaceID and SessionID also key
+// the instances of per-workspace and per-session servers.
+type SessionVars struct {
+	WorkspaceID   string
+	WorkspaceRoot string
+	ClientRoot    string
+	WorkspaceName string
+	SessionID     string
+}
+
+type sessionKey struct{}
+
+// WithSession returns a context whose downstream calls expand template
+// variables from v.
+func WithSession(ctx context.Context, v SessionVars) context.Context {
+	return context.WithValue(ctx, sessionKey{}, v)
+}
+
+// sessionFrom returns the SessionVars attached by WithSession.
+func sessionFrom(ctx context.Context) SessionVars {
+	v, _ := ctx.Value(sessionKey{}).(SessionVars)
+	return v
+}
+
+// ErrMissingSessionVar indicates a server references a template variable
+// the calling session has no value for, such as any variable when the call
+// is made outside a session.
+var ErrMissingSessionVar = errors.New("session has no value for template variable")
+
+// templateVar matches the session template variables. Other ${VAR}
+// references are left for environment expansion.
+var templateVar = regexp.MustCompile(`\$\{(WORKSPACE_ROOT|CLIENT_ROOT|WORKSPACE_NAME|SESSION_ID)\}`)
+
+func (v SessionVars) lookup(name string) string {
+	switch name {
+	case "WORKSPACE_ROOT":
+		return v.WorkspaceRoot
+	case "CLIENT_ROOT":
+		return v.ClientRoot
+	case "WORKSPACE_NAME":
+		return v.WorkspaceName
+	case "SESSION_ID":
+		return v.SessionID
+	}
+	return ""
+}
+
+// launchSpec is how to start a server, with template variables expanded.
+type launchSpec struct {
+	Command    string            `json:"command"`
+	Args       []string          `json:"args"`
+	Env        map[string]string `json:"env"`
+	URL        string            `json:"url"`
+	WorkingDir string            `json:"working_dir"`
+	Sandbox    *sandboxSpec      `json:"sandbox,omitempty"`
+
+	templated bool // the server used at least one template variable
+}
+
+// expandServer resolves the launch settings of server for a session. A
+// variable the session has no value for is an error rather than an empty
+// string, so a filesystem server is never pointed at the wrong directory.
+func expandServer(server *store.DownstreamServer, vars SessionVars) (*launchSpec, error) {
+	spec := &launchSpec{}
+	var missing string
+	expand := func(s string) string {
+		return templateVar.ReplaceAllStringFunc(s, func(m string) string {
+			spec.templated = true
+			name := m[2 : len(m)-1]
+			val := vars.lookup(name)
+			if val == "" && missing == "" {
+				missing = name
+			}
+			return val
+		})
+	}
+
+	if len(server.Args) > 0 {
+		if err := json.Unmarshal(server.Args, &spec.Args); err != nil {
+			return nil, fmt.Errorf("unmarshal args: %w", err)
+		}
+	}
+	spec.Command = expand(server.Command)
+	for i, a := range spec.Args {
+		spec.Args[i] = expand(a)
+	}
+	if len(server.Env) > 0 {
+		spec.Env = make(map[string]string, len(server.Env))
+		for k, v := range server.Env {
+			spec.Env[k] = expand(v)
+		}
+	}
+	if server.URL != nil {
+		spec.URL = expand(*server.URL)
+	}
+	spec.WorkingDir = expand(server.WorkingDir)
+	if sb := server.Sandbox; sb != nil {
+		spec.Sandbox = &sandboxSpec{SandboxProfile: *sb}
+		spec.Sandbox.ReadPaths = make([]string, len(sb.ReadPaths))
+		for i, p := range sb.ReadPaths {
+			spec.Sandbox.ReadPaths[i] = expand(p)
+		}
+		spec.Sandbox.WritePaths = make([]string, len(sb.WritePaths))
+		for i, p := range sb.WritePaths {
+			spec.Sandbox.WritePaths[i] = expand(p)
+		}
+		if sb.Landlock {
+			// Writes are confined to the session's workspace, so each
+			// workspace needs its own instance.
+			spec.Sandbox.Root = vars.WorkspaceRoot
+			spec.templated = true
+		}
+	}
+
+	if missing != "" {
+		return nil, fmt.Errorf("server %q uses ${%s}: %w", server.Name, missing, ErrMissingSessionVar)
+	}
+	return spec, nil
+}
+
+// variant identifies the expanded settings of a templated server, so each
+// distinct expansion gets its own instance. It is empty for servers without
+// template variables, which share instances as before.
+func (s *launchSpec) variant() string {
+	if !s.templated {
+		return ""
+	}
+	// Maps marshal with sorted keys, so equal specs hash equally.
+	data, _ := json.Marshal(s)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// instanceScope returns the part of the instance key that separates the
+// instances of a server with instance_scope "workspace" or "session". A
+// session without a workspace gets its own instance of a per-workspace
+// server rather than sharing one with every other unbound session. Calls
+// made outside any session, such as tool discovery from the API, share the
+// server's unscoped instance.
+func instanceScope(server *store.DownstreamServer, vars SessionVars) string {
+	switch server.InstanceScope {
+	case "workspace":
+		if vars.WorkspaceID != "" {
+			return "workspace:" + vars.WorkspaceID
+		}
+		fallthrough
+	case "session":
+		if vars.SessionID != "" {
+			return "session:" + vars.SessionID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+package downstream
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+func TestExpandServer(t *testing.T) {
+	vars := SessionVars{
+		WorkspaceID:   "ws-1",
+		WorkspaceRoot: "/src/app",
+		ClientRoot:    "/src/app/pkg",
+		WorkspaceName: "app",
+		SessionID:     "s-1",
+	}
+	url := "https://mcp.example.com/${WORKSPACE_NAME}"
+
+	tests := []struct {
+		name   string
+		server store.DownstreamServer
+		want   launchSpec
+	}{
+		{
+			name: "no variables",
+			server: store.DownstreamServer{
+				Command: "mcp-server", Args: json.RawMessage(`["--port","${PORT}"]`),
+				Env: map[string]string{"HOME": "${HOME}"},
+			},
+			want: launchSpec{
+				Command: "mcp-server", Args: []string{"--port", "${PORT}"},
+				Env: map[string]string{"HOME": "${HOME}"},
+			},
+		},
+		{
+			name: "command args env and working dir",
+			server: store.DownstreamServer{
+				Command:    "${WORKSPACE_ROOT}/bin/server",
+				Args:       json.RawMessage(`["--root","${CLIENT_ROOT}","--session=${SESSION_ID}"]`),
+				Env:        map[string]string{"NAME": "${WORKSPACE_NAME}", "KEEP": "${KEEP}"},
+				WorkingDir: "${WORKSPACE_ROOT}",
+			},
+			want: launchSpec{
+				Command:    "/src/app/bin/server",
+				Args:       []string{"--root", "/src/app/pkg", "--session=s-1"},
+				Env:        map[string]string{"NAME": "app", "KEEP": "${KEEP}"},
+				WorkingDir: "/src/app",
+				templated:  true,
+			},
+		},
+		{
+			name:   "url",
+			server: store.DownstreamServer{Transport: "http", URL: &url},
+			want:   launchSpec{URL: "https://mcp.example.com/app", templated: true},
+		},
+		{
+			name: "landlock sandbox is rooted at the workspace",
+			server: store.DownstreamServer{
+				Command: "mcp-server",
+				Sandbox: &store.SandboxProfile{Landlock: true, WritePaths: []string{"${CLIENT_ROOT}/out"}},
+			},
+			want: launchSpec{
+				Command: "mcp-server",
+				Sandbox: &sandboxSpec{
+					SandboxProfile: store.SandboxProfile{
+						Landlock: true, ReadPaths: []string{}, WritePaths: []string{"/src/app/pkg/out"},
+					},
+					Root: "/src/app",
+				},
+				templated: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandServer(&tt.server, vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("expandServer = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandServerMissingVariable(t *testing.T) {
+	server := &store.DownstreamServer{
+		Name:    "fs",
+		Command: "mcp-fs",
+		Args:    json.RawMessage(`["${WORKSPACE_ROOT}","${SESSION_ID}"]`),
+	}
+	// Set variables expand; the first unset one is reported.
+	_, err := expandServer(server, SessionVars{SessionID: "s-1"})
+	if !errors.Is(err, ErrMissingSessionVar) || !strings.Contains(err.Error(), "${WORKSPACE_ROOT}") {
+		t.Fatalf("err = %v, want one naming ${WORKSPACE_ROOT}", err)
+	}
+	if _, err := expandServer(server, SessionVars{WorkspaceRoot: "/src/app", SessionID: "s-1"}); err != nil {
+		t.Fatalf("err = %v with every variable set", err)
+	}
+}
+
+func TestLaunchSpecVariant(t *testing.T) {
+	templated := &store.DownstreamServer{Command: "mcp-fs", Args: json.RawMessage(`["${WORKSPACE_ROOT}"]`)}
+	plain := &store.DownstreamServer{Command: "mcp-fs", Args: json.RawMessage(`["/src"]`)}
+
+	variant := func(server *store.DownstreamServer, vars SessionVars) string {
+		t.Helper()
+		spec, err := expandServer(server, vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return spec.variant()
+	}
+	a := SessionVars{WorkspaceRoot: "/src/a", SessionID: "s-1"}
+	b := SessionVars{WorkspaceRoot: "/src/b", SessionID: "s-1"}
+
+	tests := []struct {
+		name  string
+		x, y  string
+		equal bool
+	}{
+		{
+			"same expansion shares a variant", variant(templated, a),
+			variant(templated, SessionVars{WorkspaceRoot: "/src/a", SessionID: "s-2"}), true,
+		},
+		{"different expansion gets its own", variant(templated, a), variant(templated, b), false},
+		{"untemplated server has none", variant(plain, a), "", true},
+	}
+	for _, tt := range tests {
+		if (tt.x == tt.y) != tt.equal {
+			t.Errorf("%s: %q vs %q", tt.name, tt.x, tt.y)
+		}
+	}
+	if v := variant(templated, a); v == "" {
+		t.Error("templated server has an empty variant")
+	}
+}
+
+func TestInstanceScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		vars  SessionVars
+		want  string
+	}{
+		{"", SessionVars{WorkspaceID: "ws-1", SessionID: "s-1"}, ""},
+		{"global", SessionVars{WorkspaceID: "ws-1", SessionID: "s-1"}, ""},
+		{"workspace", SessionVars{WorkspaceID: "ws-1", SessionID: "s-1"}, "workspace:ws-1"},
+		{"workspace", SessionVars{SessionID: "s-1"}, "session:s-1"}, // unbound session
+		{"workspace", SessionVars{}, ""},                            // outside any session
+		{"session", SessionVars{WorkspaceID: "ws-1", SessionID: "s-1"}, "session:s-1"},
+		{"session", SessionVars{WorkspaceID: "ws-1"}, ""},
+	}
+	for _, tt := range tests {
+		server := &store.DownstreamServer{InstanceScope: tt.scope}
+		if got := instanceScope(server, tt.vars); got != tt.want {
+			t.Errorf("instanceScope(%q, %+v) = %q, want %q", tt.scope, tt.vars, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package downstream
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+const (
+	defaultInitTimeout = 30 * time.Second
+	defaultCallTimeout = 60 * time.Second
+)
+
+// cancelGrace is how long a stdio process has to answer a ping after a
+// call timed out, and how long a cancellation may take to send.
+var cancelGrace = 5 * time.Second
+
+// ErrTimeout is returned by Call when the downstream server does not
+// answer within the call's timeout.
+var ErrTimeout = errors.New("downstream call timed out")
+
+// WithTimeouts sets the initialize handshake and call timeouts of servers
+// that do not set their own. A zero call timeout lets calls run as long as
+// the client waits.
+func WithTimeouts(init, call time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.initTimeout = init
+		m.callTimeout = call
+	}
+}
+
+// serverInitTimeout returns how long a server has to answer initialize.
+func (m *Manager) serverInitTimeout(server *store.DownstreamServer) time.Duration {
+	if server.InitTimeoutSec > 0 {
+		return time.Duration(server.InitTimeoutSec) * time.Second
+	}
+	return m.initTimeout
+}
+
+// toolCallTimeout returns the timeout of a call to tool: the tool's own,
+// else the server's, else the gateway default. Zero means none.
+func (m *Manager) toolCallTimeout(server *store.DownstreamServer, tool string) time.Duration {
+	sec := server.CallTimeoutSec
+	if t := server.ToolTimeouts[tool]; t != 0 {
+		sec = t
+	}
+	switch {
+	case sec < 0:
+		return 0
+	case sec > 0:
+		return time.Duration(sec) * time.Second
+	}
+	return m.callTimeout
+}
+
+// cancelReason is the reason given downstream in notifications/cancelled.
+func cancelReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "request timed out"
+	}
+	return "request cancelled by client"
+}
@@ -0,0 +1,71 @@
+package downstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowServer answers everything but tools/call and logs each message it
+// reads to $LOG.
+const slowServer = `while read -r line; do
+  printf '%s\n' "$line" >> "$LOG"
+  case "$line" in *'"tools/call"'*) continue ;; esac
+  id=$(printf '%s' "$line" | sed -n 's/.*"id":\([0-9]*\).*/\1/p')
+  [ -n "$id" ] && printf '{"jsonrpc":"2.0","id":%s,"result":{}}\n' "$id"
+done`
+
+func callTool(inst *Instance, timeout time.Duration) error {
+	_, err := callWithTimeout(context.Background(), timeout, func(ctx context.Context) (json.RawMessage, error) {
+		return inst.Call(ctx, "tools/call", json.RawMessage(`{"name":"slow"}`))
+	})
+	return err
+}
+
+func TestCallTimeoutCancelsDownstream(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "messages")
+	t.Setenv("LOG", logPath)
+	m := NewManager(nil, nil)
+	defer m.Shutdown(context.Background()) //nolint:errcheck
+	inst := startScripted(t, m, InstanceKey{ServerID: "srv"}, slowServer)
+
+	if err := callTool(inst, 50*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	waitFor(t, "cancellation", func() bool {
+		data, _ := os.ReadFile(logPath)
+		return strings.Contains(string(data), `"method":"notifications/cancelled"`) &&
+			strings.Contains(string(data), `"reason":"request timed out"`)
+	})
+
+	// The server still answers, so it is kept.
+	waitFor(t, "idle", func() bool { return inst.getState() == StateIdle })
+	if err := inst.ping(context.Background()); err != nil {
+		t.Fatalf("ping after timeout: %v", err)
+	}
+}
+
+func TestCallTimeoutRecyclesWedgedInstance(t *testing.T) {
+	defer func(d time.Duration) { cancelGrace = d }(cancelGrace)
+	cancelGrace = 50 * time.Millisecond
+
+	m := NewManager(nil, nil)
+	defer m.Shutdown(context.Background()) //nolint:errcheck
+	inst := startScripted(t, m, InstanceKey{ServerID: "srv"}, hungServer)
+
+	if err := callTool(inst, 50*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	waitFor(t, "wedged instance to stop", func() bool {
+		s := inst.getState()
+		if s == StateIdle {
+			t.Fatal("recycled instance went back to idle")
+		}
+		return s == StateStopped
+	})
+}
@@ -0,0 +1,449 @@
+package downstream
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // required by RFC 6455 for the accept key
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WebSocket opcodes (RFC 6455 section 5.2).
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+const (
+	wsAcceptGUID   = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsSubprotocol  = "mcp"
+	wsMaxMessage   = 16 << 20 // larger messages fail the connection
+	wsWriteTimeout = 10 * time.Second
+
+	wsNormalClosure = 1000
+	wsProtocolError = 1002
+)
+
+// errWSClosed means the peer closed the connection with a close frame.
+var errWSClosed = errors.New("websocket closed")
+
+// wsProxy picks the proxy for a connection, given a request for the
+// equivalent http:// or https:// URL. Like HTTP requests, WebSocket
+// connections honour HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+var wsProxy = http.ProxyFromEnvironment
+
+// wsConn is one WebSocket connection. It implements the parts of RFC 6455
+// MCP needs: text messages, fragmentation, ping/pong and the close
+// handshake; extensions are not negotiated. Reads happen on a single
+// goroutine, writes may come from any.
+type wsConn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool // clients mask their frames
+
+	wmu      sync.Mutex
+	lastRead atomic.Int64 // unix nanos of the last frame received
+	once     sync.Once
+	closed   chan struct{}
+}
+
+func newWSConn(conn net.Conn, br *bufio.Reader, client bool) *wsConn {
+	c := &wsConn{conn: conn, br: br, client: client, closed: make(chan struct{})}
+	c.lastRead.Store(time.Now().UnixNano())
+	return c
+}
+
+// dialWebSocket opens a client connection to a ws:// or wss:// URL
+// (http:// and https:// are accepted as aliases), sending header with the
+// upgrade request. Connections through a proxy use a CONNECT tunnel. ctx
+// bounds the handshake only.
+func dialWebSocket(ctx context.Context, rawURL string, header http.Header) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	var secure bool
+	switch u.Scheme {
+	case "ws", "http":
+	case "wss", "https":
+		secure = true
+	default:
+		return nil, fmt.Errorf("unsupported websocket scheme %q", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if secure {
+			port = "443"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	target := &url.URL{Scheme: "http", Host: addr}
+	if secure {
+		target.Scheme = "https"
+	}
+	proxy, err := wsProxy(&http.Request{URL: target})
+	if err != nil {
+		return nil, fmt.Errorf("proxy for %s: %w", addr, err)
+	}
+	dialAddr := addr
+	if proxy != nil {
+		if dialAddr, err = proxyAddr(proxy); err != nil {
+			return nil, err
+		}
+	}
+
+	var dialer net.Dialer
+	raw, err := dialer.DialContext(ctx, "tcp", dialAddr)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", dialAddr, err)
+	}
+	// Interrupt the handshake when ctx ends.
+	stop := context.AfterFunc(ctx, func() { raw.SetDeadline(time.Unix(1, 0)) }) //nolint:errcheck
+
+	c, err := handshakeWS(ctx, raw, u, addr, secure, proxy, header)
+	if !stop() {
+		raw.Close()
+		return nil, fmt.Errorf("websocket handshake: %w", ctx.Err())
+	}
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshakeWS opens the tunnel through proxy if there is one, sets up TLS
+// for secure connections and upgrades conn to a WebSocket.
+func handshakeWS(
+	ctx context.Context, conn net.Conn, u *url.URL, addr string, secure bool, proxy *url.URL, header http.Header,
+) (*wsConn, error) {
+	var br *bufio.Reader
+	if proxy != nil {
+		if proxy.Scheme == "https" {
+			tc := tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+			if err := tc.HandshakeContext(ctx); err != nil {
+				return nil, fmt.Errorf("proxy tls: %w", err)
+			}
+			conn = tc
+		}
+		var err error
+		if br, err = connectTunnel(conn, proxy, addr); err != nil {
+			return nil, err
+		}
+	}
+	if secure {
+		if br != nil {
+			// TLS reads whatever the proxy sent after its response first.
+			conn = &bufferedConn{Conn: conn, br: br}
+			br = nil
+		}
+		tc := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		conn = tc
+	}
+	return upgrade(conn, br, u, header)
+}
+
+// proxyAddr returns the host:port of an http:// or https:// proxy.
+func proxyAddr(proxy *url.URL) (string, error) {
+	port := proxy.Port()
+	switch proxy.Scheme {
+	case "http":
+		if port == "" {
+			port = "80"
+		}
+	case "https":
+		if port == "" {
+			port = "443"
+		}
+	default:
+		return "", fmt.Errorf("unsupported proxy scheme %q for websocket", proxy.Scheme)
+	}
+	return net.JoinHostPort(proxy.Hostname(), port), nil
+}
+
+// connectTunnel asks the proxy on conn to connect it to addr. The returned
+// reader holds any bytes the proxy sent after its response, so later reads
+// from the tunnel must go through it.
+func connectTunnel(conn net.Conn, proxy *url.URL, addr string) (*bufio.Reader, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxy.User; user != nil {
+		password, _ := user.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("send proxy CONNECT: %w", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("read proxy CONNECT response: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy CONNECT %s: %s", addr, resp.Status)
+	}
+	return br, nil
+}
+
+// bufferedConn is a net.Conn whose reads drain br first.
+type bufferedConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) { return c.br.Read(p) }
+
+// upgrade performs the opening handshake on conn. br, if not nil, is the
+// reader already buffering conn.
+func upgrade(conn net.Conn, br *bufio.Reader, u *url.URL, header http.Header) (*wsConn, error) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce) //nolint:errcheck // never fails
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Host:       u.Host,
+		Header:     header.Clone(),
+	}
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Protocol", wsSubprotocol)
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("send upgrade request: %w", err)
+	}
+
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("read upgrade response: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, ErrAuthRequired
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{code: resp.StatusCode, body: string(body)}
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		return nil, fmt.Errorf("invalid websocket upgrade response")
+	}
+	return newWSConn(conn, br, true), nil
+}
+
+// wsAcceptKey is the Sec-WebSocket-Accept value for a Sec-WebSocket-Key.
+func wsAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsAcceptGUID)) //nolint:gosec
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// writeText sends data as one text message.
+func (c *wsConn) writeText(data []byte) error {
+	return c.writeFrame(wsText, data)
+}
+
+// ping sends a ping frame; the pong counts as activity.
+func (c *wsConn) ping() error {
+	return c.writeFrame(wsPing, nil)
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode) // FIN, no fragmentation
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if c.client {
+		var mask [4]byte
+		rand.Read(mask[:]) //nolint:errcheck // never fails
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)) //nolint:errcheck
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// readMessage returns the next data message. Pings are answered and pongs
+// skipped on the way. A close frame returns errWSClosed; close then sends
+// the reply.
+func (c *wsConn) readMessage() ([]byte, error) {
+	var msg []byte
+	fragmented := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			if len(payload) >= 2 {
+				return nil, fmt.Errorf("%w (code %d)", errWSClosed, binary.BigEndian.Uint16(payload))
+			}
+			return nil, errWSClosed
+		case wsText, wsBinary:
+			if fragmented {
+				return nil, fmt.Errorf("websocket: new message inside a fragmented one")
+			}
+			msg = payload
+		case wsContinuation:
+			if !fragmented {
+				return nil, fmt.Errorf("websocket: unexpected continuation frame")
+			}
+			if len(msg)+len(payload) > wsMaxMessage {
+				return nil, fmt.Errorf("websocket: message exceeds %d bytes", wsMaxMessage)
+			}
+			msg = append(msg, payload...)
+		default:
+			return nil, fmt.Errorf("websocket: unknown opcode %#x", opcode)
+		}
+		if fin {
+			return msg, nil
+		}
+		fragmented = true
+	}
+}
+
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	c.lastRead.Store(time.Now().UnixNano())
+
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("websocket: reserved bits set")
+	}
+	masked := head[1]&0x80 != 0
+	if masked && c.client {
+		// A server must not mask its frames (RFC 6455 section 5.1).
+		c.closeWith(wsProtocolError)
+		return false, 0, nil, fmt.Errorf("websocket: masked frame from server")
+	}
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsClose && (n > 125 || !fin) {
+		return false, 0, nil, fmt.Errorf("websocket: invalid control frame")
+	}
+	if n > wsMaxMessage {
+		return false, 0, nil, fmt.Errorf("websocket: message exceeds %d bytes", wsMaxMessage)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// lastActivity reports when a frame was last received.
+func (c *wsConn) lastActivity() time.Time {
+	return time.Unix(0, c.lastRead.Load())
+}
+
+// close sends a normal close frame, without waiting for the reply, and
+// closes the connection. It is safe to call more than once.
+func (c *wsConn) close() {
+	c.closeWith(wsNormalClosure)
+}
+
+// closeWith is close with the given status code. Only the first call
+// sends a close frame.
+func (c *wsConn) closeWith(code uint16) {
+	c.once.Do(func() {
+		c.writeFrame(wsClose, binary.BigEndian.AppendUint16(nil, code)) //nolint:errcheck
+		c.conn.Close()
+		close(c.closed)
+	})
+}
@@ -0,0 +1,412 @@
+package downstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultWSPingInterval = 30 * time.Second
+
+// WSInstance communicates with a remote MCP server over a WebSocket. One
+// full-duplex connection carries JSON-RPC messages both ways, one per text
+// message, and responses are matched to requests by ID. The connection is
+// kept alive with pings and reconnected with backoff when it drops, which
+// starts a new session.
+type WSInstance struct {
+	key InstanceKey
+	url string
+
+	initTimeout  time.Duration
+	idleTimeout  time.Duration
+	pingInterval time.Duration // a connection silent for two intervals is dropped
+	reqID        atomic.Int64
+
+	mu          sync.Mutex
+	state       InstanceState
+	authHeaders http.Header
+	idleTimer   *time.Timer
+	conn        *wsConn                // current connection; nil while connecting
+	connected   chan struct{}          // closed once conn is set
+	pending     map[string]chan []byte // response waiters by request ID
+
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func newWSInstance(key InstanceKey, url string, idleTimeout time.Duration, headers http.Header) *WSInstance {
+	return &WSInstance{
+		key:          key,
+		url:          url,
+		initTimeout:  defaultInitTimeout,
+		idleTimeout:  idleTimeout,
+		pingInterval: defaultWSPingInterval,
+		state:        StateStopped,
+		authHeaders:  headers,
+		connected:    make(chan struct{}),
+		pending:      make(map[string]chan []byte),
+		stopped:      make(chan struct{}),
+	}
+}
+
+func (w *WSInstance) getState() InstanceState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state
+}
+
+func (w *WSInstance) start(ctx context.Context) error {
+	w.mu.Lock()
+	if w.state != StateStopped {
+		st := w.state
+		w.mu.Unlock()
+		return fmt.Errorf("cannot start websocket instance in state %s", st)
+	}
+	w.state = StateStarting
+	w.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, w.initTimeout)
+	defer cancel()
+	conn, err := w.connect(ctx)
+	if err != nil {
+		w.mu.Lock()
+		w.state = StateStopped
+		w.mu.Unlock()
+		return err
+	}
+
+	w.mu.Lock()
+	w.state = StateReady
+	w.mu.Unlock()
+	go w.run(conn)
+	return nil
+}
+
+func (w *WSInstance) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.idleTimer != nil {
+		w.idleTimer.Stop()
+	}
+	w.state = StateStopped
+	w.stopOnce.Do(func() { close(w.stopped) })
+}
+
+// connect opens a connection and initializes a session on it.
+func (w *WSInstance) connect(ctx context.Context) (*wsConn, error) {
+	w.mu.Lock()
+	headers := w.authHeaders
+	w.mu.Unlock()
+
+	conn, err := dialWebSocket(ctx, w.url, headers)
+	if err != nil {
+		return nil, err
+	}
+	go w.readLoop(conn)
+
+	if err := w.handshake(ctx, conn); err != nil {
+		conn.close()
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case <-w.stopped:
+		conn.close()
+		return nil, fmt.Errorf("websocket instance stopped")
+	default:
+	}
+	w.conn = conn
+	close(w.connected)
+	return conn, nil
+}
+
+func (w *WSInstance) handshake(ctx context.Context, conn *wsConn) error {
+	_, err := w.roundTrip(ctx, conn, jsonRPCRequest{
+		JSONRPC: "2.0",
+		ID:      w.nextID(),
+		Method:  "initialize",
+		Params:  initializeParams,
+	})
+	if err != nil {
+		return err
+	}
+	// Non-fatal: some servers don't handle this.
+	w.roundTrip(ctx, conn, jsonRPCRequest{JSONRPC: "2.0", Method: "notifications/initialized"}) //nolint:errcheck
+	return nil
+}
+
+// run keeps the connection alive and reconnects it whenever it drops,
+// until the instance stops. Requests waiting on a dropped connection
+// fail: their responses went with it. If the server stays unreachable the
+// instance stops, so the next call starts a fresh one.
+func (w *WSInstance) run(conn *wsConn) {
+	ticker := time.NewTicker(w.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopped:
+			conn.close()
+			return
+		case <-ticker.C:
+			if time.Since(conn.lastActivity()) > 2*w.pingInterval {
+				slog.Warn("downstream websocket unresponsive", "server", w.key.ServerID)
+				conn.close()
+			} else if err := conn.ping(); err != nil {
+				conn.close()
+			}
+			continue
+		case <-conn.closed:
+		}
+
+		w.mu.Lock()
+		w.conn = nil
+		w.connected = make(chan struct{})
+		for id, ch := range w.pending {
+			close(ch)
+			delete(w.pending, id)
+		}
+		w.mu.Unlock()
+
+		select {
+		case <-w.stopped:
+			return
+		default:
+		}
+		slog.Warn("downstream websocket closed, reconnecting", "server", w.key.ServerID)
+		if conn = w.reconnect(); conn == nil {
+			select {
+			case <-w.stopped:
+			default:
+				slog.Error("downstream websocket lost", "server", w.key.ServerID)
+				w.stop()
+			}
+			return
+		}
+	}
+}
+
+func (w *WSInstance) reconnect() *wsConn {
+	delay := reconnectDelay
+	for attempt := 1; attempt <= maxReconnects; attempt++ {
+		select {
+		case <-time.After(delay):
+		case <-w.stopped:
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), w.initTimeout)
+		conn, err := w.connect(ctx)
+		cancel()
+		if err == nil {
+			return conn
+		}
+		slog.Warn("reconnect downstream websocket",
+			"server", w.key.ServerID, "attempt", attempt, "error", err)
+		if errors.Is(err, ErrAuthRequired) {
+			// Retrying with the same credentials cannot help; a new
+			// instance resolves them again.
+			return nil
+		}
+		delay *= 2
+	}
+	return nil
+}
+
+// readLoop handles the messages of one connection until it ends.
+func (w *WSInstance) readLoop(conn *wsConn) {
+	defer conn.close()
+	for {
+		data, err := conn.readMessage()
+		if err != nil {
+			slog.Debug("downstream websocket ended", "server", w.key.ServerID, "error", err)
+			return
+		}
+		w.dispatch(conn, data)
+	}
+}
+
+// dispatch hands a response to its waiting request and answers requests
+// from the server. Notifications are dropped.
+func (w *WSInstance) dispatch(conn *wsConn, data []byte) {
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil || msg.ID == nil {
+		return
+	}
+	if msg.Method != "" {
+		if err := conn.writeText(replyTo(msg.ID, msg.Method)); err != nil {
+			slog.Debug("answer downstream request", "server", w.key.ServerID, "method", msg.Method, "error", err)
+		}
+		return
+	}
+
+	w.mu.Lock()
+	ch := w.pending[string(msg.ID)]
+	delete(w.pending, string(msg.ID))
+	w.mu.Unlock()
+	if ch != nil {
+		ch <- data
+	}
+}
+
+// rpc sends req on the current connection, waiting out a reconnect in
+// progress.
+func (w *WSInstance) rpc(ctx context.Context, req jsonRPCRequest) (json.RawMessage, error) {
+	for {
+		w.mu.Lock()
+		conn, connected := w.conn, w.connected
+		w.mu.Unlock()
+		if conn != nil {
+			return w.roundTrip(ctx, conn, req)
+		}
+		select {
+		case <-connected:
+		case <-w.stopped:
+			return nil, fmt.Errorf("websocket instance stopped")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// roundTrip sends req on conn and, unless it is a notification, waits for
+// its response.
+func (w *WSInstance) roundTrip(
+	ctx context.Context, conn *wsConn, req jsonRPCRequest,
+) (json.RawMessage, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	ex := ExchangeFrom(ctx)
+	if ex != nil {
+		ex.Request = body
+	}
+
+	var ch chan []byte
+	if req.ID != nil {
+		id := string(req.ID)
+		ch = make(chan []byte, 1)
+		w.mu.Lock()
+		w.pending[id] = ch
+		w.mu.Unlock()
+		defer func() {
+			w.mu.Lock()
+			delete(w.pending, id)
+			w.mu.Unlock()
+		}()
+	}
+
+	if err := conn.writeText(body); err != nil {
+		conn.close()
+		return nil, fmt.Errorf("websocket write: %w", err)
+	}
+	if ch == nil {
+		return nil, nil
+	}
+
+	var data []byte
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-conn.closed:
+		return nil, errStreamClosed
+	case d, ok := <-ch:
+		if !ok {
+			return nil, errStreamClosed
+		}
+		data = d
+	}
+	if ex != nil {
+		ex.Response = data
+	}
+	var resp jsonRPCResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, &remoteError{prefix: "rpc error", jsonRPCError: *resp.Error}
+	}
+	return resp.Result, nil
+}
+
+func (w *WSInstance) nextID() json.RawMessage {
+	return json.RawMessage(fmt.Sprintf(`%d`, w.reqID.Add(1)))
+}
+
+// ping sends an MCP ping on the current connection. It does not count as
+// use for the idle timeout.
+func (w *WSInstance) ping(ctx context.Context) error {
+	_, err := w.rpc(ctx, jsonRPCRequest{JSONRPC: "2.0", ID: w.nextID(), Method: "ping"})
+	return err
+}
+
+// ListTools sends a tools/list request to the server.
+func (w *WSInstance) ListTools(ctx context.Context) (json.RawMessage, error) {
+	return w.Call(ctx, "tools/list", json.RawMessage(`{}`))
+}
+
+// Call sends a request to the server and waits for its response. If the
+// caller gives up first, the server is told with notifications/cancelled.
+func (w *WSInstance) Call(
+	ctx context.Context, method string, params json.RawMessage,
+) (json.RawMessage, error) {
+	w.setBusy(true)
+	defer w.setBusy(false)
+
+	req := jsonRPCRequest{JSONRPC: "2.0", ID: w.nextID(), Method: method, Params: params}
+	result, err := w.rpc(ctx, req)
+	if err != nil && ctx.Err() != nil {
+		w.cancelRequest(req.ID, ctx.Err())
+	}
+	return result, err
+}
+
+// cancelRequest tells the server to stop working on a request whose
+// caller gave up.
+func (w *WSInstance) cancelRequest(id json.RawMessage, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelGrace)
+	defer cancel()
+	params, _ := json.Marshal(map[string]any{"requestId": id, "reason": cancelReason(cause)})
+	_, err := w.rpc(ctx, jsonRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "notifications/cancelled",
+		Params:  params,
+	})
+	if err != nil {
+		slog.Debug("send cancellation to downstream", "server", w.key.ServerID, "error", err)
+	}
+}
+
+// setBusy marks the instance busy for a call and idle after it, unless it
+// has stopped meanwhile.
+func (w *WSInstance) setBusy(busy bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.state == StateStopped || w.state == StateStopping {
+		return
+	}
+	if busy {
+		w.state = StateBusy
+		return
+	}
+	w.state = StateIdle
+	if w.idleTimeout <= 0 {
+		return
+	}
+	if w.idleTimer != nil {
+		w.idleTimer.Stop()
+	}
+	w.idleTimer = time.AfterFunc(w.idleTimeout, w.stop)
+}
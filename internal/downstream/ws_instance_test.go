@@ -0,0 +1,383 @@
+package downstream
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// wsServer is an MCP server over WebSocket that answers every request with
+// an empty result. Before answering tools/call it sends the client a ping
+// request of its own and waits for the reply.
+type wsServer struct {
+	*httptest.Server
+	hangAfterInit bool // the first connection stops reading once initialized
+
+	mu      sync.Mutex
+	conns   []*wsConn
+	headers []http.Header
+	methods []string
+	done    chan struct{}
+}
+
+func newWSServer(t *testing.T, hangAfterInit bool) *wsServer {
+	t.Helper()
+	srv := &wsServer{hangAfterInit: hangAfterInit, done: make(chan struct{})}
+	srv.Server = httptest.NewServer(http.HandlerFunc(srv.serve))
+	t.Cleanup(func() {
+		close(srv.done)
+		srv.drop()
+		srv.Close()
+	})
+	return srv
+}
+
+// acceptWS completes the server side of the opening handshake.
+func acceptWS(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Header.Get("Sec-WebSocket-Version") != "13" || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return nil, fmt.Errorf("not a websocket upgrade")
+	}
+	conn, brw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Protocol: mcp\r\nSec-WebSocket-Accept: %s\r\n\r\n", wsAcceptKey(r.Header.Get("Sec-WebSocket-Key")))
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return newWSConn(conn, brw.Reader, false), nil
+}
+
+func (s *wsServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "Bearer bad" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	conn, err := acceptWS(w, r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	s.headers = append(s.headers, r.Header.Clone())
+	first := len(s.conns) == 1
+	s.mu.Unlock()
+
+	defer conn.close()
+	var callID json.RawMessage
+	for {
+		data, err := conn.readMessage()
+		if err != nil {
+			return
+		}
+		var msg jsonRPCRequest
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.methods = append(s.methods, msg.Method)
+		s.mu.Unlock()
+
+		switch {
+		case msg.Method == "notifications/initialized" && first && s.hangAfterInit:
+			<-s.done
+			return
+		case msg.Method == "tools/call":
+			callID = msg.ID
+			conn.writeText([]byte(`{"jsonrpc":"2.0","id":"srv-1","method":"ping"}`)) //nolint:errcheck
+		case msg.Method == "" && string(msg.ID) == `"srv-1"`:
+			// The client answered our ping; now answer its call.
+			conn.writeText([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"pong":true}}`, callID))) //nolint:errcheck
+		case msg.ID != nil:
+			conn.writeText([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{}}`, msg.ID))) //nolint:errcheck
+		}
+	}
+}
+
+// drop closes every connection.
+func (s *wsServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.close()
+	}
+}
+
+func (s *wsServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/mcp"
+}
+
+func TestWSInstanceCall(t *testing.T) {
+	srv := newWSServer(t, false)
+	headers := http.Header{"Authorization": {"Bearer token"}}
+	inst := newWSInstance(InstanceKey{ServerID: "srv"}, wsURL(srv.Server), time.Minute, headers)
+	if err := inst.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer inst.stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := inst.Call(ctx, "tools/call", json.RawMessage(`{"name":"echo"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != `{"pong":true}` {
+		t.Fatalf("result = %s", result)
+	}
+	if err := inst.ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if got := srv.headers[0].Get("Authorization"); got != "Bearer token" {
+		t.Fatalf("Authorization = %q", got)
+	}
+	if got := srv.headers[0].Get("Sec-WebSocket-Protocol"); got != "mcp" {
+		t.Fatalf("Sec-WebSocket-Protocol = %q", got)
+	}
+	if len(srv.methods) < 2 || srv.methods[0] != "initialize" || srv.methods[1] != "notifications/initialized" {
+		t.Fatalf("methods = %v", srv.methods)
+	}
+}
+
+func TestWSInstanceAuthRequired(t *testing.T) {
+	srv := newWSServer(t, false)
+	headers := http.Header{"Authorization": {"Bearer bad"}}
+	inst := newWSInstance(InstanceKey{ServerID: "srv"}, wsURL(srv.Server), time.Minute, headers)
+	if err := inst.start(context.Background()); !errors.Is(err, ErrAuthRequired) {
+		t.Fatalf("err = %v, want ErrAuthRequired", err)
+	}
+}
+
+func TestWSInstanceReconnects(t *testing.T) {
+	srv := newWSServer(t, false)
+	inst := newWSInstance(InstanceKey{ServerID: "srv"}, wsURL(srv.Server), time.Minute, nil)
+	if err := inst.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer inst.stop()
+
+	srv.drop()
+	waitFor(t, "second connection", func() bool { return srv.connections() == 2 })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := inst.Call(ctx, "tools/list", json.RawMessage(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWSInstanceDropsUnresponsiveConnection(t *testing.T) {
+	srv := newWSServer(t, true)
+	inst := newWSInstance(InstanceKey{ServerID: "srv"}, wsURL(srv.Server), time.Minute, nil)
+	inst.pingInterval = 20 * time.Millisecond
+	if err := inst.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer inst.stop()
+
+	// The first connection never answers the keepalive pings.
+	waitFor(t, "reconnect", func() bool { return srv.connections() == 2 })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := inst.ping(ctx); err != nil {
+		t.Fatalf("ping after reconnect: %v", err)
+	}
+}
+
+// connectProxy is an HTTP proxy that only supports CONNECT. auths returns
+// the Proxy-Authorization header of each tunnel.
+func connectProxy(t *testing.T) (srv *httptest.Server, auths func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var auth []string
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		auth = append(auth, r.Header.Get("Proxy-Authorization"))
+		mu.Unlock()
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		client, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		brw.WriteString("HTTP/1.1 200 Connection established\r\n\r\n") //nolint:errcheck
+		brw.Flush()                                                    //nolint:errcheck
+		go func() {
+			io.Copy(upstream, brw) //nolint:errcheck
+			upstream.Close()
+		}()
+		io.Copy(client, upstream) //nolint:errcheck
+		client.Close()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), auth...)
+	}
+}
+
+func TestWSInstanceThroughProxy(t *testing.T) {
+	srv := newWSServer(t, false)
+	proxy, auths := connectProxy(t)
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("user", "secret")
+
+	var proxied []string
+	old := wsProxy
+	wsProxy = func(r *http.Request) (*url.URL, error) {
+		proxied = append(proxied, r.URL.String())
+		return proxyURL, nil
+	}
+	defer func() { wsProxy = old }()
+
+	inst := newWSInstance(InstanceKey{ServerID: "srv"}, wsURL(srv.Server), time.Minute, nil)
+	if err := inst.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer inst.stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := inst.ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+
+	if want := "http://" + strings.TrimPrefix(srv.URL, "http://"); len(proxied) != 1 || proxied[0] != want {
+		t.Fatalf("proxy looked up for %q, want [%s]", proxied, want)
+	}
+	if auth := auths(); len(auth) != 1 || auth[0] != "Basic dXNlcjpzZWNyZXQ=" {
+		t.Fatalf("Proxy-Authorization = %q", auth)
+	}
+	if srv.connections() != 1 {
+		t.Fatalf("server saw %d connections", srv.connections())
+	}
+}
+
+func TestWSReadMessage(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	c := newWSConn(client, bufio.NewReader(client), true)
+	s := newWSConn(server, bufio.NewReader(server), false)
+
+	errc := make(chan error, 1)
+	go func() {
+		// "hel" + ping + "lo": a fragmented text message with a control
+		// frame in between.
+		frames := [][]byte{
+			{0x01, 3, 'h', 'e', 'l'},
+			{0x89, 2, 'h', 'i'},
+			{0x80, 2, 'l', 'o'},
+		}
+		for _, f := range frames {
+			if _, err := server.Write(f); err != nil {
+				errc <- err
+				return
+			}
+			if f[0] == 0x89 {
+				_, op, payload, err := s.readFrame()
+				if err == nil && (op != wsPong || string(payload) != "hi") {
+					err = fmt.Errorf("got opcode %#x %q, want pong", op, payload)
+				}
+				if err != nil {
+					errc <- err
+					return
+				}
+			}
+		}
+		errc <- nil
+	}()
+
+	msg, err := c.readMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg) != "hello" {
+		t.Fatalf("message = %q", msg)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWSRejectsMaskedServerFrame(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	c := newWSConn(client, bufio.NewReader(client), true)
+	s := newWSConn(server, bufio.NewReader(server), false)
+
+	closeCode := make(chan uint16, 1)
+	go func() {
+		server.Write([]byte{0x81, 0x82, 1, 2, 3, 4, 'h' ^ 1, 'i' ^ 2}) //nolint:errcheck
+		_, op, payload, err := s.readFrame()
+		if err != nil || op != wsClose || len(payload) < 2 {
+			closeCode <- 0
+			return
+		}
+		closeCode <- binary.BigEndian.Uint16(payload)
+	}()
+
+	if _, err := c.readMessage(); err == nil {
+		t.Fatal("masked server frame accepted")
+	}
+	if code := <-closeCode; code != wsProtocolError {
+		t.Fatalf("close code = %d, want %d", code, wsProtocolError)
+	}
+}
+
+func TestConnectTunnelKeepsBufferedBytes(t *testing.T) {
+	client, proxy := net.Pipe()
+	defer client.Close()
+	defer proxy.Close()
+
+	go func() {
+		br := bufio.NewReader(proxy)
+		if _, err := http.ReadRequest(br); err != nil {
+			return
+		}
+		// The response and the first tunnelled bytes arrive together.
+		proxy.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\nHTTP/1.1 101")) //nolint:errcheck
+	}()
+
+	proxyURL, _ := url.Parse("http://proxy.example:3128")
+	br, err := connectTunnel(client, proxyURL, "mcp.example:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest := make([]byte, len("HTTP/1.1 101"))
+	if _, err := io.ReadFull(br, rest); err != nil || string(rest) != "HTTP/1.1 101" {
+		t.Fatalf("tunnel read %q, %v", rest, err)
+	}
+}
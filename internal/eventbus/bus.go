@@ -0,0 +1,140 @@
+// Package eventbus fans out events to streaming subscribers. Every event
+// gets a monotonic ID and stays in a bounded replay buffer, so a subscriber
+// that falls behind or reconnects reads on from its last ID and learns how
+// many events it missed instead of losing them silently.
+package eventbus
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is a published value and its ID.
+type Event[T any] struct {
+	ID   uint64
+	Data T
+}
+
+// Bus keeps the last events published in a ring buffer and wakes
+// subscribers when new ones arrive.
+type Bus[T any] struct {
+	mu     sync.Mutex
+	ring   []Event[T]
+	start  int    // ring index of the oldest event
+	n      int    // events in the ring
+	base   uint64 // last ID before this bus published anything
+	lastID uint64
+	subs   map[*Subscription[T]]struct{}
+}
+
+// New creates a bus replaying up to capacity events. IDs start from the
+// current time in microseconds, so they keep increasing across restarts and
+// an ID from an earlier process is recognised as unknown rather than
+// replaying unrelated events.
+func New[T any](capacity int) *Bus[T] {
+	base := uint64(time.Now().UnixMicro())
+	return &Bus[T]{
+		ring:   make([]Event[T], max(capacity, 1)),
+		base:   base,
+		lastID: base,
+		subs:   make(map[*Subscription[T]]struct{}),
+	}
+}
+
+// Publish stores v under the next ID, wakes subscribers and returns the ID.
+// It never blocks on subscribers.
+func (b *Bus[T]) Publish(v T) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	ev := Event[T]{ID: b.lastID, Data: v}
+	if b.n < len(b.ring) {
+		b.ring[(b.start+b.n)%len(b.ring)] = ev
+		b.n++
+	} else {
+		b.ring[b.start] = ev
+		b.start = (b.start + 1) % len(b.ring)
+	}
+	for s := range b.subs {
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+	return ev.ID
+}
+
+// LastID returns the ID of the latest event, or the bus's starting ID if
+// nothing was published yet. Reading Since(LastID()) returns only events
+// published afterwards.
+func (b *Bus[T]) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+// Since returns the buffered events after the given ID, oldest first.
+// missed counts the events after that ID that already left the buffer.
+// ok is false when the ID was not issued by this bus (e.g. it comes from
+// before a restart); the caller should then resume from LastID.
+func (b *Bus[T]) Since(after uint64) (events []Event[T], missed uint64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if after < b.base || after > b.lastID {
+		return nil, 0, false
+	}
+	oldest := b.lastID - uint64(b.n) + 1
+	if after+1 < oldest {
+		missed = oldest - after - 1
+		after = oldest - 1
+	}
+	skip := int(after + 1 - oldest)
+	for i := skip; i < b.n; i++ {
+		events = append(events, b.ring[(b.start+i)%len(b.ring)])
+	}
+	return events, missed, true
+}
+
+// Tail returns up to the last n buffered events, oldest first.
+func (b *Bus[T]) Tail(n int) []Event[T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n = min(max(n, 0), b.n)
+	events := make([]Event[T], 0, n)
+	for i := b.n - n; i < b.n; i++ {
+		events = append(events, b.ring[(b.start+i)%len(b.ring)])
+	}
+	return events
+}
+
+// Subscription wakes a reader when events are published.
+type Subscription[T any] struct {
+	bus    *Bus[T]
+	notify chan struct{}
+}
+
+// Subscribe registers a new subscription. The caller must Close it when
+// done.
+func (b *Bus[T]) Subscribe() *Subscription[T] {
+	s := &Subscription[T]{bus: b, notify: make(chan struct{}, 1)}
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Ready receives a value after one or more events were published; the
+// reader then collects them with Since.
+func (s *Subscription[T]) Ready() <-chan struct{} {
+	return s.notify
+}
+
+// Close removes the subscription.
+func (s *Subscription[T]) Close() {
+	s.bus.mu.Lock()
+	delete(s.bus.subs, s)
+	s.bus.mu.Unlock()
+}
@@ -0,0 +1,74 @@
+package eventbus
+
+import "testing"
+
+func TestSinceReplaysAndCountsMissed(t *testing.T) {
+	b := New[int](3)
+	start := b.LastID()
+
+	if evs, missed, ok := b.Since(start); !ok || len(evs) != 0 || missed != 0 {
+		t.Fatalf("empty bus: %v %d %v", evs, missed, ok)
+	}
+
+	sub := b.Subscribe()
+	defer sub.Close()
+
+	var ids []uint64
+	for i := range 5 {
+		ids = append(ids, b.Publish(i))
+	}
+	select {
+	case <-sub.Ready():
+	default:
+		t.Fatal("subscriber not woken")
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] != ids[i-1]+1 {
+			t.Fatalf("ids not consecutive: %v", ids)
+		}
+	}
+
+	// From the start: the first two events left the ring.
+	evs, missed, ok := b.Since(start)
+	if !ok || missed != 2 || len(evs) != 3 || evs[0].Data != 2 || evs[2].Data != 4 {
+		t.Fatalf("Since(start) = %v, missed %d, ok %v", evs, missed, ok)
+	}
+
+	// Resuming inside the ring replays the rest without a gap.
+	evs, missed, ok = b.Since(ids[2])
+	if !ok || missed != 0 || len(evs) != 2 || evs[0].ID != ids[3] {
+		t.Fatalf("Since(ids[2]) = %v, missed %d, ok %v", evs, missed, ok)
+	}
+
+	if evs, _, ok := b.Since(b.LastID()); !ok || len(evs) != 0 {
+		t.Fatalf("Since(LastID) = %v, ok %v", evs, ok)
+	}
+}
+
+func TestSinceUnknownID(t *testing.T) {
+	b := New[string](4)
+	b.Publish("a")
+
+	for _, id := range []uint64{0, b.LastID() + 1} {
+		if _, _, ok := b.Since(id); ok {
+			t.Errorf("Since(%d) accepted an ID the bus never issued", id)
+		}
+	}
+}
+
+func TestTail(t *testing.T) {
+	b := New[int](3)
+	if evs := b.Tail(2); len(evs) != 0 {
+		t.Fatalf("empty bus: %v", evs)
+	}
+	for i := range 5 {
+		b.Publish(i)
+	}
+	evs := b.Tail(2)
+	if len(evs) != 2 || evs[0].Data != 3 || evs[1].Data != 4 || evs[1].ID != b.LastID() {
+		t.Fatalf("Tail(2) = %v", evs)
+	}
+	if evs := b.Tail(10); len(evs) != 3 || evs[0].Data != 2 {
+		t.Fatalf("Tail(10) = %v", evs)
+	}
+}
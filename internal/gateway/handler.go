@@ -12,8 +12,11 @@ import (
 	"github.com/google/uuid"
 	"github.com/revitteth/mcplexer/internal/approval"
 	"github.com/revitteth/mcplexer/internal/audit"
+	"github.com/revitteth/mcplexer/internal/downstream"
+	"github.com/revitteth/mcplexer/internal/metrics"
 	"github.com/revitteth/mcplexer/internal/routing"
 	"github.com/revitteth/mcplexer/internal/store"
+	"github.com/revitteth/mcplexer/internal/tracing"
 )
 
 // ToolLister abstracts downstream tool discovery and invocation.
@@ -21,6 +24,7 @@ type ToolLister interface {
 	ListAllTools(ctx context.Context) (map[string]json.RawMessage, error)
 	ListToolsForServers(ctx context.Context, serverIDs []string) (map[string]json.RawMessage, error)
 	Call(ctx context.Context, serverID, authScopeID, toolName string, args json.RawMessage) (json.RawMessage, error)
+	StopSession(sessionID string)
 }
 
 // handler contains the logic for each MCP method.
@@ -31,6 +35,12 @@ type handler struct {
 	sessions  *sessionManager
 	auditor   *audit.Logger
 	approvals *approval.Manager // nil = approval system disabled
+
+	// Set by the server for the lifetime of a connection. Approved calls
+	// resumed in the background run under connCtx and report through notify.
+	connCtx context.Context
+	notify  func(method string, params any)
+	detach  func() // detaches the session from approvals; nil when not attached
 }
 
 func newHandler(
@@ -45,9 +55,11 @@ func newHandler(
 		store:     s,
 		engine:    e,
 		manager:   m,
-		sessions:  newSessionManager(s, t),
+		sessions:  newSessionManager(s, t, m),
 		auditor:   a,
 		approvals: approvals,
+		connCtx:   context.Background(),
+		notify:    func(string, any) {},
 	}
 }
 
@@ -62,6 +74,7 @@ func (h *handler) handleInitialize(
 	if err := h.sessions.create(ctx, p.ClientInfo, p.Roots); err != nil {
 		slog.Error("create session", "error", err)
 	}
+	h.attachApprovals()
 
 	result := InitializeResult{
 		ProtocolVersion: "2024-11-05",
@@ -70,6 +83,9 @@ func (h *handler) handleInitialize(
 		},
 		ServerInfo: ServerInfo{Name: "mcplexer", Version: "0.1.0"},
 	}
+	if h.approvals != nil {
+		result.Capabilities.Logging = &struct{}{}
+	}
 
 	data, err := json.Marshal(result)
 	if err != nil {
@@ -81,6 +97,7 @@ func (h *handler) handleInitialize(
 func (h *handler) handleToolsList(
 	ctx context.Context,
 ) (json.RawMessage, *RPCError) {
+	ctx = downstream.WithSession(ctx, h.sessions.templateVars())
 	servers, err := h.store.ListDownstreamServers(ctx)
 	if err != nil {
 		return nil, &RPCError{
@@ -191,16 +208,27 @@ func (h *handler) handleToolsCall(
 	ctx context.Context, params json.RawMessage,
 ) (json.RawMessage, *RPCError) {
 	start := time.Now()
+	ctx = downstream.WithSession(ctx, h.sessions.templateVars())
 
 	var req CallToolRequest
 	if err := json.Unmarshal(params, &req); err != nil {
 		return nil, &RPCError{Code: CodeInvalidParams, Message: err.Error()}
 	}
 
+	// Continue the caller's trace when it sent one.
+	if req.Meta != nil {
+		if sc, ok := tracing.ParseTraceparent(req.Meta.Traceparent); ok {
+			ctx = tracing.WithParent(ctx, sc)
+		}
+	}
+	ctx, span := tracing.Start(ctx, "tools/call", tracing.KindServer,
+		tracing.String("mcp.tool", req.Name))
+	defer span.End()
+
 	// Handle built-in mcplexer tools before routing.
 	if strings.HasPrefix(req.Name, "mcplexer__") {
 		result, rpcErr := h.handleBuiltinCall(ctx, req)
-		h.recordAudit(ctx, req.Name, req.Arguments, nil, result, rpcErr, start)
+		h.recordAudit(ctx, req.Name, req.Arguments, nil, "", result, rpcErr, start)
 		return result, rpcErr
 	}
 
@@ -208,22 +236,51 @@ func (h *handler) handleToolsCall(
 	originalTool := extractOriginalToolName(req.Name)
 
 	// Route the call, falling back through ancestor workspaces.
+	_, routeSpan := tracing.Start(ctx, "route", tracing.KindInternal)
 	routeResult, err := h.engine.RouteWithFallback(ctx, routing.RouteContext{
 		ToolName: req.Name,
 	}, h.sessions.clientRoot(), h.sessions.workspaceAncestors())
+	routeSpan.RecordError(err)
+	routeSpan.End()
 	if err != nil {
 		rpcErr := mapRouteError(err)
-		h.recordAudit(ctx, req.Name, req.Arguments, nil, nil, rpcErr, start)
+		h.recordAudit(ctx, req.Name, req.Arguments, nil, "", nil, rpcErr, start)
 		return nil, rpcErr
 	}
 
 	// Two-phase approval interception.
+	var approvalID string
 	if routeResult.RequiresApproval && h.approvals != nil {
-		result, rpcErr := h.handleApprovalGate(ctx, req, routeResult, originalTool, start)
+		var result json.RawMessage
+		var rpcErr *RPCError
+		_, approvalSpan := tracing.Start(ctx, "approval", tracing.KindInternal)
+		var approved *store.ToolApproval
+		approved, result, rpcErr = h.handleApprovalGate(ctx, req, routeResult, originalTool, start)
+		approvalSpan.SetAttributes(tracing.Bool("mcplexer.approved", approved != nil))
+		approvalSpan.End()
 		if result != nil || rpcErr != nil {
 			return result, rpcErr
 		}
-		// Approval granted — fall through to dispatch.
+		// Approval granted — dispatch the call that was approved, which
+		// for a resumed approval is the one stored with it.
+		approvalID = approved.ID
+		req.Arguments = approvedArguments(approved)
+	}
+
+	return h.callDownstream(ctx, req, routeResult, originalTool, approvalID, start)
+}
+
+// callDownstream dispatches a routed tool call and records its audit record.
+func (h *handler) callDownstream(
+	ctx context.Context,
+	req CallToolRequest,
+	routeResult *routing.RouteResult,
+	originalTool, approvalID string,
+	start time.Time,
+) (json.RawMessage, *RPCError) {
+	// Capture the raw exchange for debug-level audit records.
+	if routeResult.LogLevel == store.LogLevelDebug {
+		ctx = downstream.WithExchange(ctx, &downstream.Exchange{})
 	}
 
 	// Dispatch to downstream.
@@ -239,25 +296,30 @@ func (h *handler) handleToolsCall(
 			Code:    CodeProcessError,
 			Message: fmt.Sprintf("downstream call: %v", err),
 		}
-		h.recordAudit(ctx, req.Name, req.Arguments, routeResult, nil, rpcErr, start)
+		if errors.Is(err, downstream.ErrTimeout) {
+			rpcErr.Code = CodeTimeout
+		}
+		h.recordAudit(ctx, req.Name, req.Arguments, routeResult, approvalID, nil, rpcErr, start)
 		return nil, rpcErr
 	}
 
-	h.recordAudit(ctx, req.Name, req.Arguments, routeResult, result, nil, start)
+	h.recordAudit(ctx, req.Name, req.Arguments, routeResult, approvalID, result, nil, start)
 	return result, nil
 }
 
 // handleApprovalGate implements two-phase approval interception.
 // Phase 1: no _justification → return error asking for it.
 // Phase 2: _justification present → block until approved/denied/timeout.
-// Returns (nil, nil) when approved (caller should proceed to dispatch).
+// Returns the approval with a nil result and error when approved (caller
+// should proceed to dispatch and link the resulting audit record). Retrying
+// a call resumes an earlier approval for it (see approval.FindResumable).
 func (h *handler) handleApprovalGate(
 	ctx context.Context,
 	req CallToolRequest,
 	route *routing.RouteResult,
 	originalTool string,
 	start time.Time,
-) (json.RawMessage, *RPCError) {
+) (*store.ToolApproval, json.RawMessage, *RPCError) {
 	// Parse arguments to check for _justification.
 	var args map[string]json.RawMessage
 	if len(req.Arguments) > 0 {
@@ -278,14 +340,15 @@ func (h *handler) handleApprovalGate(
 				"Retry your call with an additional `_justification` field " +
 				"explaining why you need to use this tool.",
 		)
-		h.recordAudit(ctx, req.Name, req.Arguments, route, result, nil, start)
-		return result, nil
+		h.recordAudit(ctx, req.Name, req.Arguments, route, "", result, nil, start)
+		return nil, result, nil
 	}
 
 	// Phase 2: justification present — strip it from args and block.
 	delete(args, "_justification")
 	cleanArgs, _ := json.Marshal(args)
 	req.Arguments = cleanArgs
+	callParams, _ := json.Marshal(req)
 
 	timeout := route.ApprovalTimeout
 	if timeout <= 0 {
@@ -298,7 +361,8 @@ func (h *handler) handleApprovalGate(
 		RequestModel:       h.sessions.modelHint(),
 		WorkspaceID:        h.sessions.workspaceID(),
 		ToolName:           req.Name,
-		Arguments:          string(cleanArgs),
+		Arguments:          approval.CanonicalArguments(cleanArgs),
+		CallParams:         callParams,
 		Justification:      justification,
 		RouteRuleID:        route.MatchedRuleID,
 		DownstreamServerID: route.DownstreamServerID,
@@ -306,38 +370,118 @@ func (h *handler) handleApprovalGate(
 		TimeoutSec:         timeout,
 	}
 
-	approved, err := h.approvals.RequestApproval(ctx, rec)
+	// Resume an earlier approval for the same call (e.g. one that survived
+	// a daemon restart) instead of asking again.
+	resumed, err := h.approvals.FindResumable(ctx, rec)
 	if err != nil {
+		slog.Warn("failed to look up resumable approval", "tool", req.Name, "error", err)
+	}
+
+	var approved bool
+	if resumed != nil {
+		slog.Info("resuming approval", "id", resumed.ID, "status", resumed.Status, "tool", req.Name)
+		fresh := rec
+		rec = resumed
+		approved, err = h.approvals.Await(ctx, rec)
+		if errors.Is(err, approval.ErrNotArmed) {
+			// Expired before it could be re-armed: ask again.
+			rec = fresh
+			approved, err = h.approvals.RequestApproval(ctx, rec)
+		}
+	} else {
+		approved, err = h.approvals.RequestApproval(ctx, rec)
+	}
+	if err != nil {
+		if errors.Is(err, approval.ErrShuttingDown) || ctx.Err() != nil {
+			result := marshalErrorResult(fmt.Sprintf(
+				"Approval %s is still pending but mcplexer is shutting down. "+
+					"Once approved, the call runs when this client reconnects and the result "+
+					"is sent as a notification; retrying the same call also resumes it.",
+				rec.ID))
+			h.recordAudit(ctx, req.Name, req.Arguments, route, "", result, nil, start)
+			return nil, result, nil
+		}
 		rpcErr := &RPCError{
 			Code:    CodeInternalError,
 			Message: fmt.Sprintf("approval request failed: %v", err),
 		}
-		h.recordAudit(ctx, req.Name, req.Arguments, route, nil, rpcErr, start)
-		return nil, rpcErr
+		h.recordAudit(ctx, req.Name, req.Arguments, route, "", nil, rpcErr, start)
+		return nil, nil, rpcErr
 	}
 
 	if !approved {
 		result := marshalErrorResult(
 			fmt.Sprintf("Tool call denied. Reason: %s", rec.Resolution),
 		)
-		h.recordAudit(ctx, req.Name, req.Arguments, route, result, nil, start)
-		return result, nil
+		h.recordAudit(ctx, req.Name, req.Arguments, route, rec.ID, result, nil, start)
+		return nil, result, nil
 	}
 
-	// Approved — return nil to signal caller to proceed with dispatch.
-	return nil, nil
+	// Each approval authorises exactly one execution.
+	if err := h.approvals.ClaimExecution(ctx, rec.ID); err != nil {
+		result := marshalErrorResult(fmt.Sprintf(
+			"Approval %s cannot be used: %v. Retry with a new `_justification` to request approval again.",
+			rec.ID, err))
+		h.recordAudit(ctx, req.Name, req.Arguments, route, "", result, nil, start)
+		return nil, result, nil
+	}
+
+	// Approved — return nil result to signal caller to proceed with dispatch.
+	return rec, nil, nil
+}
+
+// approvedArguments returns the arguments of the tools/call an approval was
+// granted for, falling back to its stored arguments for records without the
+// originating call params.
+func approvedArguments(rec *store.ToolApproval) json.RawMessage {
+	var req CallToolRequest
+	if len(rec.CallParams) > 0 && json.Unmarshal(rec.CallParams, &req) == nil && len(req.Arguments) > 0 {
+		return req.Arguments
+	}
+	return json.RawMessage(rec.Arguments)
 }
 
-// recordAudit creates and persists an audit record for a tool call.
+// recordAudit updates the call metrics, then creates and persists an audit
+// record for a tool call.
 func (h *handler) recordAudit(
 	ctx context.Context,
 	toolName string,
 	params json.RawMessage,
 	route *routing.RouteResult,
+	approvalID string,
 	result json.RawMessage,
 	rpcErr *RPCError,
 	start time.Time,
 ) {
+	elapsed := time.Since(start)
+	status := "success"
+	switch {
+	case rpcErr != nil && rpcErr.Code == CodeTimeout:
+		status = "timeout"
+	case rpcErr != nil || isToolError(result):
+		status = "error"
+	}
+	var serverID string
+	if route != nil {
+		serverID = route.DownstreamServerID
+	}
+	labels := []string{toolName, serverID, h.sessions.workspaceID(), status}
+	metrics.ToolCalls.Inc(labels...)
+	metrics.ToolCallDuration.ObserveDuration(elapsed, labels...)
+
+	if span := tracing.SpanFrom(ctx); span != nil {
+		span.SetAttributes(tracing.String("mcplexer.status", status))
+		if serverID != "" {
+			span.SetAttributes(tracing.String("mcplexer.downstream_server_id", serverID))
+		}
+		switch {
+		case rpcErr != nil:
+			span.SetError(rpcErr.Message)
+		case isToolError(result):
+			span.SetError("tool returned an error")
+		}
+	}
+
 	if h.auditor == nil {
 		return
 	}
@@ -359,18 +503,27 @@ func (h *handler) recordAudit(
 		ToolName:       toolName,
 		ParamsRedacted: params,
 		Status:         "success",
-		LatencyMs:      int(time.Since(start).Milliseconds()),
+		LatencyMs:      int(elapsed.Milliseconds()),
 		ResponseSize:   len(result),
+		ApprovalID:     approvalID,
+		Response:       result,
+	}
+	if sc := tracing.SpanContextFrom(ctx); sc.IsValid() {
+		rec.TraceID = sc.TraceID.String()
 	}
 
 	if route != nil {
 		rec.RouteRuleID = route.MatchedRuleID
 		rec.DownstreamServerID = route.DownstreamServerID
 		rec.AuthScopeID = route.AuthScopeID
+		rec.LogLevel = route.LogLevel
+	}
+	if ex := downstream.ExchangeFrom(ctx); ex != nil && ex.Request != nil {
+		rec.Exchange, _ = json.Marshal(ex)
 	}
 
 	if rpcErr != nil {
-		rec.Status = "error"
+		rec.Status = status
 		rec.ErrorCode = fmt.Sprintf("%d", rpcErr.Code)
 		rec.ErrorMessage = rpcErr.Message
 	} else if isToolError(result) {
@@ -381,6 +534,12 @@ func (h *handler) recordAudit(
 
 	if err := h.auditor.Record(ctx, rec); err != nil {
 		slog.Error("audit record failed", "error", err)
+		return
+	}
+	if approvalID != "" && rec.LogLevel != store.LogLevelNone {
+		if err := h.store.LinkApprovalAuditRecord(ctx, approvalID, rec.ID); err != nil {
+			slog.Warn("link approval to audit record failed", "approval", approvalID, "error", err)
+		}
 	}
 }
 
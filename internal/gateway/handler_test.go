@@ -3,10 +3,14 @@ package gateway
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sort"
 	"testing"
 	"time"
 
+	"github.com/revitteth/mcplexer/internal/approval"
+	"github.com/revitteth/mcplexer/internal/audit"
+	"github.com/revitteth/mcplexer/internal/downstream"
 	"github.com/revitteth/mcplexer/internal/routing"
 	"github.com/revitteth/mcplexer/internal/store"
 )
@@ -15,8 +19,11 @@ import (
 
 // mockToolLister implements ToolLister for testing.
 type mockToolLister struct {
-	tools map[string]json.RawMessage
-	err   error
+	tools    map[string]json.RawMessage
+	err      error
+	callErr  error           // returned by Call
+	callArgs json.RawMessage // arguments of the last Call
+	stopped  []string        // session IDs passed to StopSession
 }
 
 func (m *mockToolLister) ListAllTools(_ context.Context) (map[string]json.RawMessage, error) {
@@ -33,8 +40,13 @@ func (m *mockToolLister) ListToolsForServers(_ context.Context, serverIDs []stri
 	return result, m.err
 }
 
-func (m *mockToolLister) Call(_ context.Context, _, _, _ string, _ json.RawMessage) (json.RawMessage, error) {
-	return nil, nil
+func (m *mockToolLister) Call(_ context.Context, _, _, _ string, args json.RawMessage) (json.RawMessage, error) {
+	m.callArgs = args
+	return nil, m.callErr
+}
+
+func (m *mockToolLister) StopSession(sessionID string) {
+	m.stopped = append(m.stopped, sessionID)
 }
 
 // mockStore implements store.Store with minimal stubs for handler tests.
@@ -43,6 +55,8 @@ type mockStore struct {
 	capUpdates map[string]json.RawMessage
 	workspaces []mockWorkspace
 	routeRules map[string][]store.RouteRule // keyed by workspace ID
+	audits     []store.AuditRecord
+	approvals  []store.ToolApproval // returned as resumable
 }
 
 // mockWorkspace is a lightweight workspace definition for tests.
@@ -120,7 +134,13 @@ func (m *mockStore) ListActiveSessions(_ context.Context) ([]store.Session, erro
 func (m *mockStore) CleanupStaleSessions(_ context.Context, _ time.Time) (int, error) { return 0, nil }
 
 // Stubs — AuditStore.
-func (m *mockStore) InsertAuditRecord(_ context.Context, _ *store.AuditRecord) error { return nil }
+func (m *mockStore) InsertAuditRecord(_ context.Context, r *store.AuditRecord) error {
+	m.audits = append(m.audits, *r)
+	return nil
+}
+func (m *mockStore) InsertAuditRecords(_ context.Context, _ []*store.AuditRecord) error {
+	return nil
+}
 func (m *mockStore) QueryAuditRecords(_ context.Context, _ store.AuditFilter) ([]store.AuditRecord, int, error) {
 	return nil, 0, nil
 }
@@ -130,13 +150,60 @@ func (m *mockStore) GetAuditStats(_ context.Context, _ string, _, _ time.Time) (
 func (m *mockStore) GetDashboardTimeSeries(_ context.Context, _, _ time.Time) ([]store.TimeSeriesPoint, error) {
 	return nil, nil
 }
+func (m *mockStore) QueryUsage(_ context.Context, _ store.UsageFilter) ([]store.UsageRow, error) {
+	return nil, nil
+}
 
 // Stubs — ToolApprovalStore.
 func (m *mockStore) CreateToolApproval(_ context.Context, _ *store.ToolApproval) error   { return nil }
-func (m *mockStore) GetToolApproval(_ context.Context, _ string) (*store.ToolApproval, error) { return nil, nil }
+func (m *mockStore) GetToolApproval(_ context.Context, id string) (*store.ToolApproval, error) {
+	for i := range m.approvals {
+		if m.approvals[i].ID == id {
+			return &m.approvals[i], nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
 func (m *mockStore) ListPendingApprovals(_ context.Context) ([]store.ToolApproval, error) { return nil, nil }
 func (m *mockStore) ResolveToolApproval(_ context.Context, _, _, _, _, _ string) error    { return nil }
-func (m *mockStore) ExpirePendingApprovals(_ context.Context, _ time.Time) (int, error)   { return 0, nil }
+func (m *mockStore) ListResumableApprovals(_ context.Context, _ string) ([]store.ToolApproval, error) {
+	return m.approvals, nil
+}
+func (m *mockStore) MarkToolApprovalExecuted(_ context.Context, _ string) error { return nil }
+func (m *mockStore) QueryToolApprovals(_ context.Context, _ store.ApprovalFilter) ([]store.ToolApproval, int, error) {
+	return nil, 0, nil
+}
+func (m *mockStore) GetApprovalStats(_ context.Context, _ store.ApprovalFilter) (*store.ApprovalStats, error) {
+	return &store.ApprovalStats{}, nil
+}
+func (m *mockStore) LinkApprovalAuditRecord(_ context.Context, _, _ string) error { return nil }
+
+// Stubs — RetentionStore.
+func (m *mockStore) ListExpiredAuditRecords(_ context.Context, _ store.AuditPruneFilter, _ int) ([]store.AuditRecord, error) {
+	return nil, nil
+}
+func (m *mockStore) PruneAuditRecords(_ context.Context, _ []store.AuditRecord, _ store.AuditGapSigner) error {
+	return nil
+}
+func (m *mockStore) PruneSessions(_ context.Context, _ time.Time) (int, error)         { return 0, nil }
+func (m *mockStore) PruneToolApprovals(_ context.Context, _ time.Time) (int, error)    { return 0, nil }
+func (m *mockStore) DatabaseSize(_ context.Context) (int64, error)                     { return 0, nil }
+
+// Stubs — AuditChainStore.
+func (m *mockStore) GetAuditChainHead(_ context.Context) (*store.AuditChainHead, error) {
+	return &store.AuditChainHead{}, nil
+}
+func (m *mockStore) ListAuditChain(_ context.Context, _ int64, _ int) ([]store.AuditRecord, error) {
+	return nil, nil
+}
+func (m *mockStore) ListAuditChainGaps(_ context.Context) ([]store.AuditChainGap, error) { return nil, nil }
+func (m *mockStore) CreateAuditCheckpoint(_ context.Context, _ *store.AuditCheckpoint) error { return nil }
+func (m *mockStore) GetLatestAuditCheckpoint(_ context.Context) (*store.AuditCheckpoint, error) {
+	return nil, store.ErrNotFound
+}
+func (m *mockStore) ListAuditCheckpoints(_ context.Context) ([]store.AuditCheckpoint, error) {
+	return nil, nil
+}
 
 // Stubs — Store top-level.
 func (m *mockStore) Tx(_ context.Context, _ func(store.Store) error) error { return nil }
@@ -216,6 +283,20 @@ func TestExtractNamespacedTools(t *testing.T) {
 		},
 	}
 
+	t.Run("preserves annotations", func(t *testing.T) {
+		readOnly := true
+		got, err := extractNamespacedTools("github", toolsJSON(
+			Tool{Name: "list_issues", Annotations: &ToolAnnotations{ReadOnlyHint: &readOnly}},
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].Annotations == nil ||
+			got[0].Annotations.ReadOnlyHint == nil || !*got[0].Annotations.ReadOnlyHint {
+			t.Fatalf("annotations not preserved: %+v", got)
+		}
+	})
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := extractNamespacedTools(tt.namespace, tt.input)
@@ -491,3 +572,116 @@ func stringContains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestHandleToolsCall_Timeout(t *testing.T) {
+	servers := []store.DownstreamServer{{ID: "srv", ToolNamespace: "ns"}}
+	lister := &mockToolLister{
+		callErr: fmt.Errorf("%w after 1s", downstream.ErrTimeout),
+	}
+	h, ms := newTestHandler(lister, servers)
+	ms.routeRules["ws-global"][0].DownstreamServerID = "srv"
+	h.auditor = audit.NewLogger(ms, ms, nil)
+
+	params, _ := json.Marshal(CallToolRequest{Name: "ns__slow", Arguments: json.RawMessage(`{}`)})
+	_, rpcErr := h.handleToolsCall(context.Background(), params)
+	if rpcErr == nil || rpcErr.Code != CodeTimeout {
+		t.Fatalf("rpc error = %+v, want code %d", rpcErr, CodeTimeout)
+	}
+	if len(ms.audits) != 1 || ms.audits[0].Status != "timeout" || ms.audits[0].ErrorCode != "-32001" {
+		t.Fatalf("audit = %+v", ms.audits)
+	}
+}
+
+func TestHandleToolsCall_ResumedApprovalDispatchesStoredCall(t *testing.T) {
+	servers := []store.DownstreamServer{{ID: "srv", ToolNamespace: "ns"}}
+	lister := &mockToolLister{}
+	h, ms := newTestHandler(lister, servers)
+	ms.routeRules["ws-global"][0].DownstreamServerID = "srv"
+	ms.routeRules["ws-global"][0].RequiresApproval = true
+	h.auditor = audit.NewLogger(ms, ms, nil)
+	h.approvals = approval.NewManager(ms, approval.NewBus())
+	defer h.approvals.Shutdown()
+	h.sessions.session = &store.Session{ID: "new-session", ClientType: "claude-code"}
+
+	// Approved while the requesting client was disconnected.
+	resolved := time.Now()
+	ms.approvals = []store.ToolApproval{{
+		ID:                "appr-1",
+		Status:            "approved",
+		RequestSessionID:  "old-session",
+		RequestClientType: "claude-code",
+		WorkspaceID:       "ws-global",
+		ToolName:          "ns__create_issue",
+		Arguments:         `{"labels":["a","b"],"title":"bug"}`,
+		CallParams:        json.RawMessage(`{"name":"ns__create_issue","arguments":{"title":"bug","labels":["a","b"]}}`),
+		TimeoutSec:        60,
+		ResolvedAt:        &resolved,
+	}}
+
+	// The reconnected client retries the call, encoded differently.
+	params, _ := json.Marshal(CallToolRequest{
+		Name:      "ns__create_issue",
+		Arguments: json.RawMessage(`{"_justification":"triage", "title": "bug", "labels": ["a", "b"]}`),
+	})
+	if _, rpcErr := h.handleToolsCall(context.Background(), params); rpcErr != nil {
+		t.Fatalf("rpc error = %+v", rpcErr)
+	}
+	if string(lister.callArgs) != `{"title":"bug","labels":["a","b"]}` {
+		t.Fatalf("dispatched arguments = %s, want the stored call's", lister.callArgs)
+	}
+	if len(ms.audits) != 1 || ms.audits[0].ApprovalID != "appr-1" {
+		t.Fatalf("audit = %+v", ms.audits)
+	}
+}
+
+func TestApprovedCallRunsForReconnectedSession(t *testing.T) {
+	servers := []store.DownstreamServer{{ID: "srv", ToolNamespace: "ns"}}
+	lister := &mockToolLister{}
+	h, ms := newTestHandler(lister, servers)
+	ms.routeRules["ws-global"][0].DownstreamServerID = "srv"
+	ms.routeRules["ws-global"][0].RequiresApproval = true
+	h.auditor = audit.NewLogger(ms, ms, nil)
+	h.approvals = approval.NewManager(ms, approval.NewBus())
+	defer h.approvals.Shutdown()
+	h.sessions.session = &store.Session{ID: "new-session", ClientType: "claude-code"}
+
+	notices := make(chan map[string]any, 1)
+	h.notify = func(method string, params any) {
+		if method == "notifications/message" {
+			notices <- params.(map[string]any)
+		}
+	}
+
+	// Approved while the client was reconnecting, then nothing retried it.
+	resolved := time.Now()
+	ms.approvals = []store.ToolApproval{{
+		ID:                "appr-1",
+		Status:            "approved",
+		RequestSessionID:  "old-session",
+		RequestClientType: "claude-code",
+		WorkspaceID:       "ws-global",
+		ToolName:          "ns__create_issue",
+		Arguments:         `{"title":"bug"}`,
+		CallParams:        json.RawMessage(`{"name":"ns__create_issue","arguments":{"title":"bug"}}`),
+		TimeoutSec:        60,
+		ResolvedAt:        &resolved,
+	}}
+	h.attachApprovals()
+	defer h.detachApprovals()
+
+	select {
+	case n := <-notices:
+		notice, ok := n["data"].(approvedCallNotice)
+		if !ok || notice.ApprovalID != "appr-1" || notice.Error != nil || n["level"] != "info" {
+			t.Fatalf("notification = %+v", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("approved call not run for the reconnected session")
+	}
+	if string(lister.callArgs) != `{"title":"bug"}` {
+		t.Fatalf("dispatched arguments = %s", lister.callArgs)
+	}
+	if len(ms.audits) != 1 || ms.audits[0].ApprovalID != "appr-1" {
+		t.Fatalf("audit = %+v", ms.audits)
+	}
+}
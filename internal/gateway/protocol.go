@@ -20,6 +20,13 @@ type Response struct {
 	Error   *RPCError       `json:"error,omitempty"`
 }
 
+// Notification is a JSON-RPC 2.0 notification sent to the client.
+type Notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
 // RPCError is a JSON-RPC 2.0 error.
 type RPCError struct {
 	Code    int    `json:"code"`
@@ -71,7 +78,8 @@ type InitializeResult struct {
 
 // ServerCapability declares server capabilities.
 type ServerCapability struct {
-	Tools *ToolCapability `json:"tools,omitempty"`
+	Tools   *ToolCapability `json:"tools,omitempty"`
+	Logging *struct{}       `json:"logging,omitempty"` // notifications/message reporting approved calls
 }
 
 // ToolCapability declares tool-related capabilities.
@@ -87,15 +95,34 @@ type ServerInfo struct {
 
 // Tool represents an MCP tool definition.
 type Tool struct {
-	Name        string          `json:"name"`
-	Description string          `json:"description,omitempty"`
-	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	InputSchema json.RawMessage  `json:"inputSchema,omitempty"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations are the behavioural hints a server attaches to a tool.
+// They are untrusted by default in MCP; the routing engine only acts on them
+// for downstreams whose annotation_trust is "trusted".
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`
 }
 
 // CallToolRequest is the params for tools/call.
 type CallToolRequest struct {
 	Name      string          `json:"name"`
 	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Meta      *RequestMeta    `json:"_meta,omitempty"`
+}
+
+// RequestMeta is the _meta object of a request. Only the fields mcplexer
+// acts on are decoded.
+type RequestMeta struct {
+	Traceparent string `json:"traceparent,omitempty"` // W3C trace context of the caller
 }
 
 // CallToolResult is the result of tools/call.
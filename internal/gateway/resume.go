@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/approval"
+	"github.com/revitteth/mcplexer/internal/downstream"
+	"github.com/revitteth/mcplexer/internal/routing"
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// approvedCallNotice is the data of the notifications/message that reports
+// an approved call run without a waiting tools/call, e.g. one approved while
+// the client was reconnecting.
+type approvedCallNotice struct {
+	ApprovalID string          `json:"approval_id"`
+	Tool       string          `json:"tool"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      *RPCError       `json:"error,omitempty"`
+}
+
+// attachApprovals registers the session with the approval manager so
+// approved calls it may resume run for it. Re-initializing replaces the
+// earlier registration.
+func (h *handler) attachApprovals() {
+	if h.approvals == nil {
+		return
+	}
+	h.detachApprovals()
+	h.detach = h.approvals.Attach(approval.Client{
+		SessionID:   h.sessions.sessionID(),
+		WorkspaceID: h.sessions.workspaceID(),
+		ClientType:  h.sessions.clientType(),
+	}, h.executeApproved)
+}
+
+// detachApprovals undoes attachApprovals.
+func (h *handler) detachApprovals() {
+	if h.detach != nil {
+		h.detach()
+		h.detach = nil
+	}
+}
+
+// executeApproved runs an approved call in the background and reports the
+// outcome to the client.
+func (h *handler) executeApproved(a *store.ToolApproval) {
+	go h.runApproved(a)
+}
+
+// runApproved dispatches the call stored with an approval, as
+// handleToolsCall would once the approval is granted, and sends the result
+// to the client as a notifications/message.
+func (h *handler) runApproved(a *store.ToolApproval) {
+	start := time.Now()
+	ctx := downstream.WithSession(h.connCtx, h.sessions.templateVars())
+
+	// Each approval authorises exactly one execution.
+	if err := h.approvals.ClaimExecution(ctx, a.ID); err != nil {
+		if !errors.Is(err, approval.ErrAlreadyExecuted) {
+			slog.Warn("failed to claim approved call", "id", a.ID, "error", err)
+		}
+		return
+	}
+	slog.Info("running approved call", "id", a.ID, "tool", a.ToolName, "session", h.sessions.sessionID())
+
+	req := CallToolRequest{Name: a.ToolName, Arguments: approvedArguments(a)}
+	var result json.RawMessage
+	var rpcErr *RPCError
+	routeResult, err := h.engine.RouteWithFallback(ctx, routing.RouteContext{
+		ToolName: req.Name,
+	}, h.sessions.clientRoot(), h.sessions.workspaceAncestors())
+	if err != nil {
+		rpcErr = mapRouteError(err)
+		h.recordAudit(ctx, req.Name, req.Arguments, nil, a.ID, nil, rpcErr, start)
+	} else {
+		result, rpcErr = h.callDownstream(ctx, req, routeResult, extractOriginalToolName(req.Name), a.ID, start)
+	}
+
+	level := "info"
+	if rpcErr != nil {
+		level = "error"
+	}
+	h.notify("notifications/message", map[string]any{
+		"level":  level,
+		"logger": "mcplexer.approvals",
+		"data":   approvedCallNotice{ApprovalID: a.ID, Tool: req.Name, Result: result, Error: rpcErr},
+	})
+}
@@ -65,6 +65,15 @@ func (s *Server) RunConn(ctx context.Context, r io.Reader, w io.Writer) error {
 
 func (s *Server) run(ctx context.Context, r io.Reader, w io.Writer) error {
 	defer s.handler.sessions.disconnect(ctx) //nolint:errcheck
+	defer s.handler.detachApprovals()
+
+	s.handler.connCtx = ctx
+	s.handler.notify = func(method string, params any) {
+		n := &Notification{JSONRPC: "2.0", Method: method, Params: params}
+		if err := s.writeMessage(w, n); err != nil {
+			slog.Warn("write notification", "method", method, "error", err)
+		}
+	}
 
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
@@ -149,10 +158,16 @@ func (s *Server) handleNotification(req Request) {
 }
 
 func (s *Server) writeResponse(w io.Writer, resp *Response) error {
+	return s.writeMessage(w, resp)
+}
+
+// writeMessage writes one JSON-RPC message. Notifications may be sent from
+// other goroutines while a request is being handled.
+func (s *Server) writeMessage(w io.Writer, msg any) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	data, err := json.Marshal(resp)
+	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
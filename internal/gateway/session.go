@@ -9,6 +9,8 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/revitteth/mcplexer/internal/downstream"
+	"github.com/revitteth/mcplexer/internal/metrics"
 	"github.com/revitteth/mcplexer/internal/routing"
 	"github.com/revitteth/mcplexer/internal/store"
 )
@@ -33,12 +35,19 @@ type sessionManager struct {
 	store      store.Store
 	transport  TransportMode
 	session    *store.Session
-	clientPath string                     // trusted client CWD
+	clientPath string                      // trusted client CWD
 	wsChain    []routing.WorkspaceAncestor // resolved workspace ancestors, most specific first
+	counted    bool                        // session is included in metrics.ActiveSessions
+	instances  sessionStopper              // stops per-session downstream instances; may be nil
 }
 
-func newSessionManager(s store.Store, t TransportMode) *sessionManager {
-	return &sessionManager{store: s, transport: t}
+// sessionStopper stops the downstream instances owned by a session.
+type sessionStopper interface {
+	StopSession(sessionID string)
+}
+
+func newSessionManager(s store.Store, t TransportMode, instances sessionStopper) *sessionManager {
+	return &sessionManager{store: s, transport: t, instances: instances}
 }
 
 func (sm *sessionManager) create(ctx context.Context, clientInfo ClientInfo, roots []Root) error {
@@ -53,7 +62,22 @@ func (sm *sessionManager) create(ctx context.Context, clientInfo ClientInfo, roo
 		sm.session.WorkspaceID = &sm.wsChain[0].ID
 	}
 
-	return sm.store.CreateSession(ctx, sm.session)
+	if err := sm.store.CreateSession(ctx, sm.session); err != nil {
+		return err
+	}
+	if !sm.counted {
+		sm.counted = true
+		metrics.ActiveSessions.Inc(sm.transportLabel())
+	}
+	return nil
+}
+
+// transportLabel names the transport in metrics.
+func (sm *sessionManager) transportLabel() string {
+	if sm.transport == TransportStdio {
+		return "stdio"
+	}
+	return "socket"
 }
 
 // resolveWorkspaceChain finds all workspaces whose root path is an ancestor
@@ -88,7 +112,7 @@ func (sm *sessionManager) resolveWorkspaceChain(ctx context.Context, roots []Roo
 
 	chain := make([]routing.WorkspaceAncestor, len(ancestors))
 	for i, ws := range ancestors {
-		chain[i] = routing.WorkspaceAncestor{ID: ws.ID, RootPath: ws.RootPath}
+		chain[i] = routing.WorkspaceAncestor{ID: ws.ID, Name: ws.Name, RootPath: ws.RootPath}
 	}
 	return chain
 }
@@ -183,6 +207,13 @@ func (sm *sessionManager) disconnect(ctx context.Context) error {
 	if sm.session == nil {
 		return nil
 	}
+	if sm.counted {
+		sm.counted = false
+		metrics.ActiveSessions.Dec(sm.transportLabel())
+	}
+	if sm.instances != nil {
+		sm.instances.StopSession(sm.session.ID)
+	}
 	return sm.store.DisconnectSession(ctx, sm.session.ID)
 }
 
@@ -221,3 +252,15 @@ func (sm *sessionManager) modelHint() string {
 	}
 	return sm.session.ModelHint
 }
+
+// templateVars returns the values downstream server settings can reference,
+// from the most specific workspace.
+func (sm *sessionManager) templateVars() downstream.SessionVars {
+	v := downstream.SessionVars{ClientRoot: sm.clientPath, SessionID: sm.sessionID()}
+	if len(sm.wsChain) > 0 {
+		v.WorkspaceID = sm.wsChain[0].ID
+		v.WorkspaceRoot = sm.wsChain[0].RootPath
+		v.WorkspaceName = sm.wsChain[0].Name
+	}
+	return v
+}
@@ -1,6 +1,7 @@
 package gateway
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -157,3 +158,46 @@ func TestWorkspaceID_ReturnsFirst(t *testing.T) {
 		t.Errorf("workspaceID() = %q, want %q", got, "ws-specific")
 	}
 }
+
+func TestTemplateVars_UsesMostSpecificWorkspace(t *testing.T) {
+	sm := &sessionManager{
+		clientPath: "/home/user/app/web",
+		wsChain: []routing.WorkspaceAncestor{
+			{ID: "ws-app", Name: "app", RootPath: "/home/user/app"},
+			{ID: "ws-global", Name: "global", RootPath: "/"},
+		},
+	}
+	v := sm.templateVars()
+	if v.WorkspaceRoot != "/home/user/app" || v.WorkspaceName != "app" {
+		t.Errorf("workspace vars = %q, %q", v.WorkspaceRoot, v.WorkspaceName)
+	}
+	if v.ClientRoot != "/home/user/app/web" {
+		t.Errorf("ClientRoot = %q", v.ClientRoot)
+	}
+	if v.SessionID != "" {
+		t.Errorf("SessionID = %q, want empty before initialize", v.SessionID)
+	}
+}
+
+func TestDisconnect_StopsSessionInstances(t *testing.T) {
+	lister := &mockToolLister{}
+	sm := newSessionManager(&mockStore{}, TransportSocket, lister)
+	ctx := context.Background()
+
+	if err := sm.disconnect(ctx); err != nil {
+		t.Fatalf("disconnect before initialize: %v", err)
+	}
+	if len(lister.stopped) != 0 {
+		t.Fatalf("stopped %v before a session existed", lister.stopped)
+	}
+
+	if err := sm.create(ctx, ClientInfo{Name: "test"}, nil); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := sm.disconnect(ctx); err != nil {
+		t.Fatalf("disconnect: %v", err)
+	}
+	if len(lister.stopped) != 1 || lister.stopped[0] != sm.sessionID() {
+		t.Fatalf("stopped = %v, want [%s]", lister.stopped, sm.sessionID())
+	}
+}
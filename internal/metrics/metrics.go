@@ -0,0 +1,150 @@
+// Package metrics keeps in-process counters, gauges and histograms and
+// serves them in the Prometheus text exposition format. Series are updated
+// on the hot path without touching the database; values that live in other
+// components (instance states, sink queues) are read at scrape time through
+// registered collectors.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// family is one metric name with its HELP and TYPE lines.
+type family interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families and scrape-time collectors.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// Default is the registry the package-level metrics are registered in.
+var Default = NewRegistry()
+
+// register adds f, replacing any family of the same name.
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	r.families[f.name()] = f
+	r.mu.Unlock()
+}
+
+// WriteText writes every family in the text exposition format, sorted by
+// name.
+func (r *Registry) WriteText(w *bufio.Writer) {
+	r.mu.Lock()
+	fams := make([]family, 0, len(r.families))
+	for _, f := range r.families {
+		fams = append(fams, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(fams, func(i, j int) bool { return fams[i].name() < fams[j].name() })
+	for _, f := range fams {
+		f.write(w)
+	}
+}
+
+// Handler serves the registry in the text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		r.WriteText(bw)
+		bw.Flush() //nolint:errcheck
+	})
+}
+
+// desc is the shared metadata of a family.
+type desc struct {
+	fqName string
+	help   string
+	kind   string // counter, gauge or histogram
+	labels []string
+}
+
+func (d *desc) name() string { return d.fqName }
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.fqName, escapeHelp(d.help), d.fqName, d.kind)
+}
+
+// writeSample writes one sample line. extra is an additional label pair
+// such as le="0.5", already formatted.
+func (d *desc) writeSample(w *bufio.Writer, suffix string, values []string, extra string, v float64) {
+	w.WriteString(d.fqName)
+	w.WriteString(suffix)
+	if len(values) > 0 || extra != "" {
+		w.WriteByte('{')
+		for i, l := range d.labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, l, escapeLabel(values[i]))
+		}
+		if extra != "" {
+			if len(values) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extra)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+// seriesKey joins label values into a map key.
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func (d *desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", d.fqName, len(d.labels), len(values)))
+	}
+}
+
+// sortedKeys returns the keys of m in order, for stable output.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
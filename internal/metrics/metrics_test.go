@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"bufio"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	calls := r.NewCounterVec("calls_total", "Calls made.", "tool", "status")
+	calls.Inc("b", "ok")
+	calls.Add(2, "a", `say "hi"`+"\n")
+	sessions := r.NewGaugeVec("sessions", "Open sessions.", "transport")
+	sessions.Inc("stdio")
+	sessions.Inc("stdio")
+	sessions.Dec("stdio")
+	lat := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "tool")
+	lat.Observe(0.05, "a")
+	lat.Observe(0.5, "a")
+	lat.Observe(5, "a")
+	r.Collect("instances", "Instances by state.", "gauge", []string{"state"}, func() []Sample {
+		return []Sample{{Labels: []string{"idle"}, Value: 2}, {Labels: []string{"busy"}, Value: 1}}
+	})
+
+	var sb strings.Builder
+	w := bufio.NewWriter(&sb)
+	r.WriteText(w)
+	w.Flush()
+
+	want := `# HELP calls_total Calls made.
+# TYPE calls_total counter
+calls_total{tool="a",status="say \"hi\"\n"} 2
+calls_total{tool="b",status="ok"} 1
+# HELP instances Instances by state.
+# TYPE instances gauge
+instances{state="busy"} 1
+instances{state="idle"} 2
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{tool="a",le="0.1"} 1
+latency_seconds_bucket{tool="a",le="1"} 2
+latency_seconds_bucket{tool="a",le="+Inf"} 3
+latency_seconds_sum{tool="a"} 5.55
+latency_seconds_count{tool="a"} 3
+# HELP sessions Open sessions.
+# TYPE sessions gauge
+sessions{transport="stdio"} 1
+`
+	if sb.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", sb.String(), want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("up_total", "Up.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "\nup_total 1\n") {
+		t.Fatalf("body = %q", rec.Body.String())
+	}
+}
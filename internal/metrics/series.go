@@ -0,0 +1,32 @@
+package metrics
+
+// Metrics updated in-process by the gateway and its components.
+var (
+	ToolCalls = Default.NewCounterVec("mcplexer_tool_calls_total",
+		"Tool calls handled by the gateway.",
+		"tool", "server", "workspace", "status")
+	ToolCallDuration = Default.NewHistogramVec("mcplexer_tool_call_duration_seconds",
+		"Tool call latency, including any approval wait.",
+		DurationBuckets, "tool", "server", "workspace", "status")
+	ApprovalWait = Default.NewHistogramVec("mcplexer_approval_wait_seconds",
+		"Time from an approval request to its decision or timeout.",
+		[]float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}, "status")
+	DownstreamRestarts = Default.NewCounterVec("mcplexer_downstream_restarts_total",
+		"Downstream instances started again after stopping (crash or idle timeout).",
+		"server")
+	DownstreamHealthChecks = Default.NewCounterVec("mcplexer_downstream_health_checks_total",
+		"Downstream health probes by server and result (ok, failed).",
+		"server", "result")
+	OAuthRefreshFailures = Default.NewCounterVec("mcplexer_oauth_refresh_failures_total",
+		"Failed OAuth token refreshes.",
+		"auth_scope")
+	ActiveSessions = Default.NewGaugeVec("mcplexer_active_sessions",
+		"Connected MCP client sessions.",
+		"transport")
+	TraceSpans = Default.NewCounterVec("mcplexer_trace_spans_total",
+		"Spans handed to the trace exporter, by outcome (exported, failed, dropped).",
+		"result")
+	StreamEventsDropped = Default.NewCounterVec("mcplexer_stream_events_dropped_total",
+		"Events an SSE subscriber missed because they left the replay buffer.",
+		"stream")
+)
@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"bufio"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CounterVec is a set of monotonically increasing counters partitioned by
+// label values.
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	v      float64
+}
+
+// NewCounterVec creates a CounterVec and registers it in r.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		desc:   desc{fqName: name, help: help, kind: "counter", labels: labels},
+		series: make(map[string]*counterSeries),
+	}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the counter for values.
+func (c *CounterVec) Inc(values ...string) { c.Add(1, values...) }
+
+// Add adds v, which must not be negative, to the counter for values.
+func (c *CounterVec) Add(v float64, values ...string) {
+	c.checkLabels(values)
+	key := seriesKey(values)
+	c.mu.Lock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	s.v += v
+	c.mu.Unlock()
+}
+
+// Value returns the counter for values.
+func (c *CounterVec) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[seriesKey(values)]; ok {
+		return s.v
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range sortedKeys(c.series) {
+		s := c.series[k]
+		c.writeSample(w, "", s.values, "", s.v)
+	}
+}
+
+// GaugeVec is a set of gauges partitioned by label values.
+type GaugeVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+// NewGaugeVec creates a GaugeVec and registers it in r.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{
+		desc:   desc{fqName: name, help: help, kind: "gauge", labels: labels},
+		series: make(map[string]*counterSeries),
+	}
+	r.register(g)
+	return g
+}
+
+// Add adds v, which may be negative, to the gauge for values.
+func (g *GaugeVec) Add(v float64, values ...string) {
+	g.checkLabels(values)
+	key := seriesKey(values)
+	g.mu.Lock()
+	s, ok := g.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), values...)}
+		g.series[key] = s
+	}
+	s.v += v
+	g.mu.Unlock()
+}
+
+// Inc adds one to the gauge for values.
+func (g *GaugeVec) Inc(values ...string) { g.Add(1, values...) }
+
+// Dec subtracts one from the gauge for values.
+func (g *GaugeVec) Dec(values ...string) { g.Add(-1, values...) }
+
+// Value returns the gauge for values.
+func (g *GaugeVec) Value(values ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s, ok := g.series[seriesKey(values)]; ok {
+		return s.v
+	}
+	return 0
+}
+
+func (g *GaugeVec) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, k := range sortedKeys(g.series) {
+		s := g.series[k]
+		g.writeSample(w, "", s.values, "", s.v)
+	}
+}
+
+// HistogramVec counts observations into cumulative buckets, partitioned by
+// label values.
+type HistogramVec struct {
+	desc
+	buckets []float64 // upper bounds, ascending, without +Inf
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // per bucket, not cumulative; last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// DurationBuckets suit latencies from a few milliseconds to a few minutes,
+// in seconds.
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// NewHistogramVec creates a HistogramVec with the given ascending bucket
+// upper bounds and registers it in r.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{fqName: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records v for values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.checkLabels(values)
+	i := 0
+	for i < len(h.buckets) && v > h.buckets[i] {
+		i++
+	}
+	key := seriesKey(values)
+	h.mu.Lock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			values: append([]string(nil), values...),
+			counts: make([]uint64, len(h.buckets)+1),
+		}
+		h.series[key] = s
+	}
+	s.counts[i]++
+	s.sum += v
+	s.count++
+	h.mu.Unlock()
+}
+
+// ObserveDuration records d in seconds for values.
+func (h *HistogramVec) ObserveDuration(d time.Duration, values ...string) {
+	h.Observe(d.Seconds(), values...)
+}
+
+// Count returns the number of observations for values.
+func (h *HistogramVec) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[seriesKey(values)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, k := range sortedKeys(h.series) {
+		s := h.series[k]
+		var cum uint64
+		for i, ub := range h.buckets {
+			cum += s.counts[i]
+			h.writeSample(w, "_bucket", s.values, `le="`+formatFloat(ub)+`"`, float64(cum))
+		}
+		h.writeSample(w, "_bucket", s.values, `le="+Inf"`, float64(s.count))
+		h.writeSample(w, "_sum", s.values, "", s.sum)
+		h.writeSample(w, "_count", s.values, "", float64(s.count))
+	}
+}
+
+// Sample is one value reported by a collector.
+type Sample struct {
+	Labels []string // values for the collector's label names
+	Value  float64
+}
+
+// collector reports gauge or counter samples read at scrape time.
+type collector struct {
+	desc
+	fn func() []Sample
+}
+
+// Collect registers fn to be called on every scrape, reporting samples of a
+// gauge ("gauge") or counter ("counter") kept elsewhere. Registering the
+// same name again replaces the collector.
+func (r *Registry) Collect(name, help, kind string, labels []string, fn func() []Sample) {
+	r.register(&collector{
+		desc: desc{fqName: name, help: help, kind: kind, labels: labels},
+		fn:   fn,
+	})
+}
+
+func (c *collector) write(w *bufio.Writer) {
+	samples := c.fn()
+	sortSamples(samples)
+	c.writeHeader(w)
+	for _, s := range samples {
+		c.checkLabels(s.Labels)
+		c.writeSample(w, "", s.Labels, "", s.Value)
+	}
+}
+
+func sortSamples(samples []Sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return seriesKey(samples[i].Labels) < seriesKey(samples[j].Labels)
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/revitteth/mcplexer/internal/metrics"
 	"github.com/revitteth/mcplexer/internal/store"
 )
 
@@ -41,9 +42,20 @@ func (fm *FlowManager) exchangeCode(
 	return fm.postToken(ctx, p.TokenURL, form)
 }
 
-// RefreshToken refreshes an expired access token using the stored refresh token.
+// RefreshToken refreshes an expired access token using the stored refresh
+// token. Failures are counted in metrics.OAuthRefreshFailures.
 func (fm *FlowManager) RefreshToken(
 	ctx context.Context, authScopeID string,
+) (*store.OAuthTokenData, error) {
+	td, err := fm.refreshToken(ctx, authScopeID)
+	if err != nil {
+		metrics.OAuthRefreshFailures.Inc(authScopeID)
+	}
+	return td, err
+}
+
+func (fm *FlowManager) refreshToken(
+	ctx context.Context, authScopeID string,
 ) (*store.OAuthTokenData, error) {
 	scope, err := fm.store.GetAuthScope(ctx, authScopeID)
 	if err != nil {
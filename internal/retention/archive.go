@@ -0,0 +1,73 @@
+package retention
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// archive writes pruned audit records as gzip-compressed JSONL, one file per
+// compaction pass. The file is created on the first write.
+type archive struct {
+	path string
+	f    *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+func newArchive(dir string, now time.Time) *archive {
+	name := fmt.Sprintf("audit-%s.jsonl.gz", now.UTC().Format("20060102T150405Z"))
+	return &archive{path: filepath.Join(dir, name)}
+}
+
+// Path returns the archive file path, or "" if nothing was written.
+func (a *archive) Path() string {
+	if a.enc == nil {
+		return ""
+	}
+	return a.path
+}
+
+// Write appends records and flushes them to disk, so the records are durable
+// before the caller deletes them.
+func (a *archive) Write(recs []store.AuditRecord) error {
+	if a.enc == nil {
+		if err := os.MkdirAll(filepath.Dir(a.path), 0o700); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		a.f = f
+		a.gz = gzip.NewWriter(f)
+		a.enc = json.NewEncoder(a.gz)
+	}
+	for i := range recs {
+		if err := a.enc.Encode(&recs[i]); err != nil {
+			return err
+		}
+	}
+	if err := a.gz.Flush(); err != nil {
+		return err
+	}
+	return a.f.Sync()
+}
+
+// Close finishes the gzip stream and closes the file. Safe to call twice.
+func (a *archive) Close() error {
+	if a.f == nil {
+		return nil
+	}
+	err := a.gz.Close()
+	if cerr := a.f.Close(); err == nil {
+		err = cerr
+	}
+	a.f = nil
+	return err
+}
@@ -0,0 +1,210 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// batchSize is the number of audit records archived and pruned per step.
+const batchSize = 1000
+
+// defaultInterval applies when Policy.Interval is not positive.
+const defaultInterval = time.Hour
+
+// Policy holds the global retention limits. Zero values disable a limit.
+// Workspaces can override the audit limits via AuditRetentionDays and
+// AuditMaxRows.
+type Policy struct {
+	AuditMaxAge    time.Duration
+	AuditMaxRows   int
+	SessionMaxAge  time.Duration // disconnected sessions only
+	ApprovalMaxAge time.Duration // resolved approvals only
+	ArchiveDir     string        // if set, pruned audit records are archived here
+	Interval       time.Duration
+}
+
+// Result summarizes a compaction pass.
+type Result struct {
+	AuditPruned     int
+	SessionsPruned  int
+	ApprovalsPruned int
+	ArchivePath     string // empty when nothing was archived
+}
+
+// Compactor periodically prunes audit records, sessions and tool approvals
+// that fall outside the retention policy. Pruned audit records are folded
+// into rollups by the store, so aggregate stats keep covering them.
+type Compactor struct {
+	workspaces store.WorkspaceStore
+	store      store.RetentionStore
+	policy     Policy
+	signGap    store.AuditGapSigner
+	now        func() time.Time
+}
+
+// Option configures a Compactor.
+type Option func(*Compactor)
+
+// WithGapSigner signs the audit chain gaps left by pruned records, so
+// verification can tell them from deleted records.
+func WithGapSigner(sign store.AuditGapSigner) Option {
+	return func(c *Compactor) { c.signGap = sign }
+}
+
+// NewCompactor creates a Compactor for the given policy.
+func NewCompactor(ws store.WorkspaceStore, rs store.RetentionStore, p Policy, opts ...Option) *Compactor {
+	c := &Compactor{workspaces: ws, store: rs, policy: p, now: time.Now}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run compacts immediately and then on every interval until ctx is done.
+func (c *Compactor) Run(ctx context.Context) {
+	interval := c.policy.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		res, err := c.Compact(ctx)
+		if err != nil {
+			slog.Error("retention compaction failed", "err", err)
+		} else if res.AuditPruned+res.SessionsPruned+res.ApprovalsPruned > 0 {
+			slog.Info("retention compaction",
+				"audit_records", res.AuditPruned,
+				"sessions", res.SessionsPruned,
+				"approvals", res.ApprovalsPruned,
+				"archive", res.ArchivePath)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Compact runs one retention pass. Workspace overrides are applied first;
+// the global audit limits then cover the remaining workspaces.
+func (c *Compactor) Compact(ctx context.Context) (*Result, error) {
+	now := c.now().UTC()
+	res := &Result{}
+
+	var arch *archive
+	if c.policy.ArchiveDir != "" {
+		arch = newArchive(c.policy.ArchiveDir, now)
+		defer arch.Close() //nolint:errcheck
+	}
+
+	filters, err := c.auditFilters(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range filters {
+		n, err := c.pruneAudit(ctx, f, arch)
+		res.AuditPruned += n
+		if err != nil {
+			return res, err
+		}
+	}
+	if arch != nil {
+		if err := arch.Close(); err != nil {
+			return res, fmt.Errorf("close audit archive: %w", err)
+		}
+		res.ArchivePath = arch.Path()
+	}
+
+	if c.policy.SessionMaxAge > 0 {
+		n, err := c.store.PruneSessions(ctx, now.Add(-c.policy.SessionMaxAge))
+		if err != nil {
+			return res, fmt.Errorf("prune sessions: %w", err)
+		}
+		res.SessionsPruned = n
+	}
+	if c.policy.ApprovalMaxAge > 0 {
+		n, err := c.store.PruneToolApprovals(ctx, now.Add(-c.policy.ApprovalMaxAge))
+		if err != nil {
+			return res, fmt.Errorf("prune tool approvals: %w", err)
+		}
+		res.ApprovalsPruned = n
+	}
+	return res, nil
+}
+
+// auditFilters builds the prune filters for workspace overrides and the
+// global policy.
+func (c *Compactor) auditFilters(ctx context.Context, now time.Time) ([]store.AuditPruneFilter, error) {
+	workspaces, err := c.workspaces.ListWorkspaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list workspaces: %w", err)
+	}
+
+	var filters []store.AuditPruneFilter
+	var ageOverrides, rowOverrides []string
+	for _, ws := range workspaces {
+		id := ws.ID
+		if ws.AuditRetentionDays > 0 {
+			before := now.AddDate(0, 0, -ws.AuditRetentionDays)
+			filters = append(filters, store.AuditPruneFilter{WorkspaceID: &id, Before: &before})
+			ageOverrides = append(ageOverrides, id)
+		}
+		if ws.AuditMaxRows > 0 {
+			filters = append(filters, store.AuditPruneFilter{WorkspaceID: &id, KeepNewest: ws.AuditMaxRows})
+			rowOverrides = append(rowOverrides, id)
+		}
+	}
+
+	if c.policy.AuditMaxAge > 0 {
+		before := now.Add(-c.policy.AuditMaxAge)
+		filters = append(filters, store.AuditPruneFilter{
+			ExcludeWorkspaceIDs: ageOverrides,
+			Before:              &before,
+		})
+	}
+	if c.policy.AuditMaxRows > 0 {
+		filters = append(filters, store.AuditPruneFilter{
+			ExcludeWorkspaceIDs: rowOverrides,
+			KeepNewest:          c.policy.AuditMaxRows,
+		})
+	}
+	return filters, nil
+}
+
+// pruneAudit archives and prunes the records matching f in batches, oldest
+// first. Each batch is archived before it is deleted.
+func (c *Compactor) pruneAudit(ctx context.Context, f store.AuditPruneFilter, arch *archive) (int, error) {
+	var total int
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		recs, err := c.store.ListExpiredAuditRecords(ctx, f, batchSize)
+		if err != nil {
+			return total, fmt.Errorf("list expired audit records: %w", err)
+		}
+		if len(recs) == 0 {
+			return total, nil
+		}
+		if arch != nil {
+			if err := arch.Write(recs); err != nil {
+				return total, fmt.Errorf("archive audit records: %w", err)
+			}
+		}
+		if err := c.store.PruneAuditRecords(ctx, recs, c.signGap); err != nil {
+			return total, fmt.Errorf("prune audit records: %w", err)
+		}
+		total += len(recs)
+		if len(recs) < batchSize {
+			return total, nil
+		}
+	}
+}
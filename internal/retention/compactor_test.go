@@ -0,0 +1,148 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/audit"
+	"github.com/revitteth/mcplexer/internal/store"
+	"github.com/revitteth/mcplexer/internal/store/sqlite"
+)
+
+func newTestDB(t *testing.T) *sqlite.DB {
+	t.Helper()
+	db, err := sqlite.New(context.Background(), t.TempDir()+"/test.db")
+	if err != nil {
+		t.Fatalf("new test db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCompact(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	short := &store.Workspace{Name: "short", DefaultPolicy: "allow", AuditRetentionDays: 1}
+	capped := &store.Workspace{Name: "capped", DefaultPolicy: "allow", AuditMaxRows: 1}
+	for _, ws := range []*store.Workspace{short, capped} {
+		if err := db.CreateWorkspace(ctx, ws); err != nil {
+			t.Fatalf("create workspace: %v", err)
+		}
+	}
+
+	// Ages in days per workspace; "" is covered only by the global policy.
+	seed := map[string][]int{
+		short.ID:  {0, 2, 5},
+		capped.ID: {0, 1, 2},
+		"":        {0, 5, 20},
+	}
+	for ws, ages := range seed {
+		for _, age := range ages {
+			rec := &store.AuditRecord{
+				Timestamp:   now.AddDate(0, 0, -age),
+				WorkspaceID: ws,
+				ToolName:    "test__tool",
+				Status:      "success",
+			}
+			if err := db.InsertAuditRecord(ctx, rec); err != nil {
+				t.Fatalf("insert: %v", err)
+			}
+		}
+	}
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	dir := t.TempDir()
+	c := NewCompactor(db, db, Policy{AuditMaxAge: 10 * 24 * time.Hour, ArchiveDir: dir},
+		WithGapSigner(audit.GapSigner(key)))
+	c.now = func() time.Time { return now }
+
+	res, err := c.Compact(ctx)
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	// short: 2 older than a day; capped: 2 beyond the newest; global: 1 past 10 days.
+	if res.AuditPruned != 5 {
+		t.Fatalf("pruned = %d, want 5", res.AuditPruned)
+	}
+
+	for ws, want := range map[string]int{short.ID: 1, capped.ID: 1, "": 2} {
+		id := ws
+		_, total, err := db.QueryAuditRecords(ctx, store.AuditFilter{WorkspaceID: &id})
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		if total != want {
+			t.Errorf("workspace %q has %d records, want %d", ws, total, want)
+		}
+	}
+
+	if res.ArchivePath == "" {
+		t.Fatal("expected an archive file")
+	}
+	archived := readArchive(t, res.ArchivePath)
+	if len(archived) != 5 {
+		t.Fatalf("archived %d records, want 5", len(archived))
+	}
+
+	// The pruned ranges are signed, so the chain still verifies.
+	rep, err := audit.VerifyChain(ctx, db, pub)
+	if err != nil || !rep.OK || rep.Pruned != 5 {
+		t.Fatalf("verify = %+v, %v", rep, err)
+	}
+
+	// A second pass has nothing left to do and writes no archive.
+	res, err = c.Compact(ctx)
+	if err != nil {
+		t.Fatalf("second compact: %v", err)
+	}
+	if res.AuditPruned != 0 || res.ArchivePath != "" {
+		t.Fatalf("second pass = %+v, want no-op", res)
+	}
+
+	// Stats still cover the pruned records through rollups.
+	stats, err := db.GetAuditStats(ctx, "", now.AddDate(0, 0, -30), now)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.TotalRequests != 9 {
+		t.Fatalf("total requests = %d, want 9", stats.TotalRequests)
+	}
+}
+
+func readArchive(t *testing.T, path string) []store.AuditRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	var out []store.AuditRecord
+	sc := bufio.NewScanner(gz)
+	for sc.Scan() {
+		var rec store.AuditRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("decode archived record: %v", err)
+		}
+		out = append(out, rec)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	return out
+}
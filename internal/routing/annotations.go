@@ -0,0 +1,106 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// toolHints holds the annotation hints relevant to approval policy.
+type toolHints struct {
+	ReadOnlyHint    *bool `json:"readOnlyHint"`
+	DestructiveHint *bool `json:"destructiveHint"`
+}
+
+// readOnly reports whether the tool declares it does not modify its
+// environment. Absent hints default to false, per the MCP spec.
+func (h toolHints) readOnly() bool {
+	return h.ReadOnlyHint != nil && *h.ReadOnlyHint
+}
+
+// destructive reports whether the tool may perform destructive updates.
+// Per the MCP spec this defaults to true unless the tool is read-only.
+func (h toolHints) destructive() bool {
+	if h.readOnly() {
+		return false
+	}
+	return h.DestructiveHint == nil || *h.DestructiveHint
+}
+
+// mergeAnnotationPolicy overlays the fields set in override onto base.
+func mergeAnnotationPolicy(base, override store.AnnotationPolicy) store.AnnotationPolicy {
+	if override.RequireApprovalDestructive != nil {
+		base.RequireApprovalDestructive = override.RequireApprovalDestructive
+	}
+	if override.AutoAllowReadOnly != nil {
+		base.AutoAllowReadOnly = override.AutoAllowReadOnly
+	}
+	return base
+}
+
+// applyAnnotationPolicy adjusts result.RequiresApproval using the workspace
+// and rule annotation policies and the tool's annotations. Annotations come
+// from the downstream's cached tools/list result; tools of untrusted
+// downstreams, and tools not found in the cache, are treated as having no
+// annotations (not read-only, possibly destructive).
+func (e *Engine) applyAnnotationPolicy(
+	ctx context.Context, workspaceID string, rule *parsedRule, result *RouteResult,
+) {
+	var policy store.AnnotationPolicy
+	if workspaceID != "" {
+		if ws, err := e.store.GetWorkspace(ctx, workspaceID); err == nil && ws != nil {
+			policy = ws.AnnotationPolicy
+		}
+	}
+	policy = mergeAnnotationPolicy(policy, rule.AnnotationPolicy)
+
+	requireDestructive := policy.RequireApprovalDestructive != nil && *policy.RequireApprovalDestructive
+	allowReadOnly := policy.AutoAllowReadOnly != nil && *policy.AutoAllowReadOnly
+	if !requireDestructive && !allowReadOnly {
+		return
+	}
+
+	hints := e.lookupToolHints(ctx, result.DownstreamServerID, result.OriginalToolName)
+	if allowReadOnly && hints.readOnly() {
+		result.RequiresApproval = false
+	}
+	if requireDestructive && hints.destructive() {
+		result.RequiresApproval = true
+	}
+}
+
+// lookupToolHints finds the annotations for a namespaced tool in the
+// downstream's capabilities cache. Returns zero hints when the downstream is
+// untrusted or the tool is unknown.
+func (e *Engine) lookupToolHints(ctx context.Context, serverID, toolName string) toolHints {
+	if serverID == "" {
+		return toolHints{}
+	}
+	srv, err := e.store.GetDownstreamServer(ctx, serverID)
+	if err != nil || srv == nil || srv.AnnotationTrust == "untrusted" {
+		return toolHints{}
+	}
+
+	name := toolName
+	if srv.ToolNamespace != "" {
+		name = strings.TrimPrefix(toolName, srv.ToolNamespace+"__")
+	}
+
+	var cache struct {
+		Tools []struct {
+			Name        string    `json:"name"`
+			Annotations toolHints `json:"annotations"`
+		} `json:"tools"`
+	}
+	if len(srv.CapabilitiesCache) == 0 || json.Unmarshal(srv.CapabilitiesCache, &cache) != nil {
+		return toolHints{}
+	}
+	for _, t := range cache.Tools {
+		if t.Name == name {
+			return t.Annotations
+		}
+	}
+	return toolHints{}
+}
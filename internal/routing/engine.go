@@ -12,6 +12,7 @@ import (
 // computation during routing.
 type WorkspaceAncestor struct {
 	ID       string
+	Name     string
 	RootPath string
 }
 
@@ -30,6 +31,7 @@ type RouteResult struct {
 	OriginalToolName   string
 	RequiresApproval   bool
 	ApprovalTimeout    int
+	LogLevel           string // audit capture depth from the matched rule
 }
 
 var (
@@ -75,7 +77,17 @@ func (e *Engine) Route(ctx context.Context, rc RouteContext) (*RouteResult, erro
 	e.resolveNamespaces(ctx, parsed)
 	sortRules(parsed)
 
-	return matchRoute(parsed, rc)
+	result, err := matchRoute(parsed, rc)
+	if err != nil {
+		return nil, err
+	}
+	for i := range parsed {
+		if parsed[i].ID == result.MatchedRuleID {
+			e.applyAnnotationPolicy(ctx, rc.WorkspaceID, &parsed[i], result)
+			break
+		}
+	}
+	return result, nil
 }
 
 // resolveNamespaces looks up the tool_namespace for each rule's downstream
@@ -189,6 +201,7 @@ func matchRoute(rules []parsedRule, rc RouteContext) (*RouteResult, error) {
 			OriginalToolName:   rc.ToolName,
 			RequiresApproval:   r.RequiresApproval,
 			ApprovalTimeout:    r.ApprovalTimeout,
+			LogLevel:           r.LogLevel,
 		}, nil
 	}
 
@@ -11,10 +11,12 @@ import (
 )
 
 // mockRouteStore implements store.Store for routing engine tests.
-// Only ListRouteRules and GetDownstreamServer are meaningful; all other methods are stubs.
+// Only ListRouteRules, GetDownstreamServer and GetWorkspace are meaningful;
+// all other methods are stubs.
 type mockRouteStore struct {
 	rules       map[string][]store.RouteRule
 	downstreams map[string]*store.DownstreamServer
+	workspaces  map[string]*store.Workspace
 }
 
 func (m *mockRouteStore) ListRouteRules(_ context.Context, wsID string) ([]store.RouteRule, error) {
@@ -33,7 +35,12 @@ func (m *mockRouteStore) GetRouteRule(context.Context, string) (*store.RouteRule
 func (m *mockRouteStore) UpdateRouteRule(context.Context, *store.RouteRule) error        { return nil }
 func (m *mockRouteStore) DeleteRouteRule(context.Context, string) error                  { return nil }
 func (m *mockRouteStore) CreateWorkspace(context.Context, *store.Workspace) error        { return nil }
-func (m *mockRouteStore) GetWorkspace(context.Context, string) (*store.Workspace, error) { return nil, nil }
+func (m *mockRouteStore) GetWorkspace(_ context.Context, id string) (*store.Workspace, error) {
+	if ws, ok := m.workspaces[id]; ok {
+		return ws, nil
+	}
+	return nil, store.ErrNotFound
+}
 func (m *mockRouteStore) GetWorkspaceByName(context.Context, string) (*store.Workspace, error) { return nil, nil }
 func (m *mockRouteStore) ListWorkspaces(context.Context) ([]store.Workspace, error)            { return nil, nil }
 func (m *mockRouteStore) UpdateWorkspace(context.Context, *store.Workspace) error              { return nil }
@@ -63,16 +70,57 @@ func (m *mockRouteStore) DisconnectSession(context.Context, string) error
 func (m *mockRouteStore) ListActiveSessions(context.Context) ([]store.Session, error)                        { return nil, nil }
 func (m *mockRouteStore) CleanupStaleSessions(context.Context, time.Time) (int, error)                      { return 0, nil }
 func (m *mockRouteStore) InsertAuditRecord(context.Context, *store.AuditRecord) error                       { return nil }
+func (m *mockRouteStore) InsertAuditRecords(context.Context, []*store.AuditRecord) error { return nil }
 func (m *mockRouteStore) QueryAuditRecords(context.Context, store.AuditFilter) ([]store.AuditRecord, int, error) { return nil, 0, nil }
 func (m *mockRouteStore) GetAuditStats(context.Context, string, time.Time, time.Time) (*store.AuditStats, error) { return nil, nil }
 func (m *mockRouteStore) GetDashboardTimeSeries(context.Context, time.Time, time.Time) ([]store.TimeSeriesPoint, error) {
 	return nil, nil
 }
+func (m *mockRouteStore) QueryUsage(context.Context, store.UsageFilter) ([]store.UsageRow, error) {
+	return nil, nil
+}
 func (m *mockRouteStore) CreateToolApproval(context.Context, *store.ToolApproval) error { return nil }
 func (m *mockRouteStore) GetToolApproval(context.Context, string) (*store.ToolApproval, error) { return nil, nil }
 func (m *mockRouteStore) ListPendingApprovals(context.Context) ([]store.ToolApproval, error)   { return nil, nil }
 func (m *mockRouteStore) ResolveToolApproval(context.Context, string, string, string, string, string) error { return nil }
-func (m *mockRouteStore) ExpirePendingApprovals(context.Context, time.Time) (int, error) { return 0, nil }
+func (m *mockRouteStore) ListResumableApprovals(context.Context, string) ([]store.ToolApproval, error) {
+	return nil, nil
+}
+func (m *mockRouteStore) MarkToolApprovalExecuted(context.Context, string) error { return nil }
+func (m *mockRouteStore) QueryToolApprovals(context.Context, store.ApprovalFilter) ([]store.ToolApproval, int, error) {
+	return nil, 0, nil
+}
+func (m *mockRouteStore) GetApprovalStats(context.Context, store.ApprovalFilter) (*store.ApprovalStats, error) {
+	return nil, nil
+}
+func (m *mockRouteStore) LinkApprovalAuditRecord(context.Context, string, string) error { return nil }
+func (m *mockRouteStore) ListExpiredAuditRecords(context.Context, store.AuditPruneFilter, int) ([]store.AuditRecord, error) {
+	return nil, nil
+}
+func (m *mockRouteStore) PruneAuditRecords(context.Context, []store.AuditRecord, store.AuditGapSigner) error {
+	return nil
+}
+func (m *mockRouteStore) PruneSessions(context.Context, time.Time) (int, error)      { return 0, nil }
+func (m *mockRouteStore) PruneToolApprovals(context.Context, time.Time) (int, error) { return 0, nil }
+func (m *mockRouteStore) DatabaseSize(context.Context) (int64, error)                { return 0, nil }
+func (m *mockRouteStore) GetAuditChainHead(context.Context) (*store.AuditChainHead, error) {
+	return &store.AuditChainHead{}, nil
+}
+func (m *mockRouteStore) ListAuditChain(context.Context, int64, int) ([]store.AuditRecord, error) {
+	return nil, nil
+}
+func (m *mockRouteStore) ListAuditChainGaps(context.Context) ([]store.AuditChainGap, error) {
+	return nil, nil
+}
+func (m *mockRouteStore) CreateAuditCheckpoint(context.Context, *store.AuditCheckpoint) error {
+	return nil
+}
+func (m *mockRouteStore) GetLatestAuditCheckpoint(context.Context) (*store.AuditCheckpoint, error) {
+	return nil, store.ErrNotFound
+}
+func (m *mockRouteStore) ListAuditCheckpoints(context.Context) ([]store.AuditCheckpoint, error) {
+	return nil, nil
+}
 func (m *mockRouteStore) Tx(context.Context, func(store.Store) error) error { return nil }
 func (m *mockRouteStore) Ping(context.Context) error                        { return nil }
 func (m *mockRouteStore) Close() error                                      { return nil }
@@ -671,3 +719,73 @@ func TestRoute_NamespaceAware(t *testing.T) {
 		})
 	}
 }
+
+func TestRouteAnnotationPolicy(t *testing.T) {
+	yes, no := true, false
+	caps := json.RawMessage(`{"tools":[
+		{"name":"list_issues","annotations":{"readOnlyHint":true}},
+		{"name":"create_issue","annotations":{"destructiveHint":false}},
+		{"name":"delete_repo","annotations":{"destructiveHint":true}},
+		{"name":"plain"}
+	]}`)
+
+	newStore := func(trust string, wsPolicy, rulePolicy store.AnnotationPolicy, requiresApproval bool) *mockRouteStore {
+		return &mockRouteStore{
+			rules: map[string][]store.RouteRule{
+				"ws1": {{
+					ID: "r1", WorkspaceID: "ws1", PathGlob: "**",
+					DownstreamServerID: "gh", Policy: "allow",
+					ToolMatch:        json.RawMessage(`["*"]`),
+					RequiresApproval: requiresApproval,
+					AnnotationPolicy: rulePolicy,
+				}},
+			},
+			downstreams: map[string]*store.DownstreamServer{
+				"gh": {ID: "gh", ToolNamespace: "github", CapabilitiesCache: caps, AnnotationTrust: trust},
+			},
+			workspaces: map[string]*store.Workspace{
+				"ws1": {ID: "ws1", AnnotationPolicy: wsPolicy},
+			},
+		}
+	}
+
+	requireDestructive := store.AnnotationPolicy{RequireApprovalDestructive: &yes}
+	allowReadOnly := store.AnnotationPolicy{AutoAllowReadOnly: &yes}
+
+	tests := []struct {
+		name             string
+		trust            string
+		wsPolicy         store.AnnotationPolicy
+		rulePolicy       store.AnnotationPolicy
+		requiresApproval bool
+		tool             string
+		want             bool
+	}{
+		{"no policy keeps rule setting", "trusted", store.AnnotationPolicy{}, store.AnnotationPolicy{}, false, "github__delete_repo", false},
+		{"workspace requires destructive", "trusted", requireDestructive, store.AnnotationPolicy{}, false, "github__delete_repo", true},
+		{"non-destructive passes", "trusted", requireDestructive, store.AnnotationPolicy{}, false, "github__create_issue", false},
+		{"read-only passes", "trusted", requireDestructive, store.AnnotationPolicy{}, false, "github__list_issues", false},
+		{"missing hints default destructive", "trusted", requireDestructive, store.AnnotationPolicy{}, false, "github__plain", true},
+		{"unknown tool treated destructive", "trusted", requireDestructive, store.AnnotationPolicy{}, false, "github__nope", true},
+		{"route overrides workspace", "trusted", requireDestructive, store.AnnotationPolicy{RequireApprovalDestructive: &no}, false, "github__delete_repo", false},
+		{"auto-allow read-only", "trusted", store.AnnotationPolicy{}, allowReadOnly, true, "github__list_issues", false},
+		{"auto-allow leaves others", "trusted", store.AnnotationPolicy{}, allowReadOnly, true, "github__create_issue", true},
+		{"untrusted ignores read-only", "untrusted", store.AnnotationPolicy{}, allowReadOnly, true, "github__list_issues", true},
+		{"untrusted treats all as destructive", "untrusted", requireDestructive, store.AnnotationPolicy{}, false, "github__create_issue", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine(newStore(tt.trust, tt.wsPolicy, tt.rulePolicy, tt.requiresApproval))
+			result, err := engine.Route(context.Background(), RouteContext{
+				WorkspaceID: "ws1", ToolName: tt.tool,
+			})
+			if err != nil {
+				t.Fatalf("route: %v", err)
+			}
+			if result.RequiresApproval != tt.want {
+				t.Errorf("RequiresApproval = %v, want %v", result.RequiresApproval, tt.want)
+			}
+		})
+	}
+}
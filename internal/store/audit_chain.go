@@ -0,0 +1,88 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// auditHashInput is the canonical form of an audit record for chain hashing.
+// Optional fields are omitted when empty, so fields added later (also with
+// omitempty) leave the hashes of older records unchanged.
+type auditHashInput struct {
+	Seq                  int64  `json:"seq"`
+	PrevHash             string `json:"prev_hash"`
+	ID                   string `json:"id"`
+	Timestamp            string `json:"timestamp"`
+	SessionID            string `json:"session_id,omitempty"`
+	ClientType           string `json:"client_type,omitempty"`
+	Model                string `json:"model,omitempty"`
+	WorkspaceID          string `json:"workspace_id,omitempty"`
+	Subpath              string `json:"subpath,omitempty"`
+	ToolName             string `json:"tool_name,omitempty"`
+	Params               string `json:"params,omitempty"`
+	RouteRuleID          string `json:"route_rule_id,omitempty"`
+	DownstreamServerID   string `json:"downstream_server_id,omitempty"`
+	DownstreamInstanceID string `json:"downstream_instance_id,omitempty"`
+	AuthScopeID          string `json:"auth_scope_id,omitempty"`
+	Status               string `json:"status,omitempty"`
+	ErrorCode            string `json:"error_code,omitempty"`
+	ErrorMessage         string `json:"error_message,omitempty"`
+	LatencyMs            int    `json:"latency_ms,omitempty"`
+	ResponseSize         int    `json:"response_size,omitempty"`
+	ApprovalID           string `json:"approval_id,omitempty"`
+	LogLevel             string `json:"log_level,omitempty"`
+	Response             string `json:"response,omitempty"`
+	Exchange             string `json:"exchange,omitempty"`
+	Redactions           string `json:"redactions,omitempty"`
+	TraceID              string `json:"trace_id,omitempty"`
+	CreatedAt            string `json:"created_at"`
+}
+
+// MarshalRedactions encodes redactions as stored and hashed; empty for none.
+func MarshalRedactions(rs []Redaction) string {
+	if len(rs) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(rs) // plain strings cannot fail
+	return string(data)
+}
+
+// AuditRecordHash computes the chain hash of r from its stored fields,
+// including Seq and PrevHash. Times are hashed at the second resolution the
+// store keeps.
+func AuditRecordHash(r *AuditRecord) string {
+	in := auditHashInput{
+		Seq:                  r.Seq,
+		PrevHash:             r.PrevHash,
+		ID:                   r.ID,
+		Timestamp:            r.Timestamp.UTC().Format(time.RFC3339),
+		SessionID:            r.SessionID,
+		ClientType:           r.ClientType,
+		Model:                r.Model,
+		WorkspaceID:          r.WorkspaceID,
+		Subpath:              r.Subpath,
+		ToolName:             r.ToolName,
+		Params:               string(r.ParamsRedacted),
+		RouteRuleID:          r.RouteRuleID,
+		DownstreamServerID:   r.DownstreamServerID,
+		DownstreamInstanceID: r.DownstreamInstanceID,
+		AuthScopeID:          r.AuthScopeID,
+		Status:               r.Status,
+		ErrorCode:            r.ErrorCode,
+		ErrorMessage:         r.ErrorMessage,
+		LatencyMs:            r.LatencyMs,
+		ResponseSize:         r.ResponseSize,
+		ApprovalID:           r.ApprovalID,
+		LogLevel:             r.LogLevel,
+		Response:             string(r.Response),
+		Exchange:             string(r.Exchange),
+		Redactions:           MarshalRedactions(r.Redactions),
+		TraceID:              r.TraceID,
+		CreatedAt:            r.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	data, _ := json.Marshal(in) // plain strings and ints cannot fail
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AuditCursorKey is the decoded position of an audit keyset cursor: the
+// sort key of the last record returned and its chain sequence number,
+// which is unique and breaks ties in insertion order.
+type AuditCursorKey struct {
+	Sort      string    `json:"s"`
+	Timestamp time.Time `json:"t,omitzero"`
+	LatencyMs int       `json:"l,omitempty"`
+	Seq       int64     `json:"q"`
+}
+
+// ValidAuditSort reports whether sort is empty or a known AuditSort* value.
+func ValidAuditSort(sort string) bool {
+	switch sort {
+	case "", AuditSortTimestampDesc, AuditSortTimestampAsc, AuditSortLatencyDesc, AuditSortLatencyAsc:
+		return true
+	}
+	return false
+}
+
+// AuditCursor returns an opaque cursor that continues a query with the given
+// sort after r, the last record of a page. Unlike offsets, cursors do not
+// repeat or skip records when new ones are inserted between pages.
+func AuditCursor(sort string, r *AuditRecord) string {
+	if sort == "" {
+		sort = AuditSortTimestampDesc
+	}
+	k := AuditCursorKey{Sort: sort, Seq: r.Seq}
+	switch sort {
+	case AuditSortLatencyDesc, AuditSortLatencyAsc:
+		k.LatencyMs = r.LatencyMs
+	default:
+		k.Timestamp = r.Timestamp.UTC().Truncate(time.Second)
+	}
+	data, _ := json.Marshal(k) // plain fields cannot fail
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseAuditCursor decodes a cursor from AuditCursor and checks that it was
+// issued for sort.
+func ParseAuditCursor(cursor, sort string) (AuditCursorKey, error) {
+	if sort == "" {
+		sort = AuditSortTimestampDesc
+	}
+	var k AuditCursorKey
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return k, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if err := json.Unmarshal(data, &k); err != nil {
+		return k, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if k.Seq <= 0 {
+		return k, fmt.Errorf("%w: missing sequence number", ErrInvalidCursor)
+	}
+	if k.Sort != sort {
+		return k, fmt.Errorf("%w: issued for sort %q, not %q", ErrInvalidCursor, k.Sort, sort)
+	}
+	return k, nil
+}
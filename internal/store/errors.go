@@ -11,4 +11,8 @@ var (
 
 	// ErrConflict indicates a concurrent modification conflict.
 	ErrConflict = errors.New("conflict")
+
+	// ErrInvalidCursor indicates a malformed pagination cursor, one issued
+	// for a different sort order, or a cursor combined with an offset.
+	ErrInvalidCursor = errors.New("invalid cursor")
 )
@@ -12,9 +12,15 @@ type Workspace struct {
 	RootPath      string          `json:"root_path"`
 	Tags          json.RawMessage `json:"tags,omitempty"`
 	DefaultPolicy string          `json:"default_policy"`
-	Source        string          `json:"source"`
-	CreatedAt     time.Time       `json:"created_at"`
-	UpdatedAt     time.Time       `json:"updated_at"`
+	// AnnotationPolicy applies to every route in the workspace unless the
+	// route overrides it.
+	AnnotationPolicy AnnotationPolicy `json:"annotation_policy"`
+	// Audit retention overrides; 0 uses the global policy.
+	AuditRetentionDays int       `json:"audit_retention_days"`
+	AuditMaxRows       int       `json:"audit_max_rows"`
+	Source             string    `json:"source"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 // AuthScope represents a credential scope for downstream server authentication.
@@ -49,50 +55,118 @@ type OAuthProvider struct {
 
 // OAuthTokenData holds decrypted OAuth2 token information.
 type OAuthTokenData struct {
-	AccessToken  string   `json:"access_token"`
-	RefreshToken string   `json:"refresh_token"`
-	TokenType    string   `json:"token_type"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
 	ExpiresAt    time.Time `json:"expires_at"`
-	Scopes       []string `json:"scopes,omitempty"`
+	Scopes       []string  `json:"scopes,omitempty"`
 }
 
 // DownstreamServer represents a downstream MCP server configuration.
 type DownstreamServer struct {
-	ID                string          `json:"id"`
-	Name              string          `json:"name"`
-	Transport         string          `json:"transport"`
-	Command           string          `json:"command"`
-	Args              json.RawMessage `json:"args,omitempty"`
-	URL               *string         `json:"url,omitempty"`
-	ToolNamespace     string          `json:"tool_namespace"`
-	Discovery         string          `json:"discovery"` // "static" or "dynamic"
-	CapabilitiesCache json.RawMessage `json:"capabilities_cache,omitempty"`
-	IdleTimeoutSec    int             `json:"idle_timeout_sec"`
-	MaxInstances      int             `json:"max_instances"`
-	RestartPolicy     string          `json:"restart_policy"`
-	Disabled          bool            `json:"disabled"`
-	Source            string          `json:"source"`
-	CreatedAt         time.Time       `json:"created_at"`
-	UpdatedAt         time.Time       `json:"updated_at"`
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	Transport         string            `json:"transport"` // "stdio", "http" (Streamable HTTP), "sse" (legacy HTTP+SSE) or "websocket"
+	Command           string            `json:"command"`
+	Args              json.RawMessage   `json:"args,omitempty"`
+	URL               *string           `json:"url,omitempty"`
+	ToolNamespace     string            `json:"tool_namespace"`
+	Discovery         string            `json:"discovery"` // "static" or "dynamic"
+	CapabilitiesCache json.RawMessage   `json:"capabilities_cache,omitempty"`
+	IdleTimeoutSec    int               `json:"idle_timeout_sec"`
+	MaxInstances      int               `json:"max_instances"`
+	RestartPolicy     string            `json:"restart_policy"`
+	AnnotationTrust   string            `json:"annotation_trust"` // "trusted" (default) or "untrusted"
+	Env               map[string]string `json:"env,omitempty"`    // extra env for stdio servers; ${VAR} expands
+	EnvPassthrough    []string          `json:"env_passthrough"`  // inherited OS vars (NAME or PREFIX*); nil inherits all
+	WorkingDir        string            `json:"working_dir,omitempty"`
+	InstanceScope     string            `json:"instance_scope"`    // "global" (default), "workspace" or "session"
+	Sandbox           *SandboxProfile   `json:"sandbox,omitempty"` // nil runs stdio servers unsandboxed
+	HealthIntervalSec int               `json:"health_interval_sec"`
+	HealthTimeoutSec  int               `json:"health_timeout_sec"`
+	InitTimeoutSec    int               `json:"init_timeout_sec"`        // 0 uses the gateway default
+	CallTimeoutSec    int               `json:"call_timeout_sec"`        // 0 uses the gateway default; negative means none
+	ToolTimeouts      map[string]int    `json:"tool_timeouts,omitempty"` // call timeout in seconds by tool name
+	Disabled          bool              `json:"disabled"`
+	Source            string            `json:"source"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+// Remote reports whether the server is reached at a URL rather than run
+// as a local process.
+func (d *DownstreamServer) Remote() bool {
+	switch d.Transport {
+	case "http", "sse", "websocket":
+		return true
+	}
+	return false
 }
 
 // RouteRule represents a routing rule for matching tool calls to downstream servers.
 type RouteRule struct {
-	ID                 string          `json:"id"`
-	Name               string          `json:"name"`
-	Priority           int             `json:"priority"`
-	WorkspaceID        string          `json:"workspace_id"`
-	PathGlob           string          `json:"path_glob"`
-	ToolMatch          json.RawMessage `json:"tool_match,omitempty"`
-	DownstreamServerID string          `json:"downstream_server_id"`
-	AuthScopeID        string          `json:"auth_scope_id"`
-	Policy             string          `json:"policy"`
-	LogLevel           string          `json:"log_level"`
-	RequiresApproval   bool            `json:"requires_approval"`
-	ApprovalTimeout    int             `json:"approval_timeout"`
-	Source             string          `json:"source"`
-	CreatedAt          time.Time       `json:"created_at"`
-	UpdatedAt          time.Time       `json:"updated_at"`
+	ID                 string           `json:"id"`
+	Name               string           `json:"name"`
+	Priority           int              `json:"priority"`
+	WorkspaceID        string           `json:"workspace_id"`
+	PathGlob           string           `json:"path_glob"`
+	ToolMatch          json.RawMessage  `json:"tool_match,omitempty"`
+	DownstreamServerID string           `json:"downstream_server_id"`
+	AuthScopeID        string           `json:"auth_scope_id"`
+	Policy             string           `json:"policy"`
+	LogLevel           string           `json:"log_level"`
+	RequiresApproval   bool             `json:"requires_approval"`
+	ApprovalTimeout    int              `json:"approval_timeout"`
+	AnnotationPolicy   AnnotationPolicy `json:"annotation_policy"`
+	Source             string           `json:"source"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+}
+
+// Route log levels control how much of a tool call is captured in its audit
+// record. An empty level means LogLevelInfo.
+const (
+	LogLevelNone     = "none"     // no record; only counters are kept
+	LogLevelMetadata = "metadata" // no params
+	LogLevelInfo     = "info"     // redacted params (default)
+	LogLevelFull     = "full"     // also the redacted, size-capped response
+	LogLevelDebug    = "debug"    // also the raw downstream JSON-RPC exchange
+)
+
+// AnnotationPolicy derives approval requirements from MCP tool annotations.
+// Nil fields are unset; on a route rule, set fields override the workspace.
+type AnnotationPolicy struct {
+	// RequireApprovalDestructive requires approval for tools that may be
+	// destructive (not readOnlyHint, destructiveHint true or absent).
+	RequireApprovalDestructive *bool `json:"require_approval_destructive,omitempty"`
+	// AutoAllowReadOnly skips approval for tools marked readOnlyHint.
+	AutoAllowReadOnly *bool `json:"auto_allow_read_only,omitempty"`
+}
+
+// SandboxProfile restricts a stdio downstream process on Linux. Each
+// restriction is opt-in; zero limits are unlimited.
+type SandboxProfile struct {
+	CPUSeconds   uint64 `json:"cpu_seconds,omitempty"`    // RLIMIT_CPU
+	MemoryMB     uint64 `json:"memory_mb,omitempty"`      // RLIMIT_AS, i.e. address space
+	MaxOpenFiles uint64 `json:"max_open_files,omitempty"` // RLIMIT_NOFILE
+
+	// Namespaces runs the process in private user and mount namespaces with
+	// its own /tmp.
+	Namespaces bool `json:"namespaces,omitempty"`
+	// DenyNetwork runs the process in a new network namespace with no
+	// interfaces but loopback.
+	DenyNetwork bool `json:"deny_network,omitempty"`
+
+	// Landlock limits writes to the workspace root and WritePaths, and
+	// reads to those plus system directories and ReadPaths.
+	Landlock   bool     `json:"landlock,omitempty"`
+	ReadPaths  []string `json:"read_paths,omitempty"`
+	WritePaths []string `json:"write_paths,omitempty"`
+
+	// Seccomp denies syscalls used to escape or tamper with the host
+	// (mount, ptrace, kexec_load, ...) plus any named in SeccompDeny.
+	Seccomp     bool     `json:"seccomp,omitempty"`
+	SeccompDeny []string `json:"seccomp_deny,omitempty"`
 }
 
 // Session represents an active or past MCP client session.
@@ -126,23 +200,107 @@ type AuditRecord struct {
 	ErrorMessage         string          `json:"error_message,omitempty"`
 	LatencyMs            int             `json:"latency_ms"`
 	ResponseSize         int             `json:"response_size"`
+	ApprovalID           string          `json:"approval_id,omitempty"` // approval that gated this call
+	LogLevel             string          `json:"log_level,omitempty"`   // capture depth applied to this record
+	Response             json.RawMessage `json:"response,omitempty"`    // redacted tool result (full and debug)
+	Exchange             json.RawMessage `json:"exchange,omitempty"`    // raw downstream JSON-RPC (debug)
+	Redactions           []Redaction     `json:"redactions,omitempty"`  // where values were redacted
+	TraceID              string          `json:"trace_id,omitempty"`    // tool call trace, as 32 hex characters
 	CreatedAt            time.Time       `json:"created_at"`
 
+	// Hash chain, assigned by the store on insert.
+	Seq      int64  `json:"seq,omitempty"`
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+
 	// Enriched fields for UI
 	RouteRuleSummary     string `json:"route_rule_summary,omitempty"`
 	DownstreamServerName string `json:"downstream_server_name,omitempty"`
 }
 
+// Redaction records one redacted value in an audit record. Path locates it,
+// e.g. "params.items[2].body"; Detector names what matched: "key_name",
+// "hint", a built-in value detector such as "github_token", or "custom_N".
+type Redaction struct {
+	Path     string `json:"path"`
+	Detector string `json:"detector"`
+}
+
+// AuditChainHead is the latest position of the audit hash chain.
+type AuditChainHead struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// AuditChainGap is a contiguous run of audit records removed by retention.
+// PrevHash is the prev_hash of the first removed record and LastHash the
+// hash of the last, so the chain can be verified across the gap. PublicKey
+// and Signature are base64-encoded Ed25519 values, empty when unsigned.
+type AuditChainGap struct {
+	FirstSeq  int64     `json:"first_seq"`
+	LastSeq   int64     `json:"last_seq"`
+	PrevHash  string    `json:"prev_hash"`
+	LastHash  string    `json:"last_hash"`
+	PrunedAt  time.Time `json:"pruned_at"`
+	PublicKey string    `json:"public_key,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+// AuditGapSigner sets PublicKey and Signature on a gap before it is stored.
+type AuditGapSigner func(g *AuditChainGap)
+
+// AuditCheckpoint is a signed statement of the audit chain head. PublicKey
+// and Signature are base64-encoded Ed25519 values.
+type AuditCheckpoint struct {
+	ID        string    `json:"id"`
+	Seq       int64     `json:"seq"`
+	Hash      string    `json:"hash"`
+	PublicKey string    `json:"public_key"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // AuditFilter specifies query parameters for listing audit records.
 type AuditFilter struct {
-	SessionID   *string    `json:"session_id,omitempty"`
-	WorkspaceID *string    `json:"workspace_id,omitempty"`
-	ToolName    *string    `json:"tool_name,omitempty"`
-	Status      *string    `json:"status,omitempty"`
-	After       *time.Time `json:"after,omitempty"`
-	Before      *time.Time `json:"before,omitempty"`
-	Limit       int        `json:"limit"`
-	Offset      int        `json:"offset"`
+	SessionID          *string    `json:"session_id,omitempty"`
+	WorkspaceID        *string    `json:"workspace_id,omitempty"`
+	ToolName           *string    `json:"tool_name,omitempty"`
+	Status             *string    `json:"status,omitempty"`
+	ExcludeStatus      *string    `json:"exclude_status,omitempty"` // status != this
+	DownstreamServerID *string    `json:"downstream_server_id,omitempty"`
+	RouteRuleID        *string    `json:"route_rule_id,omitempty"`
+	AuthScopeID        *string    `json:"auth_scope_id,omitempty"`
+	ErrorCode          *string    `json:"error_code,omitempty"`
+	ClientType         *string    `json:"client_type,omitempty"`
+	TraceID            *string    `json:"trace_id,omitempty"`
+	MinLatencyMs       *int       `json:"min_latency_ms,omitempty"` // latency_ms >= this
+	After              *time.Time `json:"after,omitempty"`
+	Before             *time.Time `json:"before,omitempty"`
+	Search             *string    `json:"q,omitempty"`      // full-text search over tool names, params and errors
+	Sort               string     `json:"sort,omitempty"`   // an AuditSort* value; empty is newest first
+	Cursor             string     `json:"cursor,omitempty"` // from AuditCursor; continues after that record, Offset must be 0
+	Limit              int        `json:"limit"`
+	Offset             int        `json:"offset"`
+}
+
+// Audit record sort orders. Each breaks ties by record ID.
+const (
+	AuditSortTimestampDesc = "timestamp_desc" // newest first (default)
+	AuditSortTimestampAsc  = "timestamp_asc"
+	AuditSortLatencyDesc   = "latency_desc" // slowest first
+	AuditSortLatencyAsc    = "latency_asc"
+)
+
+// AuditPruneFilter selects audit records that fall outside a retention
+// policy: older than Before and/or beyond the KeepNewest most recent rows in
+// scope (every set condition must hold). WorkspaceID limits the scope to one
+// workspace; ExcludeWorkspaceIDs removes workspaces that have their own
+// policy from a global scope.
+type AuditPruneFilter struct {
+	WorkspaceID         *string
+	ExcludeWorkspaceIDs []string
+	Before              *time.Time
+	KeepNewest          int
 }
 
 // TimeSeriesPoint holds minute-bucketed aggregate metrics.
@@ -165,22 +323,65 @@ type AuditStats struct {
 
 // ToolApproval represents a pending or resolved tool call approval request.
 type ToolApproval struct {
-	ID                 string     `json:"id"`
-	Status             string     `json:"status"` // pending, approved, denied, timeout, cancelled
-	RequestSessionID   string     `json:"request_session_id"`
-	RequestClientType  string     `json:"request_client_type"`
-	RequestModel       string     `json:"request_model"`
-	WorkspaceID        string     `json:"workspace_id"`
-	ToolName           string     `json:"tool_name"`
-	Arguments          string     `json:"arguments"`
-	Justification      string     `json:"justification"`
-	RouteRuleID        string     `json:"route_rule_id"`
-	DownstreamServerID string     `json:"downstream_server_id"`
-	AuthScopeID        string     `json:"auth_scope_id"`
-	ApproverSessionID  string     `json:"approver_session_id"`
-	ApproverType       string     `json:"approver_type"` // mcp_agent, dashboard, system
-	Resolution         string     `json:"resolution"`
-	TimeoutSec         int        `json:"timeout_sec"`
-	CreatedAt          time.Time  `json:"created_at"`
-	ResolvedAt         *time.Time `json:"resolved_at,omitempty"`
+	ID                 string          `json:"id"`
+	Status             string          `json:"status"` // pending, approved, denied, timeout, cancelled
+	RequestSessionID   string          `json:"request_session_id"`
+	RequestClientType  string          `json:"request_client_type"`
+	RequestModel       string          `json:"request_model"`
+	WorkspaceID        string          `json:"workspace_id"`
+	ToolName           string          `json:"tool_name"`
+	Arguments          string          `json:"arguments"`
+	CallParams         json.RawMessage `json:"call_params,omitempty"` // originating tools/call params
+	Justification      string          `json:"justification"`
+	RouteRuleID        string          `json:"route_rule_id"`
+	DownstreamServerID string          `json:"downstream_server_id"`
+	AuthScopeID        string          `json:"auth_scope_id"`
+	ApproverSessionID  string          `json:"approver_session_id"`
+	ApproverType       string          `json:"approver_type"` // mcp_agent, dashboard, system
+	Resolution         string          `json:"resolution"`
+	TimeoutSec         int             `json:"timeout_sec"`
+	CreatedAt          time.Time       `json:"created_at"`
+	ResolvedAt         *time.Time      `json:"resolved_at,omitempty"`
+	ExecutedAt         *time.Time      `json:"executed_at,omitempty"`
+	AuditRecordID      string          `json:"audit_record_id,omitempty"` // audit record of the resulting call
+}
+
+// ApprovalFilter specifies query parameters for the approval history.
+type ApprovalFilter struct {
+	Status           *string    `json:"status,omitempty"`
+	ToolName         *string    `json:"tool_name,omitempty"`
+	WorkspaceID      *string    `json:"workspace_id,omitempty"`
+	ApproverType     *string    `json:"approver_type,omitempty"`
+	RequestSessionID *string    `json:"request_session_id,omitempty"`
+	After            *time.Time `json:"after,omitempty"`
+	Before           *time.Time `json:"before,omitempty"`
+	Query            *string    `json:"q,omitempty"` // free text over justification and resolution
+	Limit            int        `json:"limit"`
+	Offset           int        `json:"offset"`
+}
+
+// ApprovalStats holds aggregate statistics for approvals, overall and per tool.
+type ApprovalStats struct {
+	ApprovalCounts
+	Tools []ToolApprovalStats `json:"tools"`
+}
+
+// ToolApprovalStats holds approval statistics for a single tool.
+type ToolApprovalStats struct {
+	ToolName string `json:"tool_name"`
+	ApprovalCounts
+}
+
+// ApprovalCounts holds approval outcome counts. ApprovalRate is approved over
+// decided (approved + denied + timeout); MedianDecisionMs covers approvals
+// resolved by an approver, excluding timeouts.
+type ApprovalCounts struct {
+	Total            int     `json:"total"`
+	Pending          int     `json:"pending"`
+	Approved         int     `json:"approved"`
+	Denied           int     `json:"denied"`
+	Timeout          int     `json:"timeout"`
+	Cancelled        int     `json:"cancelled"`
+	ApprovalRate     float64 `json:"approval_rate"`
+	MedianDecisionMs int64   `json:"median_decision_ms"`
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,47 +13,107 @@ import (
 )
 
 func (d *DB) InsertAuditRecord(ctx context.Context, r *store.AuditRecord) error {
-	if r.ID == "" {
-		r.ID = uuid.NewString()
-	}
-	if r.Timestamp.IsZero() {
-		r.Timestamp = time.Now().UTC()
-	}
-	if r.CreatedAt.IsZero() {
-		r.CreatedAt = time.Now().UTC()
+	return d.InsertAuditRecords(ctx, []*store.AuditRecord{r})
+}
+
+func (d *DB) InsertAuditRecords(ctx context.Context, recs []*store.AuditRecord) error {
+	for _, r := range recs {
+		if r.ID == "" {
+			r.ID = uuid.NewString()
+		}
+		if r.Timestamp.IsZero() {
+			r.Timestamp = time.Now().UTC()
+		}
+		if r.CreatedAt.IsZero() {
+			r.CreatedAt = time.Now().UTC()
+		}
+
+		// Hash the params exactly as stored.
+		r.ParamsRedacted = json.RawMessage(normalizeJSON(r.ParamsRedacted, "{}"))
 	}
 
-	params := normalizeJSON(r.ParamsRedacted, "{}")
+	return d.inTx(ctx, func(q queryable) error {
+		for _, r := range recs {
+			if err := insertChainedAuditRecord(ctx, q, r); err != nil {
+				return err
+			}
+			if err := upsertUsageRollups(ctx, q, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// insertChainedAuditRecord advances the chain head, hashes r onto it and
+// inserts it. Advancing the head first takes the write lock up front, so
+// concurrent writers serialize on the chain.
+func insertChainedAuditRecord(ctx context.Context, q queryable, r *store.AuditRecord) error {
+	err := q.QueryRowContext(ctx,
+		`UPDATE audit_chain_head SET seq = seq + 1 WHERE id = 1 RETURNING seq, hash`,
+	).Scan(&r.Seq, &r.PrevHash)
+	if err != nil {
+		return fmt.Errorf("advance audit chain: %w", err)
+	}
+	r.Hash = store.AuditRecordHash(r)
 
-	_, err := d.q.ExecContext(ctx, `
+	_, err = q.ExecContext(ctx, `
 		INSERT INTO audit_records
 			(id, timestamp, session_id, client_type, model, workspace_id,
 			 subpath, tool_name, params_redacted, route_rule_id,
 			 downstream_server_id, downstream_instance_id, auth_scope_id,
 			 status, error_code, error_message, latency_ms, response_size,
-			 created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			 approval_id, log_level, response, exchange, redactions, trace_id,
+			 created_at, seq, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		r.ID, formatTime(r.Timestamp), r.SessionID, r.ClientType, r.Model,
-		r.WorkspaceID, r.Subpath, r.ToolName, params, r.RouteRuleID,
+		r.WorkspaceID, r.Subpath, r.ToolName, string(r.ParamsRedacted), r.RouteRuleID,
 		r.DownstreamServerID, r.DownstreamInstanceID, r.AuthScopeID,
 		r.Status, r.ErrorCode, r.ErrorMessage, r.LatencyMs, r.ResponseSize,
-		formatTime(r.CreatedAt),
+		r.ApprovalID, r.LogLevel, string(r.Response), string(r.Exchange),
+		store.MarshalRedactions(r.Redactions), r.TraceID, formatTime(r.CreatedAt), r.Seq, r.PrevHash, r.Hash,
 	)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `UPDATE audit_chain_head SET hash = ? WHERE id = 1`, r.Hash)
 	return err
 }
 
 func (d *DB) QueryAuditRecords(
 	ctx context.Context, f store.AuditFilter,
 ) ([]store.AuditRecord, int, error) {
+	order, ok := auditSortOrders[f.Sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown audit sort %q", f.Sort)
+	}
 	where, args := buildAuditWhere(f)
 
-	// Count total.
+	// Count total, ignoring the cursor so it stays stable across pages.
 	var total int
-	countQ := "SELECT COUNT(*) FROM audit_records" + where
+	countQ := "SELECT COUNT(*) FROM audit_records r" + where
 	if err := d.q.QueryRowContext(ctx, countQ, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
+	dataWhere, dataArgs := where, args
+	if f.Cursor != "" {
+		if f.Offset != 0 {
+			return nil, 0, fmt.Errorf("%w: cannot be combined with an offset", store.ErrInvalidCursor)
+		}
+		k, err := store.ParseAuditCursor(f.Cursor, f.Sort)
+		if err != nil {
+			return nil, 0, err
+		}
+		cond, condArgs := order.after(k)
+		if dataWhere == "" {
+			dataWhere = " WHERE " + cond
+		} else {
+			dataWhere += " AND " + cond
+		}
+		dataArgs = append(append([]any{}, args...), condArgs...)
+	}
+
 	// Fetch page.
 	limit := f.Limit
 	if limit <= 0 {
@@ -62,15 +123,16 @@ func (d *DB) QueryAuditRecords(
 		r.id, r.timestamp, r.session_id, r.client_type, r.model, r.workspace_id,
 		r.subpath, r.tool_name, r.params_redacted, r.route_rule_id,
 		r.downstream_server_id, r.downstream_instance_id, r.auth_scope_id,
-		r.status, r.error_code, r.error_message, r.latency_ms, r.response_size, r.created_at,
+		r.status, r.error_code, r.error_message, r.latency_ms, r.response_size,
+		r.approval_id, r.log_level, r.response, r.exchange, r.redactions, r.trace_id,
+		r.created_at, r.seq, r.prev_hash, r.hash,
 		COALESCE(rr.path_glob, '') as route_rule_summary,
 		COALESCE(ds.name, '') as downstream_server_name
 		FROM audit_records r
 		LEFT JOIN route_rules rr ON r.route_rule_id = rr.id
-		LEFT JOIN downstream_servers ds ON r.downstream_server_id = ds.id ` +
-		strings.ReplaceAll(where, "workspace_id", "r.workspace_id") + // Qualify ambiguous columns if needed
-		` ORDER BY r.timestamp DESC LIMIT ? OFFSET ?`
-	dataArgs := append(args, limit, f.Offset)
+		LEFT JOIN downstream_servers ds ON r.downstream_server_id = ds.id` +
+		dataWhere + ` ORDER BY ` + order.orderBy + ` LIMIT ? OFFSET ?`
+	dataArgs = append(dataArgs, limit, f.Offset)
 
 	rows, err := d.q.QueryContext(ctx, dataQ, dataArgs...)
 	if err != nil {
@@ -104,22 +166,72 @@ func (d *DB) GetAuditStats(
 		args = []any{formatTime(after), formatTime(before)}
 	}
 
+	var latencySum int64
+	var latencyMax int
 	err := d.q.QueryRowContext(ctx, `
 		SELECT
 			COUNT(*),
 			COUNT(*) FILTER (WHERE status = 'success'),
-			COUNT(*) FILTER (WHERE status = 'error'),
-			COALESCE(AVG(latency_ms), 0)
+			COUNT(*) FILTER (WHERE status != 'success'),
+			COALESCE(SUM(latency_ms), 0),
+			COALESCE(MAX(latency_ms), 0)
 		FROM audit_records
 		`+whereClause,
 		args...,
-	).Scan(&s.TotalRequests, &s.SuccessCount, &s.ErrorCount, &s.AvgLatencyMs)
+	).Scan(&s.TotalRequests, &s.SuccessCount, &s.ErrorCount, &latencySum, &latencyMax)
 	if err != nil {
 		return nil, err
 	}
 
-	// P95 latency approximation.
-	err = d.q.QueryRowContext(ctx, `
+	// Fold in aggregates of pruned records.
+	rollup, err := d.sumAuditRollups(ctx, workspaceID, after, before)
+	if err != nil {
+		return nil, err
+	}
+	if rollup.Total == 0 {
+		if s.TotalRequests > 0 {
+			s.AvgLatencyMs = float64(latencySum) / float64(s.TotalRequests)
+		}
+		s.P95LatencyMs = d.exactP95(ctx, whereClause, args)
+		return &s, nil
+	}
+
+	// With rollups in range, P95 comes from the merged latency histogram.
+	rows, err := d.q.QueryContext(ctx, `
+		SELECT `+latencyBucketSQL()+` AS slot, COUNT(*)
+		FROM audit_records
+		`+whereClause+`
+		GROUP BY slot`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var slot, n int
+		if err := rows.Scan(&slot, &n); err != nil {
+			return nil, fmt.Errorf("scan latency histogram: %w", err)
+		}
+		rollup.Hist[slot] += n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.TotalRequests += rollup.Total
+	s.SuccessCount += rollup.SuccessCount
+	s.ErrorCount += rollup.ErrorCount
+	s.AvgLatencyMs = float64(latencySum+rollup.LatencySumMs) / float64(s.TotalRequests)
+	s.P95LatencyMs = histogramPercentile(rollup.Hist, 0.95, max(latencyMax, rollup.LatencyMaxMs))
+	return &s, nil
+}
+
+// exactP95 returns the 95th percentile latency of the raw records matching
+// whereClause.
+func (d *DB) exactP95(ctx context.Context, whereClause string, args []any) int {
+	var p95 int
+	err := d.q.QueryRowContext(ctx, `
 		SELECT COALESCE(latency_ms, 0) FROM audit_records
 		`+whereClause+`
 		ORDER BY latency_ms ASC
@@ -128,96 +240,266 @@ func (d *DB) GetAuditStats(
 			`+whereClause+`
 		)`,
 		append(args, args...)...,
-	).Scan(&s.P95LatencyMs)
+	).Scan(&p95)
 	if err != nil {
 		// No rows is fine — P95 stays 0.
-		s.P95LatencyMs = 0
+		return 0
 	}
-	return &s, nil
+	return p95
+}
+
+// sumAuditRollups merges the audit_rollups rows whose minute bucket overlaps
+// [after, before].
+func (d *DB) sumAuditRollups(
+	ctx context.Context, workspaceID string, after, before time.Time,
+) (*auditRollup, error) {
+	q := `SELECT ` + rollupColumns + ` FROM audit_rollups WHERE bucket >= ? AND bucket <= ?`
+	args := []any{formatTime(after.Truncate(time.Minute)), formatTime(before)}
+	if workspaceID != "" {
+		q += " AND workspace_id = ?"
+		args = append(args, workspaceID)
+	}
+	rows, err := d.q.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sum := newAuditRollup()
+	for rows.Next() {
+		r := newAuditRollup()
+		if err := r.scan(rows); err != nil {
+			return nil, fmt.Errorf("scan audit rollup: %w", err)
+		}
+		sum.merge(r)
+	}
+	return sum, rows.Err()
 }
 
 func (d *DB) GetDashboardTimeSeries(
 	ctx context.Context, after, before time.Time,
 ) ([]store.TimeSeriesPoint, error) {
+	buckets := map[string]*auditRollup{}
+	bucketFor := func(key string) *auditRollup {
+		b, ok := buckets[key]
+		if !ok {
+			b = newAuditRollup()
+			buckets[key] = b
+		}
+		return b
+	}
+
+	// Raw records, grouped finely enough to merge distinct sessions and
+	// servers with the rollups of pruned records.
 	rows, err := d.q.QueryContext(ctx, `
 		SELECT
 			strftime('%Y-%m-%dT%H:%M:00Z', timestamp) AS bucket,
-			COUNT(DISTINCT session_id) AS sessions,
-			COUNT(DISTINCT downstream_server_id) AS servers,
+			session_id,
+			downstream_server_id,
 			COUNT(*) AS total,
-			COUNT(*) FILTER (WHERE status = 'error') AS errors
+			COUNT(*) FILTER (WHERE status != 'success') AS errors
 		FROM audit_records
 		WHERE timestamp >= ? AND timestamp <= ?
-		GROUP BY bucket
-		ORDER BY bucket ASC`,
+		GROUP BY bucket, session_id, downstream_server_id`,
 		formatTime(after), formatTime(before),
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-
-	var out []store.TimeSeriesPoint
 	for rows.Next() {
-		var p store.TimeSeriesPoint
-		var bucket string
-		if err := rows.Scan(&bucket, &p.Sessions, &p.Servers, &p.Total, &p.Errors); err != nil {
+		var bucket, sessionID, serverID string
+		var total, errs int
+		if err := rows.Scan(&bucket, &sessionID, &serverID, &total, &errs); err != nil {
 			return nil, fmt.Errorf("scan time series row: %w", err)
 		}
-		p.Bucket = parseTime(bucket)
-		out = append(out, p)
+		b := bucketFor(bucket)
+		b.Total += total
+		b.ErrorCount += errs
+		b.Sessions[sessionID] = struct{}{}
+		b.Servers[serverID] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rollups, err := d.q.QueryContext(ctx, `
+		SELECT bucket, `+rollupColumns+` FROM audit_rollups
+		WHERE bucket >= ? AND bucket <= ?`,
+		formatTime(after.Truncate(time.Minute)), formatTime(before),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rollups.Close()
+	for rollups.Next() {
+		var bucket string
+		r := newAuditRollup()
+		if err := r.scan(prefixScanner{rollups, &bucket}); err != nil {
+			return nil, fmt.Errorf("scan audit rollup: %w", err)
+		}
+		bucketFor(bucket).merge(r)
 	}
-	return out, rows.Err()
+	if err := rollups.Err(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(buckets))
+	for k := range buckets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]store.TimeSeriesPoint, 0, len(keys))
+	for _, k := range keys {
+		b := buckets[k]
+		out = append(out, store.TimeSeriesPoint{
+			Bucket:   parseTime(k),
+			Sessions: len(b.Sessions),
+			Servers:  len(b.Servers),
+			Total:    b.Total,
+			Errors:   b.ErrorCount,
+		})
+	}
+	return out, nil
 }
 
+// auditSortOrder is an ORDER BY for audit records and its keyset condition.
+type auditSortOrder struct {
+	orderBy string
+	after   func(k store.AuditCursorKey) (string, []any)
+}
+
+// auditSortOrders maps AuditFilter.Sort to its order. Every order ends in
+// r.seq so cursor positions are unique and ties keep insertion order.
+var auditSortOrders = map[string]auditSortOrder{
+	"":                           timestampDesc,
+	store.AuditSortTimestampDesc: timestampDesc,
+	store.AuditSortTimestampAsc: {
+		orderBy: "r.timestamp ASC, r.seq ASC",
+		after: func(k store.AuditCursorKey) (string, []any) {
+			return "(r.timestamp, r.seq) > (?, ?)", []any{formatTime(k.Timestamp), k.Seq}
+		},
+	},
+	store.AuditSortLatencyDesc: {
+		orderBy: "r.latency_ms DESC, r.seq DESC",
+		after: func(k store.AuditCursorKey) (string, []any) {
+			return "(r.latency_ms, r.seq) < (?, ?)", []any{k.LatencyMs, k.Seq}
+		},
+	},
+	store.AuditSortLatencyAsc: {
+		orderBy: "r.latency_ms ASC, r.seq ASC",
+		after: func(k store.AuditCursorKey) (string, []any) {
+			return "(r.latency_ms, r.seq) > (?, ?)", []any{k.LatencyMs, k.Seq}
+		},
+	},
+}
+
+var timestampDesc = auditSortOrder{
+	orderBy: "r.timestamp DESC, r.seq DESC",
+	after: func(k store.AuditCursorKey) (string, []any) {
+		return "(r.timestamp, r.seq) < (?, ?)", []any{formatTime(k.Timestamp), k.Seq}
+	},
+}
+
+// buildAuditWhere returns the WHERE clause for f over audit_records
+// aliased as r. The cursor is not included.
 func buildAuditWhere(f store.AuditFilter) (string, []any) {
 	var conds []string
 	var args []any
-	if f.SessionID != nil {
-		conds = append(conds, "session_id = ?")
-		args = append(args, *f.SessionID)
-	}
-	if f.WorkspaceID != nil {
-		conds = append(conds, "workspace_id = ?")
-		args = append(args, *f.WorkspaceID)
+	eq := func(col string, v *string) {
+		if v != nil {
+			conds = append(conds, "r."+col+" = ?")
+			args = append(args, *v)
+		}
 	}
-	if f.ToolName != nil {
-		conds = append(conds, "tool_name = ?")
-		args = append(args, *f.ToolName)
+	eq("session_id", f.SessionID)
+	eq("workspace_id", f.WorkspaceID)
+	eq("tool_name", f.ToolName)
+	eq("status", f.Status)
+	if f.ExcludeStatus != nil {
+		conds = append(conds, "r.status != ?")
+		args = append(args, *f.ExcludeStatus)
 	}
-	if f.Status != nil {
-		conds = append(conds, "status = ?")
-		args = append(args, *f.Status)
+	eq("downstream_server_id", f.DownstreamServerID)
+	eq("route_rule_id", f.RouteRuleID)
+	eq("auth_scope_id", f.AuthScopeID)
+	eq("error_code", f.ErrorCode)
+	eq("client_type", f.ClientType)
+	eq("trace_id", f.TraceID)
+	if f.MinLatencyMs != nil {
+		conds = append(conds, "r.latency_ms >= ?")
+		args = append(args, *f.MinLatencyMs)
 	}
 	if f.After != nil {
-		conds = append(conds, "timestamp >= ?")
+		conds = append(conds, "r.timestamp >= ?")
 		args = append(args, formatTime(*f.After))
 	}
 	if f.Before != nil {
-		conds = append(conds, "timestamp <= ?")
+		conds = append(conds, "r.timestamp <= ?")
 		args = append(args, formatTime(*f.Before))
 	}
+	if f.Search != nil {
+		if m := ftsMatchQuery(*f.Search); m != "" {
+			conds = append(conds, "r.seq IN (SELECT rowid FROM audit_fts WHERE audit_fts MATCH ?)")
+			args = append(args, m)
+		}
+	}
 	if len(conds) == 0 {
 		return "", nil
 	}
 	return " WHERE " + strings.Join(conds, " AND "), args
 }
 
+// ftsMatchQuery turns free text into an FTS5 query that matches records
+// containing every term. Each whitespace-separated term is quoted as a
+// phrase, so punctuation such as "customers.csv" matches literally instead
+// of being parsed as query syntax; a trailing * makes a term a prefix match.
+func ftsMatchQuery(q string) string {
+	var terms []string
+	for _, t := range strings.Fields(q) {
+		prefix := strings.HasSuffix(t, "*")
+		t = strings.TrimRight(t, "*")
+		if t == "" {
+			continue
+		}
+		term := `"` + strings.ReplaceAll(t, `"`, `""`) + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " ")
+}
+
 func scanAuditRow(row rowScanner) (*store.AuditRecord, error) {
 	var r store.AuditRecord
-	var ts, createdAt, params string
+	var ts, createdAt, params, response, exchange, redactions string
 	err := row.Scan(
 		&r.ID, &ts, &r.SessionID, &r.ClientType, &r.Model,
 		&r.WorkspaceID, &r.Subpath, &r.ToolName, &params,
 		&r.RouteRuleID, &r.DownstreamServerID, &r.DownstreamInstanceID,
 		&r.AuthScopeID, &r.Status, &r.ErrorCode, &r.ErrorMessage,
-		&r.LatencyMs, &r.ResponseSize, &createdAt,
+		&r.LatencyMs, &r.ResponseSize, &r.ApprovalID,
+		&r.LogLevel, &response, &exchange, &redactions, &r.TraceID,
+		&createdAt, &r.Seq, &r.PrevHash, &r.Hash,
 		&r.RouteRuleSummary, &r.DownstreamServerName,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("scan audit row: %w", err)
 	}
 	r.ParamsRedacted = json.RawMessage(params)
+	if response != "" {
+		r.Response = json.RawMessage(response)
+	}
+	if exchange != "" {
+		r.Exchange = json.RawMessage(exchange)
+	}
+	if redactions != "" {
+		if err := json.Unmarshal([]byte(redactions), &r.Redactions); err != nil {
+			return nil, fmt.Errorf("decode audit redactions: %w", err)
+		}
+	}
 	r.Timestamp = parseTime(ts)
 	r.CreatedAt = parseTime(createdAt)
 	return &r, nil
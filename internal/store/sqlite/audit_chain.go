@@ -0,0 +1,241 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+const auditChainColumns = `
+	id, timestamp, session_id, client_type, model, workspace_id,
+	subpath, tool_name, params_redacted, route_rule_id,
+	downstream_server_id, downstream_instance_id, auth_scope_id,
+	status, error_code, error_message, latency_ms, response_size,
+	approval_id, log_level, response, exchange, redactions, trace_id,
+	created_at, seq, prev_hash, hash, '', ''`
+
+func (d *DB) GetAuditChainHead(ctx context.Context) (*store.AuditChainHead, error) {
+	var h store.AuditChainHead
+	err := d.q.QueryRowContext(ctx,
+		`SELECT seq, hash FROM audit_chain_head WHERE id = 1`,
+	).Scan(&h.Seq, &h.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (d *DB) ListAuditChain(
+	ctx context.Context, afterSeq int64, limit int,
+) ([]store.AuditRecord, error) {
+	if limit <= 0 {
+		limit = 1000
+	}
+	rows, err := d.q.QueryContext(ctx,
+		`SELECT `+auditChainColumns+` FROM audit_records
+		 WHERE seq > ? ORDER BY seq ASC LIMIT ?`,
+		afterSeq, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []store.AuditRecord
+	for rows.Next() {
+		r, err := scanAuditRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *r)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) ListAuditChainGaps(ctx context.Context) ([]store.AuditChainGap, error) {
+	rows, err := d.q.QueryContext(ctx, `
+		SELECT first_seq, last_seq, prev_hash, last_hash, pruned_at, public_key, signature
+		FROM audit_chain_gaps ORDER BY first_seq ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []store.AuditChainGap
+	for rows.Next() {
+		var g store.AuditChainGap
+		var prunedAt string
+		if err := rows.Scan(
+			&g.FirstSeq, &g.LastSeq, &g.PrevHash, &g.LastHash, &prunedAt, &g.PublicKey, &g.Signature,
+		); err != nil {
+			return nil, fmt.Errorf("scan audit chain gap: %w", err)
+		}
+		g.PrunedAt = parseTime(prunedAt)
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+func (d *DB) CreateAuditCheckpoint(ctx context.Context, c *store.AuditCheckpoint) error {
+	if c.ID == "" {
+		c.ID = uuid.NewString()
+	}
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now().UTC()
+	}
+	_, err := d.q.ExecContext(ctx, `
+		INSERT INTO audit_checkpoints (id, seq, hash, public_key, signature, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		c.ID, c.Seq, c.Hash, c.PublicKey, c.Signature, formatTime(c.CreatedAt),
+	)
+	return mapConstraintError(err)
+}
+
+func (d *DB) GetLatestAuditCheckpoint(ctx context.Context) (*store.AuditCheckpoint, error) {
+	c, err := scanAuditCheckpoint(d.q.QueryRowContext(ctx, `
+		SELECT id, seq, hash, public_key, signature, created_at
+		FROM audit_checkpoints ORDER BY seq DESC, created_at DESC LIMIT 1`))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	return c, err
+}
+
+func (d *DB) ListAuditCheckpoints(ctx context.Context) ([]store.AuditCheckpoint, error) {
+	rows, err := d.q.QueryContext(ctx, `
+		SELECT id, seq, hash, public_key, signature, created_at
+		FROM audit_checkpoints ORDER BY seq ASC, created_at ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []store.AuditCheckpoint
+	for rows.Next() {
+		c, err := scanAuditCheckpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan audit checkpoint: %w", err)
+		}
+		out = append(out, *c)
+	}
+	return out, rows.Err()
+}
+
+func scanAuditCheckpoint(row rowScanner) (*store.AuditCheckpoint, error) {
+	var c store.AuditCheckpoint
+	var createdAt string
+	if err := row.Scan(&c.ID, &c.Seq, &c.Hash, &c.PublicKey, &c.Signature, &createdAt); err != nil {
+		return nil, err
+	}
+	c.CreatedAt = parseTime(createdAt)
+	return &c, nil
+}
+
+// recordChainGaps records the pruned records as runs of consecutive sequence
+// numbers, each signed with sign when it is non-nil. Runs are not merged with
+// gaps from earlier prunes: the merged row would have to be signed again,
+// vouching for a range this prune never saw. Verification bridges adjacent
+// gaps instead.
+func recordChainGaps(ctx context.Context, q queryable, records []store.AuditRecord, sign store.AuditGapSigner) error {
+	sorted := make([]store.AuditRecord, 0, len(records))
+	for _, r := range records {
+		if r.Seq > 0 {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Seq < sorted[j].Seq })
+
+	// Whole seconds, so the signed time survives the round trip.
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1].Seq == sorted[j].Seq+1 {
+			j++
+		}
+		gap := store.AuditChainGap{
+			FirstSeq: sorted[i].Seq,
+			LastSeq:  sorted[j].Seq,
+			PrevHash: sorted[i].PrevHash,
+			LastHash: sorted[j].Hash,
+			PrunedAt: now,
+		}
+		i = j + 1
+		if sign != nil {
+			sign(&gap)
+		}
+
+		if _, err := q.ExecContext(ctx, `
+			INSERT INTO audit_chain_gaps
+				(first_seq, last_seq, prev_hash, last_hash, pruned_at, public_key, signature)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			gap.FirstSeq, gap.LastSeq, gap.PrevHash, gap.LastHash, formatTime(gap.PrunedAt),
+			gap.PublicKey, gap.Signature,
+		); err != nil {
+			return fmt.Errorf("insert audit chain gap: %w", err)
+		}
+	}
+	return nil
+}
+
+// chainLegacyAuditRecords appends audit records written before the hash
+// chain existed to the chain, oldest first.
+func chainLegacyAuditRecords(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+auditChainColumns+` FROM audit_records
+		 WHERE seq = 0 ORDER BY timestamp ASC, id ASC`)
+	if err != nil {
+		return err
+	}
+	var legacy []store.AuditRecord
+	for rows.Next() {
+		r, err := scanAuditRow(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		legacy = append(legacy, *r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	for i := range legacy {
+		r := &legacy[i]
+		r.ParamsRedacted = json.RawMessage(normalizeJSON(r.ParamsRedacted, "{}"))
+		if err := tx.QueryRowContext(ctx,
+			`UPDATE audit_chain_head SET seq = seq + 1 WHERE id = 1 RETURNING seq, hash`,
+		).Scan(&r.Seq, &r.PrevHash); err != nil {
+			return fmt.Errorf("advance audit chain: %w", err)
+		}
+		r.Hash = store.AuditRecordHash(r)
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE audit_records SET seq = ?, prev_hash = ?, hash = ? WHERE id = ?`,
+			r.Seq, r.PrevHash, r.Hash, r.ID,
+		); err != nil {
+			return fmt.Errorf("chain audit record: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE audit_chain_head SET hash = ? WHERE id = 1`, r.Hash,
+		); err != nil {
+			return fmt.Errorf("advance audit chain: %w", err)
+		}
+	}
+	return tx.Commit()
+}
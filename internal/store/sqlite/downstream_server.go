@@ -28,16 +28,28 @@ func (d *DB) CreateDownstreamServer(ctx context.Context, ds *store.DownstreamSer
 	if ds.Source == "" {
 		ds.Source = "api"
 	}
+	if ds.AnnotationTrust == "" {
+		ds.AnnotationTrust = "trusted"
+	}
+	if ds.InstanceScope == "" {
+		ds.InstanceScope = "global"
+	}
 
 	_, err := d.q.ExecContext(ctx, `
 		INSERT INTO downstream_servers
 			(id, name, transport, command, args, url, tool_namespace, discovery,
 			 capabilities_cache, idle_timeout_sec, max_instances, restart_policy,
-			 disabled, source, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			 annotation_trust, env, env_passthrough, working_dir, instance_scope,
+			 sandbox, health_interval_sec, health_timeout_sec, init_timeout_sec,
+			 call_timeout_sec, tool_timeouts, disabled, source, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		ds.ID, ds.Name, ds.Transport, ds.Command, args, ds.URL,
 		ds.ToolNamespace, ds.Discovery, caps, ds.IdleTimeoutSec, ds.MaxInstances,
-		ds.RestartPolicy, ds.Disabled, ds.Source, formatTime(ds.CreatedAt), formatTime(ds.UpdatedAt),
+		ds.RestartPolicy, ds.AnnotationTrust, marshalServerEnv(ds.Env),
+		marshalEnvPassthrough(ds.EnvPassthrough), ds.WorkingDir, ds.InstanceScope,
+		marshalSandbox(ds.Sandbox), ds.HealthIntervalSec, ds.HealthTimeoutSec, ds.InitTimeoutSec,
+		ds.CallTimeoutSec, marshalToolTimeouts(ds.ToolTimeouts),
+		ds.Disabled, ds.Source, formatTime(ds.CreatedAt), formatTime(ds.UpdatedAt),
 	)
 	if err != nil {
 		return mapConstraintError(err)
@@ -49,7 +61,9 @@ func (d *DB) GetDownstreamServer(ctx context.Context, id string) (*store.Downstr
 	row := d.q.QueryRowContext(ctx, `
 		SELECT id, name, transport, command, args, url, tool_namespace, discovery,
 		       capabilities_cache, idle_timeout_sec, max_instances, restart_policy,
-		       disabled, source, created_at, updated_at
+		       annotation_trust, env, env_passthrough, working_dir, instance_scope,
+		       sandbox, health_interval_sec, health_timeout_sec, init_timeout_sec,
+		       call_timeout_sec, tool_timeouts, disabled, source, created_at, updated_at
 		FROM downstream_servers WHERE id = ?`, id)
 	return scanDownstreamServer(row)
 }
@@ -58,7 +72,9 @@ func (d *DB) GetDownstreamServerByName(ctx context.Context, name string) (*store
 	row := d.q.QueryRowContext(ctx, `
 		SELECT id, name, transport, command, args, url, tool_namespace, discovery,
 		       capabilities_cache, idle_timeout_sec, max_instances, restart_policy,
-		       disabled, source, created_at, updated_at
+		       annotation_trust, env, env_passthrough, working_dir, instance_scope,
+		       sandbox, health_interval_sec, health_timeout_sec, init_timeout_sec,
+		       call_timeout_sec, tool_timeouts, disabled, source, created_at, updated_at
 		FROM downstream_servers WHERE name = ?`, name)
 	return scanDownstreamServer(row)
 }
@@ -67,7 +83,9 @@ func (d *DB) ListDownstreamServers(ctx context.Context) ([]store.DownstreamServe
 	rows, err := d.q.QueryContext(ctx, `
 		SELECT id, name, transport, command, args, url, tool_namespace, discovery,
 		       capabilities_cache, idle_timeout_sec, max_instances, restart_policy,
-		       disabled, source, created_at, updated_at
+		       annotation_trust, env, env_passthrough, working_dir, instance_scope,
+		       sandbox, health_interval_sec, health_timeout_sec, init_timeout_sec,
+		       call_timeout_sec, tool_timeouts, disabled, source, created_at, updated_at
 		FROM downstream_servers ORDER BY name`)
 	if err != nil {
 		return nil, err
@@ -92,17 +110,29 @@ func (d *DB) UpdateDownstreamServer(ctx context.Context, ds *store.DownstreamSer
 	if ds.Source == "" {
 		ds.Source = "api"
 	}
+	if ds.AnnotationTrust == "" {
+		ds.AnnotationTrust = "trusted"
+	}
+	if ds.InstanceScope == "" {
+		ds.InstanceScope = "global"
+	}
 
 	res, err := d.q.ExecContext(ctx, `
 		UPDATE downstream_servers
 		SET name = ?, transport = ?, command = ?, args = ?, url = ?,
 		    tool_namespace = ?, discovery = ?, capabilities_cache = ?,
 		    idle_timeout_sec = ?, max_instances = ?, restart_policy = ?,
-		    disabled = ?, source = ?, updated_at = ?
+		    annotation_trust = ?, env = ?, env_passthrough = ?, working_dir = ?,
+		    instance_scope = ?, sandbox = ?, health_interval_sec = ?,
+		    health_timeout_sec = ?, init_timeout_sec = ?, call_timeout_sec = ?,
+		    tool_timeouts = ?, disabled = ?, source = ?, updated_at = ?
 		WHERE id = ?`,
 		ds.Name, ds.Transport, ds.Command, args, ds.URL,
 		ds.ToolNamespace, ds.Discovery, caps,
 		ds.IdleTimeoutSec, ds.MaxInstances, ds.RestartPolicy,
+		ds.AnnotationTrust, marshalServerEnv(ds.Env), marshalEnvPassthrough(ds.EnvPassthrough),
+		ds.WorkingDir, ds.InstanceScope, marshalSandbox(ds.Sandbox), ds.HealthIntervalSec,
+		ds.HealthTimeoutSec, ds.InitTimeoutSec, ds.CallTimeoutSec, marshalToolTimeouts(ds.ToolTimeouts),
 		ds.Disabled, ds.Source, formatTime(ds.UpdatedAt), ds.ID,
 	)
 	if err != nil {
@@ -136,12 +166,15 @@ func (d *DB) UpdateCapabilitiesCache(
 
 func scanDownstreamServer(row *sql.Row) (*store.DownstreamServer, error) {
 	var ds store.DownstreamServer
-	var createdAt, updatedAt, args, caps string
+	var createdAt, updatedAt, args, caps, env, toolTimeouts string
+	var passthrough, sandbox sql.NullString
 	err := row.Scan(
 		&ds.ID, &ds.Name, &ds.Transport, &ds.Command, &args,
 		&ds.URL, &ds.ToolNamespace, &ds.Discovery, &caps,
 		&ds.IdleTimeoutSec, &ds.MaxInstances, &ds.RestartPolicy,
-		&ds.Disabled, &ds.Source, &createdAt, &updatedAt,
+		&ds.AnnotationTrust, &env, &passthrough, &ds.WorkingDir, &ds.InstanceScope,
+		&sandbox, &ds.HealthIntervalSec, &ds.HealthTimeoutSec, &ds.InitTimeoutSec,
+		&ds.CallTimeoutSec, &toolTimeouts, &ds.Disabled, &ds.Source, &createdAt, &updatedAt,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, store.ErrNotFound
@@ -151,6 +184,9 @@ func scanDownstreamServer(row *sql.Row) (*store.DownstreamServer, error) {
 	}
 	ds.Args = json.RawMessage(args)
 	ds.CapabilitiesCache = json.RawMessage(caps)
+	unmarshalServerEnv(&ds, env, passthrough)
+	ds.Sandbox = unmarshalSandbox(sandbox)
+	ds.ToolTimeouts = unmarshalToolTimeouts(toolTimeouts)
 	ds.CreatedAt = parseTime(createdAt)
 	ds.UpdatedAt = parseTime(updatedAt)
 	return &ds, nil
@@ -158,19 +194,92 @@ func scanDownstreamServer(row *sql.Row) (*store.DownstreamServer, error) {
 
 func scanDownstreamServerRow(row rowScanner) (*store.DownstreamServer, error) {
 	var ds store.DownstreamServer
-	var createdAt, updatedAt, args, caps string
+	var createdAt, updatedAt, args, caps, env, toolTimeouts string
+	var passthrough, sandbox sql.NullString
 	err := row.Scan(
 		&ds.ID, &ds.Name, &ds.Transport, &ds.Command, &args,
 		&ds.URL, &ds.ToolNamespace, &ds.Discovery, &caps,
 		&ds.IdleTimeoutSec, &ds.MaxInstances, &ds.RestartPolicy,
-		&ds.Disabled, &ds.Source, &createdAt, &updatedAt,
+		&ds.AnnotationTrust, &env, &passthrough, &ds.WorkingDir, &ds.InstanceScope,
+		&sandbox, &ds.HealthIntervalSec, &ds.HealthTimeoutSec, &ds.InitTimeoutSec,
+		&ds.CallTimeoutSec, &toolTimeouts, &ds.Disabled, &ds.Source, &createdAt, &updatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
 	ds.Args = json.RawMessage(args)
 	ds.CapabilitiesCache = json.RawMessage(caps)
+	unmarshalServerEnv(&ds, env, passthrough)
+	ds.Sandbox = unmarshalSandbox(sandbox)
+	ds.ToolTimeouts = unmarshalToolTimeouts(toolTimeouts)
 	ds.CreatedAt = parseTime(createdAt)
 	ds.UpdatedAt = parseTime(updatedAt)
 	return &ds, nil
 }
+
+// marshalServerEnv encodes a server's env map; nil is stored as {}.
+func marshalServerEnv(env map[string]string) string {
+	if len(env) == 0 {
+		return "{}"
+	}
+	data, _ := json.Marshal(env)
+	return string(data)
+}
+
+// marshalToolTimeouts encodes per-tool timeouts; nil is stored as {}.
+func marshalToolTimeouts(timeouts map[string]int) string {
+	if len(timeouts) == 0 {
+		return "{}"
+	}
+	data, _ := json.Marshal(timeouts)
+	return string(data)
+}
+
+func unmarshalToolTimeouts(s string) map[string]int {
+	if s == "" || s == "{}" {
+		return nil
+	}
+	var timeouts map[string]int
+	_ = json.Unmarshal([]byte(s), &timeouts)
+	return timeouts
+}
+
+// marshalEnvPassthrough encodes the passthrough allowlist. Nil is stored
+// as NULL (inherit everything); an empty list inherits nothing.
+func marshalEnvPassthrough(names []string) any {
+	if names == nil {
+		return nil
+	}
+	data, _ := json.Marshal(names)
+	return string(data)
+}
+
+func unmarshalServerEnv(ds *store.DownstreamServer, env string, passthrough sql.NullString) {
+	if env != "" && env != "{}" {
+		_ = json.Unmarshal([]byte(env), &ds.Env)
+	}
+	if passthrough.Valid {
+		ds.EnvPassthrough = []string{}
+		_ = json.Unmarshal([]byte(passthrough.String), &ds.EnvPassthrough)
+	}
+}
+
+// marshalSandbox encodes a sandbox profile; nil is stored as NULL.
+func marshalSandbox(p *store.SandboxProfile) any {
+	if p == nil {
+		return nil
+	}
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+func unmarshalSandbox(s sql.NullString) *store.SandboxProfile {
+	if !s.Valid {
+		return nil
+	}
+	var p store.SandboxProfile
+	if err := json.Unmarshal([]byte(s.String), &p); err != nil {
+		return nil
+	}
+	return &p
+}
@@ -66,3 +66,17 @@ func mapConstraintError(err error) error {
 	}
 	return err
 }
+
+func formatAnnotationPolicy(p store.AnnotationPolicy) string {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+func parseAnnotationPolicy(s string) store.AnnotationPolicy {
+	var p store.AnnotationPolicy
+	_ = json.Unmarshal([]byte(s), &p)
+	return p
+}
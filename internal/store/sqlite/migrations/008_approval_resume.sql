@@ -0,0 +1,6 @@
+-- Persist the originating call so pending approvals survive daemon restarts
+-- and can be resumed, and track execution so an approval is used only once.
+ALTER TABLE tool_approvals ADD COLUMN call_params TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE tool_approvals ADD COLUMN executed_at TEXT;
+
+CREATE INDEX idx_tool_approvals_resume ON tool_approvals(workspace_id, tool_name, status);
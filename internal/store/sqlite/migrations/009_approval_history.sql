@@ -0,0 +1,8 @@
+-- Link approvals to the audit record of the call they gated (both ways) and
+-- index the columns used by the approval history filters.
+ALTER TABLE tool_approvals ADD COLUMN audit_record_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_records ADD COLUMN approval_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_tool_approvals_created ON tool_approvals(created_at);
+CREATE INDEX idx_tool_approvals_tool_created ON tool_approvals(tool_name, created_at);
+CREATE INDEX idx_audit_approval ON audit_records(approval_id) WHERE approval_id != '';
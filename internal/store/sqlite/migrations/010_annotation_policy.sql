@@ -0,0 +1,6 @@
+-- Approval policies derived from MCP tool annotations (readOnlyHint,
+-- destructiveHint), set per workspace and overridable per route, plus a
+-- per-downstream switch to ignore annotations from untrusted servers.
+ALTER TABLE workspaces ADD COLUMN annotation_policy TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE route_rules ADD COLUMN annotation_policy TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE downstream_servers ADD COLUMN annotation_trust TEXT NOT NULL DEFAULT 'trusted';
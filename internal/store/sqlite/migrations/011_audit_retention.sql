@@ -0,0 +1,22 @@
+-- Per-workspace audit retention overrides (0 = use the global policy).
+ALTER TABLE workspaces ADD COLUMN audit_retention_days INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE workspaces ADD COLUMN audit_max_rows INTEGER NOT NULL DEFAULT 0;
+
+-- Minute-level aggregates of pruned audit records, so stats and the dashboard
+-- time series keep covering history after raw rows are deleted.
+CREATE TABLE audit_rollups (
+    bucket         TEXT NOT NULL,              -- minute, RFC3339
+    workspace_id   TEXT NOT NULL DEFAULT '',
+    total          INTEGER NOT NULL DEFAULT 0,
+    success_count  INTEGER NOT NULL DEFAULT 0,
+    error_count    INTEGER NOT NULL DEFAULT 0,
+    latency_sum_ms INTEGER NOT NULL DEFAULT 0,
+    latency_max_ms INTEGER NOT NULL DEFAULT 0,
+    latency_hist   TEXT NOT NULL DEFAULT '[]', -- counts per latencyBucketsMs bound
+    session_ids    TEXT NOT NULL DEFAULT '[]',
+    server_ids     TEXT NOT NULL DEFAULT '[]',
+    PRIMARY KEY (bucket, workspace_id)
+);
+
+CREATE INDEX idx_audit_timestamp ON audit_records(timestamp);
+CREATE INDEX idx_tool_approvals_resolved ON tool_approvals(resolved_at) WHERE status != 'pending';
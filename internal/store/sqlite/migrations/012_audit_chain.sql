@@ -0,0 +1,40 @@
+-- Hash chain over audit records: each record stores its sequence number, the
+-- hash of its predecessor and its own hash. Existing rows are chained on the
+-- next startup.
+ALTER TABLE audit_records ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE audit_records ADD COLUMN prev_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_records ADD COLUMN hash TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_audit_seq ON audit_records(seq);
+
+-- Latest sequence number and hash; survives pruning of the newest records.
+CREATE TABLE audit_chain_head (
+    id   INTEGER PRIMARY KEY CHECK (id = 1),
+    seq  INTEGER NOT NULL DEFAULT 0,
+    hash TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO audit_chain_head (id, seq, hash) VALUES (1, 0, '');
+
+-- Contiguous runs of records removed by retention. The boundary hashes keep
+-- the chain verifiable across them.
+CREATE TABLE audit_chain_gaps (
+    first_seq INTEGER PRIMARY KEY,
+    last_seq  INTEGER NOT NULL,
+    prev_hash TEXT NOT NULL, -- prev_hash of the first pruned record
+    last_hash TEXT NOT NULL, -- hash of the last pruned record
+    pruned_at TEXT NOT NULL
+);
+
+CREATE INDEX idx_audit_chain_gaps_last ON audit_chain_gaps(last_seq);
+
+-- Signed checkpoints of the chain head.
+CREATE TABLE audit_checkpoints (
+    id         TEXT PRIMARY KEY,
+    seq        INTEGER NOT NULL,
+    hash       TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    signature  TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX idx_audit_checkpoints_seq ON audit_checkpoints(seq);
@@ -0,0 +1,36 @@
+-- Full-text index over audit tool names, redacted params and error messages.
+-- Contentless: rows are keyed by the record's chain sequence number, which,
+-- unlike the implicit rowid, is stable across VACUUM.
+CREATE VIRTUAL TABLE audit_fts USING fts5(
+    tool_name,
+    params,
+    error_message,
+    content = '',
+    contentless_delete = 1,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+INSERT INTO audit_fts (rowid, tool_name, params, error_message)
+    SELECT seq, tool_name, params_redacted, error_message
+    FROM audit_records WHERE seq > 0;
+
+CREATE TRIGGER audit_fts_insert AFTER INSERT ON audit_records
+WHEN new.seq > 0 BEGIN
+    INSERT INTO audit_fts (rowid, tool_name, params, error_message)
+    VALUES (new.seq, new.tool_name, new.params_redacted, new.error_message);
+END;
+
+CREATE TRIGGER audit_fts_delete AFTER DELETE ON audit_records
+WHEN old.seq > 0 BEGIN
+    DELETE FROM audit_fts WHERE rowid = old.seq;
+END;
+
+-- Also indexes legacy records as they are chained on startup.
+CREATE TRIGGER audit_fts_update
+AFTER UPDATE OF seq, tool_name, params_redacted, error_message ON audit_records
+BEGIN
+    DELETE FROM audit_fts WHERE rowid = old.seq AND old.seq > 0;
+    INSERT INTO audit_fts (rowid, tool_name, params, error_message)
+    SELECT new.seq, new.tool_name, new.params_redacted, new.error_message
+    WHERE new.seq > 0;
+END;
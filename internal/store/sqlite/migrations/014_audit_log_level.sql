@@ -0,0 +1,5 @@
+-- Capture depth per audit record, following the route's log_level. Response
+-- and exchange are only filled at the full and debug levels.
+ALTER TABLE audit_records ADD COLUMN log_level TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_records ADD COLUMN response TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_records ADD COLUMN exchange TEXT NOT NULL DEFAULT '';
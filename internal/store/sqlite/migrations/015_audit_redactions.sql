@@ -0,0 +1,3 @@
+-- Where values were redacted in each audit record, as a JSON array of
+-- {path, detector}. Empty when nothing was redacted.
+ALTER TABLE audit_records ADD COLUMN redactions TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- Indexes for the audit query filters and keyset sort orders. Keyset pages
+-- order by (timestamp, id) or (latency_ms, id), so the plain timestamp index
+-- is replaced by one that also covers the tie-breaker.
+DROP INDEX IF EXISTS idx_audit_timestamp;
+CREATE INDEX idx_audit_timestamp_id ON audit_records(timestamp, id);
+CREATE INDEX idx_audit_latency_id ON audit_records(latency_ms, id);
+
+CREATE INDEX idx_audit_server_ts ON audit_records(downstream_server_id, timestamp);
+CREATE INDEX idx_audit_route_ts ON audit_records(route_rule_id, timestamp);
+CREATE INDEX idx_audit_scope_ts ON audit_records(auth_scope_id, timestamp);
+CREATE INDEX idx_audit_client_ts ON audit_records(client_type, timestamp);
+CREATE INDEX idx_audit_error_code_ts ON audit_records(error_code, timestamp);
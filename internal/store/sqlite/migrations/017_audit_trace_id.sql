@@ -0,0 +1,5 @@
+-- Trace ID of the tool call span, as 32 hex characters, so audit records
+-- can be joined with exported traces. Empty when no span was recorded.
+ALTER TABLE audit_records ADD COLUMN trace_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_audit_trace_id ON audit_records(trace_id) WHERE trace_id != '';
@@ -0,0 +1,176 @@
+-- Hourly and daily usage aggregates, kept up to date as audit records are
+-- inserted. Each row covers one combination of workspace, tool, server,
+-- client and model in a bucket, so any grouping is a SUM over rows. The
+-- hist_N columns count calls per latency bucket (see latencyBucketsMs) for
+-- percentiles.
+CREATE TABLE usage_rollups (
+    granularity          TEXT NOT NULL, -- hour or day
+    bucket               TEXT NOT NULL, -- bucket start, RFC3339 UTC
+    workspace_id         TEXT NOT NULL DEFAULT '',
+    tool_name            TEXT NOT NULL DEFAULT '',
+    downstream_server_id TEXT NOT NULL DEFAULT '',
+    client_type          TEXT NOT NULL DEFAULT '',
+    model                TEXT NOT NULL DEFAULT '',
+    calls                INTEGER NOT NULL DEFAULT 0,
+    error_count          INTEGER NOT NULL DEFAULT 0,
+    latency_sum_ms       INTEGER NOT NULL DEFAULT 0,
+    latency_max_ms       INTEGER NOT NULL DEFAULT 0,
+    response_bytes       INTEGER NOT NULL DEFAULT 0,
+    hist_0               INTEGER NOT NULL DEFAULT 0,
+    hist_1               INTEGER NOT NULL DEFAULT 0,
+    hist_2               INTEGER NOT NULL DEFAULT 0,
+    hist_3               INTEGER NOT NULL DEFAULT 0,
+    hist_4               INTEGER NOT NULL DEFAULT 0,
+    hist_5               INTEGER NOT NULL DEFAULT 0,
+    hist_6               INTEGER NOT NULL DEFAULT 0,
+    hist_7               INTEGER NOT NULL DEFAULT 0,
+    hist_8               INTEGER NOT NULL DEFAULT 0,
+    hist_9               INTEGER NOT NULL DEFAULT 0,
+    hist_10              INTEGER NOT NULL DEFAULT 0,
+    hist_11              INTEGER NOT NULL DEFAULT 0,
+    hist_12              INTEGER NOT NULL DEFAULT 0,
+    hist_13              INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (granularity, bucket, workspace_id, tool_name,
+                 downstream_server_id, client_type, model)
+);
+
+CREATE INDEX idx_usage_rollups_tool ON usage_rollups(granularity, tool_name, bucket);
+CREATE INDEX idx_usage_rollups_server ON usage_rollups(granularity, downstream_server_id, bucket);
+
+-- Backfill hours from existing audit records.
+INSERT INTO usage_rollups
+    (granularity, bucket, workspace_id, tool_name, downstream_server_id,
+     client_type, model, calls, error_count, latency_sum_ms, latency_max_ms,
+     response_bytes, hist_0, hist_1, hist_2, hist_3, hist_4, hist_5, hist_6,
+     hist_7, hist_8, hist_9, hist_10, hist_11, hist_12, hist_13)
+SELECT 'hour', strftime('%Y-%m-%dT%H:00:00Z', timestamp), workspace_id, tool_name,
+       downstream_server_id, client_type, model, COUNT(*),
+       SUM(status = 'error'), SUM(latency_ms), MAX(latency_ms), SUM(response_size),
+       SUM(latency_ms <= 5),
+       SUM(latency_ms > 5 AND latency_ms <= 10),
+       SUM(latency_ms > 10 AND latency_ms <= 25),
+       SUM(latency_ms > 25 AND latency_ms <= 50),
+       SUM(latency_ms > 50 AND latency_ms <= 100),
+       SUM(latency_ms > 100 AND latency_ms <= 250),
+       SUM(latency_ms > 250 AND latency_ms <= 500),
+       SUM(latency_ms > 500 AND latency_ms <= 1000),
+       SUM(latency_ms > 1000 AND latency_ms <= 2500),
+       SUM(latency_ms > 2500 AND latency_ms <= 5000),
+       SUM(latency_ms > 5000 AND latency_ms <= 10000),
+       SUM(latency_ms > 10000 AND latency_ms <= 30000),
+       SUM(latency_ms > 30000 AND latency_ms <= 60000),
+       SUM(latency_ms > 60000)
+FROM audit_records
+GROUP BY 2, 3, 4, 5, 6, 7;
+
+-- Backfill days from existing audit records.
+INSERT INTO usage_rollups
+    (granularity, bucket, workspace_id, tool_name, downstream_server_id,
+     client_type, model, calls, error_count, latency_sum_ms, latency_max_ms,
+     response_bytes, hist_0, hist_1, hist_2, hist_3, hist_4, hist_5, hist_6,
+     hist_7, hist_8, hist_9, hist_10, hist_11, hist_12, hist_13)
+SELECT 'day', strftime('%Y-%m-%dT00:00:00Z', timestamp), workspace_id, tool_name,
+       downstream_server_id, client_type, model, COUNT(*),
+       SUM(status = 'error'), SUM(latency_ms), MAX(latency_ms), SUM(response_size),
+       SUM(latency_ms <= 5),
+       SUM(latency_ms > 5 AND latency_ms <= 10),
+       SUM(latency_ms > 10 AND latency_ms <= 25),
+       SUM(latency_ms > 25 AND latency_ms <= 50),
+       SUM(latency_ms > 50 AND latency_ms <= 100),
+       SUM(latency_ms > 100 AND latency_ms <= 250),
+       SUM(latency_ms > 250 AND latency_ms <= 500),
+       SUM(latency_ms > 500 AND latency_ms <= 1000),
+       SUM(latency_ms > 1000 AND latency_ms <= 2500),
+       SUM(latency_ms > 2500 AND latency_ms <= 5000),
+       SUM(latency_ms > 5000 AND latency_ms <= 10000),
+       SUM(latency_ms > 10000 AND latency_ms <= 30000),
+       SUM(latency_ms > 30000 AND latency_ms <= 60000),
+       SUM(latency_ms > 60000)
+FROM audit_records
+GROUP BY 2, 3, 4, 5, 6, 7;
+
+-- Fold in the minute rollups of already pruned records. They only keep the
+-- workspace, so the other dimensions stay empty.
+INSERT INTO usage_rollups
+    (granularity, bucket, workspace_id, calls, error_count, latency_sum_ms,
+     latency_max_ms, hist_0, hist_1, hist_2, hist_3, hist_4, hist_5, hist_6,
+     hist_7, hist_8, hist_9, hist_10, hist_11, hist_12, hist_13)
+SELECT 'hour', strftime('%Y-%m-%dT%H:00:00Z', bucket), workspace_id, SUM(total),
+       SUM(error_count), SUM(latency_sum_ms), MAX(latency_max_ms),
+       SUM(COALESCE(json_extract(latency_hist, '$[0]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[1]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[2]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[3]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[4]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[5]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[6]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[7]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[8]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[9]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[10]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[11]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[12]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[13]'), 0))
+FROM audit_rollups
+GROUP BY 2, 3
+ON CONFLICT DO UPDATE SET
+    calls = calls + excluded.calls,
+    error_count = error_count + excluded.error_count,
+    latency_sum_ms = latency_sum_ms + excluded.latency_sum_ms,
+    latency_max_ms = max(latency_max_ms, excluded.latency_max_ms),
+    hist_0 = hist_0 + excluded.hist_0,
+    hist_1 = hist_1 + excluded.hist_1,
+    hist_2 = hist_2 + excluded.hist_2,
+    hist_3 = hist_3 + excluded.hist_3,
+    hist_4 = hist_4 + excluded.hist_4,
+    hist_5 = hist_5 + excluded.hist_5,
+    hist_6 = hist_6 + excluded.hist_6,
+    hist_7 = hist_7 + excluded.hist_7,
+    hist_8 = hist_8 + excluded.hist_8,
+    hist_9 = hist_9 + excluded.hist_9,
+    hist_10 = hist_10 + excluded.hist_10,
+    hist_11 = hist_11 + excluded.hist_11,
+    hist_12 = hist_12 + excluded.hist_12,
+    hist_13 = hist_13 + excluded.hist_13;
+
+INSERT INTO usage_rollups
+    (granularity, bucket, workspace_id, calls, error_count, latency_sum_ms,
+     latency_max_ms, hist_0, hist_1, hist_2, hist_3, hist_4, hist_5, hist_6,
+     hist_7, hist_8, hist_9, hist_10, hist_11, hist_12, hist_13)
+SELECT 'day', strftime('%Y-%m-%dT00:00:00Z', bucket), workspace_id, SUM(total),
+       SUM(error_count), SUM(latency_sum_ms), MAX(latency_max_ms),
+       SUM(COALESCE(json_extract(latency_hist, '$[0]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[1]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[2]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[3]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[4]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[5]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[6]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[7]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[8]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[9]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[10]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[11]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[12]'), 0)),
+       SUM(COALESCE(json_extract(latency_hist, '$[13]'), 0))
+FROM audit_rollups
+GROUP BY 2, 3
+ON CONFLICT DO UPDATE SET
+    calls = calls + excluded.calls,
+    error_count = error_count + excluded.error_count,
+    latency_sum_ms = latency_sum_ms + excluded.latency_sum_ms,
+    latency_max_ms = max(latency_max_ms, excluded.latency_max_ms),
+    hist_0 = hist_0 + excluded.hist_0,
+    hist_1 = hist_1 + excluded.hist_1,
+    hist_2 = hist_2 + excluded.hist_2,
+    hist_3 = hist_3 + excluded.hist_3,
+    hist_4 = hist_4 + excluded.hist_4,
+    hist_5 = hist_5 + excluded.hist_5,
+    hist_6 = hist_6 + excluded.hist_6,
+    hist_7 = hist_7 + excluded.hist_7,
+    hist_8 = hist_8 + excluded.hist_8,
+    hist_9 = hist_9 + excluded.hist_9,
+    hist_10 = hist_10 + excluded.hist_10,
+    hist_11 = hist_11 + excluded.hist_11,
+    hist_12 = hist_12 + excluded.hist_12,
+    hist_13 = hist_13 + excluded.hist_13;
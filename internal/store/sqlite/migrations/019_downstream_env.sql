@@ -0,0 +1,6 @@
+-- Per-server process settings for stdio downstreams: extra environment
+-- variables, an allowlist of inherited OS variables (NULL inherits all)
+-- and the working directory.
+ALTER TABLE downstream_servers ADD COLUMN env TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE downstream_servers ADD COLUMN env_passthrough TEXT;
+ALTER TABLE downstream_servers ADD COLUMN working_dir TEXT NOT NULL DEFAULT '';
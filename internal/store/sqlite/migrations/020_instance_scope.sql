@@ -0,0 +1,3 @@
+-- How widely a downstream server's instances are shared: one per auth
+-- scope ("global"), per workspace, or per client session.
+ALTER TABLE downstream_servers ADD COLUMN instance_scope TEXT NOT NULL DEFAULT 'global';
@@ -0,0 +1,3 @@
+-- Optional sandbox profile for stdio downstreams on Linux, stored as JSON.
+-- NULL runs the process unsandboxed.
+ALTER TABLE downstream_servers ADD COLUMN sandbox TEXT;
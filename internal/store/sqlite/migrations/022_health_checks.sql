@@ -0,0 +1,4 @@
+-- Health probe settings per downstream server. 0 uses the gateway's
+-- defaults; a negative interval disables probes.
+ALTER TABLE downstream_servers ADD COLUMN health_interval_sec INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE downstream_servers ADD COLUMN health_timeout_sec INTEGER NOT NULL DEFAULT 0;
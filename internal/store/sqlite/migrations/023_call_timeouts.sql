@@ -0,0 +1,6 @@
+-- Timeouts per downstream server. 0 uses the gateway's defaults; a
+-- negative call timeout lets calls run as long as the client waits.
+-- tool_timeouts maps tool names to their own call timeout in seconds.
+ALTER TABLE downstream_servers ADD COLUMN init_timeout_sec INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE downstream_servers ADD COLUMN call_timeout_sec INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE downstream_servers ADD COLUMN tool_timeouts TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,5 @@
+-- Ranges pruned by retention are signed with the audit checkpoint key so a
+-- forged gap cannot hide deleted records. Gaps recorded before this
+-- migration stay unsigned and are reported by verification.
+ALTER TABLE audit_chain_gaps ADD COLUMN public_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_chain_gaps ADD COLUMN signature TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,360 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// latencyBucketsMs are the upper bounds of the latency histogram kept in
+// audit_rollups. The histogram has one extra slot for latencies above the
+// last bound.
+var latencyBucketsMs = []int{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}
+
+// pruneChunkSize bounds the number of ids bound to a single DELETE.
+const pruneChunkSize = 500
+
+// latencyBucket returns the histogram slot for a latency.
+func latencyBucket(ms int) int {
+	for i, bound := range latencyBucketsMs {
+		if ms <= bound {
+			return i
+		}
+	}
+	return len(latencyBucketsMs)
+}
+
+// latencyBucketSQL is a CASE expression mapping latency_ms to its histogram
+// slot, matching latencyBucket.
+func latencyBucketSQL() string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for i, bound := range latencyBucketsMs {
+		fmt.Fprintf(&b, " WHEN latency_ms <= %d THEN %d", bound, i)
+	}
+	fmt.Fprintf(&b, " ELSE %d END", len(latencyBucketsMs))
+	return b.String()
+}
+
+// histogramPercentile returns the upper bound of the bucket holding the p-th
+// percentile. Latencies beyond the last bound report maxMs.
+func histogramPercentile(hist []int, p float64, maxMs int) int {
+	var total int
+	for _, n := range hist {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := int(float64(total)*p + 0.999999)
+	var seen int
+	for i, n := range hist {
+		seen += n
+		if seen >= rank {
+			if i < len(latencyBucketsMs) {
+				return min(latencyBucketsMs[i], maxMs)
+			}
+			return maxMs
+		}
+	}
+	return maxMs
+}
+
+// auditRollup is the in-memory form of an audit_rollups row.
+type auditRollup struct {
+	Total        int
+	SuccessCount int
+	ErrorCount   int
+	LatencySumMs int64
+	LatencyMaxMs int
+	Hist         []int
+	Sessions     map[string]struct{}
+	Servers      map[string]struct{}
+}
+
+func newAuditRollup() *auditRollup {
+	return &auditRollup{
+		Hist:     make([]int, len(latencyBucketsMs)+1),
+		Sessions: map[string]struct{}{},
+		Servers:  map[string]struct{}{},
+	}
+}
+
+func (a *auditRollup) add(r store.AuditRecord) {
+	a.Total++
+	switch r.Status {
+	case "success":
+		a.SuccessCount++
+	case "error", "timeout":
+		a.ErrorCount++
+	}
+	a.LatencySumMs += int64(r.LatencyMs)
+	a.LatencyMaxMs = max(a.LatencyMaxMs, r.LatencyMs)
+	a.Hist[latencyBucket(r.LatencyMs)]++
+	a.Sessions[r.SessionID] = struct{}{}
+	a.Servers[r.DownstreamServerID] = struct{}{}
+}
+
+func (a *auditRollup) merge(o *auditRollup) {
+	a.Total += o.Total
+	a.SuccessCount += o.SuccessCount
+	a.ErrorCount += o.ErrorCount
+	a.LatencySumMs += o.LatencySumMs
+	a.LatencyMaxMs = max(a.LatencyMaxMs, o.LatencyMaxMs)
+	for i := range o.Hist {
+		if i < len(a.Hist) {
+			a.Hist[i] += o.Hist[i]
+		}
+	}
+	for id := range o.Sessions {
+		a.Sessions[id] = struct{}{}
+	}
+	for id := range o.Servers {
+		a.Servers[id] = struct{}{}
+	}
+}
+
+func (a *auditRollup) scan(row rowScanner) error {
+	var hist, sessions, servers string
+	if err := row.Scan(
+		&a.Total, &a.SuccessCount, &a.ErrorCount, &a.LatencySumMs,
+		&a.LatencyMaxMs, &hist, &sessions, &servers,
+	); err != nil {
+		return err
+	}
+	var h []int
+	var sess, srvs []string
+	_ = json.Unmarshal([]byte(hist), &h)
+	_ = json.Unmarshal([]byte(sessions), &sess)
+	_ = json.Unmarshal([]byte(servers), &srvs)
+	copy(a.Hist, h)
+	for _, id := range sess {
+		a.Sessions[id] = struct{}{}
+	}
+	for _, id := range srvs {
+		a.Servers[id] = struct{}{}
+	}
+	return nil
+}
+
+const rollupColumns = `total, success_count, error_count, latency_sum_ms,
+	latency_max_ms, latency_hist, session_ids, server_ids`
+
+func sortedKeys(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// inTx runs fn against the active transaction, or a new one when d is not
+// already inside Tx.
+func (d *DB) inTx(ctx context.Context, fn func(q queryable) error) error {
+	if _, ok := d.q.(*sql.Tx); ok {
+		return fn(d.q)
+	}
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *DB) ListExpiredAuditRecords(
+	ctx context.Context, f store.AuditPruneFilter, limit int,
+) ([]store.AuditRecord, error) {
+	if f.Before == nil && f.KeepNewest <= 0 {
+		return nil, nil
+	}
+
+	var scope []string
+	var scopeArgs []any
+	if f.WorkspaceID != nil {
+		scope = append(scope, "workspace_id = ?")
+		scopeArgs = append(scopeArgs, *f.WorkspaceID)
+	}
+	if len(f.ExcludeWorkspaceIDs) > 0 {
+		scope = append(scope, "workspace_id NOT IN ("+placeholders(len(f.ExcludeWorkspaceIDs))+")")
+		for _, id := range f.ExcludeWorkspaceIDs {
+			scopeArgs = append(scopeArgs, id)
+		}
+	}
+
+	conds := append([]string{}, scope...)
+	args := append([]any{}, scopeArgs...)
+	if f.Before != nil {
+		conds = append(conds, "timestamp < ?")
+		args = append(args, formatTime(*f.Before))
+	}
+	if f.KeepNewest > 0 {
+		inner := "SELECT id FROM audit_records"
+		if len(scope) > 0 {
+			inner += " WHERE " + strings.Join(scope, " AND ")
+		}
+		inner += " ORDER BY timestamp DESC, id DESC LIMIT -1 OFFSET ?"
+		conds = append(conds, "id IN ("+inner+")")
+		args = append(args, scopeArgs...)
+		args = append(args, f.KeepNewest)
+	}
+	if limit <= 0 {
+		limit = 1000
+	}
+	args = append(args, limit)
+
+	rows, err := d.q.QueryContext(ctx, `SELECT `+auditChainColumns+`
+		FROM audit_records
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY timestamp ASC, id ASC LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []store.AuditRecord
+	for rows.Next() {
+		r, err := scanAuditRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *r)
+	}
+	return out, rows.Err()
+}
+
+// PruneAuditRecords folds the records into minute-level audit_rollups and
+// deletes them, recording the chain gaps they leave, in one transaction.
+func (d *DB) PruneAuditRecords(ctx context.Context, records []store.AuditRecord, sign store.AuditGapSigner) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	type rollupKey struct{ bucket, workspaceID string }
+	groups := map[rollupKey]*auditRollup{}
+	ids := make([]any, 0, len(records))
+	for _, r := range records {
+		key := rollupKey{formatTime(r.Timestamp.Truncate(time.Minute)), r.WorkspaceID}
+		agg, ok := groups[key]
+		if !ok {
+			agg = newAuditRollup()
+			groups[key] = agg
+		}
+		agg.add(r)
+		ids = append(ids, r.ID)
+	}
+
+	return d.inTx(ctx, func(q queryable) error {
+		for key, agg := range groups {
+			existing := newAuditRollup()
+			err := existing.scan(q.QueryRowContext(ctx,
+				`SELECT `+rollupColumns+` FROM audit_rollups WHERE bucket = ? AND workspace_id = ?`,
+				key.bucket, key.workspaceID,
+			))
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("load audit rollup: %w", err)
+			}
+			existing.merge(agg)
+
+			hist, _ := json.Marshal(existing.Hist)
+			sessions, _ := json.Marshal(sortedKeys(existing.Sessions))
+			servers, _ := json.Marshal(sortedKeys(existing.Servers))
+			_, err = q.ExecContext(ctx, `
+				INSERT INTO audit_rollups
+					(bucket, workspace_id, `+rollupColumns+`)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (bucket, workspace_id) DO UPDATE SET
+					total = excluded.total,
+					success_count = excluded.success_count,
+					error_count = excluded.error_count,
+					latency_sum_ms = excluded.latency_sum_ms,
+					latency_max_ms = excluded.latency_max_ms,
+					latency_hist = excluded.latency_hist,
+					session_ids = excluded.session_ids,
+					server_ids = excluded.server_ids`,
+				key.bucket, key.workspaceID, existing.Total, existing.SuccessCount,
+				existing.ErrorCount, existing.LatencySumMs, existing.LatencyMaxMs,
+				string(hist), string(sessions), string(servers),
+			)
+			if err != nil {
+				return fmt.Errorf("upsert audit rollup: %w", err)
+			}
+		}
+
+		for start := 0; start < len(ids); start += pruneChunkSize {
+			chunk := ids[start:min(start+pruneChunkSize, len(ids))]
+			if _, err := q.ExecContext(ctx,
+				"DELETE FROM audit_records WHERE id IN ("+placeholders(len(chunk))+")",
+				chunk...,
+			); err != nil {
+				return fmt.Errorf("delete audit records: %w", err)
+			}
+		}
+		return recordChainGaps(ctx, q, records, sign)
+	})
+}
+
+func (d *DB) PruneSessions(ctx context.Context, before time.Time) (int, error) {
+	res, err := d.q.ExecContext(ctx,
+		`DELETE FROM sessions WHERE disconnected_at IS NOT NULL AND disconnected_at < ?`,
+		formatTime(before),
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (d *DB) PruneToolApprovals(ctx context.Context, before time.Time) (int, error) {
+	res, err := d.q.ExecContext(ctx,
+		`DELETE FROM tool_approvals
+		 WHERE status != 'pending' AND resolved_at IS NOT NULL AND resolved_at < ?`,
+		formatTime(before),
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (d *DB) DatabaseSize(ctx context.Context) (int64, error) {
+	var pages, pageSize int64
+	if err := d.q.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages); err != nil {
+		return 0, err
+	}
+	if err := d.q.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return pages * pageSize, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// prefixScanner scans a leading column into first before the columns read by
+// the wrapped consumer.
+type prefixScanner struct {
+	row   rowScanner
+	first any
+}
+
+func (p prefixScanner) Scan(dest ...any) error {
+	return p.row.Scan(append([]any{p.first}, dest...)...)
+}
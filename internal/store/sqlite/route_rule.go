@@ -28,12 +28,13 @@ func (d *DB) CreateRouteRule(ctx context.Context, r *store.RouteRule) error {
 		INSERT INTO route_rules
 			(id, name, priority, workspace_id, path_glob, tool_match,
 			 downstream_server_id, auth_scope_id, policy, log_level,
-			 requires_approval, approval_timeout,
+			 requires_approval, approval_timeout, annotation_policy,
 			 source, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		r.ID, r.Name, r.Priority, r.WorkspaceID, r.PathGlob, toolMatch,
 		r.DownstreamServerID, r.AuthScopeID, r.Policy, r.LogLevel,
 		boolToInt(r.RequiresApproval), r.ApprovalTimeout,
+		formatAnnotationPolicy(r.AnnotationPolicy),
 		r.Source, formatTime(r.CreatedAt), formatTime(r.UpdatedAt),
 	)
 	if err != nil {
@@ -46,7 +47,7 @@ func (d *DB) GetRouteRule(ctx context.Context, id string) (*store.RouteRule, err
 	row := d.q.QueryRowContext(ctx, `
 		SELECT id, name, priority, workspace_id, path_glob, tool_match,
 		       downstream_server_id, auth_scope_id, policy, log_level,
-		       requires_approval, approval_timeout,
+		       requires_approval, approval_timeout, annotation_policy,
 		       source, created_at, updated_at
 		FROM route_rules WHERE id = ?`, id)
 	return scanRouteRule(row)
@@ -59,7 +60,7 @@ func (d *DB) ListRouteRules(ctx context.Context, workspaceID string) ([]store.Ro
 		rows, err = d.q.QueryContext(ctx, `
 			SELECT id, name, priority, workspace_id, path_glob, tool_match,
 			       downstream_server_id, auth_scope_id, policy, log_level,
-			       requires_approval, approval_timeout,
+			       requires_approval, approval_timeout, annotation_policy,
 			       source, created_at, updated_at
 			FROM route_rules
 			WHERE workspace_id = ?
@@ -68,7 +69,7 @@ func (d *DB) ListRouteRules(ctx context.Context, workspaceID string) ([]store.Ro
 		rows, err = d.q.QueryContext(ctx, `
 			SELECT id, name, priority, workspace_id, path_glob, tool_match,
 			       downstream_server_id, auth_scope_id, policy, log_level,
-			       requires_approval, approval_timeout,
+			       requires_approval, approval_timeout, annotation_policy,
 			       source, created_at, updated_at
 			FROM route_rules
 			ORDER BY priority DESC, id ASC`)
@@ -101,11 +102,12 @@ func (d *DB) UpdateRouteRule(ctx context.Context, r *store.RouteRule) error {
 		SET name = ?, priority = ?, workspace_id = ?, path_glob = ?, tool_match = ?,
 		    downstream_server_id = ?, auth_scope_id = ?, policy = ?,
 		    log_level = ?, requires_approval = ?, approval_timeout = ?,
-		    source = ?, updated_at = ?
+		    annotation_policy = ?, source = ?, updated_at = ?
 		WHERE id = ?`,
 		r.Name, r.Priority, r.WorkspaceID, r.PathGlob, toolMatch,
 		r.DownstreamServerID, r.AuthScopeID, r.Policy,
 		r.LogLevel, boolToInt(r.RequiresApproval), r.ApprovalTimeout,
+		formatAnnotationPolicy(r.AnnotationPolicy),
 		r.Source, formatTime(r.UpdatedAt), r.ID,
 	)
 	if err != nil {
@@ -124,12 +126,12 @@ func (d *DB) DeleteRouteRule(ctx context.Context, id string) error {
 
 func scanRouteRule(row *sql.Row) (*store.RouteRule, error) {
 	var r store.RouteRule
-	var createdAt, updatedAt, toolMatch string
+	var createdAt, updatedAt, toolMatch, annotationPolicy string
 	var requiresApproval int
 	err := row.Scan(
 		&r.ID, &r.Name, &r.Priority, &r.WorkspaceID, &r.PathGlob, &toolMatch,
 		&r.DownstreamServerID, &r.AuthScopeID, &r.Policy, &r.LogLevel,
-		&requiresApproval, &r.ApprovalTimeout,
+		&requiresApproval, &r.ApprovalTimeout, &annotationPolicy,
 		&r.Source, &createdAt, &updatedAt,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -140,6 +142,7 @@ func scanRouteRule(row *sql.Row) (*store.RouteRule, error) {
 	}
 	r.ToolMatch = json.RawMessage(toolMatch)
 	r.RequiresApproval = requiresApproval != 0
+	r.AnnotationPolicy = parseAnnotationPolicy(annotationPolicy)
 	r.CreatedAt = parseTime(createdAt)
 	r.UpdatedAt = parseTime(updatedAt)
 	return &r, nil
@@ -147,12 +150,12 @@ func scanRouteRule(row *sql.Row) (*store.RouteRule, error) {
 
 func scanRouteRuleRow(row rowScanner) (*store.RouteRule, error) {
 	var r store.RouteRule
-	var createdAt, updatedAt, toolMatch string
+	var createdAt, updatedAt, toolMatch, annotationPolicy string
 	var requiresApproval int
 	err := row.Scan(
 		&r.ID, &r.Name, &r.Priority, &r.WorkspaceID, &r.PathGlob, &toolMatch,
 		&r.DownstreamServerID, &r.AuthScopeID, &r.Policy, &r.LogLevel,
-		&requiresApproval, &r.ApprovalTimeout,
+		&requiresApproval, &r.ApprovalTimeout, &annotationPolicy,
 		&r.Source, &createdAt, &updatedAt,
 	)
 	if err != nil {
@@ -160,6 +163,7 @@ func scanRouteRuleRow(row rowScanner) (*store.RouteRule, error) {
 	}
 	r.ToolMatch = json.RawMessage(toolMatch)
 	r.RequiresApproval = requiresApproval != 0
+	r.AnnotationPolicy = parseAnnotationPolicy(annotationPolicy)
 	r.CreatedAt = parseTime(createdAt)
 	r.UpdatedAt = parseTime(updatedAt)
 	return &r, nil
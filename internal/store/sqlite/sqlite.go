@@ -51,6 +51,11 @@ func New(ctx context.Context, path string) (*DB, error) {
 		return nil, fmt.Errorf("migrate: %w", err)
 	}
 
+	if err := chainLegacyAuditRecords(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("chain legacy audit records: %w", err)
+	}
+
 	return &DB{db: db, q: db}, nil
 }
 
@@ -3,6 +3,8 @@ package sqlite_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -75,6 +77,8 @@ func TestWorkspaceCRUD(t *testing.T) {
 
 	// Update.
 	got.Name = "updated-ws"
+	got.AuditRetentionDays = 7
+	got.AuditMaxRows = 1000
 	if err := db.UpdateWorkspace(ctx, got); err != nil {
 		t.Fatalf("update: %v", err)
 	}
@@ -82,6 +86,9 @@ func TestWorkspaceCRUD(t *testing.T) {
 	if got2.Name != "updated-ws" {
 		t.Fatalf("name after update = %q", got2.Name)
 	}
+	if got2.AuditRetentionDays != 7 || got2.AuditMaxRows != 1000 {
+		t.Fatalf("retention after update = %d days, %d rows", got2.AuditRetentionDays, got2.AuditMaxRows)
+	}
 
 	// Delete.
 	if err := db.DeleteWorkspace(ctx, w.ID); err != nil {
@@ -169,6 +176,8 @@ func TestDownstreamServerCRUD(t *testing.T) {
 		IdleTimeoutSec: 300,
 		MaxInstances:   1,
 		RestartPolicy:  "on-failure",
+		Env:            map[string]string{"LOG_LEVEL": "debug"},
+		WorkingDir:     "/srv/github",
 	}
 
 	if err := db.CreateDownstreamServer(ctx, ds); err != nil {
@@ -182,6 +191,18 @@ func TestDownstreamServerCRUD(t *testing.T) {
 	if got.ToolNamespace != "github" {
 		t.Fatalf("namespace = %q", got.ToolNamespace)
 	}
+	if got.Env["LOG_LEVEL"] != "debug" || got.WorkingDir != "/srv/github" {
+		t.Fatalf("env = %v, working_dir = %q", got.Env, got.WorkingDir)
+	}
+	if got.EnvPassthrough != nil {
+		t.Fatalf("env_passthrough = %v, want nil (inherit all)", got.EnvPassthrough)
+	}
+	if got.InstanceScope != "global" {
+		t.Fatalf("instance_scope = %q, want global", got.InstanceScope)
+	}
+	if got.Sandbox != nil {
+		t.Fatalf("sandbox = %+v, want nil", got.Sandbox)
+	}
 
 	got, err = db.GetDownstreamServerByName(ctx, "github-mcp")
 	if err != nil {
@@ -197,9 +218,26 @@ func TestDownstreamServerCRUD(t *testing.T) {
 	}
 
 	got.Name = "github-mcp-v2"
+	got.EnvPassthrough = []string{}
+	got.InstanceScope = "session"
+	got.Sandbox = &store.SandboxProfile{Landlock: true, WritePaths: []string{"/srv/cache"}}
+	got.HealthIntervalSec, got.HealthTimeoutSec = -1, 5
+	got.InitTimeoutSec, got.CallTimeoutSec = 45, -1
+	got.ToolTimeouts = map[string]int{"search": 300}
 	if err := db.UpdateDownstreamServer(ctx, got); err != nil {
 		t.Fatalf("update: %v", err)
 	}
+	if got, _ := db.GetDownstreamServer(ctx, ds.ID); got.EnvPassthrough == nil || len(got.EnvPassthrough) != 0 {
+		t.Fatalf("env_passthrough = %#v, want empty (inherit none)", got.EnvPassthrough)
+	} else if got.InstanceScope != "session" {
+		t.Fatalf("instance_scope = %q, want session", got.InstanceScope)
+	} else if got.Sandbox == nil || !got.Sandbox.Landlock || len(got.Sandbox.WritePaths) != 1 {
+		t.Fatalf("sandbox = %+v", got.Sandbox)
+	} else if got.HealthIntervalSec != -1 || got.HealthTimeoutSec != 5 {
+		t.Fatalf("health = %d/%d, want -1/5", got.HealthIntervalSec, got.HealthTimeoutSec)
+	} else if got.InitTimeoutSec != 45 || got.CallTimeoutSec != -1 || got.ToolTimeouts["search"] != 300 {
+		t.Fatalf("timeouts = %d/%d/%v", got.InitTimeoutSec, got.CallTimeoutSec, got.ToolTimeouts)
+	}
 
 	cache := json.RawMessage(`{"tools":["create_issue"]}`)
 	if err := db.UpdateCapabilitiesCache(ctx, ds.ID, cache); err != nil {
@@ -231,7 +269,11 @@ func TestRouteRuleCRUD(t *testing.T) {
 	if err := db.CreateDownstreamServer(ctx, ds); err != nil {
 		t.Fatal(err)
 	}
+	if ds.AnnotationTrust != "trusted" {
+		t.Fatalf("annotation_trust = %q, want trusted", ds.AnnotationTrust)
+	}
 
+	allowReadOnly := true
 	r := &store.RouteRule{
 		Priority:           100,
 		WorkspaceID:        ws.ID,
@@ -240,6 +282,7 @@ func TestRouteRuleCRUD(t *testing.T) {
 		DownstreamServerID: ds.ID,
 		Policy:             "allow",
 		LogLevel:           "info",
+		AnnotationPolicy:   store.AnnotationPolicy{AutoAllowReadOnly: &allowReadOnly},
 	}
 
 	if err := db.CreateRouteRule(ctx, r); err != nil {
@@ -253,6 +296,10 @@ func TestRouteRuleCRUD(t *testing.T) {
 	if got.Priority != 100 {
 		t.Fatalf("priority = %d", got.Priority)
 	}
+	if p := got.AnnotationPolicy; p.AutoAllowReadOnly == nil || !*p.AutoAllowReadOnly ||
+		p.RequireApprovalDestructive != nil {
+		t.Fatalf("annotation_policy = %+v", p)
+	}
 
 	list, err := db.ListRouteRules(ctx, ws.ID)
 	if err != nil {
@@ -334,6 +381,10 @@ func TestAuditCRUD(t *testing.T) {
 			LatencyMs:  50 + i*10,
 			WorkspaceID: "ws1",
 		}
+		if i == 0 {
+			r.Redactions = []store.Redaction{{Path: "params.body", Detector: "github_token"}}
+			r.TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		}
 		if err := db.InsertAuditRecord(ctx, r); err != nil {
 			t.Fatalf("insert %d: %v", i, err)
 		}
@@ -360,6 +411,25 @@ func TestAuditCRUD(t *testing.T) {
 	if total != 1 {
 		t.Fatalf("total by tool = %d", total)
 	}
+	if rs := records[0].Redactions; len(rs) != 1 || rs[0].Detector != "github_token" {
+		t.Fatalf("redactions = %+v", rs)
+	}
+	if store.AuditRecordHash(&records[0]) != records[0].Hash {
+		t.Fatal("hash does not cover stored redactions")
+	}
+
+	// Query by trace ID.
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	records, total, err = db.QueryAuditRecords(ctx, store.AuditFilter{
+		TraceID: &traceID,
+		Limit:   10,
+	})
+	if err != nil {
+		t.Fatalf("query by trace: %v", err)
+	}
+	if total != 1 || records[0].ToolName != "github__create_issue" || records[0].TraceID != traceID {
+		t.Fatalf("by trace: total=%d records=%+v", total, records)
+	}
 
 	// Stats.
 	stats, err := db.GetAuditStats(ctx, "ws1",
@@ -492,3 +562,747 @@ func TestNotFound(t *testing.T) {
 		})
 	}
 }
+
+func TestToolApprovalResume(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	a := &store.ToolApproval{
+		RequestSessionID: "s1",
+		WorkspaceID:      "ws1",
+		ToolName:         "github__create_issue",
+		Arguments:        `{"title":"bug"}`,
+		CallParams:       json.RawMessage(`{"name":"github__create_issue","arguments":{"title":"bug"}}`),
+		TimeoutSec:       60,
+	}
+	if err := db.CreateToolApproval(ctx, a); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	got, err := db.GetToolApproval(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if string(got.CallParams) != string(a.CallParams) {
+		t.Fatalf("call_params = %s", got.CallParams)
+	}
+
+	list, err := db.ListResumableApprovals(ctx, "ws1")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("resumable = %d, %v", len(list), err)
+	}
+
+	// Pending approvals cannot be executed.
+	if err := db.MarkToolApprovalExecuted(ctx, a.ID); err != store.ErrNotFound {
+		t.Fatalf("execute pending = %v, want ErrNotFound", err)
+	}
+	if err := db.ResolveToolApproval(ctx, a.ID, "approved", "", "dashboard", "ok"); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if err := db.MarkToolApprovalExecuted(ctx, a.ID); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if err := db.MarkToolApprovalExecuted(ctx, a.ID); err != store.ErrNotFound {
+		t.Fatalf("execute twice = %v, want ErrNotFound", err)
+	}
+
+	list, err = db.ListResumableApprovals(ctx, "ws1")
+	if err != nil || len(list) != 0 {
+		t.Fatalf("resumable after execute = %d, %v", len(list), err)
+	}
+}
+
+func TestToolApprovalHistory(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	// Approvals are resolved now, so age is the time-to-decision.
+	now := time.Now().UTC()
+	seed := []struct {
+		tool, status, approver, just, reason string
+		age                                  time.Duration
+	}{
+		{"github__create_issue", "approved", "dashboard", "file bug report", "looks fine", 10 * time.Second},
+		{"github__create_issue", "denied", "mcp_agent", "spam 100% of repos", "no", 30 * time.Second},
+		{"github__create_issue", "timeout", "system", "cleanup", "timed out", 40 * time.Second},
+		{"slack__post", "approved", "dashboard", "notify team", "ok", 20 * time.Second},
+		{"slack__post", "pending", "", "notify again", "", 5 * time.Second},
+	}
+	var ids []string
+	for _, s := range seed {
+		a := &store.ToolApproval{
+			RequestSessionID: "s1",
+			WorkspaceID:      "ws1",
+			ToolName:         s.tool,
+			Justification:    s.just,
+			CreatedAt:        now.Add(-s.age),
+		}
+		if err := db.CreateToolApproval(ctx, a); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		ids = append(ids, a.ID)
+		if s.status == "pending" {
+			continue
+		}
+		if err := db.ResolveToolApproval(ctx, a.ID, s.status, "", s.approver, s.reason); err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+	}
+
+	strp := func(s string) *string { return &s }
+	after := now.Add(-25 * time.Second)
+
+	t.Run("filters", func(t *testing.T) {
+		tests := []struct {
+			name string
+			f    store.ApprovalFilter
+			want int
+		}{
+			{"all", store.ApprovalFilter{}, 5},
+			{"status", store.ApprovalFilter{Status: strp("approved")}, 2},
+			{"tool", store.ApprovalFilter{ToolName: strp("slack__post")}, 2},
+			{"approver", store.ApprovalFilter{ApproverType: strp("dashboard")}, 2},
+			{"session", store.ApprovalFilter{RequestSessionID: strp("other")}, 0},
+			{"text justification", store.ApprovalFilter{Query: strp("notify")}, 2},
+			{"text resolution", store.ApprovalFilter{Query: strp("looks")}, 1},
+			{"text literal percent", store.ApprovalFilter{Query: strp("100%")}, 1},
+			{"after", store.ApprovalFilter{After: &after}, 3},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, total, err := db.QueryToolApprovals(ctx, tt.f)
+				if err != nil {
+					t.Fatalf("query: %v", err)
+				}
+				if total != tt.want || len(got) != tt.want {
+					t.Fatalf("got %d rows, total %d, want %d", len(got), total, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		page, total, err := db.QueryToolApprovals(ctx, store.ApprovalFilter{Limit: 2, Offset: 2})
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		if total != 5 || len(page) != 2 {
+			t.Fatalf("got %d rows, total %d", len(page), total)
+		}
+		// Newest first: pending, github approved, then slack approved.
+		if page[0].ID != ids[3] {
+			t.Fatalf("page[0] = %s, want %s", page[0].ID, ids[3])
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		stats, err := db.GetApprovalStats(ctx, store.ApprovalFilter{})
+		if err != nil {
+			t.Fatalf("stats: %v", err)
+		}
+		if stats.Total != 5 || stats.Approved != 2 || stats.Denied != 1 ||
+			stats.Timeout != 1 || stats.Pending != 1 {
+			t.Fatalf("counts = %+v", stats.ApprovalCounts)
+		}
+		if stats.ApprovalRate != 0.5 {
+			t.Fatalf("approval rate = %v, want 0.5", stats.ApprovalRate)
+		}
+		// Decisions: 10s, 30s, 20s (timeout excluded) → median ~20s.
+		if m := stats.MedianDecisionMs; m < 20000 || m > 22000 {
+			t.Fatalf("median = %d, want ~20000", m)
+		}
+		if len(stats.Tools) != 2 || stats.Tools[0].ToolName != "github__create_issue" {
+			t.Fatalf("tools = %+v", stats.Tools)
+		}
+		gh := stats.Tools[0]
+		if gh.Total != 3 || gh.MedianDecisionMs < 20000 || gh.MedianDecisionMs > 22000 {
+			t.Fatalf("github stats = %+v", gh)
+		}
+	})
+
+	t.Run("audit link", func(t *testing.T) {
+		rec := &store.AuditRecord{
+			ToolName:   "github__create_issue",
+			Status:     "success",
+			ApprovalID: ids[0],
+		}
+		if err := db.InsertAuditRecord(ctx, rec); err != nil {
+			t.Fatalf("insert audit: %v", err)
+		}
+		if err := db.LinkApprovalAuditRecord(ctx, ids[0], rec.ID); err != nil {
+			t.Fatalf("link: %v", err)
+		}
+		if err := db.LinkApprovalAuditRecord(ctx, "missing", rec.ID); err != store.ErrNotFound {
+			t.Fatalf("link missing = %v, want ErrNotFound", err)
+		}
+
+		a, err := db.GetToolApproval(ctx, ids[0])
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if a.AuditRecordID != rec.ID {
+			t.Fatalf("audit_record_id = %q, want %q", a.AuditRecordID, rec.ID)
+		}
+		recs, _, err := db.QueryAuditRecords(ctx, store.AuditFilter{})
+		if err != nil || len(recs) != 1 {
+			t.Fatalf("query audit = %d, %v", len(recs), err)
+		}
+		if recs[0].ApprovalID != ids[0] {
+			t.Fatalf("approval_id = %q, want %q", recs[0].ApprovalID, ids[0])
+		}
+	})
+}
+
+func TestAuditRetention(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	seed := []struct {
+		offset    time.Duration
+		workspace string
+		session   string
+		server    string
+		status    string
+		latency   int
+	}{
+		{0, "ws1", "s1", "srv-a", "success", 10},
+		{10 * time.Second, "ws1", "s2", "srv-a", "error", 40},
+		{20 * time.Second, "ws2", "s1", "srv-b", "success", 200},
+		{1 * time.Minute, "ws1", "s1", "srv-a", "success", 20},
+		{2 * time.Minute, "ws2", "s3", "srv-b", "error", 900},
+		{3 * time.Minute, "ws1", "s1", "srv-a", "success", 30},
+	}
+	for i, s := range seed {
+		r := &store.AuditRecord{
+			Timestamp:          base.Add(s.offset),
+			WorkspaceID:        s.workspace,
+			SessionID:          s.session,
+			DownstreamServerID: s.server,
+			ToolName:           "test__tool",
+			Status:             s.status,
+			LatencyMs:          s.latency,
+		}
+		if err := db.InsertAuditRecord(ctx, r); err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+	}
+
+	after, before := base.Add(-time.Minute), base.Add(5*time.Minute)
+	wantStats, err := db.GetAuditStats(ctx, "", after, before)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	wantWS1, err := db.GetAuditStats(ctx, "ws1", after, before)
+	if err != nil {
+		t.Fatalf("ws1 stats: %v", err)
+	}
+	wantSeries, err := db.GetDashboardTimeSeries(ctx, after, before)
+	if err != nil {
+		t.Fatalf("time series: %v", err)
+	}
+
+	t.Run("list expired", func(t *testing.T) {
+		cutoff := base.Add(90 * time.Second)
+		ws1 := "ws1"
+		tests := []struct {
+			name string
+			f    store.AuditPruneFilter
+			want int
+		}{
+			{"no limits", store.AuditPruneFilter{}, 0},
+			{"before", store.AuditPruneFilter{Before: &cutoff}, 4},
+			{"before in workspace", store.AuditPruneFilter{WorkspaceID: &ws1, Before: &cutoff}, 3},
+			{"before excluding workspace", store.AuditPruneFilter{ExcludeWorkspaceIDs: []string{"ws1"}, Before: &cutoff}, 1},
+			{"keep newest", store.AuditPruneFilter{KeepNewest: 4}, 2},
+			{"keep newest in workspace", store.AuditPruneFilter{WorkspaceID: &ws1, KeepNewest: 1}, 3},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				recs, err := db.ListExpiredAuditRecords(ctx, tt.f, 100)
+				if err != nil {
+					t.Fatalf("list: %v", err)
+				}
+				if len(recs) != tt.want {
+					t.Fatalf("got %d records, want %d", len(recs), tt.want)
+				}
+				for i := 1; i < len(recs); i++ {
+					if recs[i].Timestamp.Before(recs[i-1].Timestamp) {
+						t.Fatalf("records not oldest first")
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("prune keeps aggregates", func(t *testing.T) {
+		// Prune in two batches so the first minute's rollup is merged.
+		cutoff := base.Add(150 * time.Second)
+		for _, limit := range []int{2, 100} {
+			recs, err := db.ListExpiredAuditRecords(ctx, store.AuditPruneFilter{Before: &cutoff}, limit)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if err := db.PruneAuditRecords(ctx, recs, nil); err != nil {
+				t.Fatalf("prune: %v", err)
+			}
+		}
+		recs, total, err := db.QueryAuditRecords(ctx, store.AuditFilter{})
+		if err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		if total != 1 || recs[0].LatencyMs != 30 {
+			t.Fatalf("remaining = %d, want only the newest record", total)
+		}
+
+		for _, tc := range []struct {
+			workspace string
+			want      *store.AuditStats
+		}{{"", wantStats}, {"ws1", wantWS1}} {
+			got, err := db.GetAuditStats(ctx, tc.workspace, after, before)
+			if err != nil {
+				t.Fatalf("stats: %v", err)
+			}
+			if got.TotalRequests != tc.want.TotalRequests ||
+				got.SuccessCount != tc.want.SuccessCount ||
+				got.ErrorCount != tc.want.ErrorCount ||
+				got.AvgLatencyMs != tc.want.AvgLatencyMs {
+				t.Errorf("workspace %q stats = %+v, want %+v", tc.workspace, got, tc.want)
+			}
+			if got.P95LatencyMs < tc.want.P95LatencyMs {
+				t.Errorf("workspace %q p95 = %d, want >= %d", tc.workspace, got.P95LatencyMs, tc.want.P95LatencyMs)
+			}
+		}
+
+		series, err := db.GetDashboardTimeSeries(ctx, after, before)
+		if err != nil {
+			t.Fatalf("time series: %v", err)
+		}
+		if len(series) != len(wantSeries) {
+			t.Fatalf("got %d buckets, want %d", len(series), len(wantSeries))
+		}
+		for i := range series {
+			if series[i] != wantSeries[i] {
+				t.Errorf("bucket %d = %+v, want %+v", i, series[i], wantSeries[i])
+			}
+		}
+	})
+
+	t.Run("sessions and approvals", func(t *testing.T) {
+		for _, id := range []string{"old", "active"} {
+			if err := db.CreateSession(ctx, &store.Session{ID: id, ConnectedAt: base}); err != nil {
+				t.Fatalf("create session: %v", err)
+			}
+		}
+		if err := db.DisconnectSession(ctx, "old"); err != nil {
+			t.Fatalf("disconnect: %v", err)
+		}
+		n, err := db.PruneSessions(ctx, time.Now().Add(time.Hour))
+		if err != nil || n != 1 {
+			t.Fatalf("prune sessions = %d, %v; want 1", n, err)
+		}
+		if _, err := db.GetSession(ctx, "active"); err != nil {
+			t.Fatalf("active session pruned: %v", err)
+		}
+
+		var ids []string
+		for _, status := range []string{"approved", "pending"} {
+			a := &store.ToolApproval{ToolName: "test__tool"}
+			if err := db.CreateToolApproval(ctx, a); err != nil {
+				t.Fatalf("create approval: %v", err)
+			}
+			if status != "pending" {
+				if err := db.ResolveToolApproval(ctx, a.ID, status, "", "dashboard", ""); err != nil {
+					t.Fatalf("resolve: %v", err)
+				}
+			}
+			ids = append(ids, a.ID)
+		}
+		n, err = db.PruneToolApprovals(ctx, time.Now().Add(time.Hour))
+		if err != nil || n != 1 {
+			t.Fatalf("prune approvals = %d, %v; want 1", n, err)
+		}
+		if _, err := db.GetToolApproval(ctx, ids[1]); err != nil {
+			t.Fatalf("pending approval pruned: %v", err)
+		}
+	})
+
+	t.Run("database size", func(t *testing.T) {
+		size, err := db.DatabaseSize(ctx)
+		if err != nil || size <= 0 {
+			t.Fatalf("size = %d, %v", size, err)
+		}
+	})
+}
+
+func TestAuditChain(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	var batch []*store.AuditRecord
+	for i := 0; i < 5; i++ {
+		r := &store.AuditRecord{
+			Timestamp:      base.Add(time.Duration(i) * time.Second),
+			ToolName:       "test__tool",
+			Status:         "success",
+			ParamsRedacted: json.RawMessage(`{"n": 1}`),
+		}
+		// The last three go in one batch.
+		if i >= 2 {
+			batch = append(batch, r)
+			continue
+		}
+		if err := db.InsertAuditRecord(ctx, r); err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+	}
+	if err := db.InsertAuditRecords(ctx, batch); err != nil {
+		t.Fatalf("insert batch: %v", err)
+	}
+	for i, r := range batch {
+		if r.Seq != int64(i+3) || r.Hash == "" {
+			t.Fatalf("batch record %d: seq = %d, hash = %q", i, r.Seq, r.Hash)
+		}
+	}
+
+	recs, err := db.ListAuditChain(ctx, 0, 100)
+	if err != nil || len(recs) != 5 {
+		t.Fatalf("list chain = %d, %v", len(recs), err)
+	}
+	prev := ""
+	for _, r := range recs {
+		if r.PrevHash != prev {
+			t.Fatalf("seq %d: prev_hash does not link", r.Seq)
+		}
+		if got := store.AuditRecordHash(&r); got != r.Hash {
+			t.Fatalf("seq %d: stored hash %s, recomputed %s", r.Seq, r.Hash, got)
+		}
+		prev = r.Hash
+	}
+	head, err := db.GetAuditChainHead(ctx)
+	if err != nil || head.Seq != 5 || head.Hash != prev {
+		t.Fatalf("head = %+v, %v", head, err)
+	}
+
+	// Pruning records 2-3 and then 4 leaves two adjacent gaps, each
+	// signed by the prune that wrote it.
+	sign := func(g *store.AuditChainGap) {
+		g.PublicKey, g.Signature = "k", fmt.Sprintf("sig %d-%d", g.FirstSeq, g.LastSeq)
+	}
+	if err := db.PruneAuditRecords(ctx, recs[1:3], sign); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if err := db.PruneAuditRecords(ctx, recs[3:4], nil); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	gaps, err := db.ListAuditChainGaps(ctx)
+	if err != nil || len(gaps) != 2 {
+		t.Fatalf("gaps = %+v, %v", gaps, err)
+	}
+	g := gaps[0]
+	if g.FirstSeq != 2 || g.LastSeq != 3 || g.PrevHash != recs[0].Hash || g.LastHash != recs[2].Hash ||
+		g.PublicKey != "k" || g.Signature != "sig 2-3" || g.PrunedAt.IsZero() {
+		t.Fatalf("gap = %+v", g)
+	}
+	if g := gaps[1]; g.FirstSeq != 4 || g.LastSeq != 4 || g.PrevHash != recs[2].Hash || g.Signature != "" {
+		t.Fatalf("unsigned gap = %+v", g)
+	}
+
+	if _, err := db.GetLatestAuditCheckpoint(ctx); err != store.ErrNotFound {
+		t.Fatalf("latest checkpoint on empty table: %v", err)
+	}
+	for _, seq := range []int64{3, 5} {
+		cp := &store.AuditCheckpoint{Seq: seq, Hash: "h", PublicKey: "k", Signature: "s"}
+		if err := db.CreateAuditCheckpoint(ctx, cp); err != nil {
+			t.Fatalf("create checkpoint: %v", err)
+		}
+	}
+	latest, err := db.GetLatestAuditCheckpoint(ctx)
+	if err != nil || latest.Seq != 5 {
+		t.Fatalf("latest checkpoint = %+v, %v", latest, err)
+	}
+	cps, err := db.ListAuditCheckpoints(ctx)
+	if err != nil || len(cps) != 2 || cps[0].Seq != 3 {
+		t.Fatalf("checkpoints = %+v, %v", cps, err)
+	}
+}
+
+func TestAuditSearch(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	seed := []store.AuditRecord{
+		{ToolName: "fs__read_file", Status: "success", ParamsRedacted: json.RawMessage(`{"path":"/data/customers.csv"}`)},
+		{ToolName: "fs__write_file", Status: "success", ParamsRedacted: json.RawMessage(`{"path":"/data/orders.csv"}`)},
+		{ToolName: "github__create_issue", Status: "error", ErrorMessage: "Rate limit exceeded, retry later"},
+		{ToolName: "slack__post_message", Status: "error", ErrorMessage: "channel not found"},
+	}
+	for i := range seed {
+		seed[i].Timestamp = time.Now().UTC().Add(time.Duration(i) * time.Second)
+		if err := db.InsertAuditRecord(ctx, &seed[i]); err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+	}
+
+	search := func(q string, f store.AuditFilter) []string {
+		t.Helper()
+		f.Search = &q
+		recs, total, err := db.QueryAuditRecords(ctx, f)
+		if err != nil {
+			t.Fatalf("search %q: %v", q, err)
+		}
+		if total != len(recs) {
+			t.Fatalf("search %q: total = %d, got %d records", q, total, len(recs))
+		}
+		var tools []string
+		for _, r := range recs {
+			tools = append(tools, r.ToolName)
+		}
+		return tools
+	}
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"customers.csv", []string{"fs__read_file"}},
+		{"csv", []string{"fs__write_file", "fs__read_file"}},
+		{"rate limit", []string{"github__create_issue"}},
+		{"RATE", []string{"github__create_issue"}},
+		{"cust*", []string{"fs__read_file"}},
+		{"slack__post_message", []string{"slack__post_message"}},
+		{`"quoted" OR (syntax`, nil},
+		{"nothing-matches", nil},
+	}
+	for _, tt := range tests {
+		if got := search(tt.q, store.AuditFilter{}); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("search %q = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+
+	// Combined with other filters.
+	errStatus := "error"
+	if got := search("not found", store.AuditFilter{Status: &errStatus}); fmt.Sprint(got) != "[slack__post_message]" {
+		t.Errorf("search with status = %v", got)
+	}
+
+	// Pruned records leave the index.
+	if err := db.PruneAuditRecords(ctx, seed[:1], nil); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if got := search("customers", store.AuditFilter{}); got != nil {
+		t.Errorf("pruned record still matches: %v", got)
+	}
+}
+
+func TestAuditQueryFiltersAndCursor(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Pairs of records share a timestamp so cursors must break ties by
+	// sequence number.
+	seed := func(t *testing.T) *sqlite.DB {
+		db := newTestDB(t)
+		for i := 0; i < 10; i++ {
+			r := &store.AuditRecord{
+				Timestamp:          base.Add(time.Duration(i/2) * time.Second),
+				ToolName:           "github__list_prs",
+				Status:             "success",
+				LatencyMs:          i * 100,
+				ClientType:         "claude",
+				DownstreamServerID: "ds1",
+				RouteRuleID:        "rr1",
+				AuthScopeID:        "as1",
+			}
+			if i%3 == 0 {
+				r.Status, r.ErrorCode, r.ClientType, r.DownstreamServerID = "error", "-32603", "cursor", "ds2"
+			}
+			if err := db.InsertAuditRecord(ctx, r); err != nil {
+				t.Fatalf("insert %d: %v", i, err)
+			}
+		}
+		return db
+	}
+
+	db := seed(t)
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	for _, tt := range []struct {
+		name string
+		f    store.AuditFilter
+		want int
+	}{
+		{"server", store.AuditFilter{DownstreamServerID: str("ds2")}, 4},
+		{"route", store.AuditFilter{RouteRuleID: str("rr1")}, 10},
+		{"scope", store.AuditFilter{AuthScopeID: str("nope")}, 0},
+		{"error code", store.AuditFilter{ErrorCode: str("-32603")}, 4},
+		{"not success", store.AuditFilter{ExcludeStatus: str("success")}, 4},
+		{"client", store.AuditFilter{ClientType: str("claude")}, 6},
+		{"latency", store.AuditFilter{MinLatencyMs: num(700)}, 3},
+		{"combined", store.AuditFilter{ClientType: str("claude"), MinLatencyMs: num(500)}, 3},
+	} {
+		_, total, err := db.QueryAuditRecords(ctx, tt.f)
+		if err != nil || total != tt.want {
+			t.Errorf("%s: total = %d, %v; want %d", tt.name, total, err, tt.want)
+		}
+	}
+
+	// Each late record sorts first for its order, so with offsets it would
+	// shift the pages and repeat a record.
+	for _, tt := range []struct {
+		sort string
+		late store.AuditRecord
+	}{
+		{"", store.AuditRecord{Timestamp: base.Add(time.Hour)}},
+		{store.AuditSortTimestampAsc, store.AuditRecord{Timestamp: base.Add(-time.Hour)}},
+		{store.AuditSortLatencyDesc, store.AuditRecord{Timestamp: base, LatencyMs: 10000}},
+		{store.AuditSortLatencyAsc, store.AuditRecord{Timestamp: base, LatencyMs: -1}},
+	} {
+		db := seed(t)
+		f := store.AuditFilter{Sort: tt.sort, Limit: 3}
+		var latencies []int
+		var lastSeq int64
+		seen := map[string]bool{}
+		for {
+			recs, _, err := db.QueryAuditRecords(ctx, f)
+			if err != nil {
+				t.Fatalf("sort %q: %v", tt.sort, err)
+			}
+			for _, r := range recs {
+				if seen[r.ID] {
+					t.Fatalf("sort %q: duplicate %s", tt.sort, r.ID)
+				}
+				seen[r.ID] = true
+				latencies = append(latencies, r.LatencyMs)
+				if tt.sort == store.AuditSortTimestampAsc && r.Seq <= lastSeq {
+					t.Fatalf("timestamp_asc: seq %d after %d", r.Seq, lastSeq)
+				}
+				lastSeq = r.Seq
+			}
+			if len(recs) < f.Limit {
+				break
+			}
+			f.Cursor = store.AuditCursor(tt.sort, &recs[len(recs)-1])
+
+			late := tt.late
+			late.ToolName, late.Status = "late", "success"
+			if err := db.InsertAuditRecord(ctx, &late); err != nil {
+				t.Fatalf("insert late: %v", err)
+			}
+		}
+		if len(seen) != 10 {
+			t.Fatalf("sort %q: saw %d records: %v", tt.sort, len(seen), latencies)
+		}
+		if tt.sort == store.AuditSortLatencyDesc && latencies[0] != 900 {
+			t.Fatalf("latency_desc starts with %d", latencies[0])
+		}
+	}
+
+	_, _, err := db.QueryAuditRecords(ctx, store.AuditFilter{
+		Sort:   store.AuditSortLatencyAsc,
+		Cursor: store.AuditCursor(store.AuditSortTimestampDesc, &store.AuditRecord{Seq: 1}),
+	})
+	if !errors.Is(err, store.ErrInvalidCursor) {
+		t.Fatalf("mismatched cursor err = %v", err)
+	}
+	_, _, err = db.QueryAuditRecords(ctx, store.AuditFilter{
+		Cursor: store.AuditCursor("", &store.AuditRecord{Timestamp: base, Seq: 1}),
+		Offset: 3,
+	})
+	if !errors.Is(err, store.ErrInvalidCursor) {
+		t.Fatalf("cursor with offset err = %v", err)
+	}
+}
+
+func TestQueryUsage(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	for i, r := range []store.AuditRecord{
+		{ToolName: "stripe__charge", DownstreamServerID: "stripe", WorkspaceID: "ws1", LatencyMs: 40, ResponseSize: 100},
+		{ToolName: "stripe__charge", DownstreamServerID: "stripe", WorkspaceID: "ws1", LatencyMs: 80, ResponseSize: 100},
+		{ToolName: "stripe__refund", DownstreamServerID: "stripe", WorkspaceID: "ws2", LatencyMs: 3000, Status: "error"},
+		{ToolName: "github__list", DownstreamServerID: "github", WorkspaceID: "ws2", LatencyMs: 20, ResponseSize: 10},
+	} {
+		r.Timestamp = day.Add(time.Duration(i) * 50 * time.Minute)
+		if r.Status == "" {
+			r.Status = "success"
+		}
+		if err := db.InsertAuditRecord(ctx, &r); err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+	}
+
+	// Top tools for the day.
+	rows, err := db.QueryUsage(ctx, store.UsageFilter{
+		Granularity: store.UsageDay,
+		GroupBy:     []string{store.UsageByTool},
+		After:       day,
+		Before:      day.Add(24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("by tool: %v", err)
+	}
+	if len(rows) != 3 || *rows[0].ToolName != "stripe__charge" || rows[0].Calls != 2 {
+		t.Fatalf("by tool = %+v", rows)
+	}
+	if rows[0].ResponseBytes != 200 || rows[0].P50LatencyMs != 50 || rows[0].P99LatencyMs != 80 || rows[0].AvgLatencyMs != 60 {
+		t.Fatalf("stripe__charge = %+v", rows[0])
+	}
+	if rows[0].WorkspaceID != nil || rows[0].Bucket != nil {
+		t.Fatal("ungrouped dimensions are set")
+	}
+
+	// Which workspace uses Stripe most.
+	stripe := "stripe"
+	rows, err = db.QueryUsage(ctx, store.UsageFilter{
+		Granularity:        store.UsageHour,
+		GroupBy:            []string{store.UsageByWorkspace},
+		DownstreamServerID: &stripe,
+		Limit:              1,
+	})
+	if err != nil {
+		t.Fatalf("by workspace: %v", err)
+	}
+	if len(rows) != 1 || *rows[0].WorkspaceID != "ws1" || rows[0].Calls != 2 {
+		t.Fatalf("by workspace = %+v", rows)
+	}
+
+	// Error rate and hourly buckets.
+	rows, err = db.QueryUsage(ctx, store.UsageFilter{
+		Granularity: store.UsageHour,
+		GroupBy:     []string{store.UsageByBucket},
+		Sort:        store.UsageSortBucket,
+	})
+	if err != nil {
+		t.Fatalf("by bucket: %v", err)
+	}
+	if len(rows) != 3 || !rows[0].Bucket.Equal(day) || rows[0].Calls != 2 || rows[1].Calls != 1 {
+		t.Fatalf("by bucket = %+v", rows)
+	}
+	if rows[1].ErrorRate != 1 || rows[1].P95LatencyMs != 3000 {
+		t.Fatalf("error bucket = %+v", rows[1])
+	}
+
+	// Totals survive pruning the raw records.
+	recs, _, _ := db.QueryAuditRecords(ctx, store.AuditFilter{Limit: 10})
+	if err := db.PruneAuditRecords(ctx, recs, nil); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	rows, err = db.QueryUsage(ctx, store.UsageFilter{Granularity: store.UsageDay})
+	if err != nil {
+		t.Fatalf("totals: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Calls != 4 || rows[0].Errors != 1 {
+		t.Fatalf("totals = %+v", rows)
+	}
+
+	if _, err := db.QueryUsage(ctx, store.UsageFilter{Granularity: "week"}); err == nil {
+		t.Fatal("invalid granularity accepted")
+	}
+	if _, err := db.QueryUsage(ctx, store.UsageFilter{Granularity: store.UsageDay, Sort: store.UsageSortBucket}); err == nil {
+		t.Fatal("bucket sort without bucket grouping accepted")
+	}
+}
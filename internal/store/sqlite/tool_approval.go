@@ -3,13 +3,24 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/revitteth/mcplexer/internal/store"
 )
 
+const toolApprovalColumns = `
+	id, status, request_session_id, request_client_type, request_model,
+	workspace_id, tool_name, arguments, call_params, justification,
+	route_rule_id, downstream_server_id, auth_scope_id,
+	approver_session_id, approver_type, resolution,
+	timeout_sec, created_at, resolved_at, executed_at, audit_record_id`
+
 func (d *DB) CreateToolApproval(ctx context.Context, a *store.ToolApproval) error {
 	if a.ID == "" {
 		a.ID = uuid.NewString()
@@ -22,58 +33,50 @@ func (d *DB) CreateToolApproval(ctx context.Context, a *store.ToolApproval) erro
 	}
 
 	_, err := d.q.ExecContext(ctx, `
-		INSERT INTO tool_approvals
-			(id, status, request_session_id, request_client_type, request_model,
-			 workspace_id, tool_name, arguments, justification,
-			 route_rule_id, downstream_server_id, auth_scope_id,
-			 approver_session_id, approver_type, resolution,
-			 timeout_sec, created_at, resolved_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		INSERT INTO tool_approvals (`+toolApprovalColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		a.ID, a.Status, a.RequestSessionID, a.RequestClientType, a.RequestModel,
-		a.WorkspaceID, a.ToolName, a.Arguments, a.Justification,
+		a.WorkspaceID, a.ToolName, a.Arguments, normalizeJSON(a.CallParams, "{}"), a.Justification,
 		a.RouteRuleID, a.DownstreamServerID, a.AuthScopeID,
 		a.ApproverSessionID, a.ApproverType, a.Resolution,
-		a.TimeoutSec, formatTime(a.CreatedAt), formatTimePtr(a.ResolvedAt),
+		a.TimeoutSec, formatTime(a.CreatedAt), formatTimePtr(a.ResolvedAt), formatTimePtr(a.ExecutedAt),
+		a.AuditRecordID,
 	)
 	return err
 }
 
 func (d *DB) GetToolApproval(ctx context.Context, id string) (*store.ToolApproval, error) {
 	row := d.q.QueryRowContext(ctx, `
-		SELECT id, status, request_session_id, request_client_type, request_model,
-		       workspace_id, tool_name, arguments, justification,
-		       route_rule_id, downstream_server_id, auth_scope_id,
-		       approver_session_id, approver_type, resolution,
-		       timeout_sec, created_at, resolved_at
+		SELECT `+toolApprovalColumns+`
 		FROM tool_approvals WHERE id = ?`, id)
 
-	return scanToolApproval(row)
+	a, err := scanToolApprovalRow(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	return a, err
 }
 
 func (d *DB) ListPendingApprovals(ctx context.Context) ([]store.ToolApproval, error) {
-	rows, err := d.q.QueryContext(ctx, `
-		SELECT id, status, request_session_id, request_client_type, request_model,
-		       workspace_id, tool_name, arguments, justification,
-		       route_rule_id, downstream_server_id, auth_scope_id,
-		       approver_session_id, approver_type, resolution,
-		       timeout_sec, created_at, resolved_at
+	return d.listToolApprovals(ctx, `
+		SELECT `+toolApprovalColumns+`
 		FROM tool_approvals
 		WHERE status = 'pending'
 		ORDER BY created_at ASC`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+}
 
-	var out []store.ToolApproval
-	for rows.Next() {
-		a, err := scanToolApprovalRow(rows)
-		if err != nil {
-			return nil, err
-		}
-		out = append(out, *a)
-	}
-	return out, rows.Err()
+// ListResumableApprovals returns the workspace's approvals that have not
+// been used yet: still pending, or approved but never executed. Newest
+// first.
+func (d *DB) ListResumableApprovals(ctx context.Context, workspaceID string) ([]store.ToolApproval, error) {
+	return d.listToolApprovals(ctx, `
+		SELECT `+toolApprovalColumns+`
+		FROM tool_approvals
+		WHERE workspace_id = ?
+		  AND status IN ('pending', 'approved') AND executed_at IS NULL
+		ORDER BY created_at DESC`,
+		workspaceID,
+	)
 }
 
 func (d *DB) ResolveToolApproval(
@@ -94,57 +97,248 @@ func (d *DB) ResolveToolApproval(
 	return checkRowsAffected(res)
 }
 
-func (d *DB) ExpirePendingApprovals(ctx context.Context, before time.Time) (int, error) {
+// MarkToolApprovalExecuted records that an approved call has been dispatched.
+// Returns store.ErrNotFound if the approval is not approved or was already
+// executed, so each approval authorises exactly one call.
+func (d *DB) MarkToolApprovalExecuted(ctx context.Context, id string) error {
 	res, err := d.q.ExecContext(ctx, `
 		UPDATE tool_approvals
-		SET status = 'timeout', resolved_at = ?
-		WHERE status = 'pending' AND created_at < ?`,
-		formatTime(time.Now().UTC()), formatTime(before),
+		SET executed_at = ?
+		WHERE id = ? AND status = 'approved' AND executed_at IS NULL`,
+		formatTime(time.Now().UTC()), id,
 	)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	n, err := res.RowsAffected()
-	return int(n), err
+	return checkRowsAffected(res)
 }
 
-func scanToolApproval(row *sql.Row) (*store.ToolApproval, error) {
-	var a store.ToolApproval
-	var createdAt string
-	var resolvedAt *string
-	err := row.Scan(
-		&a.ID, &a.Status, &a.RequestSessionID, &a.RequestClientType, &a.RequestModel,
-		&a.WorkspaceID, &a.ToolName, &a.Arguments, &a.Justification,
-		&a.RouteRuleID, &a.DownstreamServerID, &a.AuthScopeID,
-		&a.ApproverSessionID, &a.ApproverType, &a.Resolution,
-		&a.TimeoutSec, &createdAt, &resolvedAt,
+// LinkApprovalAuditRecord records the audit record of the call an approval
+// gated, so the history can link from the approval to the call outcome.
+func (d *DB) LinkApprovalAuditRecord(ctx context.Context, approvalID, auditRecordID string) error {
+	res, err := d.q.ExecContext(ctx, `
+		UPDATE tool_approvals SET audit_record_id = ? WHERE id = ?`,
+		auditRecordID, approvalID,
 	)
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, store.ErrNotFound
+	if err != nil {
+		return err
+	}
+	return checkRowsAffected(res)
+}
+
+// QueryToolApprovals returns a page of approvals matching f, newest first,
+// along with the total number of matches.
+func (d *DB) QueryToolApprovals(
+	ctx context.Context, f store.ApprovalFilter,
+) ([]store.ToolApproval, int, error) {
+	where, args := buildApprovalWhere(f)
+
+	var total int
+	countQ := "SELECT COUNT(*) FROM tool_approvals" + where
+	if err := d.q.QueryRowContext(ctx, countQ, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 50
 	}
+	out, err := d.listToolApprovals(ctx, `
+		SELECT `+toolApprovalColumns+`
+		FROM tool_approvals`+where+`
+		ORDER BY created_at DESC LIMIT ? OFFSET ?`,
+		append(args, limit, f.Offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, total, nil
+}
+
+// GetApprovalStats aggregates outcome counts, approval rate and median
+// time-to-decision for approvals matching f, overall and per tool. Limit and
+// Offset are ignored.
+func (d *DB) GetApprovalStats(
+	ctx context.Context, f store.ApprovalFilter,
+) (*store.ApprovalStats, error) {
+	where, args := buildApprovalWhere(f)
+	rows, err := d.q.QueryContext(ctx, `
+		SELECT tool_name, status, approver_type, created_at, resolved_at
+		FROM tool_approvals`+where+`
+		ORDER BY tool_name`,
+		args...,
+	)
 	if err != nil {
 		return nil, err
 	}
-	a.CreatedAt = parseTime(createdAt)
-	a.ResolvedAt = parseTimePtr(resolvedAt)
-	return &a, nil
+	defer rows.Close()
+
+	overall := &approvalAgg{}
+	perTool := make(map[string]*approvalAgg)
+	var tools []string
+	for rows.Next() {
+		var tool, status, approverType, createdAt string
+		var resolvedAt *string
+		if err := rows.Scan(&tool, &status, &approverType, &createdAt, &resolvedAt); err != nil {
+			return nil, fmt.Errorf("scan approval stats row: %w", err)
+		}
+		agg, ok := perTool[tool]
+		if !ok {
+			agg = &approvalAgg{}
+			perTool[tool] = agg
+			tools = append(tools, tool)
+		}
+
+		var decisionMs int64 = -1
+		if resolvedAt != nil && (status == "approved" || status == "denied") && approverType != "system" {
+			decisionMs = parseTime(*resolvedAt).Sub(parseTime(createdAt)).Milliseconds()
+		}
+		overall.add(status, decisionMs)
+		agg.add(status, decisionMs)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := &store.ApprovalStats{
+		ApprovalCounts: overall.counts(),
+		Tools:          make([]store.ToolApprovalStats, 0, len(tools)),
+	}
+	for _, tool := range tools {
+		stats.Tools = append(stats.Tools, store.ToolApprovalStats{
+			ToolName:       tool,
+			ApprovalCounts: perTool[tool].counts(),
+		})
+	}
+	return stats, nil
+}
+
+// approvalAgg accumulates approval outcomes for GetApprovalStats.
+type approvalAgg struct {
+	c         store.ApprovalCounts
+	decisions []int64
+}
+
+// add counts one approval; decisionMs < 0 means it has no decision time.
+func (a *approvalAgg) add(status string, decisionMs int64) {
+	a.c.Total++
+	switch status {
+	case "pending":
+		a.c.Pending++
+	case "approved":
+		a.c.Approved++
+	case "denied":
+		a.c.Denied++
+	case "timeout":
+		a.c.Timeout++
+	case "cancelled":
+		a.c.Cancelled++
+	}
+	if decisionMs >= 0 {
+		a.decisions = append(a.decisions, decisionMs)
+	}
+}
+
+func (a *approvalAgg) counts() store.ApprovalCounts {
+	c := a.c
+	if decided := c.Approved + c.Denied + c.Timeout; decided > 0 {
+		c.ApprovalRate = float64(c.Approved) / float64(decided)
+	}
+	if n := len(a.decisions); n > 0 {
+		sort.Slice(a.decisions, func(i, j int) bool { return a.decisions[i] < a.decisions[j] })
+		if n%2 == 1 {
+			c.MedianDecisionMs = a.decisions[n/2]
+		} else {
+			c.MedianDecisionMs = (a.decisions[n/2-1] + a.decisions[n/2]) / 2
+		}
+	}
+	return c
+}
+
+func buildApprovalWhere(f store.ApprovalFilter) (string, []any) {
+	var conds []string
+	var args []any
+	if f.Status != nil {
+		conds = append(conds, "status = ?")
+		args = append(args, *f.Status)
+	}
+	if f.ToolName != nil {
+		conds = append(conds, "tool_name = ?")
+		args = append(args, *f.ToolName)
+	}
+	if f.WorkspaceID != nil {
+		conds = append(conds, "workspace_id = ?")
+		args = append(args, *f.WorkspaceID)
+	}
+	if f.ApproverType != nil {
+		conds = append(conds, "approver_type = ?")
+		args = append(args, *f.ApproverType)
+	}
+	if f.RequestSessionID != nil {
+		conds = append(conds, "request_session_id = ?")
+		args = append(args, *f.RequestSessionID)
+	}
+	if f.After != nil {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, formatTime(*f.After))
+	}
+	if f.Before != nil {
+		conds = append(conds, "created_at <= ?")
+		args = append(args, formatTime(*f.Before))
+	}
+	if f.Query != nil && *f.Query != "" {
+		pattern := "%" + escapeLike(*f.Query) + "%"
+		conds = append(conds, `(justification LIKE ? ESCAPE '\' OR resolution LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (d *DB) listToolApprovals(
+	ctx context.Context, query string, args ...any,
+) ([]store.ToolApproval, error) {
+	rows, err := d.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []store.ToolApproval
+	for rows.Next() {
+		a, err := scanToolApprovalRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *a)
+	}
+	return out, rows.Err()
 }
 
 func scanToolApprovalRow(row rowScanner) (*store.ToolApproval, error) {
 	var a store.ToolApproval
-	var createdAt string
-	var resolvedAt *string
+	var createdAt, callParams string
+	var resolvedAt, executedAt *string
 	err := row.Scan(
 		&a.ID, &a.Status, &a.RequestSessionID, &a.RequestClientType, &a.RequestModel,
-		&a.WorkspaceID, &a.ToolName, &a.Arguments, &a.Justification,
+		&a.WorkspaceID, &a.ToolName, &a.Arguments, &callParams, &a.Justification,
 		&a.RouteRuleID, &a.DownstreamServerID, &a.AuthScopeID,
 		&a.ApproverSessionID, &a.ApproverType, &a.Resolution,
-		&a.TimeoutSec, &createdAt, &resolvedAt,
+		&a.TimeoutSec, &createdAt, &resolvedAt, &executedAt, &a.AuditRecordID,
 	)
 	if err != nil {
 		return nil, err
 	}
+	a.CallParams = json.RawMessage(callParams)
 	a.CreatedAt = parseTime(createdAt)
 	a.ResolvedAt = parseTimePtr(resolvedAt)
+	a.ExecutedAt = parseTimePtr(executedAt)
 	return &a, nil
 }
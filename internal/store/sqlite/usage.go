@@ -0,0 +1,214 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/revitteth/mcplexer/internal/store"
+)
+
+// usageHistColumns are the usage_rollups histogram columns, one per
+// latencyBucket slot.
+var usageHistColumns = func() []string {
+	cols := make([]string, len(latencyBucketsMs)+1)
+	for i := range cols {
+		cols[i] = "hist_" + strconv.Itoa(i)
+	}
+	return cols
+}()
+
+// usageDimensionColumns maps UsageBy* dimensions to usage_rollups columns.
+var usageDimensionColumns = map[string]string{
+	store.UsageByBucket:    "bucket",
+	store.UsageByWorkspace: "workspace_id",
+	store.UsageByTool:      "tool_name",
+	store.UsageByServer:    "downstream_server_id",
+	store.UsageByClient:    "client_type",
+	store.UsageByModel:     "model",
+}
+
+// upsertUsageRollups adds r to its hourly and daily usage rollups.
+func upsertUsageRollups(ctx context.Context, q queryable, r *store.AuditRecord) error {
+	hist := usageHistColumns[latencyBucket(r.LatencyMs)]
+	var isError int
+	if r.Status != "success" { // "error" or "timeout"
+		isError = 1
+	}
+	for _, g := range []string{store.UsageHour, store.UsageDay} {
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO usage_rollups
+				(granularity, bucket, workspace_id, tool_name, downstream_server_id,
+				 client_type, model, calls, error_count, latency_sum_ms,
+				 latency_max_ms, response_bytes, `+hist+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?, 1)
+			ON CONFLICT (granularity, bucket, workspace_id, tool_name,
+				downstream_server_id, client_type, model) DO UPDATE SET
+				calls = calls + 1,
+				error_count = error_count + excluded.error_count,
+				latency_sum_ms = latency_sum_ms + excluded.latency_sum_ms,
+				latency_max_ms = max(latency_max_ms, excluded.latency_max_ms),
+				response_bytes = response_bytes + excluded.response_bytes,
+				`+hist+` = `+hist+` + 1`,
+			g, formatTime(store.UsageBucket(g, r.Timestamp)), r.WorkspaceID, r.ToolName,
+			r.DownstreamServerID, r.ClientType, r.Model, isError, r.LatencyMs,
+			r.LatencyMs, r.ResponseSize,
+		)
+		if err != nil {
+			return fmt.Errorf("upsert %s usage rollup: %w", g, err)
+		}
+	}
+	return nil
+}
+
+func (d *DB) QueryUsage(ctx context.Context, f store.UsageFilter) ([]store.UsageRow, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	conds := []string{"granularity = ?"}
+	args := []any{f.Granularity}
+	if !f.After.IsZero() {
+		conds = append(conds, "bucket >= ?")
+		args = append(args, formatTime(store.UsageBucket(f.Granularity, f.After)))
+	}
+	if !f.Before.IsZero() {
+		conds = append(conds, "bucket < ?")
+		args = append(args, formatTime(f.Before))
+	}
+	eq := func(col string, v *string) {
+		if v != nil {
+			conds = append(conds, col+" = ?")
+			args = append(args, *v)
+		}
+	}
+	eq("workspace_id", f.WorkspaceID)
+	eq("tool_name", f.ToolName)
+	eq("downstream_server_id", f.DownstreamServerID)
+	eq("client_type", f.ClientType)
+	eq("model", f.Model)
+
+	groupCols := make([]string, len(f.GroupBy))
+	for i, dim := range f.GroupBy {
+		groupCols[i] = usageDimensionColumns[dim]
+	}
+	sums := make([]string, len(usageHistColumns))
+	for i, col := range usageHistColumns {
+		sums[i] = "SUM(" + col + ")"
+	}
+	selectCols := append(append([]string{}, groupCols...),
+		"SUM(calls)", "SUM(error_count)", "SUM(latency_sum_ms)",
+		"MAX(latency_max_ms)", "SUM(response_bytes)")
+	selectCols = append(selectCols, sums...)
+
+	query := `SELECT ` + strings.Join(selectCols, ", ") + `
+		FROM usage_rollups WHERE ` + strings.Join(conds, " AND ")
+	if len(groupCols) > 0 {
+		query += " GROUP BY " + strings.Join(groupCols, ", ")
+	} else {
+		query += " HAVING SUM(calls) > 0"
+	}
+
+	rows, err := d.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query usage: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []usageGroup
+	for rows.Next() {
+		dims := make([]string, len(groupCols))
+		hist := make([]int, len(usageHistColumns))
+		var u store.UsageRow
+		var latencySum int64
+		dest := make([]any, 0, len(dims)+5+len(hist))
+		for i := range dims {
+			dest = append(dest, &dims[i])
+		}
+		dest = append(dest, &u.Calls, &u.Errors, &latencySum, &u.MaxLatencyMs, &u.ResponseBytes)
+		for i := range hist {
+			dest = append(dest, &hist[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan usage row: %w", err)
+		}
+
+		for i, dim := range f.GroupBy {
+			v := dims[i]
+			switch dim {
+			case store.UsageByBucket:
+				t := parseTime(v)
+				u.Bucket = &t
+			case store.UsageByWorkspace:
+				u.WorkspaceID = &v
+			case store.UsageByTool:
+				u.ToolName = &v
+			case store.UsageByServer:
+				u.DownstreamServerID = &v
+			case store.UsageByClient:
+				u.ClientType = &v
+			case store.UsageByModel:
+				u.Model = &v
+			}
+		}
+		if u.Calls > 0 {
+			u.ErrorRate = float64(u.Errors) / float64(u.Calls)
+			u.AvgLatencyMs = float64(latencySum) / float64(u.Calls)
+		}
+		u.P50LatencyMs = histogramPercentile(hist, 0.50, u.MaxLatencyMs)
+		u.P95LatencyMs = histogramPercentile(hist, 0.95, u.MaxLatencyMs)
+		u.P99LatencyMs = histogramPercentile(hist, 0.99, u.MaxLatencyMs)
+		groups = append(groups, usageGroup{row: u, key: strings.Join(dims, "\xff")})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortUsageGroups(groups, f.Sort)
+	if f.Limit > 0 && len(groups) > f.Limit {
+		groups = groups[:f.Limit]
+	}
+	out := make([]store.UsageRow, len(groups))
+	for i, g := range groups {
+		out[i] = g.row
+	}
+	return out, nil
+}
+
+// usageGroup is a usage row with its joined dimension values.
+type usageGroup struct {
+	row store.UsageRow
+	key string
+}
+
+// sortUsageGroups orders groups by sort, breaking ties by their dimension
+// values so results are stable.
+func sortUsageGroups(groups []usageGroup, by string) {
+	metric := func(u *store.UsageRow) float64 {
+		switch by {
+		case store.UsageSortErrors:
+			return float64(u.Errors)
+		case store.UsageSortErrorRate:
+			return u.ErrorRate
+		case store.UsageSortP95Latency:
+			return float64(u.P95LatencyMs)
+		case store.UsageSortResponseBytes:
+			return float64(u.ResponseBytes)
+		default:
+			return float64(u.Calls)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := &groups[i].row, &groups[j].row
+		if by == store.UsageSortBucket {
+			if !a.Bucket.Equal(*b.Bucket) {
+				return a.Bucket.Before(*b.Bucket)
+			}
+		} else if ma, mb := metric(a), metric(b); ma != mb {
+			return ma > mb
+		}
+		return groups[i].key < groups[j].key
+	})
+}
@@ -25,9 +25,13 @@ func (d *DB) CreateWorkspace(ctx context.Context, w *store.Workspace) error {
 	}
 
 	_, err := d.q.ExecContext(ctx, `
-		INSERT INTO workspaces (id, name, root_path, tags, default_policy, source, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-		w.ID, w.Name, w.RootPath, tags, w.DefaultPolicy, w.Source,
+		INSERT INTO workspaces
+			(id, name, root_path, tags, default_policy, annotation_policy,
+			 audit_retention_days, audit_max_rows, source, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		w.ID, w.Name, w.RootPath, tags, w.DefaultPolicy,
+		formatAnnotationPolicy(w.AnnotationPolicy),
+		w.AuditRetentionDays, w.AuditMaxRows, w.Source,
 		formatTime(w.CreatedAt), formatTime(w.UpdatedAt),
 	)
 	if err != nil {
@@ -38,21 +42,24 @@ func (d *DB) CreateWorkspace(ctx context.Context, w *store.Workspace) error {
 
 func (d *DB) GetWorkspace(ctx context.Context, id string) (*store.Workspace, error) {
 	row := d.q.QueryRowContext(ctx, `
-		SELECT id, name, root_path, tags, default_policy, source, created_at, updated_at
+		SELECT id, name, root_path, tags, default_policy, annotation_policy,
+		       audit_retention_days, audit_max_rows, source, created_at, updated_at
 		FROM workspaces WHERE id = ?`, id)
 	return scanWorkspace(row)
 }
 
 func (d *DB) GetWorkspaceByName(ctx context.Context, name string) (*store.Workspace, error) {
 	row := d.q.QueryRowContext(ctx, `
-		SELECT id, name, root_path, tags, default_policy, source, created_at, updated_at
+		SELECT id, name, root_path, tags, default_policy, annotation_policy,
+		       audit_retention_days, audit_max_rows, source, created_at, updated_at
 		FROM workspaces WHERE name = ?`, name)
 	return scanWorkspace(row)
 }
 
 func (d *DB) ListWorkspaces(ctx context.Context) ([]store.Workspace, error) {
 	rows, err := d.q.QueryContext(ctx, `
-		SELECT id, name, root_path, tags, default_policy, source, created_at, updated_at
+		SELECT id, name, root_path, tags, default_policy, annotation_policy,
+		       audit_retention_days, audit_max_rows, source, created_at, updated_at
 		FROM workspaces ORDER BY name`)
 	if err != nil {
 		return nil, err
@@ -79,9 +86,12 @@ func (d *DB) UpdateWorkspace(ctx context.Context, w *store.Workspace) error {
 
 	res, err := d.q.ExecContext(ctx, `
 		UPDATE workspaces
-		SET name = ?, root_path = ?, tags = ?, default_policy = ?, source = ?, updated_at = ?
+		SET name = ?, root_path = ?, tags = ?, default_policy = ?, annotation_policy = ?,
+		    audit_retention_days = ?, audit_max_rows = ?, source = ?, updated_at = ?
 		WHERE id = ?`,
-		w.Name, w.RootPath, tags, w.DefaultPolicy, w.Source,
+		w.Name, w.RootPath, tags, w.DefaultPolicy,
+		formatAnnotationPolicy(w.AnnotationPolicy),
+		w.AuditRetentionDays, w.AuditMaxRows, w.Source,
 		formatTime(w.UpdatedAt), w.ID,
 	)
 	if err != nil {
@@ -100,9 +110,10 @@ func (d *DB) DeleteWorkspace(ctx context.Context, id string) error {
 
 func scanWorkspace(row *sql.Row) (*store.Workspace, error) {
 	var w store.Workspace
-	var createdAt, updatedAt, tags string
+	var createdAt, updatedAt, tags, annotationPolicy string
 	err := row.Scan(&w.ID, &w.Name, &w.RootPath, &tags,
-		&w.DefaultPolicy, &w.Source, &createdAt, &updatedAt)
+		&w.DefaultPolicy, &annotationPolicy, &w.AuditRetentionDays, &w.AuditMaxRows,
+		&w.Source, &createdAt, &updatedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, store.ErrNotFound
 	}
@@ -110,6 +121,7 @@ func scanWorkspace(row *sql.Row) (*store.Workspace, error) {
 		return nil, err
 	}
 	w.Tags = json.RawMessage(tags)
+	w.AnnotationPolicy = parseAnnotationPolicy(annotationPolicy)
 	w.CreatedAt = parseTime(createdAt)
 	w.UpdatedAt = parseTime(updatedAt)
 	return &w, nil
@@ -121,13 +133,15 @@ type rowScanner interface {
 
 func scanWorkspaceRow(row rowScanner) (*store.Workspace, error) {
 	var w store.Workspace
-	var createdAt, updatedAt, tags string
+	var createdAt, updatedAt, tags, annotationPolicy string
 	err := row.Scan(&w.ID, &w.Name, &w.RootPath, &tags,
-		&w.DefaultPolicy, &w.Source, &createdAt, &updatedAt)
+		&w.DefaultPolicy, &annotationPolicy, &w.AuditRetentionDays, &w.AuditMaxRows,
+		&w.Source, &createdAt, &updatedAt)
 	if err != nil {
 		return nil, err
 	}
 	w.Tags = json.RawMessage(tags)
+	w.AnnotationPolicy = parseAnnotationPolicy(annotationPolicy)
 	w.CreatedAt = parseTime(createdAt)
 	w.UpdatedAt = parseTime(updatedAt)
 	return &w, nil
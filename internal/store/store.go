@@ -16,6 +16,8 @@ type Store interface {
 	SessionStore
 	AuditStore
 	ToolApprovalStore
+	RetentionStore
+	AuditChainStore
 	Tx(ctx context.Context, fn func(Store) error) error
 	Ping(ctx context.Context) error
 	Close() error
@@ -84,9 +86,25 @@ type SessionStore interface {
 // AuditStore manages audit log records.
 type AuditStore interface {
 	InsertAuditRecord(ctx context.Context, r *AuditRecord) error
+	// InsertAuditRecords inserts recs in order in a single transaction.
+	InsertAuditRecords(ctx context.Context, recs []*AuditRecord) error
 	QueryAuditRecords(ctx context.Context, f AuditFilter) ([]AuditRecord, int, error)
 	GetAuditStats(ctx context.Context, workspaceID string, after, before time.Time) (*AuditStats, error)
 	GetDashboardTimeSeries(ctx context.Context, after, before time.Time) ([]TimeSeriesPoint, error)
+	// QueryUsage aggregates the hourly or daily usage rollups maintained by
+	// InsertAuditRecord. Pruning audit records does not change them.
+	QueryUsage(ctx context.Context, f UsageFilter) ([]UsageRow, error)
+}
+
+// AuditChainStore reads the audit hash chain and manages its signed
+// checkpoints. The chain itself is extended by InsertAuditRecord.
+type AuditChainStore interface {
+	GetAuditChainHead(ctx context.Context) (*AuditChainHead, error)
+	ListAuditChain(ctx context.Context, afterSeq int64, limit int) ([]AuditRecord, error)
+	ListAuditChainGaps(ctx context.Context) ([]AuditChainGap, error)
+	CreateAuditCheckpoint(ctx context.Context, c *AuditCheckpoint) error
+	GetLatestAuditCheckpoint(ctx context.Context) (*AuditCheckpoint, error)
+	ListAuditCheckpoints(ctx context.Context) ([]AuditCheckpoint, error)
 }
 
 // ToolApprovalStore manages tool call approval records.
@@ -95,5 +113,20 @@ type ToolApprovalStore interface {
 	GetToolApproval(ctx context.Context, id string) (*ToolApproval, error)
 	ListPendingApprovals(ctx context.Context) ([]ToolApproval, error)
 	ResolveToolApproval(ctx context.Context, id, status, approverSessionID, approverType, resolution string) error
-	ExpirePendingApprovals(ctx context.Context, before time.Time) (int, error)
+	ListResumableApprovals(ctx context.Context, workspaceID string) ([]ToolApproval, error)
+	MarkToolApprovalExecuted(ctx context.Context, id string) error
+	QueryToolApprovals(ctx context.Context, f ApprovalFilter) ([]ToolApproval, int, error)
+	GetApprovalStats(ctx context.Context, f ApprovalFilter) (*ApprovalStats, error)
+	LinkApprovalAuditRecord(ctx context.Context, approvalID, auditRecordID string) error
+}
+
+// RetentionStore prunes historical records. Pruned audit records are rolled
+// up into aggregates that GetAuditStats and GetDashboardTimeSeries include,
+// and the chain gaps they leave are signed with sign when it is non-nil.
+type RetentionStore interface {
+	ListExpiredAuditRecords(ctx context.Context, f AuditPruneFilter, limit int) ([]AuditRecord, error)
+	PruneAuditRecords(ctx context.Context, records []AuditRecord, sign AuditGapSigner) error
+	PruneSessions(ctx context.Context, before time.Time) (int, error)
+	PruneToolApprovals(ctx context.Context, before time.Time) (int, error)
+	DatabaseSize(ctx context.Context) (int64, error)
 }
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Usage rollup granularities.
+const (
+	UsageHour = "hour"
+	UsageDay  = "day"
+)
+
+// Usage dimensions for UsageFilter.GroupBy.
+const (
+	UsageByBucket    = "bucket"
+	UsageByWorkspace = "workspace"
+	UsageByTool      = "tool"
+	UsageByServer    = "server"
+	UsageByClient    = "client"
+	UsageByModel     = "model"
+)
+
+// Usage sort orders. All are descending except UsageSortBucket.
+const (
+	UsageSortCalls         = "calls" // default
+	UsageSortErrors        = "errors"
+	UsageSortErrorRate     = "error_rate"
+	UsageSortP95Latency    = "p95_latency"
+	UsageSortResponseBytes = "response_bytes"
+	UsageSortBucket        = "bucket" // oldest first, for time series
+)
+
+// UsageFilter selects and groups hourly or daily usage rollups. Buckets are
+// included when they start at or after After aligned down to a bucket
+// boundary, and before Before; a zero value leaves that side open.
+type UsageFilter struct {
+	Granularity        string    `json:"granularity"`        // UsageHour or UsageDay
+	GroupBy            []string  `json:"group_by,omitempty"` // UsageBy* dimensions; none sums everything
+	After              time.Time `json:"after,omitzero"`
+	Before             time.Time `json:"before,omitzero"`
+	WorkspaceID        *string   `json:"workspace_id,omitempty"`
+	ToolName           *string   `json:"tool_name,omitempty"`
+	DownstreamServerID *string   `json:"downstream_server_id,omitempty"`
+	ClientType         *string   `json:"client_type,omitempty"`
+	Model              *string   `json:"model,omitempty"`
+	Sort               string    `json:"sort,omitempty"` // a UsageSort* value; empty is UsageSortCalls
+	Limit              int       `json:"limit"`
+}
+
+// Validate checks the granularity, dimensions and sort order.
+func (f *UsageFilter) Validate() error {
+	if f.Granularity != UsageHour && f.Granularity != UsageDay {
+		return fmt.Errorf("invalid granularity %q (want hour or day)", f.Granularity)
+	}
+	seen := map[string]bool{}
+	for _, d := range f.GroupBy {
+		switch d {
+		case UsageByBucket, UsageByWorkspace, UsageByTool, UsageByServer, UsageByClient, UsageByModel:
+		default:
+			return fmt.Errorf("invalid group_by dimension %q", d)
+		}
+		if seen[d] {
+			return fmt.Errorf("duplicate group_by dimension %q", d)
+		}
+		seen[d] = true
+	}
+	switch f.Sort {
+	case "", UsageSortCalls, UsageSortErrors, UsageSortErrorRate, UsageSortP95Latency, UsageSortResponseBytes:
+	case UsageSortBucket:
+		if !seen[UsageByBucket] {
+			return fmt.Errorf("sort %q needs group_by bucket", f.Sort)
+		}
+	default:
+		return fmt.Errorf("invalid sort %q", f.Sort)
+	}
+	return nil
+}
+
+// UsageBucket returns the start of the hour or day bucket holding t, in UTC.
+func UsageBucket(granularity string, t time.Time) time.Time {
+	t = t.UTC()
+	if granularity == UsageDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+// UsageRow is one group of usage rollups. Only the grouped dimensions are
+// set. Percentiles are upper bounds of latency histogram buckets.
+type UsageRow struct {
+	Bucket             *time.Time `json:"bucket,omitempty"`
+	WorkspaceID        *string    `json:"workspace_id,omitempty"`
+	ToolName           *string    `json:"tool_name,omitempty"`
+	DownstreamServerID *string    `json:"downstream_server_id,omitempty"`
+	ClientType         *string    `json:"client_type,omitempty"`
+	Model              *string    `json:"model,omitempty"`
+	Calls              int        `json:"calls"`
+	Errors             int        `json:"errors"`
+	ErrorRate          float64    `json:"error_rate"`
+	AvgLatencyMs       float64    `json:"avg_latency_ms"`
+	P50LatencyMs       int        `json:"p50_latency_ms"`
+	P95LatencyMs       int        `json:"p95_latency_ms"`
+	P99LatencyMs       int        `json:"p99_latency_ms"`
+	MaxLatencyMs       int        `json:"max_latency_ms"`
+	ResponseBytes      int64      `json:"response_bytes"`
+}
@@ -0,0 +1,241 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/revitteth/mcplexer/internal/metrics"
+)
+
+const (
+	exportQueueSize = 2048
+	exportBatchSize = 512
+	exportInterval  = 5 * time.Second
+)
+
+// OTLPExporter batches spans and posts them as OTLP/HTTP traces using the
+// JSON encoding. Endpoint is the full traces URL, typically
+// http://collector:4318/v1/traces. Spans arriving while the queue is full
+// are dropped rather than slowing the call path.
+type OTLPExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+
+	queue    chan *SpanData
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewOTLPExporter creates an exporter posting to endpoint with the given
+// extra headers (e.g. authorization) and starts its batching goroutine.
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	e := &OTLPExporter{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		queue:    make(chan *SpanData, exportQueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// ExportSpan queues s for the next batch.
+func (e *OTLPExporter) ExportSpan(s *SpanData) {
+	select {
+	case e.queue <- s:
+	default:
+		metrics.TraceSpans.Inc("dropped")
+	}
+}
+
+// Shutdown flushes queued spans and stops the exporter, waiting until ctx
+// is done at most.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]*SpanData, 0, exportBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		result := "exported"
+		if err := e.post(batch); err != nil {
+			slog.Warn("trace export failed", "endpoint", e.endpoint, "spans", len(batch), "error", err)
+			result = "failed"
+		}
+		metrics.TraceSpans.Add(float64(len(batch)), result)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) >= exportBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+					if len(batch) >= exportBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *OTLPExporter) post(spans []*SpanData) error {
+	body, err := EncodeOTLPTraces(spans)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build otlp request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post otlp traces: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) //nolint:errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("otlp collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// otlpStatusError is the OTLP status code for a failed span; spans without
+// an error keep the default unset status.
+const otlpStatusError = 2
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"` // int64 is a string in OTLP JSON
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+func otlpAttr(a Attr) otlpKeyValue {
+	kv := otlpKeyValue{Key: a.Key}
+	switch v := a.Value.(type) {
+	case string:
+		kv.Value.StringValue = &v
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &s
+	case int:
+		s := strconv.Itoa(v)
+		kv.Value.IntValue = &s
+	case bool:
+		kv.Value.BoolValue = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
+
+// EncodeOTLPTraces renders spans as an OTLP ExportTraceServiceRequest in
+// JSON. Trace and span IDs are hex encoded, as the OTLP JSON mapping
+// requires.
+func EncodeOTLPTraces(spans []*SpanData) ([]byte, error) {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		if s.ParentSpanID.IsValid() {
+			span.ParentSpanID = s.ParentSpanID.String()
+		}
+		for _, a := range s.Attrs {
+			span.Attributes = append(span.Attributes, otlpAttr(a))
+		}
+		if s.Error != "" {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: s.Error}
+		}
+		out = append(out, span)
+	}
+
+	service := "mcplexer"
+	req := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []otlpKeyValue{{Key: "service.name", Value: otlpValue{StringValue: &service}}},
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "mcplexer"},
+				"spans": out,
+			}},
+		}},
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal spans: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,308 @@
+// Package tracing records spans along the tool call path and propagates W3C
+// trace context to downstream servers. Spans are always created so that the
+// trace ID can be stored with audit records and forwarded as traceparent;
+// they are only exported when an Exporter is installed with SetExporter.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsValid reports whether id is not all zeroes.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// String returns id as 32 lowercase hex characters.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether id is not all zeroes.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// String returns id as 16 lowercase hex characters.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		putUint64(id[:8], rand.Uint64())
+		putUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		putUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+func putUint64(b []byte, v uint64) {
+	for i := range 8 {
+		b[i] = byte(v >> (56 - 8*i))
+	}
+}
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// Traceparent formats sc as a W3C traceparent header value, or "" if sc is
+// not valid.
+func (sc SpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a W3C traceparent header value. Unknown future
+// versions are accepted as long as the version 00 fields parse.
+func ParseTraceparent(s string) (SpanContext, bool) {
+	s = strings.TrimSpace(s)
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], parts[1]) ||
+		!decodeHex(sc.SpanID[:], parts[2]) ||
+		!decodeHex(flags[:], parts[3]) ||
+		!sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// decodeHex decodes lowercase hex s into dst, which it must fill exactly.
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// Span kinds, as numbered by OTLP.
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// Attr is a span attribute. Value is a string, bool, int or int64.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute.
+func String(key, value string) Attr { return Attr{Key: key, Value: value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attr { return Attr{Key: key, Value: int64(value)} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attr { return Attr{Key: key, Value: value} }
+
+// Span is one timed operation. A nil *Span is valid and does nothing, so
+// callers never need to check what Start returned.
+type Span struct {
+	sc     SpanContext
+	parent SpanID
+	name   string
+	kind   int
+	start  time.Time
+
+	mu     sync.Mutex
+	attrs  []Attr
+	errMsg string
+	ended  bool
+}
+
+// SpanData is a finished span handed to the Exporter.
+type SpanData struct {
+	SpanContext
+	ParentSpanID SpanID
+	Name         string
+	Kind         int
+	Start, End   time.Time
+	Attrs        []Attr
+	Error        string // status message; empty means OK
+}
+
+// Exporter receives finished, sampled spans. ExportSpan must not block.
+type Exporter interface {
+	ExportSpan(s *SpanData)
+}
+
+var exporter atomic.Pointer[Exporter]
+
+// SetExporter installs e as the destination for finished spans; nil stops
+// exporting.
+func SetExporter(e Exporter) {
+	if e == nil {
+		exporter.Store(nil)
+		return
+	}
+	exporter.Store(&e)
+}
+
+type spanKey struct{}
+type parentKey struct{}
+
+// Start begins a span named name as a child of the span or parent in ctx,
+// or as the root of a new trace. The returned context carries the span.
+func Start(ctx context.Context, name string, kind int, attrs ...Attr) (context.Context, *Span) {
+	parent := SpanContextFrom(ctx)
+	s := &Span{
+		name:  name,
+		kind:  kind,
+		start: time.Now(),
+		attrs: attrs,
+	}
+	if parent.IsValid() {
+		s.sc = SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+		s.parent = parent.SpanID
+	} else {
+		s.sc = SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// StartChild is like Start but only continues an existing trace: when ctx
+// carries no span or parent it returns ctx unchanged and a nil span. Use it
+// for work that is also reached outside traced calls, such as tool listing.
+func StartChild(ctx context.Context, name string, kind int, attrs ...Attr) (context.Context, *Span) {
+	if !SpanContextFrom(ctx).IsValid() {
+		return ctx, nil
+	}
+	return Start(ctx, name, kind, attrs...)
+}
+
+// WithParent returns a context whose next span is a child of sc, typically
+// parsed from an incoming traceparent. Invalid span contexts are ignored.
+func WithParent(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, parentKey{}, sc)
+}
+
+// SpanFrom returns the current span in ctx, or nil.
+func SpanFrom(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SpanContextFrom returns the span context of the current span in ctx,
+// falling back to a parent set with WithParent. It is the zero value when
+// there is neither.
+func SpanContextFrom(ctx context.Context) SpanContext {
+	if s := SpanFrom(ctx); s != nil {
+		return s.sc
+	}
+	sc, _ := ctx.Value(parentKey{}).(SpanContext)
+	return sc
+}
+
+// Traceparent returns the traceparent header value for the current span in
+// ctx, or "".
+func Traceparent(ctx context.Context) string {
+	return SpanContextFrom(ctx).Traceparent()
+}
+
+// SpanContext returns the span's identifiers.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed with msg. Empty messages are ignored.
+func (s *Span) SetError(msg string) {
+	if s == nil || msg == "" {
+		return
+	}
+	s.mu.Lock()
+	s.errMsg = msg
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed if err is non-nil.
+func (s *Span) RecordError(err error) {
+	if err != nil {
+		s.SetError(err.Error())
+	}
+}
+
+// End finishes the span and hands it to the exporter, if one is installed
+// and the span is sampled. Only the first call has any effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := &SpanData{
+		SpanContext:  s.sc,
+		ParentSpanID: s.parent,
+		Name:         s.name,
+		Kind:         s.kind,
+		Start:        s.start,
+		End:          end,
+		Attrs:        s.attrs,
+		Error:        s.errMsg,
+	}
+	s.mu.Unlock()
+
+	if !s.sc.Sampled {
+		return
+	}
+	if e := exporter.Load(); e != nil {
+		(*e).ExportSpan(data)
+	}
+}
@@ -0,0 +1,188 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTraceparentRoundTrip(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := ParseTraceparent(tp)
+	if !ok {
+		t.Fatal("valid traceparent rejected")
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Fatalf("parsed %+v", sc)
+	}
+	if got := sc.Traceparent(); got != tp {
+		t.Fatalf("Traceparent() = %q", got)
+	}
+
+	// Future versions may append fields.
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); !ok {
+		t.Fatal("future version rejected")
+	}
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*SpanData
+}
+
+func (e *recordingExporter) ExportSpan(s *SpanData) {
+	e.mu.Lock()
+	e.spans = append(e.spans, s)
+	e.mu.Unlock()
+}
+
+func TestSpanHierarchy(t *testing.T) {
+	exp := &recordingExporter{}
+	SetExporter(exp)
+	t.Cleanup(func() { SetExporter(nil) })
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := WithParent(context.Background(), parent)
+
+	ctx, root := Start(ctx, "tools/call", KindServer, String("mcp.tool", "github__list"))
+	_, child := Start(ctx, "route", KindInternal)
+	child.SetError("no route")
+	child.End()
+	child.End() // second End is ignored
+	root.End()
+
+	if len(exp.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(exp.spans))
+	}
+	c, r := exp.spans[0], exp.spans[1]
+	if r.TraceID != parent.TraceID || r.ParentSpanID != parent.SpanID {
+		t.Fatalf("root did not continue the remote trace: %+v", r.SpanContext)
+	}
+	if c.TraceID != parent.TraceID || c.ParentSpanID != r.SpanID {
+		t.Fatalf("child parent = %s, want %s", c.ParentSpanID, r.SpanID)
+	}
+	if c.Error != "no route" || r.Error != "" {
+		t.Fatalf("errors: child %q, root %q", c.Error, r.Error)
+	}
+	if got := Traceparent(ctx); got != r.SpanContext.Traceparent() {
+		t.Fatalf("Traceparent(ctx) = %q", got)
+	}
+}
+
+func TestUnsampledAndChildOnly(t *testing.T) {
+	exp := &recordingExporter{}
+	SetExporter(exp)
+	t.Cleanup(func() { SetExporter(nil) })
+
+	ctx, span := StartChild(context.Background(), "downstream.queue", KindInternal)
+	if span != nil || SpanFrom(ctx) != nil {
+		t.Fatal("StartChild began a new trace")
+	}
+	span.SetAttributes(Bool("ignored", true)) // nil spans are no-ops
+	span.End()
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span = Start(WithParent(context.Background(), parent), "tools/call", KindServer)
+	if span.SpanContext().Sampled {
+		t.Fatal("span of an unsampled trace is sampled")
+	}
+	span.End()
+
+	if len(exp.spans) != 0 {
+		t.Fatalf("exported %d spans, want 0", len(exp.spans))
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var mu sync.Mutex
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t" {
+			t.Errorf("missing header")
+		}
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, b)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	exp := NewOTLPExporter(srv.URL, map[string]string{"Authorization": "Bearer t"})
+	start := time.Unix(1700000000, 0)
+	exp.ExportSpan(&SpanData{
+		SpanContext: SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true},
+		Name:        "downstream.call",
+		Kind:        KindClient,
+		Start:       start,
+		End:         start.Add(time.Second),
+		Attrs:       []Attr{String("mcp.tool", "list"), Int("n", 3), Bool("cold", true)},
+		Error:       "boom",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("got %d posts, want 1", len(bodies))
+	}
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					Name              string `json:"name"`
+					Kind              int    `json:"kind"`
+					TraceID           string `json:"traceId"`
+					StartTimeUnixNano string `json:"startTimeUnixNano"`
+					Attributes        []struct {
+						Key   string         `json:"key"`
+						Value map[string]any `json:"value"`
+					} `json:"attributes"`
+					Status struct {
+						Code    int    `json:"code"`
+						Message string `json:"message"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(bodies[0], &req); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	s := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if s.Name != "downstream.call" || s.Kind != KindClient || len(s.TraceID) != 32 {
+		t.Fatalf("span = %+v", s)
+	}
+	if s.StartTimeUnixNano != "1700000000000000000" {
+		t.Fatalf("start = %s", s.StartTimeUnixNano)
+	}
+	if s.Status.Code != otlpStatusError || s.Status.Message != "boom" {
+		t.Fatalf("status = %+v", s.Status)
+	}
+	if len(s.Attributes) != 3 || s.Attributes[1].Value["intValue"] != "3" || s.Attributes[2].Value["boolValue"] != true {
+		t.Fatalf("attributes = %+v", s.Attributes)
+	}
+}
@@ -1,4 +1,7 @@
 import type {
+  ApprovalFilter,
+  ApprovalStats,
+  AuditChainReport,
   AuditFilter,
   AuditRecord,
   AuthScope,
@@ -254,16 +257,31 @@ export function queryAuditLogs(
   filter: AuditFilter,
 ): Promise<PaginatedResponse<AuditRecord>> {
   const params = new URLSearchParams()
+  if (filter.q) params.set('q', filter.q)
   if (filter.workspace_id) params.set('workspace_id', filter.workspace_id)
+  if (filter.session_id) params.set('session_id', filter.session_id)
   if (filter.tool_name) params.set('tool_name', filter.tool_name)
   if (filter.status) params.set('status', filter.status)
+  if (filter.downstream_server_id) params.set('downstream_server_id', filter.downstream_server_id)
+  if (filter.route_rule_id) params.set('route_rule_id', filter.route_rule_id)
+  if (filter.auth_scope_id) params.set('auth_scope_id', filter.auth_scope_id)
+  if (filter.error_code) params.set('error_code', filter.error_code)
+  if (filter.client_type) params.set('client_type', filter.client_type)
+  if (filter.trace_id) params.set('trace_id', filter.trace_id)
+  if (filter.min_latency_ms) params.set('min_latency_ms', String(filter.min_latency_ms))
   if (filter.after) params.set('after', filter.after)
   if (filter.before) params.set('before', filter.before)
+  if (filter.sort) params.set('sort', filter.sort)
+  if (filter.cursor) params.set('cursor', filter.cursor)
   if (filter.limit) params.set('limit', String(filter.limit))
   if (filter.offset) params.set('offset', String(filter.offset))
   return request(`/audit?${params.toString()}`)
 }
 
+export function verifyAuditChain(): Promise<AuditChainReport> {
+  return request('/audit/verify')
+}
+
 // Dashboard
 export function getDashboard(): Promise<DashboardData> {
   return request('/dashboard')
@@ -316,6 +334,34 @@ export function listApprovals(status?: string): Promise<ToolApproval[]> {
   return request(`/approvals${qs ? `?${qs}` : ''}`)
 }
 
+function approvalFilterParams(filter: ApprovalFilter): URLSearchParams {
+  const params = new URLSearchParams()
+  if (filter.status) params.set('status', filter.status)
+  if (filter.tool_name) params.set('tool_name', filter.tool_name)
+  if (filter.workspace_id) params.set('workspace_id', filter.workspace_id)
+  if (filter.approver_type) params.set('approver_type', filter.approver_type)
+  if (filter.request_session_id)
+    params.set('request_session_id', filter.request_session_id)
+  if (filter.q) params.set('q', filter.q)
+  if (filter.after) params.set('after', filter.after)
+  if (filter.before) params.set('before', filter.before)
+  if (filter.limit) params.set('limit', String(filter.limit))
+  if (filter.offset) params.set('offset', String(filter.offset))
+  return params
+}
+
+export function queryApprovalHistory(
+  filter: ApprovalFilter,
+): Promise<PaginatedResponse<ToolApproval>> {
+  return request(`/approvals/history?${approvalFilterParams(filter).toString()}`)
+}
+
+export function getApprovalStats(
+  filter: ApprovalFilter,
+): Promise<ApprovalStats> {
+  return request(`/approvals/stats?${approvalFilterParams(filter).toString()}`)
+}
+
 export function getApproval(id: string): Promise<ToolApproval> {
   return request(`/approvals/${id}`)
 }
@@ -4,6 +4,9 @@ export interface Workspace {
   root_path: string
   tags: Record<string, string>
   default_policy: 'allow' | 'deny'
+  annotation_policy?: AnnotationPolicy
+  audit_retention_days?: number
+  audit_max_rows?: number
   created_at: string
   updated_at: string
 }
@@ -109,10 +112,23 @@ export interface DownstreamOAuthStatusResponse {
   entries: DownstreamOAuthStatusEntry[]
 }
 
+export interface SandboxProfile {
+  cpu_seconds?: number
+  memory_mb?: number
+  max_open_files?: number
+  namespaces?: boolean
+  deny_network?: boolean
+  landlock?: boolean
+  read_paths?: string[]
+  write_paths?: string[]
+  seccomp?: boolean
+  seccomp_deny?: string[]
+}
+
 export interface DownstreamServer {
   id: string
   name: string
-  transport: 'stdio' | 'http'
+  transport: 'stdio' | 'http' | 'sse' | 'websocket'
   command: string
   args: string[]
   url: string | null
@@ -121,6 +137,17 @@ export interface DownstreamServer {
   idle_timeout_sec: number
   max_instances: number
   restart_policy: string
+  annotation_trust?: 'trusted' | 'untrusted'
+  env?: Record<string, string>
+  env_passthrough?: string[] | null
+  working_dir?: string
+  instance_scope?: 'global' | 'workspace' | 'session'
+  sandbox?: SandboxProfile | null
+  health_interval_sec?: number
+  health_timeout_sec?: number
+  init_timeout_sec?: number
+  call_timeout_sec?: number
+  tool_timeouts?: Record<string, number>
   disabled: boolean
   created_at: string
   updated_at: string
@@ -139,10 +166,18 @@ export interface RouteRule {
   log_level: string
   requires_approval: boolean
   approval_timeout: number
+  annotation_policy?: AnnotationPolicy
   created_at: string
   updated_at: string
 }
 
+// Approval policy derived from MCP tool annotations. Unset fields inherit
+// (a route inherits from its workspace).
+export interface AnnotationPolicy {
+  require_approval_destructive?: boolean
+  auto_allow_read_only?: boolean
+}
+
 export interface AuditRecord {
   id: string
   timestamp: string
@@ -157,21 +192,85 @@ export interface AuditRecord {
   downstream_server_id: string
   downstream_instance_id: string
   auth_scope_id: string
-  status: 'success' | 'error'
+  status: 'success' | 'error' | 'timeout'
   error_code: string
   error_message: string
   latency_ms: number
   response_size: number
+  approval_id?: string
+  log_level?: 'none' | 'metadata' | 'info' | 'full' | 'debug'
+  response?: unknown
+  exchange?: { request: unknown; response?: unknown }
+  redactions?: AuditRedaction[]
+  trace_id?: string
+  seq?: number
+  prev_hash?: string
+  hash?: string
   route_rule_summary?: string
   downstream_server_name?: string
 }
 
+export interface AuditRedaction {
+  path: string
+  detector: string
+}
+
+export interface AuditCheckpoint {
+  id: string
+  seq: number
+  hash: string
+  public_key: string
+  signature: string
+  created_at: string
+}
+
+export interface AuditChainProblem {
+  kind:
+    | 'edited'
+    | 'broken_link'
+    | 'gap'
+    | 'truncated'
+    | 'head_mismatch'
+    | 'checkpoint_mismatch'
+    | 'bad_signature'
+  seq: number
+  record_id?: string
+  detail: string
+}
+
+export interface AuditChainReport {
+  ok: boolean
+  verified_at: string
+  head_seq: number
+  records: number
+  pruned: number
+  checkpoints: number
+  verified_checkpoints: number
+  latest_checkpoint?: AuditCheckpoint
+  signature_key_missing?: boolean
+  problems: AuditChainProblem[]
+  problems_truncated?: boolean
+}
+
+export type AuditSort = 'timestamp_desc' | 'timestamp_asc' | 'latency_desc' | 'latency_asc'
+
 export interface AuditFilter {
+  q?: string
   workspace_id?: string
+  session_id?: string
   tool_name?: string
-  status?: 'success' | 'error'
+  status?: 'success' | 'error' | 'timeout'
+  downstream_server_id?: string
+  route_rule_id?: string
+  auth_scope_id?: string
+  error_code?: string
+  client_type?: string
+  trace_id?: string
+  min_latency_ms?: number
   after?: string
   before?: string
+  sort?: AuditSort
+  cursor?: string
   limit?: number
   offset?: number
 }
@@ -199,6 +298,7 @@ export interface DashboardData {
   recent_calls: AuditRecord[]
   stats: AuditStats | null
   timeseries: TimeSeriesPoint[]
+  db_size_bytes: number
 }
 
 export interface DownstreamStatus {
@@ -206,6 +306,24 @@ export interface DownstreamStatus {
   server_name: string
   instance_count: number
   state: string
+  health?: ServerHealth
+}
+
+export interface HealthCheck {
+  time: string
+  instance?: string
+  ok: boolean
+  latency_ms: number
+  error?: string
+}
+
+export interface ServerHealth {
+  status: 'healthy' | 'unhealthy' | 'unknown'
+  last_check?: string
+  last_error?: string
+  last_error_at?: string
+  recycled: number
+  history: HealthCheck[]
 }
 
 export interface DryRunRequest {
@@ -235,6 +353,7 @@ export interface DryRunResult {
 export interface PaginatedResponse<T> {
   data: T[]
   total: number
+  next_cursor?: string
 }
 
 export interface ToolApproval {
@@ -256,6 +375,40 @@ export interface ToolApproval {
   timeout_sec: number
   created_at: string
   resolved_at: string | null
+  executed_at?: string | null
+  audit_record_id?: string
+}
+
+export interface ApprovalFilter {
+  status?: ToolApproval['status']
+  tool_name?: string
+  workspace_id?: string
+  approver_type?: string
+  request_session_id?: string
+  q?: string
+  after?: string
+  before?: string
+  limit?: number
+  offset?: number
+}
+
+export interface ApprovalCounts {
+  total: number
+  pending: number
+  approved: number
+  denied: number
+  timeout: number
+  cancelled: number
+  approval_rate: number
+  median_decision_ms: number
+}
+
+export interface ToolApprovalStats extends ApprovalCounts {
+  tool_name: string
+}
+
+export interface ApprovalStats extends ApprovalCounts {
+  tools: ToolApprovalStats[]
 }
 
 export interface ApprovalEvent {
@@ -9,6 +9,7 @@ import type { AuditRecord } from '@/api/types'
 
 export function getErrorReason(record: AuditRecord): string {
   if (record.status === 'success') return ''
+  if (record.status === 'timeout') return 'timeout'
   if (record.error_message?.includes('denied')) return 'blocked'
   if (record.error_message === 'no matching route') return 'no route'
   return record.error_message || record.error_code || 'error'
@@ -60,7 +61,7 @@ export function AuditDetailDialog({
           <DetailRow label="Workspace" value={record.workspace_id ? wsName(record.workspace_id) : '-'} />
           <DetailRow label="Subpath" value={record.subpath || '-'} mono />
           <DetailRow label="Status" value={record.status} />
-          {record.status === 'error' && (
+          {record.status !== 'success' && (
             <>
               <DetailRow label="Reason" value={reason} />
               <DetailRow label="Error Code" value={record.error_code} mono />
@@ -86,22 +87,58 @@ export function AuditDetailDialog({
             value={record.auth_scope_id ? asName(record.auth_scope_id) : '-'}
             mono
           />
+          <DetailRow label="Log Level" value={record.log_level || 'info'} mono />
+          {record.trace_id && <DetailRow label="Trace ID" value={record.trace_id} mono />}
           {Object.keys(record.params_redacted ?? {}).length > 0 && (
+            <JsonSection label="Redacted Params" value={record.params_redacted} />
+          )}
+          {record.redactions && record.redactions.length > 0 && (
             <div className="pt-2">
               <span className="text-xs font-medium uppercase tracking-wider text-muted-foreground">
-                Redacted Params
+                Redactions
               </span>
-              <pre className="mt-2 max-h-64 overflow-auto rounded-md border border-border bg-background p-3 font-mono text-xs leading-relaxed text-accent-foreground">
-                {JSON.stringify(record.params_redacted, null, 2)}
-              </pre>
+              <div className="mt-2 space-y-1">
+                {record.redactions.map((r) => (
+                  <div
+                    key={`${r.path}:${r.detector}`}
+                    className="flex items-baseline justify-between gap-4 font-mono text-xs"
+                  >
+                    <span className="min-w-0 truncate text-accent-foreground" title={r.path}>
+                      {r.path}
+                    </span>
+                    <Badge variant="outline" className="shrink-0 text-muted-foreground">
+                      {r.detector}
+                    </Badge>
+                  </div>
+                ))}
+              </div>
             </div>
           )}
+          {record.response !== undefined && (
+            <JsonSection label="Response" value={record.response} />
+          )}
+          {record.exchange !== undefined && (
+            <JsonSection label="JSON-RPC Exchange" value={record.exchange} />
+          )}
         </div>
       </DialogContent>
     </Dialog>
   )
 }
 
+function JsonSection({ label, value }: { label: string; value: unknown }) {
+  return (
+    <div className="pt-2">
+      <span className="text-xs font-medium uppercase tracking-wider text-muted-foreground">
+        {label}
+      </span>
+      <pre className="mt-2 max-h-64 overflow-auto rounded-md border border-border bg-background p-3 font-mono text-xs leading-relaxed text-accent-foreground">
+        {JSON.stringify(value, null, 2)}
+      </pre>
+    </div>
+  )
+}
+
 function DetailRow({
   label,
   value,
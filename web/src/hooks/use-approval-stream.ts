@@ -1,4 +1,5 @@
 import { useEffect, useRef, useState } from 'react'
+import { listApprovals } from '@/api/client'
 import type { ApprovalEvent, ToolApproval } from '@/api/types'
 
 export function useApprovalStream() {
@@ -10,12 +11,15 @@ export function useApprovalStream() {
   useEffect(() => {
     let cancelled = false
     let retryTimeout: ReturnType<typeof setTimeout>
+    // Resume from the last event seen so a reconnect replays what was missed.
+    let lastEventId = ''
 
     function connect() {
       if (cancelled) return
 
       const apiBase = import.meta.env.VITE_API_BASE_URL?.replace(/\/api\/v1$/, '') || ''
-      const es = new EventSource(`${apiBase}/api/v1/approvals/stream`)
+      const qs = lastEventId ? `?last_event_id=${encodeURIComponent(lastEventId)}` : ''
+      const es = new EventSource(`${apiBase}/api/v1/approvals/stream${qs}`)
       esRef.current = es
 
       es.onopen = () => {
@@ -26,6 +30,7 @@ export function useApprovalStream() {
 
       es.onmessage = (event) => {
         if (cancelled) return
+        if (event.lastEventId) lastEventId = event.lastEventId
         try {
           const evt = JSON.parse(event.data) as ApprovalEvent
           if (evt.type === 'pending') {
@@ -38,6 +43,17 @@ export function useApprovalStream() {
         }
       }
 
+      // Events were lost; reload the pending list instead of trusting it.
+      es.addEventListener('dropped', (event) => {
+        if (cancelled) return
+        if (event.lastEventId) lastEventId = event.lastEventId
+        listApprovals('pending')
+          .then((approvals) => {
+            if (!cancelled) setPending(approvals)
+          })
+          .catch(() => {})
+      })
+
       es.onerror = () => {
         if (cancelled) return
         es.close()
@@ -20,6 +20,8 @@ export function useAuditStream(filter: AuditStreamFilter) {
   useEffect(() => {
     let cancelled = false
     let retryTimeout: ReturnType<typeof setTimeout>
+    // Resume from the last event seen so a reconnect replays what was missed.
+    let lastEventId = ''
 
     function connect() {
       if (cancelled) return
@@ -28,6 +30,7 @@ export function useAuditStream(filter: AuditStreamFilter) {
       if (filter.workspace_id) params.set('workspace_id', filter.workspace_id)
       if (filter.tool_name) params.set('tool_name', filter.tool_name)
       if (filter.status) params.set('status', filter.status)
+      if (lastEventId) params.set('last_event_id', lastEventId)
 
       const qs = params.toString()
       const apiBase = import.meta.env.VITE_API_BASE_URL?.replace(/\/api\/v1$/, '') || ''
@@ -44,6 +47,7 @@ export function useAuditStream(filter: AuditStreamFilter) {
 
       es.onmessage = (event) => {
         if (cancelled) return
+        if (event.lastEventId) lastEventId = event.lastEventId
         try {
           const record = JSON.parse(event.data) as AuditRecord
           setRecords((prev) => [record, ...prev].slice(0, MAX_RECORDS))
@@ -52,6 +56,10 @@ export function useAuditStream(filter: AuditStreamFilter) {
         }
       }
 
+      es.addEventListener('dropped', (event) => {
+        if (event.lastEventId) lastEventId = event.lastEventId
+      })
+
       es.onerror = () => {
         if (cancelled) return
         es.close()
@@ -21,7 +21,7 @@ import {
 import { useApi } from '@/hooks/use-api'
 import { useAuditStream } from '@/hooks/use-audit-stream'
 import { listAuthScopes, listWorkspaces, queryAuditLogs } from '@/api/client'
-import type { AuditFilter, AuditRecord } from '@/api/types'
+import type { AuditFilter, AuditRecord, AuditSort } from '@/api/types'
 import { ChevronLeft, ChevronRight, Radio } from 'lucide-react'
 import { Tooltip, TooltipContent, TooltipTrigger } from '@/components/ui/tooltip'
 import { AuditDetailDialog, ReasonBadge } from '@/components/AuditDetailDialog'
@@ -66,7 +66,9 @@ export function AuditPage() {
   // On page 1, show live events (deduped) then history. Other pages: just history.
   const historyRecords = historyData?.data ?? []
   const historyIds = new Set(historyRecords.map((r) => r.id))
-  const uniqueLive = isFirstPage ? liveRecords.filter((r) => !historyIds.has(r.id)) : []
+  // The live stream can't apply search, latency or sort, so hide it then.
+  const showLive = isFirstPage && !filter.q && !filter.min_latency_ms && !filter.sort
+  const uniqueLive = showLive ? liveRecords.filter((r) => !historyIds.has(r.id)) : []
   const allRecords = [...uniqueLive, ...historyRecords]
 
   return (
@@ -250,6 +252,19 @@ function FilterBar({
     <Card>
       <CardContent className="pt-6">
         <div className="flex flex-wrap items-center gap-3">
+          <Input
+            placeholder="Search params, errors, tools..."
+            className="w-full sm:w-64"
+            value={filter.q ?? ''}
+            onChange={(e) =>
+              setFilter((f) => ({
+                ...f,
+                q: e.target.value || undefined,
+                offset: 0,
+              }))
+            }
+          />
+
           <Select
             value={filter.workspace_id ?? 'all'}
             onValueChange={(v) =>
@@ -291,7 +306,7 @@ function FilterBar({
             onValueChange={(v) =>
               setFilter((f) => ({
                 ...f,
-                status: v === 'all' ? undefined : (v as 'success' | 'error'),
+                status: v === 'all' ? undefined : (v as 'success' | 'error' | 'timeout'),
                 offset: 0,
               }))
             }
@@ -303,6 +318,43 @@ function FilterBar({
               <SelectItem value="all">All statuses</SelectItem>
               <SelectItem value="success">Success</SelectItem>
               <SelectItem value="error">Error</SelectItem>
+              <SelectItem value="timeout">Timeout</SelectItem>
+            </SelectContent>
+          </Select>
+
+          <Input
+            type="number"
+            min={0}
+            placeholder="Min latency (ms)"
+            className="w-full sm:w-40"
+            value={filter.min_latency_ms ?? ''}
+            onChange={(e) =>
+              setFilter((f) => ({
+                ...f,
+                min_latency_ms: Number(e.target.value) || undefined,
+                offset: 0,
+              }))
+            }
+          />
+
+          <Select
+            value={filter.sort ?? 'timestamp_desc'}
+            onValueChange={(v) =>
+              setFilter((f) => ({
+                ...f,
+                sort: v === 'timestamp_desc' ? undefined : (v as AuditSort),
+                offset: 0,
+              }))
+            }
+          >
+            <SelectTrigger className="w-full sm:w-40">
+              <SelectValue placeholder="Newest first" />
+            </SelectTrigger>
+            <SelectContent>
+              <SelectItem value="timestamp_desc">Newest first</SelectItem>
+              <SelectItem value="timestamp_asc">Oldest first</SelectItem>
+              <SelectItem value="latency_desc">Slowest first</SelectItem>
+              <SelectItem value="latency_asc">Fastest first</SelectItem>
             </SelectContent>
           </Select>
 
@@ -30,6 +30,18 @@ function formatTime(ts: string): string {
   return new Date(ts).toLocaleTimeString()
 }
 
+function formatBytes(n: number): string {
+  if (n < 1024) return `${n} B`
+  const units = ['KB', 'MB', 'GB', 'TB']
+  let v = n / 1024
+  let i = 0
+  while (v >= 1024 && i < units.length - 1) {
+    v /= 1024
+    i++
+  }
+  return `${v.toFixed(1)} ${units[i]}`
+}
+
 function formatHHMM(ts: string): string {
   const d = new Date(ts)
   return `${String(d.getHours()).padStart(2, '0')}:${String(d.getMinutes()).padStart(2, '0')}`
@@ -204,7 +216,14 @@ export function DashboardPage() {
 
   return (
     <div className="space-y-6">
-      <h1 className="text-2xl font-bold">Dashboard</h1>
+      <div className="flex items-baseline justify-between">
+        <h1 className="text-2xl font-bold">Dashboard</h1>
+        {data.db_size_bytes > 0 && (
+          <span className="text-xs text-muted-foreground">
+            Database {formatBytes(data.db_size_bytes)}
+          </span>
+        )}
+      </div>
 
       {pendingApprovals.length > 0 && (
         <Link
@@ -78,7 +78,7 @@ export function QuickSetupPage() {
   const wsFetcher = useCallback(() => listWorkspaces(), [])
   const { data: workspaces } = useApi(wsFetcher)
 
-  const httpDownstreams = (downstreams ?? []).filter((d) => d.transport === 'http')
+  const httpDownstreams = (downstreams ?? []).filter((d) => d.transport !== 'stdio')
 
   // Handle OAuth redirect back
   useEffect(() => {
@@ -98,7 +98,7 @@ export function QuickSetupPage() {
     if (!downstreams) return
     let active = true
     for (const ds of downstreams) {
-      if (ds.transport !== 'http') continue
+      if (ds.transport === 'stdio') continue
       getDownstreamOAuthStatus(ds.id)
         .then((res) => { if (active) setOauthStatuses((prev) => ({ ...prev, [ds.id]: res.entries })) })
         .catch(() => { if (active) setStatusErrors((prev) => ({ ...prev, [ds.id]: true })) })
@@ -3,6 +3,7 @@ import { Card, CardContent } from '@/components/ui/card'
 import { Button } from '@/components/ui/button'
 import { Input } from '@/components/ui/input'
 import { Label } from '@/components/ui/label'
+import { Textarea } from '@/components/ui/textarea'
 import {
   Select,
   SelectContent,
@@ -43,7 +44,7 @@ import { ConfirmDialog } from '@/components/ui/confirm-dialog'
 
 interface FormData {
   name: string
-  transport: 'stdio' | 'http'
+  transport: 'stdio' | 'http' | 'sse' | 'websocket'
   command: string
   args: string[]
   url: string | null
@@ -51,6 +52,9 @@ interface FormData {
   idle_timeout_sec: number
   max_instances: number
   restart_policy: string
+  working_dir: string
+  env_text: string // KEY=value per line
+  instance_scope: 'global' | 'workspace' | 'session'
   disabled: boolean
 }
 
@@ -64,9 +68,27 @@ const emptyForm: FormData = {
   idle_timeout_sec: 300,
   max_instances: 1,
   restart_policy: 'on-failure',
+  working_dir: '',
+  env_text: '',
+  instance_scope: 'global',
   disabled: false,
 }
 
+function envToText(env?: Record<string, string>): string {
+  return Object.entries(env ?? {})
+    .map(([k, v]) => `${k}=${v}`)
+    .join('\n')
+}
+
+function textToEnv(text: string): Record<string, string> {
+  const env: Record<string, string> = {}
+  for (const line of text.split('\n')) {
+    const i = line.indexOf('=')
+    if (i > 0) env[line.slice(0, i).trim()] = line.slice(i + 1)
+  }
+  return env
+}
+
 export function DownstreamsPage() {
   const fetcher = useCallback(() => listDownstreams(), [])
   const { data, loading, error, refetch } = useApi(fetcher)
@@ -87,7 +109,7 @@ export function DownstreamsPage() {
     if (!data) return
     let active = true
     for (const ds of data) {
-      if (ds.transport === 'http') {
+      if (ds.transport !== 'stdio') {
         getDownstreamOAuthStatus(ds.id)
           .then((res) => {
             if (!active) return
@@ -113,7 +135,7 @@ export function DownstreamsPage() {
   }
 
   function getOAuthBadges(ds: DownstreamServer) {
-    if (ds.transport !== 'http') return null
+    if (ds.transport === 'stdio') return null
     if (statusErrors[ds.id]) {
       return (
         <Badge variant="outline" className="text-xs text-destructive border-destructive/30">
@@ -183,6 +205,9 @@ export function DownstreamsPage() {
       idle_timeout_sec: ds.idle_timeout_sec,
       max_instances: ds.max_instances,
       restart_policy: ds.restart_policy,
+      working_dir: ds.working_dir ?? '',
+      env_text: envToText(ds.env),
+      instance_scope: ds.instance_scope ?? 'global',
       disabled: false,
     })
     setSaveError(null)
@@ -201,6 +226,9 @@ export function DownstreamsPage() {
       idle_timeout_sec: ds.idle_timeout_sec,
       max_instances: ds.max_instances,
       restart_policy: ds.restart_policy,
+      working_dir: ds.working_dir ?? '',
+      env_text: envToText(ds.env),
+      instance_scope: ds.instance_scope ?? 'global',
       disabled: ds.disabled,
     })
     setSaveError(null)
@@ -211,10 +239,12 @@ export function DownstreamsPage() {
     setSaving(true)
     setSaveError(null)
     try {
+      const { env_text, ...rest } = form
+      const payload = { ...rest, env: textToEnv(env_text) }
       if (editing) {
-        await updateDownstream(editing.id, form)
+        await updateDownstream(editing.id, payload)
       } else {
-        await createDownstream(form)
+        await createDownstream(payload)
       }
       setDialogOpen(false)
       toast.success(editing ? 'Server updated' : 'Server created')
@@ -387,7 +417,7 @@ export function DownstreamsPage() {
                             </TooltipTrigger>
                             <TooltipContent>Delete</TooltipContent>
                           </Tooltip>
-                          {ds.transport === 'http' && (
+                          {ds.transport !== 'stdio' && (
                             <Tooltip>
                               <TooltipTrigger asChild>
                                 <Button
@@ -482,7 +512,7 @@ function DownstreamDialog({
             <Select
               value={form.transport}
               onValueChange={(v) =>
-                setForm((f) => ({ ...f, transport: v as 'stdio' | 'http' }))
+                setForm((f) => ({ ...f, transport: v as DownstreamServer['transport'] }))
               }
             >
               <SelectTrigger>
@@ -491,6 +521,8 @@ function DownstreamDialog({
               <SelectContent>
                 <SelectItem value="stdio">stdio</SelectItem>
                 <SelectItem value="http">http</SelectItem>
+                <SelectItem value="sse">sse (legacy)</SelectItem>
+                <SelectItem value="websocket">websocket</SelectItem>
               </SelectContent>
             </Select>
           </div>
@@ -529,6 +561,26 @@ function DownstreamDialog({
                   <span className="text-primary">$</span> {form.command} {(form.args ?? []).join(' ')}
                 </div>
               )}
+              <div className="space-y-2">
+                <Label className="text-xs text-muted-foreground">Working Directory</Label>
+                <Input
+                  className="font-mono text-sm"
+                  value={form.working_dir}
+                  onChange={(e) => setForm((f) => ({ ...f, working_dir: e.target.value }))}
+                  placeholder="/path/to/project"
+                />
+              </div>
+              <div className="space-y-2">
+                <Label className="text-xs text-muted-foreground">
+                  Environment (KEY=value per line)
+                </Label>
+                <Textarea
+                  className="font-mono text-sm"
+                  value={form.env_text}
+                  onChange={(e) => setForm((f) => ({ ...f, env_text: e.target.value }))}
+                  placeholder="LOG_LEVEL=debug"
+                />
+              </div>
             </>
           ) : (
             <div className="space-y-2">
@@ -572,6 +624,27 @@ function DownstreamDialog({
               />
             </div>
           </div>
+          <div className="space-y-2">
+            <Label className="text-xs text-muted-foreground">Instance Sharing</Label>
+            <Select
+              value={form.instance_scope}
+              onValueChange={(v) =>
+                setForm((f) => ({
+                  ...f,
+                  instance_scope: v as 'global' | 'workspace' | 'session',
+                }))
+              }
+            >
+              <SelectTrigger>
+                <SelectValue />
+              </SelectTrigger>
+              <SelectContent>
+                <SelectItem value="global">Shared by all sessions</SelectItem>
+                <SelectItem value="workspace">One per workspace</SelectItem>
+                <SelectItem value="session">One per session</SelectItem>
+              </SelectContent>
+            </Select>
+          </div>
         </div>
         {saveError && (
           <p className="text-sm text-destructive">{saveError}</p>
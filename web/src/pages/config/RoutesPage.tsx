@@ -479,6 +479,25 @@ function RouteDialog({
             </div>
           </div>
 
+          <div className="space-y-2">
+            <Label className="text-xs text-muted-foreground">Audit log level</Label>
+            <Select
+              value={form.log_level || 'info'}
+              onValueChange={(v) => setForm((f) => ({ ...f, log_level: v }))}
+            >
+              <SelectTrigger>
+                <SelectValue />
+              </SelectTrigger>
+              <SelectContent>
+                <SelectItem value="none">None (counters only)</SelectItem>
+                <SelectItem value="metadata">Metadata (no params)</SelectItem>
+                <SelectItem value="info">Info (redacted params)</SelectItem>
+                <SelectItem value="full">Full (params and response)</SelectItem>
+                <SelectItem value="debug">Debug (raw JSON-RPC exchange)</SelectItem>
+              </SelectContent>
+            </Select>
+          </div>
+
           {form.policy === 'allow' && (
             <div className="space-y-3 rounded-md border border-border/50 p-3">
               <label className="flex items-center gap-2 cursor-pointer">